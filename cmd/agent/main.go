@@ -17,6 +17,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/harungecit/vigilon/internal/models"
+	"github.com/harungecit/vigilon/internal/netcheck"
 	"gopkg.in/yaml.v3"
 )
 
@@ -26,7 +28,9 @@ type AgentConfig struct {
 	Token                  string        `yaml:"token"`
 	CheckInterval          time.Duration `yaml:"check_interval"`
 	ServiceRefreshInterval time.Duration `yaml:"service_refresh_interval"`
-	Services               []string      `yaml:"services"` // Optional fallback if API fetch fails
+	CommandInterval        time.Duration `yaml:"command_interval"` // How often to poll for on-demand commands
+	DisableRestart         bool          `yaml:"disable_restart"`  // Refuse remote restart requests
+	Services               []string      `yaml:"services"`         // Optional fallback if API fetch fails
 }
 
 // ServiceListResponse represents the API response for service list
@@ -43,6 +47,13 @@ type Service struct {
 	DisplayName string `json:"display_name"`
 	Description string `json:"description"`
 	Enabled     bool   `json:"enabled"`
+
+	// Endpoints the server leaves to the agent, e.g. internal-only URLs
+	Type models.ServiceType `json:"type,omitempty"`
+	HTTP *models.HTTPCheck  `json:"http,omitempty"`
+	TLS  *models.TLSCheck   `json:"tls,omitempty"`
+	TCP  *models.TCPCheck   `json:"tcp,omitempty"`
+	Ping *models.PingCheck  `json:"ping,omitempty"`
 }
 
 // ServiceStatus represents a service status
@@ -62,6 +73,18 @@ type AgentReport struct {
 	Services []ServiceReport `json:"services"`
 }
 
+// AgentCommand is an on-demand action requested by the server
+type AgentCommand struct {
+	ID      int    `json:"id"`
+	Service string `json:"service"`
+	Action  string `json:"action"` // check or restart
+}
+
+// CommandListResponse represents the API response for pending commands
+type CommandListResponse struct {
+	Commands []AgentCommand `json:"commands"`
+}
+
 // ServiceReport represents a single service status report
 type ServiceReport struct {
 	Name         string        `json:"name"`
@@ -71,6 +94,10 @@ type ServiceReport struct {
 	Memory       int64         `json:"memory_kb,omitempty"`
 	CPU          float64       `json:"cpu_percent,omitempty"`
 	Uptime       int64         `json:"uptime_seconds,omitempty"`
+	ResponseTime int64         `json:"response_time_ms,omitempty"`    // Endpoint checks
+	DaysToExpiry *int          `json:"days_to_expiry,omitempty"`      // Certificate of a tls check
+	Latency      *float64      `json:"latency_ms,omitempty"`          // tcp and ping checks
+	PacketLoss   *float64      `json:"packet_loss_percent,omitempty"` // ping checks
 }
 
 var (
@@ -80,6 +107,9 @@ var (
 	// Cached service list from API
 	cachedServices []string
 
+	// Endpoint services among them, by name
+	cachedEndpoints = make(map[string]Service)
+
 	// Track previous service states to log only changes
 	previousServiceStates = make(map[string]ServiceStatus)
 
@@ -107,6 +137,7 @@ func main() {
 	log.Printf("Server URL: %s", config.ServerURL)
 	log.Printf("Check interval: %v", config.CheckInterval)
 	log.Printf("Service refresh interval: %v", config.ServiceRefreshInterval)
+	log.Printf("Command poll interval: %v", config.CommandInterval)
 
 	// Set GOMAXPROCS for better resource usage
 	if runtime.NumCPU() > 2 {
@@ -138,6 +169,10 @@ func main() {
 	refreshTicker := time.NewTicker(config.ServiceRefreshInterval)
 	defer refreshTicker.Stop()
 
+	// Poll for on-demand checks and restarts
+	commandTicker := time.NewTicker(config.CommandInterval)
+	defer commandTicker.Stop()
+
 	// More aggressive GC to prevent memory buildup
 	// Run GC every 2 minutes instead of 10
 	gcTicker := time.NewTicker(2 * time.Minute)
@@ -158,6 +193,10 @@ func main() {
 			if err := refreshServiceList(config); err != nil {
 				log.Printf("Failed to refresh service list: %v", err)
 			}
+		case <-commandTicker.C:
+			if err := pollCommands(config); err != nil {
+				log.Printf("Failed to poll commands: %v", err)
+			}
 		case <-gcTicker.C:
 			runtime.GC() // Force garbage collection
 		}
@@ -183,6 +222,9 @@ func loadConfig(path string) (*AgentConfig, error) {
 	if config.ServiceRefreshInterval == 0 {
 		config.ServiceRefreshInterval = 5 * time.Minute
 	}
+	if config.CommandInterval == 0 {
+		config.CommandInterval = 5 * time.Second
+	}
 
 	return &config, nil
 }
@@ -219,11 +261,17 @@ func refreshServiceList(config *AgentConfig) error {
 
 	// Extract service names from enabled services
 	newServices := make([]string, 0, len(serviceList.Services))
+	newEndpoints := make(map[string]Service)
 	for _, service := range serviceList.Services {
 		if service.Enabled {
 			newServices = append(newServices, service.Name)
+			if service.endpoint().IsEndpoint() {
+				newEndpoints[service.Name] = service
+			}
 		}
 	}
+	// Endpoint settings may change without the names changing
+	cachedEndpoints = newEndpoints
 
 	// Check if service list changed
 	if !servicesEqual(cachedServices, newServices) {
@@ -307,6 +355,10 @@ func checkAndReport(config *AgentConfig) error {
 
 // checkService checks a single service status
 func checkService(serviceName string) ServiceReport {
+	if endpoint, ok := cachedEndpoints[serviceName]; ok {
+		return checkEndpoint(endpoint)
+	}
+
 	report := ServiceReport{
 		Name: serviceName,
 	}
@@ -324,6 +376,30 @@ func checkService(serviceName string) ServiceReport {
 	return report
 }
 
+// checkEndpoint requests an endpoint from this host, for endpoints the
+// server cannot reach itself
+func checkEndpoint(service Service) ServiceReport {
+	result := netcheck.Check(context.Background(), service.endpoint())
+
+	report := ServiceReport{
+		Name:         service.Name,
+		Status:       ServiceStatus(result.Status),
+		ResponseTime: result.Elapsed.Milliseconds(),
+		DaysToExpiry: result.DaysToExpiry(),
+		Latency:      result.LatencyMs(),
+		PacketLoss:   result.PacketLoss(),
+	}
+	if result.Err != nil {
+		report.ErrorMessage = result.Err.Error()
+	}
+	return report
+}
+
+// endpoint returns the service as checked by netcheck
+func (s Service) endpoint() *models.Service {
+	return &models.Service{Name: s.Name, Type: s.Type, HTTP: s.HTTP, TLS: s.TLS, TCP: s.TCP, Ping: s.Ping}
+}
+
 // checkLinuxService checks a systemd service on Linux
 func checkLinuxService(serviceName string) ServiceReport {
 	report := ServiceReport{
@@ -502,3 +578,168 @@ func sendReport(serverURL string, report AgentReport) error {
 
 	return nil
 }
+
+// pollCommands fetches pending commands from the server and runs them
+func pollCommands(config *AgentConfig) error {
+	url := fmt.Sprintf("%s/api/agent/commands?token=%s", config.ServerURL, config.Token)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch commands: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Drain and close response body to reuse connection
+	defer io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned status %d", resp.StatusCode)
+	}
+
+	var commandList CommandListResponse
+	if err := json.NewDecoder(resp.Body).Decode(&commandList); err != nil {
+		return fmt.Errorf("failed to decode commands: %w", err)
+	}
+
+	for _, command := range commandList.Commands {
+		success, output := runCommand(config, command)
+		log.Printf("Command %d (%s %s): success=%v %s", command.ID, command.Action, command.Service, success, output)
+		if err := sendCommandResult(config, command.ID, success, output); err != nil {
+			log.Printf("Failed to send result of command %d: %v", command.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// runCommand runs a command for one of the monitored services and reports the
+// resulting service status
+func runCommand(config *AgentConfig, command AgentCommand) (bool, string) {
+	monitored := false
+	for _, svc := range cachedServices {
+		if svc == command.Service {
+			monitored = true
+			break
+		}
+	}
+	_, endpoint := cachedEndpoints[command.Service]
+	if !monitored || (!endpoint && !validServiceName(command.Service)) {
+		return false, fmt.Sprintf("service %s is not monitored by this agent", command.Service)
+	}
+
+	var restartOutput string
+	switch command.Action {
+	case "check":
+	case "restart":
+		if config.DisableRestart {
+			return false, "remote restarts are disabled on this agent"
+		}
+		if endpoint {
+			return false, fmt.Sprintf("%s is an endpoint check and cannot be restarted", command.Service)
+		}
+		output, err := restartService(command.Service)
+		if err != nil {
+			return false, strings.TrimSpace(fmt.Sprintf("restart failed: %v %s", err, output))
+		}
+		restartOutput = output
+	default:
+		return false, fmt.Sprintf("unknown action %s", command.Action)
+	}
+
+	// Report the new status right away so the server sees it
+	serviceReport := checkService(command.Service)
+	previousServiceStates[command.Service] = serviceReport.Status
+	report := AgentReport{Token: config.Token, Services: []ServiceReport{serviceReport}}
+	if err := sendReport(config.ServerURL, report); err != nil {
+		return false, fmt.Sprintf("failed to report status: %v", err)
+	}
+
+	output := "status: " + string(serviceReport.Status)
+	if restartOutput != "" {
+		output = restartOutput + "\n" + output
+	}
+	return true, output
+}
+
+// restartService restarts a service with systemctl or PowerShell
+func restartService(serviceName string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "linux":
+		cmd = exec.CommandContext(ctx, "systemctl", "restart", serviceName)
+	case "windows":
+		cmd = exec.CommandContext(ctx, "powershell", "-Command",
+			fmt.Sprintf("Restart-Service -Name '%s' -Force", serviceName))
+	default:
+		return "", fmt.Errorf("unsupported OS: %s", runtime.GOOS)
+	}
+
+	output, err := cmd.CombinedOutput()
+	return strings.TrimSpace(string(output)), err
+}
+
+// validServiceName reports whether a service name only contains characters
+// used in systemd unit and Windows service names
+func validServiceName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("@._:-", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// sendCommandResult reports the outcome of a command to the server
+func sendCommandResult(config *AgentConfig, id int, success bool, output string) error {
+	url := fmt.Sprintf("%s/api/agent/commands/%d/result", config.ServerURL, id)
+
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"token":   config.Token,
+		"success": success,
+		"output":  output,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal result: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send result: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Drain and close response body to reuse connection
+	defer io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
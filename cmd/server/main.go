@@ -12,21 +12,39 @@ import (
 	"time"
 
 	"github.com/harungecit/vigilon/internal/api"
+	"github.com/harungecit/vigilon/internal/chat"
 	"github.com/harungecit/vigilon/internal/config"
+	"github.com/harungecit/vigilon/internal/control"
 	"github.com/harungecit/vigilon/internal/database"
+	"github.com/harungecit/vigilon/internal/email"
+	"github.com/harungecit/vigilon/internal/escalation"
 	"github.com/harungecit/vigilon/internal/models"
 	"github.com/harungecit/vigilon/internal/monitor"
+	"github.com/harungecit/vigilon/internal/netcheck"
+	"github.com/harungecit/vigilon/internal/notify"
 	"github.com/harungecit/vigilon/internal/telegram"
+	"github.com/harungecit/vigilon/internal/vault"
+	"github.com/harungecit/vigilon/internal/webhook"
 )
 
 var (
-	configPath = flag.String("config", "configs/config.yaml", "Path to configuration file")
-	version    = "1.1.2"
+	configPath        = flag.String("config", "configs/config.yaml", "Path to configuration file")
+	generateMasterKey = flag.Bool("generate-master-key", false, "Print a new vault master key and exit")
+	version           = "1.1.2"
 )
 
 func main() {
 	flag.Parse()
 
+	if *generateMasterKey {
+		key, err := vault.GenerateKey()
+		if err != nil {
+			log.Fatalf("Failed to generate master key: %v", err)
+		}
+		fmt.Println(key)
+		return
+	}
+
 	log.Printf("Vigilon Server v%s starting...", version)
 
 	// Load configuration
@@ -43,34 +61,94 @@ func main() {
 	defer db.Close()
 	log.Println("Database initialized")
 
+	// Open the secret vault
+	secrets, err := openVault(&cfg.Vault, db)
+	if err != nil {
+		log.Fatalf("Failed to open secret vault: %v", err)
+	}
+	if cfg.Telegram.BotTokenSecret != "" {
+		token, err := secrets.Secret(cfg.Telegram.BotTokenSecret)
+		if err != nil {
+			log.Printf("Warning: Failed to load Telegram bot token: %v", err)
+		} else {
+			cfg.Telegram.BotToken = string(token)
+		}
+	}
+	if cfg.Email.PasswordSecret != "" {
+		password, err := secrets.Secret(cfg.Email.PasswordSecret)
+		if err != nil {
+			log.Printf("Warning: Failed to load SMTP password: %v", err)
+		} else {
+			cfg.Email.Password = string(password)
+		}
+	}
+
 	// Sync config file servers to database
 	if err := syncConfigToDatabase(cfg, db); err != nil {
 		log.Printf("Warning: Failed to sync config to database: %v", err)
 	}
 
 	// Initialize Telegram notifier
-	telegramNotifier, err := telegram.New(&cfg.Telegram, db)
+	telegramNotifier, err := telegram.New(&cfg.Telegram, db, cfg.Server.PublicURL)
 	if err != nil {
 		log.Printf("Warning: Failed to initialize Telegram: %v", err)
 	}
 
-	// Start Telegram bot in background
+	// Initialize email notifier
+	emailNotifier, err := email.New(&cfg.Email, db, cfg.Server.PublicURL)
+	if err != nil {
+		log.Printf("Warning: Failed to initialize email: %v", err)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	if emailNotifier != nil {
+		go emailNotifier.Start(ctx)
+	}
+
+	// Initialize notification dispatcher and register channels
+	dispatcher := notify.NewDispatcher(db, notify.RetryPolicy{
+		MaxAttempts: cfg.Notifications.MaxAttempts,
+		Backoff:     cfg.Notifications.RetryBackoff,
+		MaxBackoff:  cfg.Notifications.MaxBackoff,
+	})
 	if telegramNotifier != nil {
-		go telegramNotifier.Start(ctx)
+		dispatcher.Register(telegramNotifier)
+	}
+	if emailNotifier != nil {
+		dispatcher.Register(emailNotifier)
+	}
+	if err := webhook.Sync(db, secrets, dispatcher); err != nil {
+		log.Printf("Warning: Failed to load webhooks: %v", err)
 	}
+	chatManager := chat.NewManager(db, dispatcher, cfg.Server.PublicURL)
+	if err := chatManager.Sync(); err != nil {
+		log.Printf("Warning: Failed to load chat channels: %v", err)
+	}
+	go dispatcher.Start(ctx)
+
+	// Escalate alerts nobody acknowledges
+	go escalation.NewScheduler(db, dispatcher, 30*time.Second).Start(ctx)
 
 	// Initialize monitor
-	mon := monitor.New(db, cfg.Monitoring.CheckInterval, cfg.Monitoring.AlertCooldown)
+	mon := monitor.New(db, secrets, dispatcher, cfg.Monitoring.CheckInterval, monitor.ReminderPolicy{
+		Intervals:    cfg.Monitoring.ReminderIntervals,
+		MaxReminders: cfg.Monitoring.MaxReminders,
+	})
 
 	// Start monitoring in background
 	go mon.Start(ctx)
 	log.Printf("Monitor started (check interval: %v)", cfg.Monitoring.CheckInterval)
 
+	// Start Telegram bot in background, with remote re-check and restart
+	if telegramNotifier != nil {
+		telegramNotifier.SetController(control.New(db, mon))
+		go telegramNotifier.Start(ctx)
+	}
+
 	// Initialize API
-	apiHandler := api.New(db, telegramNotifier)
+	apiHandler := api.New(db, secrets, telegramNotifier, emailNotifier, chatManager, dispatcher)
 
 	// Create HTTP server
 	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
@@ -106,7 +184,8 @@ func main() {
 	}
 
 	mon.Stop()
-	cancel() // Stop Telegram bot
+	cancel()          // Stop Telegram bot
+	dispatcher.Wait() // Deliver queued notifications
 
 	log.Println("Server stopped")
 }
@@ -131,6 +210,42 @@ func loadOrCreateConfig(path string) (*config.AppConfig, error) {
 	return cfg, nil
 }
 
+// openVault opens the secret vault and re-encrypts secrets still encrypted
+// with a previous master key. It returns nil if no master key is configured.
+func openVault(cfg *config.VaultConfig, db *database.DB) (*vault.Vault, error) {
+	key, err := vault.LoadMasterKey(cfg.MasterKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		log.Println("Secret vault disabled (no master key configured)")
+		return nil, nil
+	}
+
+	var previous [][]byte
+	for _, path := range cfg.PreviousMasterKeyFiles {
+		key, err := vault.ReadKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		previous = append(previous, key)
+	}
+
+	v, err := vault.New(db, key, previous...)
+	if err != nil {
+		return nil, err
+	}
+	count, err := v.Rekey()
+	if count > 0 {
+		log.Printf("Re-encrypted %d secrets with master key %s", count, v.KeyID())
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to re-encrypt secrets: %v", err)
+	}
+	log.Printf("Secret vault opened (master key %s)", v.KeyID())
+	return v, nil
+}
+
 // syncConfigToDatabase syncs servers from config file to database
 func syncConfigToDatabase(cfg *config.AppConfig, db *database.DB) error {
 	for _, serverDef := range cfg.Servers {
@@ -153,17 +268,26 @@ func syncConfigToDatabase(cfg *config.AppConfig, db *database.DB) error {
 		if !exists {
 			// Create new server
 			server := &models.Server{
-				Name:           serverDef.Name,
-				Hostname:       serverDef.Hostname,
-				IPAddress:      serverDef.IPAddress,
-				Port:           serverDef.Port,
-				OS:             serverDef.OS,
-				MonitoringMode: serverDef.MonitoringMode,
-				SSHUser:        serverDef.SSHUser,
-				SSHKeyPath:     serverDef.SSHKeyPath,
-				AgentToken:     serverDef.AgentToken,
-				Enabled:        serverDef.Enabled,
-				NotifyTelegram: serverDef.NotifyTelegram,
+				Name:                serverDef.Name,
+				Hostname:            serverDef.Hostname,
+				IPAddress:           serverDef.IPAddress,
+				Port:                serverDef.Port,
+				OS:                  serverDef.OS,
+				MonitoringMode:      serverDef.MonitoringMode,
+				SSHUser:             serverDef.SSHUser,
+				SSHKeyPath:          serverDef.SSHKeyPath,
+				SSHKeySecret:        serverDef.SSHKeySecret,
+				SSHAuthMethod:       serverDef.SSHAuthMethod,
+				SSHPasswordSecret:   serverDef.SSHPasswordSecret,
+				SSHPassphraseSecret: serverDef.SSHPassphraseSecret,
+				SSHAgentSocket:      serverDef.SSHAgentSocket,
+				SSHJumpHosts:        serverDef.SSHJumpHosts,
+				Transport:           serverDef.Transport,
+				WinRM:               serverDef.WinRM,
+				AgentToken:          serverDef.AgentToken,
+				AgentTokenSecret:    serverDef.AgentTokenSecret,
+				Enabled:             serverDef.Enabled,
+				NotifyTelegram:      serverDef.NotifyTelegram,
 			}
 
 			if err := db.CreateServer(server); err != nil {
@@ -181,8 +305,20 @@ func syncConfigToDatabase(cfg *config.AppConfig, db *database.DB) error {
 					DisplayName: serviceDef.DisplayName,
 					Description: serviceDef.Description,
 					Enabled:     serviceDef.Enabled,
+					Type:        serviceDef.Type,
+					CheckFrom:   serviceDef.CheckFrom,
+					HTTP:        serviceDef.HTTP,
+					TLS:         serviceDef.TLS,
+					TCP:         serviceDef.TCP,
+					Ping:        serviceDef.Ping,
+
+					Reachability: serviceDef.Reachability,
 				}
 
+				if err := netcheck.Validate(service); err != nil {
+					log.Printf("Skipping service %s: %v", serviceDef.Name, err)
+					continue
+				}
 				if err := db.CreateService(service); err != nil {
 					log.Printf("Failed to create service %s: %v", serviceDef.Name, err)
 					continue
@@ -206,8 +342,20 @@ func syncConfigToDatabase(cfg *config.AppConfig, db *database.DB) error {
 						DisplayName: serviceDef.DisplayName,
 						Description: serviceDef.Description,
 						Enabled:     serviceDef.Enabled,
+						Type:        serviceDef.Type,
+						CheckFrom:   serviceDef.CheckFrom,
+						HTTP:        serviceDef.HTTP,
+						TLS:         serviceDef.TLS,
+						TCP:         serviceDef.TCP,
+						Ping:        serviceDef.Ping,
+
+						Reachability: serviceDef.Reachability,
 					}
 
+					if err := netcheck.Validate(service); err != nil {
+						log.Printf("Skipping service %s: %v", serviceDef.Name, err)
+						continue
+					}
 					if err := db.CreateService(service); err != nil {
 						log.Printf("Failed to create service %s: %v", serviceDef.Name, err)
 						continue
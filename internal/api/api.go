@@ -8,14 +8,21 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/harungecit/vigilon/internal/auth"
+	"github.com/harungecit/vigilon/internal/chat"
 	"github.com/harungecit/vigilon/internal/database"
+	"github.com/harungecit/vigilon/internal/email"
+	"github.com/harungecit/vigilon/internal/maintenance"
 	"github.com/harungecit/vigilon/internal/models"
+	"github.com/harungecit/vigilon/internal/netcheck"
+	"github.com/harungecit/vigilon/internal/notify"
 	"github.com/harungecit/vigilon/internal/sse"
 	"github.com/harungecit/vigilon/internal/telegram"
+	"github.com/harungecit/vigilon/internal/vault"
 )
 
 // API handles HTTP requests
@@ -24,16 +31,24 @@ type API struct {
 	router         *mux.Router
 	templates      *template.Template
 	telegram       *telegram.Notifier
+	email          *email.Notifier
+	chat           *chat.Manager
+	dispatcher     *notify.Dispatcher
+	vault          *vault.Vault
 	authMiddleware *auth.Middleware
 	sseManager     *sse.Manager
 }
 
 // New creates a new API instance
-func New(db *database.DB, telegramNotifier *telegram.Notifier) *API {
+func New(db *database.DB, secrets *vault.Vault, telegramNotifier *telegram.Notifier, emailNotifier *email.Notifier, chatManager *chat.Manager, dispatcher *notify.Dispatcher) *API {
 	api := &API{
 		db:             db,
 		router:         mux.NewRouter(),
 		telegram:       telegramNotifier,
+		email:          emailNotifier,
+		chat:           chatManager,
+		dispatcher:     dispatcher,
+		vault:          secrets,
 		authMiddleware: auth.NewMiddleware(db),
 		sseManager:     sse.NewManager(),
 	}
@@ -76,6 +91,8 @@ func (a *API) setupRoutes() {
 	a.router.HandleFunc("/api/agent/report", a.handleAgentReport).Methods("POST")
 	a.router.HandleFunc("/api/agent/install-script", a.handleAgentInstallScript).Methods("POST")
 	a.router.HandleFunc("/api/agent/services", a.handleAgentServices).Methods("GET")
+	a.router.HandleFunc("/api/agent/commands", a.handleGetAgentCommands).Methods("GET")
+	a.router.HandleFunc("/api/agent/commands/{id}/result", a.handleAgentCommandResult).Methods("POST")
 
 	// SSE endpoints (protected with auth)
 	a.router.Handle("/api/sse/dashboard", a.authMiddleware.RequireAuth(http.HandlerFunc(a.handleSSEDashboard))).Methods("GET")
@@ -109,12 +126,34 @@ func (a *API) setupRoutes() {
 		a.authMiddleware.RequirePermissionAPI("servers.delete")(http.HandlerFunc(a.handleDeleteServer)))).Methods("DELETE")
 	a.router.Handle("/api/servers/{id}/disconnect", a.authMiddleware.RequireAuthAPI(
 		a.authMiddleware.RequirePermissionAPI("servers.edit")(http.HandlerFunc(a.handleDisconnectServer)))).Methods("POST")
+	a.router.Handle("/api/servers/{id}/host-keys", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("servers.view")(http.HandlerFunc(a.handleGetServerHostKeys)))).Methods("GET")
+
+	// Protected API routes - SSH host keys
+	a.router.Handle("/api/host-keys", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("servers.view")(http.HandlerFunc(a.handleGetHostKeys)))).Methods("GET")
+	a.router.Handle("/api/host-keys/{id}/trust", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("servers.host_keys")(http.HandlerFunc(a.handleTrustHostKey)))).Methods("POST")
+	a.router.Handle("/api/host-keys/{id}", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("servers.host_keys")(http.HandlerFunc(a.handleDeleteHostKey)))).Methods("DELETE")
+
+	// Protected API routes - Secrets
+	a.router.Handle("/api/secrets", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.view")(http.HandlerFunc(a.handleGetSecrets)))).Methods("GET")
+	a.router.Handle("/api/secrets", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.edit")(http.HandlerFunc(a.handleCreateSecret)))).Methods("POST")
+	a.router.Handle("/api/secrets/{id}", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.edit")(http.HandlerFunc(a.handleUpdateSecret)))).Methods("PUT")
+	a.router.Handle("/api/secrets/{id}", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.edit")(http.HandlerFunc(a.handleDeleteSecret)))).Methods("DELETE")
 
 	// Protected API routes - Services
 	a.router.Handle("/api/servers/{id}/services", a.authMiddleware.RequireAuthAPI(
 		a.authMiddleware.RequirePermissionAPI("services.view")(http.HandlerFunc(a.handleGetServices)))).Methods("GET")
 	a.router.Handle("/api/services", a.authMiddleware.RequireAuthAPI(
 		a.authMiddleware.RequirePermissionAPI("services.create")(http.HandlerFunc(a.handleCreateService)))).Methods("POST")
+	a.router.Handle("/api/services/{id}", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("services.view")(http.HandlerFunc(a.handleGetService)))).Methods("GET")
 	a.router.Handle("/api/services/{id}", a.authMiddleware.RequireAuthAPI(
 		a.authMiddleware.RequirePermissionAPI("services.edit")(http.HandlerFunc(a.handleUpdateService)))).Methods("PUT")
 	a.router.Handle("/api/services/{id}", a.authMiddleware.RequireAuthAPI(
@@ -131,6 +170,8 @@ func (a *API) setupRoutes() {
 		a.authMiddleware.RequirePermissionAPI("alerts.view")(http.HandlerFunc(a.handleGetAlerts)))).Methods("GET")
 	a.router.Handle("/api/alerts/archived", a.authMiddleware.RequireAuthAPI(
 		a.authMiddleware.RequirePermissionAPI("alerts.view")(http.HandlerFunc(a.handleGetArchivedAlerts)))).Methods("GET")
+	a.router.Handle("/api/alerts/{id}/deliveries", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("alerts.view")(http.HandlerFunc(a.handleGetAlertDeliveries)))).Methods("GET")
 	a.router.Handle("/api/alerts/{id}/acknowledge", a.authMiddleware.RequireAuthAPI(
 		a.authMiddleware.RequirePermissionAPI("alerts.acknowledge")(http.HandlerFunc(a.handleAcknowledgeAlert)))).Methods("POST")
 	a.router.Handle("/api/alerts/{id}/archive", a.authMiddleware.RequireAuthAPI(
@@ -140,6 +181,94 @@ func (a *API) setupRoutes() {
 	a.router.Handle("/api/alerts/archive-all", a.authMiddleware.RequireAuthAPI(
 		a.authMiddleware.RequirePermissionAPI("alerts.archive")(http.HandlerFunc(a.handleArchiveAllAlerts)))).Methods("POST")
 
+	// Protected API routes - Maintenance windows
+	a.router.Handle("/api/maintenance", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("servers.view")(http.HandlerFunc(a.handleGetMaintenanceWindows)))).Methods("GET")
+	a.router.Handle("/api/maintenance", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("servers.edit")(http.HandlerFunc(a.handleCreateMaintenanceWindow)))).Methods("POST")
+	a.router.Handle("/api/maintenance/{id}", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("servers.view")(http.HandlerFunc(a.handleGetMaintenanceWindow)))).Methods("GET")
+	a.router.Handle("/api/maintenance/{id}", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("servers.edit")(http.HandlerFunc(a.handleUpdateMaintenanceWindow)))).Methods("PUT")
+	a.router.Handle("/api/maintenance/{id}", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("servers.edit")(http.HandlerFunc(a.handleDeleteMaintenanceWindow)))).Methods("DELETE")
+
+	// Protected API routes - Escalation policies
+	a.router.Handle("/api/escalation-policies", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.view")(http.HandlerFunc(a.handleGetEscalationPolicies)))).Methods("GET")
+	a.router.Handle("/api/escalation-policies", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.edit")(http.HandlerFunc(a.handleCreateEscalationPolicy)))).Methods("POST")
+	a.router.Handle("/api/escalation-policies/{id}", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.view")(http.HandlerFunc(a.handleGetEscalationPolicy)))).Methods("GET")
+	a.router.Handle("/api/escalation-policies/{id}", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.edit")(http.HandlerFunc(a.handleUpdateEscalationPolicy)))).Methods("PUT")
+	a.router.Handle("/api/escalation-policies/{id}", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.edit")(http.HandlerFunc(a.handleDeleteEscalationPolicy)))).Methods("DELETE")
+
+	// Protected API routes - On-call schedules
+	a.router.Handle("/api/oncall/now", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.view")(http.HandlerFunc(a.handleGetOnCallNow)))).Methods("GET")
+	a.router.Handle("/api/oncall/schedules", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.view")(http.HandlerFunc(a.handleGetOnCallSchedules)))).Methods("GET")
+	a.router.Handle("/api/oncall/schedules", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.edit")(http.HandlerFunc(a.handleCreateOnCallSchedule)))).Methods("POST")
+	a.router.Handle("/api/oncall/schedules/{id}", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.view")(http.HandlerFunc(a.handleGetOnCallSchedule)))).Methods("GET")
+	a.router.Handle("/api/oncall/schedules/{id}", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.edit")(http.HandlerFunc(a.handleUpdateOnCallSchedule)))).Methods("PUT")
+	a.router.Handle("/api/oncall/schedules/{id}", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.edit")(http.HandlerFunc(a.handleDeleteOnCallSchedule)))).Methods("DELETE")
+	a.router.Handle("/api/oncall/schedules/{id}/oncall", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.view")(http.HandlerFunc(a.handleGetOnCall)))).Methods("GET")
+	a.router.Handle("/api/oncall/schedules/{id}/overrides", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.edit")(http.HandlerFunc(a.handleCreateOnCallOverride)))).Methods("POST")
+	a.router.Handle("/api/oncall/overrides/{id}", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.edit")(http.HandlerFunc(a.handleDeleteOnCallOverride)))).Methods("DELETE")
+
+	// Protected API routes - Webhooks
+	a.router.Handle("/api/webhooks", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.view")(http.HandlerFunc(a.handleGetWebhooks)))).Methods("GET")
+	a.router.Handle("/api/webhooks", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.edit")(http.HandlerFunc(a.handleCreateWebhook)))).Methods("POST")
+	a.router.Handle("/api/webhooks/{id}", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.view")(http.HandlerFunc(a.handleGetWebhook)))).Methods("GET")
+	a.router.Handle("/api/webhooks/{id}", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.edit")(http.HandlerFunc(a.handleUpdateWebhook)))).Methods("PUT")
+	a.router.Handle("/api/webhooks/{id}", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.edit")(http.HandlerFunc(a.handleDeleteWebhook)))).Methods("DELETE")
+	a.router.Handle("/api/webhooks/{id}/test", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.edit")(http.HandlerFunc(a.handleTestWebhook)))).Methods("POST")
+	a.router.Handle("/api/webhooks/{id}/deliveries", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.view")(http.HandlerFunc(a.handleGetWebhookDeliveries)))).Methods("GET")
+
+	// Protected API routes - Notification channels
+	a.router.Handle("/api/notifications/email/test", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.edit")(http.HandlerFunc(a.handleTestEmail)))).Methods("POST")
+	a.router.Handle("/api/audit", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.view")(http.HandlerFunc(a.handleGetAuditLog)))).Methods("GET")
+	a.router.Handle("/api/telegram/chats", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.view")(http.HandlerFunc(a.handleGetTelegramChats)))).Methods("GET")
+	a.router.Handle("/api/telegram/chats", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.edit")(http.HandlerFunc(a.handleCreateTelegramChat)))).Methods("POST")
+	a.router.Handle("/api/telegram/chats/{id}", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.view")(http.HandlerFunc(a.handleGetTelegramChat)))).Methods("GET")
+	a.router.Handle("/api/telegram/chats/{id}", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.edit")(http.HandlerFunc(a.handleUpdateTelegramChat)))).Methods("PUT")
+	a.router.Handle("/api/telegram/chats/{id}", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.edit")(http.HandlerFunc(a.handleDeleteTelegramChat)))).Methods("DELETE")
+	a.router.Handle("/api/chat-channels", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.view")(http.HandlerFunc(a.handleGetChatChannels)))).Methods("GET")
+	a.router.Handle("/api/chat-channels", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.edit")(http.HandlerFunc(a.handleCreateChatChannel)))).Methods("POST")
+	a.router.Handle("/api/chat-channels/{id}", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.view")(http.HandlerFunc(a.handleGetChatChannel)))).Methods("GET")
+	a.router.Handle("/api/chat-channels/{id}", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.edit")(http.HandlerFunc(a.handleUpdateChatChannel)))).Methods("PUT")
+	a.router.Handle("/api/chat-channels/{id}", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.edit")(http.HandlerFunc(a.handleDeleteChatChannel)))).Methods("DELETE")
+	a.router.Handle("/api/chat-channels/{id}/test", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("settings.edit")(http.HandlerFunc(a.handleTestChatChannel)))).Methods("POST")
+
 	// Protected API routes - Users
 	a.router.Handle("/api/users", a.authMiddleware.RequireAuthAPI(
 		a.authMiddleware.RequirePermissionAPI("users.view")(http.HandlerFunc(a.handleGetUsers)))).Methods("GET")
@@ -154,6 +283,12 @@ func (a *API) setupRoutes() {
 	a.router.Handle("/api/users/{id}", a.authMiddleware.RequireAuthAPI(
 		a.authMiddleware.RequirePermissionAPI("users.delete")(http.HandlerFunc(a.handleDeleteUser)))).Methods("DELETE")
 	a.router.Handle("/api/users/{id}/password", a.authMiddleware.RequireAuthAPI(http.HandlerFunc(a.handleChangePassword))).Methods("PUT", "POST")
+	a.router.Handle("/api/users/{id}/contact-methods", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("users.view")(http.HandlerFunc(a.handleGetContactMethods)))).Methods("GET")
+	a.router.Handle("/api/users/{id}/contact-methods", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("users.edit")(http.HandlerFunc(a.handleCreateContactMethod)))).Methods("POST")
+	a.router.Handle("/api/users/{id}/contact-methods/{methodId}", a.authMiddleware.RequireAuthAPI(
+		a.authMiddleware.RequirePermissionAPI("users.edit")(http.HandlerFunc(a.handleDeleteContactMethod)))).Methods("DELETE")
 
 	// Protected API routes - Roles
 	a.router.Handle("/api/roles", a.authMiddleware.RequireAuthAPI(
@@ -194,26 +329,34 @@ func (a *API) handleIndex(w http.ResponseWriter, r *http.Request) {
 
 	// Get service status for each server
 	type ServerWithServices struct {
-		Server   *models.Server
-		Services []*models.Service
-		Statuses map[int]*models.ServiceCheck
+		Server      *models.Server
+		Services    []*models.Service
+		Statuses    map[int]*models.ServiceCheck
+		Maintenance map[int]*models.MaintenanceWindow
 	}
 
+	windows := a.activeMaintenance()
+
 	serverData := make([]*ServerWithServices, 0)
 	for _, server := range servers {
 		services, _ := a.db.GetServicesByServer(server.ID)
 		statuses := make(map[int]*models.ServiceCheck)
+		inMaintenance := make(map[int]*models.MaintenanceWindow)
 
 		for _, service := range services {
 			if check, err := a.db.GetLatestServiceCheck(service.ID); err == nil {
 				statuses[service.ID] = check
 			}
+			if window := maintenance.Match(windows, server, service); window != nil {
+				inMaintenance[service.ID] = window
+			}
 		}
 
 		serverData = append(serverData, &ServerWithServices{
-			Server:   server,
-			Services: services,
-			Statuses: statuses,
+			Server:      server,
+			Services:    services,
+			Statuses:    statuses,
+			Maintenance: inMaintenance,
 		})
 	}
 
@@ -268,11 +411,20 @@ func (a *API) handleServerDetailPage(w http.ResponseWriter, r *http.Request) {
 
 	services, _ := a.db.GetServicesByServer(id)
 
+	windows := a.activeMaintenance()
+	inMaintenance := make(map[int]*models.MaintenanceWindow)
+	for _, service := range services {
+		if window := maintenance.Match(windows, server, service); window != nil {
+			inMaintenance[service.ID] = window
+		}
+	}
+
 	data := map[string]interface{}{
-		"Title":    server.Name + " - Vigilon",
-		"Server":   server,
-		"Services": services,
-		"User":     user,
+		"Title":       server.Name + " - Vigilon",
+		"Server":      server,
+		"Services":    services,
+		"Maintenance": inMaintenance,
+		"User":        user,
 	}
 
 	if err := a.templates.ExecuteTemplate(w, "server_detail.html", data); err != nil {
@@ -348,6 +500,15 @@ func (a *API) handleCreateServer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := validateJumpHosts(server.SSHJumpHosts); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := a.validateRemoteAccess(&server); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
 	if err := a.db.CreateServer(&server); err != nil {
 		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
@@ -366,6 +527,15 @@ func (a *API) handleUpdateServer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := validateJumpHosts(server.SSHJumpHosts); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := a.validateRemoteAccess(&server); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
 	server.ID = id
 	if err := a.db.UpdateServer(&server); err != nil {
 		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
@@ -375,6 +545,20 @@ func (a *API) handleUpdateServer(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, server)
 }
 
+// validateJumpHosts checks that every hop of a jump chain has a host and a
+// valid port
+func validateJumpHosts(hops []models.JumpHost) error {
+	for i, hop := range hops {
+		if strings.TrimSpace(hop.Host) == "" {
+			return fmt.Errorf("jump host %d: host is required", i+1)
+		}
+		if hop.Port < 0 || hop.Port > 65535 {
+			return fmt.Errorf("jump host %d: port must be between 1 and 65535", i+1)
+		}
+	}
+	return nil
+}
+
 func (a *API) handleDeleteServer(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, _ := strconv.Atoi(vars["id"])
@@ -420,6 +604,14 @@ func (a *API) handleCreateService(w http.ResponseWriter, r *http.Request) {
 		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
+	if err := validateServiceAlerting(&service); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := a.validateServiceCheck(&service); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
 
 	if err := a.db.CreateService(&service); err != nil {
 		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
@@ -429,18 +621,43 @@ func (a *API) handleCreateService(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusCreated, service)
 }
 
+func (a *API) handleGetService(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	service, err := a.db.GetService(id)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Service not found"})
+		return
+	}
+	respondJSON(w, http.StatusOK, service)
+}
+
 func (a *API) handleUpdateService(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, _ := strconv.Atoi(vars["id"])
 
-	var service models.Service
-	if err := json.NewDecoder(r.Body).Decode(&service); err != nil {
+	// Start from the stored service so omitted fields keep their values
+	service, err := a.db.GetService(id)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Service not found"})
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(service); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := validateServiceAlerting(service); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := a.validateServiceCheck(service); err != nil {
 		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
 
 	service.ID = id
-	if err := a.db.UpdateService(&service); err != nil {
+	if err := a.db.UpdateService(service); err != nil {
 		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
@@ -448,6 +665,71 @@ func (a *API) handleUpdateService(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, service)
 }
 
+// validateServiceAlerting rejects invalid alerting thresholds; zero values fall back to defaults
+func validateServiceAlerting(service *models.Service) error {
+	if service.FailureThreshold < 0 || service.RecoveryThreshold < 0 {
+		return fmt.Errorf("thresholds must not be negative")
+	}
+	if service.FlapThreshold < 0 || service.FlapWindow < 0 {
+		return fmt.Errorf("flap detection settings must not be negative")
+	}
+	if service.FlapThreshold == 1 {
+		return fmt.Errorf("flap_threshold must be 0 (disabled) or at least 2")
+	}
+	return nil
+}
+
+// validateServiceCheck rejects unknown service types, types the server
+// cannot run and invalid endpoint settings
+func (a *API) validateServiceCheck(service *models.Service) error {
+	if !service.Type.Valid() {
+		return fmt.Errorf("unknown service type %q", service.Type)
+	}
+	server, err := a.db.GetServer(service.ServerID)
+	if err != nil {
+		return fmt.Errorf("server not found")
+	}
+
+	switch service.Type {
+	case models.ServiceTypeSystemd:
+		if server.OS != "linux" {
+			return fmt.Errorf("systemd services need a Linux server")
+		}
+	case models.ServiceTypeWindows:
+		if server.OS != "windows" {
+			return fmt.Errorf("Windows services need a Windows server")
+		}
+	}
+
+	switch service.CheckFrom {
+	case "", models.CheckFromServer:
+	case models.CheckFromAgent:
+		if !service.IsEndpoint() {
+			return fmt.Errorf("check_from only applies to endpoint checks")
+		}
+		if server.MonitoringMode != models.ModePush {
+			return fmt.Errorf("endpoints can only be checked from the agent of a push-mode server")
+		}
+	default:
+		return fmt.Errorf("unknown check_from %q", service.CheckFrom)
+	}
+
+	// Drop the settings of another type, e.g. after the type was changed
+	if service.Type != models.ServiceTypeHTTP {
+		service.HTTP = nil
+	}
+	if service.Type != models.ServiceTypeTLS {
+		service.TLS = nil
+	}
+	if service.Type != models.ServiceTypeTCP {
+		service.TCP = nil
+	}
+	if service.Type != models.ServiceTypePing {
+		service.Ping = nil
+	}
+	return netcheck.Validate(service)
+}
+
 func (a *API) handleDeleteService(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, _ := strconv.Atoi(vars["id"])
@@ -512,11 +794,31 @@ func (a *API) handleGetAlerts(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, alerts)
 }
 
+func (a *API) handleGetAlertDeliveries(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	deliveries, err := a.db.GetAlertDeliveries(id)
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if deliveries == nil {
+		deliveries = []models.AlertDelivery{}
+	}
+	respondJSON(w, http.StatusOK, deliveries)
+}
+
 func (a *API) handleAcknowledgeAlert(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, _ := strconv.Atoi(vars["id"])
 
-	if err := a.db.AcknowledgeAlert(id); err != nil {
+	acknowledgedBy := ""
+	if user := auth.GetUserFromContext(r.Context()); user != nil {
+		acknowledgedBy = user.Username
+	}
+
+	if err := a.db.AcknowledgeAlert(id, acknowledgedBy); err != nil {
 		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
@@ -591,6 +893,10 @@ type AgentServiceReport struct {
 	Memory       int64                `json:"memory_kb,omitempty"`
 	CPU          float64              `json:"cpu_percent,omitempty"`
 	Uptime       int64                `json:"uptime_seconds,omitempty"`
+	ResponseTime int64                `json:"response_time_ms,omitempty"`    // Endpoint checks
+	DaysToExpiry *int                 `json:"days_to_expiry,omitempty"`      // Certificate of a tls check
+	Latency      *float64             `json:"latency_ms,omitempty"`          // tcp and ping checks
+	PacketLoss   *float64             `json:"packet_loss_percent,omitempty"` // ping checks
 }
 
 func (a *API) handleAgentReport(w http.ResponseWriter, r *http.Request) {
@@ -600,21 +906,7 @@ func (a *API) handleAgentReport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Find server by agent token
-	servers, err := a.db.GetAllServers()
-	if err != nil {
-		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
-		return
-	}
-
-	var server *models.Server
-	for _, s := range servers {
-		if s.AgentToken == report.Token {
-			server = s
-			break
-		}
-	}
-
+	server := a.serverByAgentToken(report.Token)
 	if server == nil {
 		respondJSON(w, http.StatusUnauthorized, map[string]string{"error": "Invalid token"})
 		return
@@ -646,10 +938,19 @@ func (a *API) handleAgentReport(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
+		// Endpoints checked from here are not the agent's to report
+		if service.IsEndpoint() && service.CheckFrom != models.CheckFromAgent {
+			continue
+		}
+
 		// Create service check
 		check := &models.ServiceCheck{
 			ServiceID:    service.ID,
 			Status:       svcReport.Status,
+			ResponseTime: svcReport.ResponseTime,
+			DaysToExpiry: svcReport.DaysToExpiry,
+			Latency:      svcReport.Latency,
+			PacketLoss:   svcReport.PacketLoss,
 			ErrorMessage: svcReport.ErrorMessage,
 			PID:          svcReport.PID,
 			Memory:       svcReport.Memory,
@@ -827,21 +1128,7 @@ func (a *API) handleAgentServices(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Find server by token
-	servers, err := a.db.GetAllServers()
-	if err != nil {
-		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
-		return
-	}
-
-	var server *models.Server
-	for _, s := range servers {
-		if s.AgentToken == token {
-			server = s
-			break
-		}
-	}
-
+	server := a.serverByAgentToken(token)
 	if server == nil {
 		respondJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid token"})
 		return
@@ -854,9 +1141,12 @@ func (a *API) handleAgentServices(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Filter only enabled services
+	// Filter only enabled services, leaving out endpoints Vigilon checks itself
 	var enabledServices []*models.Service
 	for _, svc := range allServices {
+		if svc.IsEndpoint() && svc.CheckFrom != models.CheckFromAgent {
+			continue
+		}
 		if svc.Enabled {
 			enabledServices = append(enabledServices, svc)
 		}
@@ -1687,6 +1977,7 @@ func (a *API) sseBroadcaster(ctx context.Context) {
 			a.sseManager.Broadcast("servers_update", serversListData)
 
 			// Broadcast per-server detail updates
+			windows := a.activeMaintenance()
 			for _, server := range servers {
 				type ServerDetailUpdate struct {
 					ServerID int        `json:"server_id"`
@@ -1703,16 +1994,23 @@ func (a *API) sseBroadcaster(ctx context.Context) {
 				// Get services for this server
 				services, _ := a.db.GetServicesByServer(server.ID)
 				type ServiceUpdate struct {
-					ServiceID int  `json:"service_id"`
-					Enabled   bool `json:"enabled"`
+					ServiceID     int    `json:"service_id"`
+					Enabled       bool   `json:"enabled"`
+					InMaintenance bool   `json:"in_maintenance"`
+					Maintenance   string `json:"maintenance,omitempty"` // Name of the active window
 				}
 
 				var serviceUpdates []ServiceUpdate
 				for _, svc := range services {
-					serviceUpdates = append(serviceUpdates, ServiceUpdate{
+					update := ServiceUpdate{
 						ServiceID: svc.ID,
 						Enabled:   svc.Enabled,
-					})
+					}
+					if window := maintenance.Match(windows, server, svc); window != nil {
+						update.InMaintenance = true
+						update.Maintenance = window.Name
+					}
+					serviceUpdates = append(serviceUpdates, update)
 				}
 
 				a.sseManager.Broadcast("service_update", serviceUpdates)
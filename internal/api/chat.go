@@ -0,0 +1,147 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/harungecit/vigilon/internal/chat"
+	"github.com/harungecit/vigilon/internal/models"
+)
+
+// API Handlers - Chat channels (Slack, Teams, Discord)
+
+func (a *API) handleGetChatChannels(w http.ResponseWriter, r *http.Request) {
+	channels, err := a.db.GetChatChannels()
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if channels == nil {
+		channels = []*models.ChatChannel{}
+	}
+	respondJSON(w, http.StatusOK, channels)
+}
+
+func (a *API) handleGetChatChannel(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	channel, err := a.db.GetChatChannel(id)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Chat channel not found"})
+		return
+	}
+	respondJSON(w, http.StatusOK, channel)
+}
+
+func (a *API) handleCreateChatChannel(w http.ResponseWriter, r *http.Request) {
+	channel := models.ChatChannel{Enabled: true}
+	if err := json.NewDecoder(r.Body).Decode(&channel); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := a.validateChatChannel(&channel); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := a.db.CreateChatChannel(&channel); err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	a.syncChatChannels()
+
+	respondJSON(w, http.StatusCreated, channel)
+}
+
+func (a *API) handleUpdateChatChannel(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	channel, err := a.db.GetChatChannel(id)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Chat channel not found"})
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(channel); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	channel.ID = id
+	if err := a.validateChatChannel(channel); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := a.db.UpdateChatChannel(channel); err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	a.syncChatChannels()
+
+	respondJSON(w, http.StatusOK, channel)
+}
+
+func (a *API) handleDeleteChatChannel(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	if err := a.db.DeleteChatChannel(id); err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	a.syncChatChannels()
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Chat channel deleted"})
+}
+
+// handleTestChatChannel posts a sample alert to the channel
+func (a *API) handleTestChatChannel(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	channel, err := a.db.GetChatChannel(id)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Chat channel not found"})
+		return
+	}
+	if a.chat == nil {
+		respondJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "Chat channels are not available"})
+		return
+	}
+
+	if err := a.chat.Test(r.Context(), channel); err != nil {
+		respondJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Test message sent"})
+}
+
+// validateChatChannel checks a channel and that its servers exist
+func (a *API) validateChatChannel(channel *models.ChatChannel) error {
+	if err := chat.Validate(channel); err != nil {
+		return err
+	}
+	for _, serverID := range channel.ServerIDs {
+		if _, err := a.db.GetServer(serverID); err != nil {
+			return fmt.Errorf("server %d not found", serverID)
+		}
+	}
+	return nil
+}
+
+// syncChatChannels re-registers the chat channels with the notification dispatcher
+func (a *API) syncChatChannels() {
+	if a.chat == nil {
+		return
+	}
+	if err := a.chat.Sync(); err != nil {
+		log.Printf("Failed to sync chat channels: %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/harungecit/vigilon/internal/models"
+)
+
+// API Handlers - Agent commands and audit log
+
+// handleGetAgentCommands hands the pending commands of a server to its agent
+func (a *API) handleGetAgentCommands(w http.ResponseWriter, r *http.Request) {
+	server := a.serverByAgentToken(r.URL.Query().Get("token"))
+	if server == nil {
+		respondJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid token"})
+		return
+	}
+
+	commands, err := a.db.ClaimAgentCommands(server.ID)
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if commands == nil {
+		commands = []*models.AgentCommand{}
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"commands": commands})
+}
+
+// handleAgentCommandResult stores the result of a command run by an agent
+func (a *API) handleAgentCommandResult(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	var req struct {
+		Token   string `json:"token"`
+		Success bool   `json:"success"`
+		Output  string `json:"output"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	server := a.serverByAgentToken(req.Token)
+	if server == nil {
+		respondJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid token"})
+		return
+	}
+
+	if err := a.db.CompleteAgentCommand(id, server.ID, req.Success, req.Output); err != nil {
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Command not found or no longer running"})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Result received"})
+}
+
+func (a *API) handleGetAuditLog(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if l := r.URL.Query().Get("limit"); l != "" {
+		limit, _ = strconv.Atoi(l)
+	}
+
+	offset := 0
+	if o := r.URL.Query().Get("offset"); o != "" {
+		offset, _ = strconv.Atoi(o)
+	}
+
+	entries, err := a.db.GetAuditEntries(limit, offset)
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if entries == nil {
+		entries = []*models.AuditEntry{}
+	}
+	respondJSON(w, http.StatusOK, entries)
+}
+
+// serverByAgentToken returns the server an agent token belongs to, or nil.
+// Tokens are compared in constant time, against the vault secret of servers
+// that keep their token there and against the stored hash otherwise.
+func (a *API) serverByAgentToken(token string) *models.Server {
+	if token == "" {
+		return nil
+	}
+	servers, err := a.db.GetAllServers()
+	if err != nil {
+		return nil
+	}
+	for _, s := range servers {
+		if s.AgentTokenSecret == "" {
+			if s.MatchesAgentToken(token) {
+				return s
+			}
+			continue
+		}
+		want, err := a.vault.Secret(s.AgentTokenSecret)
+		if err != nil {
+			log.Printf("Failed to load agent token of server %s: %v", s.Name, err)
+			continue
+		}
+		if subtle.ConstantTimeCompare(want, []byte(token)) == 1 {
+			return s
+		}
+	}
+	return nil
+}
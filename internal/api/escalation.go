@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/harungecit/vigilon/internal/escalation"
+	"github.com/harungecit/vigilon/internal/models"
+)
+
+// API Handlers - Escalation policies
+
+func (a *API) handleGetEscalationPolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := a.db.GetEscalationPolicies()
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if policies == nil {
+		policies = []*models.EscalationPolicy{}
+	}
+	respondJSON(w, http.StatusOK, policies)
+}
+
+func (a *API) handleGetEscalationPolicy(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	policy, err := a.db.GetEscalationPolicy(id)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Escalation policy not found"})
+		return
+	}
+	respondJSON(w, http.StatusOK, policy)
+}
+
+func (a *API) handleCreateEscalationPolicy(w http.ResponseWriter, r *http.Request) {
+	var policy models.EscalationPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := escalation.Validate(&policy); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := a.db.CreateEscalationPolicy(&policy); err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, policy)
+}
+
+func (a *API) handleUpdateEscalationPolicy(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	policy, err := a.db.GetEscalationPolicy(id)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Escalation policy not found"})
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(policy); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	policy.ID = id
+	if err := escalation.Validate(policy); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := a.db.UpdateEscalationPolicy(policy); err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, policy)
+}
+
+func (a *API) handleDeleteEscalationPolicy(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	if err := a.db.DeleteEscalationPolicy(id); err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Escalation policy deleted"})
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/harungecit/vigilon/internal/auth"
+	"github.com/harungecit/vigilon/internal/models"
+)
+
+// API Handlers - SSH host keys
+
+// serverHostKey is the host key of one hop on the way to a server. Key is
+// nil until the first connection.
+type serverHostKey struct {
+	Role    string          `json:"role"` // "server" or "jump host N"
+	Address string          `json:"address"`
+	Key     *models.HostKey `json:"key"`
+}
+
+func (a *API) handleGetHostKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := a.db.GetHostKeys()
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if keys == nil {
+		keys = []*models.HostKey{}
+	}
+	respondJSON(w, http.StatusOK, keys)
+}
+
+// handleGetServerHostKeys returns the keys of a server and its jump hosts
+// in connection order
+func (a *API) handleGetServerHostKeys(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	server, err := a.db.GetServer(id)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Server not found"})
+		return
+	}
+
+	var hops []serverHostKey
+	for i, hop := range server.JumpChain() {
+		hops = append(hops, serverHostKey{
+			Role:    "jump host " + strconv.Itoa(i+1),
+			Address: models.SSHAddress(hop.Host, hop.Port),
+		})
+	}
+	hops = append(hops, serverHostKey{Role: "server", Address: models.SSHAddress(server.IPAddress, server.Port)})
+
+	for i := range hops {
+		if key, err := a.db.GetHostKeyByAddress(hops[i].Address); err == nil {
+			hops[i].Key = key
+		}
+	}
+	respondJSON(w, http.StatusOK, hops)
+}
+
+// handleTrustHostKey trusts the pending key of an address. The request names
+// the fingerprint that was reviewed, which must still be the pending one.
+func (a *API) handleTrustHostKey(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	var req struct {
+		Fingerprint string `json:"fingerprint"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if req.Fingerprint == "" {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "fingerprint is required"})
+		return
+	}
+
+	key, err := a.db.GetHostKey(id)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Host key not found"})
+		return
+	}
+
+	user := auth.GetUserFromContext(r.Context())
+	if err := a.db.TrustPendingHostKey(id, req.Fingerprint, user.Username); err != nil {
+		respondJSON(w, http.StatusConflict, map[string]string{"error": "The fingerprint does not match the pending key of this host"})
+		return
+	}
+
+	a.auditHostKey(user.Username, "host_key.trust", key.Address, key.Fingerprint+" -> "+req.Fingerprint)
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Host key trusted"})
+}
+
+// handleDeleteHostKey forgets the key of an address, so the next connection
+// trusts the key the host presents
+func (a *API) handleDeleteHostKey(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	key, err := a.db.GetHostKey(id)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Host key not found"})
+		return
+	}
+	if err := a.db.DeleteHostKey(id); err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	user := auth.GetUserFromContext(r.Context())
+	a.auditHostKey(user.Username, "host_key.forget", key.Address, key.Fingerprint)
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Host key removed"})
+}
+
+// auditHostKey records a host key change in the audit log
+func (a *API) auditHostKey(actor, action, address, details string) {
+	entry := &models.AuditEntry{
+		Actor:   actor,
+		Source:  "web",
+		Action:  action,
+		Target:  address,
+		Success: true,
+		Details: details,
+	}
+	if err := a.db.CreateAuditEntry(entry); err != nil {
+		log.Printf("Failed to write audit log entry for %s on %s: %v", action, address, err)
+	}
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/harungecit/vigilon/internal/auth"
+	"github.com/harungecit/vigilon/internal/maintenance"
+	"github.com/harungecit/vigilon/internal/models"
+)
+
+// maintenanceWindowResponse adds the current state to a maintenance window
+type maintenanceWindowResponse struct {
+	*models.MaintenanceWindow
+	Active      bool       `json:"active"`
+	ActiveUntil *time.Time `json:"active_until,omitempty"`
+}
+
+func newMaintenanceWindowResponse(w *models.MaintenanceWindow, now time.Time) maintenanceWindowResponse {
+	resp := maintenanceWindowResponse{MaintenanceWindow: w}
+	if until, ok := maintenance.Until(w, now); ok {
+		resp.Active = true
+		resp.ActiveUntil = &until
+	}
+	return resp
+}
+
+// API Handlers - Maintenance windows
+
+func (a *API) handleGetMaintenanceWindows(w http.ResponseWriter, r *http.Request) {
+	windows, err := a.db.GetMaintenanceWindows()
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	activeOnly := r.URL.Query().Get("active") == "true"
+	now := time.Now()
+
+	result := make([]maintenanceWindowResponse, 0, len(windows))
+	for _, window := range windows {
+		resp := newMaintenanceWindowResponse(window, now)
+		if activeOnly && !resp.Active {
+			continue
+		}
+		result = append(result, resp)
+	}
+	respondJSON(w, http.StatusOK, result)
+}
+
+func (a *API) handleGetMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	window, err := a.db.GetMaintenanceWindow(id)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Maintenance window not found"})
+		return
+	}
+	respondJSON(w, http.StatusOK, newMaintenanceWindowResponse(window, time.Now()))
+}
+
+func (a *API) handleCreateMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	var window models.MaintenanceWindow
+	if err := json.NewDecoder(r.Body).Decode(&window); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// A one-off window with only a duration is a silence starting now
+	if !window.Recurring() && window.StartsAt == nil && window.EndsAt == nil && window.Duration > 0 {
+		start := time.Now()
+		end := start.Add(time.Duration(window.Duration) * time.Second)
+		window.StartsAt = &start
+		window.EndsAt = &end
+	}
+
+	if err := maintenance.Validate(&window); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if user := auth.GetUserFromContext(r.Context()); user != nil {
+		window.CreatedBy = user.Username
+	}
+
+	if err := a.db.CreateMaintenanceWindow(&window); err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, newMaintenanceWindowResponse(&window, time.Now()))
+}
+
+func (a *API) handleUpdateMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	window, err := a.db.GetMaintenanceWindow(id)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Maintenance window not found"})
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(window); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	window.ID = id
+	if err := maintenance.Validate(window); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := a.db.UpdateMaintenanceWindow(window); err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, newMaintenanceWindowResponse(window, time.Now()))
+}
+
+func (a *API) handleDeleteMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	if err := a.db.DeleteMaintenanceWindow(id); err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Maintenance window deleted"})
+}
+
+// activeMaintenance returns the maintenance windows in effect right now
+func (a *API) activeMaintenance() []*models.MaintenanceWindow {
+	windows, err := a.db.GetMaintenanceWindows()
+	if err != nil {
+		return nil
+	}
+	return maintenance.Active(windows, time.Now())
+}
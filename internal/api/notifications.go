@@ -0,0 +1,34 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// API Handlers - Notification channels
+
+// handleTestEmail sends a sample alert, recovery or digest email
+func (a *API) handleTestEmail(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		To       []string `json:"to"`       // Defaults to the configured recipients
+		Template string   `json:"template"` // alert (default), recovery or digest
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+
+	if a.email == nil {
+		respondJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "Email notifications are not configured"})
+		return
+	}
+
+	if err := a.email.SendTest(r.Context(), req.To, req.Template); err != nil {
+		respondJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Test email sent"})
+}
@@ -0,0 +1,319 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/harungecit/vigilon/internal/models"
+	"github.com/harungecit/vigilon/internal/oncall"
+)
+
+// onCallResponse describes who is on call for a schedule
+type onCallResponse struct {
+	ScheduleID     int                    `json:"schedule_id"`
+	ScheduleName   string                 `json:"schedule_name"`
+	At             time.Time              `json:"at"`
+	Shift          *oncall.Shift          `json:"shift"`
+	Username       string                 `json:"username,omitempty"`
+	Email          string                 `json:"email,omitempty"`
+	ContactMethods []models.ContactMethod `json:"contact_methods"`
+}
+
+// API Handlers - On-call schedules
+
+func (a *API) handleGetOnCallSchedules(w http.ResponseWriter, r *http.Request) {
+	schedules, err := a.db.GetOnCallSchedules()
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if schedules == nil {
+		schedules = []*models.OnCallSchedule{}
+	}
+	respondJSON(w, http.StatusOK, schedules)
+}
+
+func (a *API) handleGetOnCallSchedule(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	schedule, err := a.db.GetOnCallSchedule(id)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "On-call schedule not found"})
+		return
+	}
+	respondJSON(w, http.StatusOK, schedule)
+}
+
+func (a *API) handleCreateOnCallSchedule(w http.ResponseWriter, r *http.Request) {
+	var schedule models.OnCallSchedule
+	if err := json.NewDecoder(r.Body).Decode(&schedule); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if schedule.RotationStart.IsZero() {
+		schedule.RotationStart = time.Now()
+	}
+	if err := a.validateOnCallSchedule(&schedule); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := a.db.CreateOnCallSchedule(&schedule); err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, schedule)
+}
+
+func (a *API) handleUpdateOnCallSchedule(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	schedule, err := a.db.GetOnCallSchedule(id)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "On-call schedule not found"})
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(schedule); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	schedule.ID = id
+	if err := a.validateOnCallSchedule(schedule); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := a.db.UpdateOnCallSchedule(schedule); err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Overrides are managed through their own endpoints
+	if schedule.Overrides, err = a.db.GetOnCallOverrides(id); err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	respondJSON(w, http.StatusOK, schedule)
+}
+
+func (a *API) handleDeleteOnCallSchedule(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	if err := a.db.DeleteOnCallSchedule(id); err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "On-call schedule deleted"})
+}
+
+// handleGetOnCall answers who is on call for a schedule now, or at ?at=RFC3339
+func (a *API) handleGetOnCall(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	at, err := parseAt(r)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	schedule, err := a.db.GetOnCallSchedule(id)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "On-call schedule not found"})
+		return
+	}
+
+	resp, err := a.onCallAt(schedule, at)
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// handleGetOnCallNow answers who is on call for every schedule now, or at ?at=RFC3339
+func (a *API) handleGetOnCallNow(w http.ResponseWriter, r *http.Request) {
+	at, err := parseAt(r)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	schedules, err := a.db.GetOnCallSchedules()
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	result := make([]*onCallResponse, 0, len(schedules))
+	for _, schedule := range schedules {
+		resp, err := a.onCallAt(schedule, at)
+		if err != nil {
+			respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		result = append(result, resp)
+	}
+	respondJSON(w, http.StatusOK, result)
+}
+
+func (a *API) handleCreateOnCallOverride(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	if _, err := a.db.GetOnCallSchedule(id); err != nil {
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "On-call schedule not found"})
+		return
+	}
+
+	var override models.OnCallOverride
+	if err := json.NewDecoder(r.Body).Decode(&override); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	override.ScheduleID = id
+
+	if err := oncall.ValidateOverride(&override); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if _, err := a.db.GetUser(override.UserID); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("user %d not found", override.UserID)})
+		return
+	}
+
+	if err := a.db.CreateOnCallOverride(&override); err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, override)
+}
+
+func (a *API) handleDeleteOnCallOverride(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	if err := a.db.DeleteOnCallOverride(id); err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "On-call override deleted"})
+}
+
+// API Handlers - Contact methods
+
+func (a *API) handleGetContactMethods(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID, _ := strconv.Atoi(vars["id"])
+
+	methods, err := a.db.GetContactMethods(userID)
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	respondJSON(w, http.StatusOK, methods)
+}
+
+func (a *API) handleCreateContactMethod(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID, _ := strconv.Atoi(vars["id"])
+
+	if _, err := a.db.GetUser(userID); err != nil {
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "User not found"})
+		return
+	}
+
+	var method models.ContactMethod
+	if err := json.NewDecoder(r.Body).Decode(&method); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	method.UserID = userID
+
+	if method.Channel == "" || method.Address == "" {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "channel and address are required"})
+		return
+	}
+
+	if err := a.db.CreateContactMethod(&method); err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, method)
+}
+
+func (a *API) handleDeleteContactMethod(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID, _ := strconv.Atoi(vars["id"])
+	methodID, _ := strconv.Atoi(vars["methodId"])
+
+	if err := a.db.DeleteContactMethod(userID, methodID); err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Contact method deleted"})
+}
+
+// validateOnCallSchedule checks a schedule and that all of its users exist
+func (a *API) validateOnCallSchedule(schedule *models.OnCallSchedule) error {
+	if err := oncall.Validate(schedule); err != nil {
+		return err
+	}
+	for _, userID := range schedule.UserIDs {
+		if _, err := a.db.GetUser(userID); err != nil {
+			return fmt.Errorf("user %d not found", userID)
+		}
+	}
+	return nil
+}
+
+// onCallAt resolves the user on call for a schedule at t
+func (a *API) onCallAt(schedule *models.OnCallSchedule, t time.Time) (*onCallResponse, error) {
+	shift, err := oncall.At(schedule, t)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &onCallResponse{
+		ScheduleID:     schedule.ID,
+		ScheduleName:   schedule.Name,
+		At:             t,
+		Shift:          shift,
+		ContactMethods: []models.ContactMethod{},
+	}
+	if user, err := a.db.GetUser(shift.UserID); err == nil {
+		resp.Username = user.Username
+		resp.Email = user.Email
+	}
+	if methods, err := a.db.GetContactMethods(shift.UserID); err == nil {
+		resp.ContactMethods = methods
+	}
+	return resp, nil
+}
+
+// parseAt returns the time given in the "at" query parameter, or now
+func parseAt(r *http.Request) (time.Time, error) {
+	value := r.URL.Query().Get("at")
+	if value == "" {
+		return time.Now(), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("at must be an RFC3339 time")
+	}
+	return t, nil
+}
@@ -0,0 +1,264 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/harungecit/vigilon/internal/auth"
+	"github.com/harungecit/vigilon/internal/models"
+	"github.com/harungecit/vigilon/internal/vault"
+)
+
+// API Handlers - Secrets
+
+// maxSecretSize bounds uploaded secret values, e.g. private keys
+const maxSecretSize = 64 << 10
+
+// secretRequest is the body of a secret create or update request. Value is
+// optional on update, where it keeps the stored value.
+type secretRequest struct {
+	Name        string            `json:"name"`
+	Type        models.SecretType `json:"type"`
+	Description string            `json:"description"`
+	Value       string            `json:"value"`
+}
+
+// decodeSecretRequest reads a secret from a JSON body, or from a multipart
+// form with the value as the "value" file, so key files can be uploaded as
+// they are: curl -F name=web -F type=ssh_key -F value=@id_ed25519
+func decodeSecretRequest(w http.ResponseWriter, r *http.Request) (*secretRequest, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxSecretSize+4096)
+
+	req := &secretRequest{}
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			return nil, err
+		}
+		return req, nil
+	}
+
+	if err := r.ParseMultipartForm(maxSecretSize); err != nil {
+		return nil, err
+	}
+	req.Name = r.FormValue("name")
+	req.Type = models.SecretType(r.FormValue("type"))
+	req.Description = r.FormValue("description")
+	req.Value = r.FormValue("value")
+	if file, _, err := r.FormFile("value"); err == nil {
+		defer file.Close()
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return nil, err
+		}
+		req.Value = string(data)
+	}
+	return req, nil
+}
+
+func (a *API) handleGetSecrets(w http.ResponseWriter, r *http.Request) {
+	secrets, err := a.db.GetSecrets()
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if secrets == nil {
+		secrets = []*models.Secret{}
+	}
+	respondJSON(w, http.StatusOK, secrets)
+}
+
+func (a *API) handleCreateSecret(w http.ResponseWriter, r *http.Request) {
+	if a.vault == nil {
+		respondJSON(w, http.StatusServiceUnavailable, map[string]string{"error": vault.ErrNotConfigured.Error()})
+		return
+	}
+
+	req, err := decodeSecretRequest(w, r)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if _, err := a.db.GetSecretByName(req.Name); err == nil {
+		respondJSON(w, http.StatusConflict, map[string]string{"error": fmt.Sprintf("secret %q already exists", req.Name)})
+		return
+	}
+
+	user := auth.GetUserFromContext(r.Context())
+	secret := &models.Secret{
+		Name:        req.Name,
+		Type:        req.Type,
+		Description: req.Description,
+		CreatedBy:   user.Username,
+	}
+	if err := a.vault.Create(secret, []byte(req.Value)); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	a.auditSecret(user.Username, "secret.create", secret)
+	respondJSON(w, http.StatusCreated, secret)
+}
+
+func (a *API) handleUpdateSecret(w http.ResponseWriter, r *http.Request) {
+	if a.vault == nil {
+		respondJSON(w, http.StatusServiceUnavailable, map[string]string{"error": vault.ErrNotConfigured.Error()})
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	secret, err := a.db.GetSecret(id)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Secret not found"})
+		return
+	}
+	req, err := decodeSecretRequest(w, r)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// The stored value is kept unless the request sends a new one
+	value := []byte(req.Value)
+	if len(value) == 0 {
+		if value, err = a.vault.Secret(secret.Name); err != nil {
+			respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+
+	secret.Description = req.Description
+	if err := a.vault.Update(secret, value); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	user := auth.GetUserFromContext(r.Context())
+	a.auditSecret(user.Username, "secret.update", secret)
+	respondJSON(w, http.StatusOK, secret)
+}
+
+// handleDeleteSecret deletes a secret that no server uses any more
+func (a *API) handleDeleteSecret(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	secret, err := a.db.GetSecret(id)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Secret not found"})
+		return
+	}
+
+	servers, err := a.db.GetAllServers()
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	var users []string
+	for _, server := range servers {
+		for _, ref := range server.SecretRefs() {
+			if ref.Name == secret.Name {
+				users = append(users, server.Name)
+				break
+			}
+		}
+	}
+	hooks, err := a.db.GetWebhooks()
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	for _, hook := range hooks {
+		if hook.SigningSecret == secret.Name {
+			users = append(users, "webhook "+hook.Name)
+		}
+	}
+	if len(users) > 0 {
+		respondJSON(w, http.StatusConflict, map[string]string{"error": "Secret is used by " + strings.Join(users, ", ")})
+		return
+	}
+
+	if err := a.db.DeleteSecret(id); err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	user := auth.GetUserFromContext(r.Context())
+	a.auditSecret(user.Username, "secret.delete", secret)
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Secret deleted"})
+}
+
+// validateRemoteAccess checks the transport of a server, the auth methods of
+// the server and its jump hosts, and that the secrets they reference exist
+// and have the right type
+func (a *API) validateRemoteAccess(server *models.Server) error {
+	switch server.Transport {
+	case "", models.TransportSSH:
+	case models.TransportWinRM:
+		if server.OS != "windows" {
+			return errors.New("the winrm transport is only supported on windows")
+		}
+		if server.WinRM == nil || server.WinRM.User == "" {
+			return errors.New("a WinRM user is required")
+		}
+		if !server.WinRM.Auth.Valid() {
+			return fmt.Errorf("unknown WinRM auth %q", server.WinRM.Auth)
+		}
+	default:
+		return fmt.Errorf("unknown transport %q", server.Transport)
+	}
+
+	if !server.SSHAuthMethod.Valid() {
+		return fmt.Errorf("unknown SSH auth method %q", server.SSHAuthMethod)
+	}
+	for i, hop := range server.JumpChain() {
+		if !hop.AuthMethod.Valid() {
+			return fmt.Errorf("jump host %d: unknown SSH auth method %q", i+1, hop.AuthMethod)
+		}
+	}
+
+	for _, ref := range server.SecretRefs() {
+		if ref.Name == "" {
+			return fmt.Errorf("a %s secret is required for the selected SSH auth method", ref.Type)
+		}
+		if err := a.checkSecretRef(ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSecretRef checks that a referenced secret exists and has the right type
+func (a *API) checkSecretRef(ref models.SecretRef) error {
+	secret, err := a.db.GetSecretByName(ref.Name)
+	if err != nil {
+		return fmt.Errorf("secret %q not found", ref.Name)
+	}
+	if secret.Type != ref.Type {
+		return fmt.Errorf("secret %q is a %s secret, expected %s", ref.Name, secret.Type, ref.Type)
+	}
+	return nil
+}
+
+// auditSecret records a secret change in the audit log
+func (a *API) auditSecret(actor, action string, secret *models.Secret) {
+	entry := &models.AuditEntry{
+		Actor:   actor,
+		Source:  "web",
+		Action:  action,
+		Target:  secret.Name,
+		Success: true,
+		Details: "type: " + string(secret.Type),
+	}
+	if err := a.db.CreateAuditEntry(entry); err != nil {
+		log.Printf("Failed to write audit log entry for %s on %s: %v", action, secret.Name, err)
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/harungecit/vigilon/internal/models"
+)
+
+// API Handlers - Telegram chats (allow-list and subscriptions)
+
+func (a *API) handleGetTelegramChats(w http.ResponseWriter, r *http.Request) {
+	chats, err := a.db.GetTelegramChats()
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if chats == nil {
+		chats = []*models.TelegramChat{}
+	}
+	respondJSON(w, http.StatusOK, chats)
+}
+
+func (a *API) handleGetTelegramChat(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	chat, err := a.db.GetTelegramChat(id)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Telegram chat not found"})
+		return
+	}
+	respondJSON(w, http.StatusOK, chat)
+}
+
+func (a *API) handleCreateTelegramChat(w http.ResponseWriter, r *http.Request) {
+	chat := models.TelegramChat{Enabled: true}
+	if err := json.NewDecoder(r.Body).Decode(&chat); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := a.validateTelegramChat(&chat); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := a.db.CreateTelegramChat(&chat); err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, chat)
+}
+
+func (a *API) handleUpdateTelegramChat(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	chat, err := a.db.GetTelegramChat(id)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Telegram chat not found"})
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(chat); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	chat.ID = id
+	if err := a.validateTelegramChat(chat); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := a.db.UpdateTelegramChat(chat); err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, chat)
+}
+
+func (a *API) handleDeleteTelegramChat(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	if err := a.db.DeleteTelegramChat(id); err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Telegram chat deleted"})
+}
+
+// validateTelegramChat checks the chat ID, severities and that servers exist
+func (a *API) validateTelegramChat(chat *models.TelegramChat) error {
+	if _, err := strconv.ParseInt(chat.ChatID, 10, 64); err != nil {
+		return fmt.Errorf("chat_id must be a numeric Telegram chat ID")
+	}
+	for _, severity := range chat.Severities {
+		if !models.ValidSeverity(severity) {
+			return fmt.Errorf("unknown severity %q, use critical, warning or info", severity)
+		}
+	}
+	for _, serverID := range chat.ServerIDs {
+		if _, err := a.db.GetServer(serverID); err != nil {
+			return fmt.Errorf("server %d not found", serverID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/harungecit/vigilon/internal/models"
+	"github.com/harungecit/vigilon/internal/webhook"
+)
+
+// API Handlers - Webhooks
+
+func (a *API) handleGetWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks, err := a.db.GetWebhooks()
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	result := make([]*models.Webhook, 0, len(hooks))
+	for _, hook := range hooks {
+		hook.Secret = ""
+		result = append(result, hook)
+	}
+	respondJSON(w, http.StatusOK, result)
+}
+
+func (a *API) handleGetWebhook(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	hook, err := a.db.GetWebhook(id)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Webhook not found"})
+		return
+	}
+
+	hook.Secret = ""
+	respondJSON(w, http.StatusOK, hook)
+}
+
+func (a *API) handleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	hook := models.Webhook{Enabled: true}
+	if err := json.NewDecoder(r.Body).Decode(&hook); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	webhook.ApplyDefaults(&hook)
+	if err := webhook.Validate(&hook); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := a.checkSigningSecret(&hook); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := a.db.CreateWebhook(&hook); err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	a.syncWebhooks()
+
+	hook.Secret = ""
+	respondJSON(w, http.StatusCreated, hook)
+}
+
+func (a *API) handleUpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	// The stored secret is kept unless the request sends a new one
+	hook, err := a.db.GetWebhook(id)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Webhook not found"})
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(hook); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	hook.ID = id
+	webhook.ApplyDefaults(hook)
+	if err := webhook.Validate(hook); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := a.checkSigningSecret(hook); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := a.db.UpdateWebhook(hook); err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	a.syncWebhooks()
+
+	hook.Secret = ""
+	respondJSON(w, http.StatusOK, hook)
+}
+
+func (a *API) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	if err := a.db.DeleteWebhook(id); err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	a.syncWebhooks()
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Webhook deleted"})
+}
+
+// handleTestWebhook sends a sample event to the webhook and returns the attempt
+func (a *API) handleTestWebhook(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	hook, err := a.db.GetWebhook(id)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "Webhook not found"})
+		return
+	}
+
+	n, err := webhook.New(a.db, a.vault, hook)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	delivery := n.Test(r.Context())
+	status := http.StatusOK
+	if !delivery.Success {
+		status = http.StatusBadGateway
+	}
+	respondJSON(w, status, delivery)
+}
+
+func (a *API) handleGetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	limit := 50
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+
+	deliveries, err := a.db.GetWebhookDeliveries(id, limit)
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	respondJSON(w, http.StatusOK, deliveries)
+}
+
+// syncWebhooks re-registers the webhooks with the notification dispatcher
+func (a *API) syncWebhooks() {
+	if a.dispatcher == nil {
+		return
+	}
+	if err := webhook.Sync(a.db, a.vault, a.dispatcher); err != nil {
+		log.Printf("Failed to sync webhooks: %v", err)
+	}
+}
+
+// checkSigningSecret checks that the vault secret a webhook signs with exists
+func (a *API) checkSigningSecret(hook *models.Webhook) error {
+	if hook.SigningSecret == "" {
+		return nil
+	}
+	return a.checkSecretRef(models.SecretRef{Name: hook.SigningSecret, Type: models.SecretToken})
+}
@@ -0,0 +1,344 @@
+package chat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/harungecit/vigilon/internal/database"
+	"github.com/harungecit/vigilon/internal/models"
+	"github.com/harungecit/vigilon/internal/notify"
+)
+
+// Status colors shared by all formatters
+const (
+	colorCritical = "#dc2626" // stopped, failed
+	colorWarning  = "#ea580c" // degraded, unknown
+	colorGood     = "#16a34a" // running, recovered
+	colorInfo     = "#2563eb" // everything else
+)
+
+// level is the severity of a message, used where a service has no free colors
+type level string
+
+const (
+	levelCritical level = "critical"
+	levelWarning  level = "warning"
+	levelGood     level = "good"
+	levelInfo     level = "info"
+)
+
+// message is the chat-agnostic content of a notification
+type message struct {
+	Title     string
+	Text      string
+	Level     level
+	Fields    []field
+	URL       string
+	Timestamp time.Time
+}
+
+type field struct {
+	Name  string
+	Value string
+}
+
+// Color returns the hex color of the message level
+func (m *message) Color() string {
+	switch m.Level {
+	case levelCritical:
+		return colorCritical
+	case levelWarning:
+		return colorWarning
+	case levelGood:
+		return colorGood
+	}
+	return colorInfo
+}
+
+// formatter turns a message into the JSON payload of a chat service
+type formatter func(m *message) interface{}
+
+var formatters = map[models.ChatChannelType]formatter{
+	models.ChatSlack:         formatSlack,
+	models.ChatTeams:         formatTeams,
+	models.ChatTeamsAdaptive: formatTeamsAdaptive,
+	models.ChatDiscord:       formatDiscord,
+}
+
+// Validate checks that a chat channel is complete
+func Validate(channel *models.ChatChannel) error {
+	if channel.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if _, ok := formatters[channel.Type]; !ok {
+		return fmt.Errorf("type must be one of slack, teams, teams_adaptive or discord")
+	}
+	u, err := url.Parse(channel.WebhookURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("webhook_url must be an https URL")
+	}
+	return nil
+}
+
+// Notifier posts notifications to one chat channel
+type Notifier struct {
+	channel   *models.ChatChannel
+	format    formatter
+	publicURL string
+	client    *http.Client
+}
+
+// New creates a notifier for a chat channel. publicURL is the base URL of
+// the web UI used for links; leave it empty to omit links.
+func New(channel *models.ChatChannel, publicURL string) (*Notifier, error) {
+	format, ok := formatters[channel.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown chat channel type %q", channel.Type)
+	}
+	return &Notifier{
+		channel:   channel,
+		format:    format,
+		publicURL: strings.TrimRight(publicURL, "/"),
+		client:    &http.Client{},
+	}, nil
+}
+
+// Name returns the channel name, e.g. "slack:ops"
+func (n *Notifier) Name() string {
+	return ChannelName(n.channel)
+}
+
+// Enabled reports whether the channel is active and routes the server
+func (n *Notifier) Enabled(server *models.Server) bool {
+	return n.channel.Enabled && n.channel.Routes(server)
+}
+
+// Send formats the event and posts it to the chat webhook
+func (n *Notifier) Send(ctx context.Context, event *notify.Event) error {
+	return n.post(ctx, n.format(newMessage(event, n.publicURL)))
+}
+
+// Test posts a sample alert to the chat webhook
+func (n *Notifier) Test(ctx context.Context) error {
+	now := time.Now()
+	return n.Send(ctx, &notify.Event{
+		Type:    notify.EventAlert,
+		Server:  &models.Server{Name: "example-server", Hostname: "example.local"},
+		Service: &models.Service{Name: "example.service", DisplayName: "Example Service"},
+		Alert: &models.Alert{
+			Status:    models.StatusStopped,
+			State:     models.AlertOpen,
+			Message:   "This is a test message from Vigilon",
+			CreatedAt: now,
+		},
+		CreatedAt: now,
+	})
+}
+
+// post sends a JSON payload to the webhook URL
+func (n *Notifier) post(ctx context.Context, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.channel.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Vigilon")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+// ChannelName returns the notification channel name of a chat channel
+func ChannelName(channel *models.ChatChannel) string {
+	return string(channel.Type) + ":" + channel.Name
+}
+
+// isChatChannel reports whether a registered channel name belongs to a chat channel
+func isChatChannel(name string) bool {
+	for t := range formatters {
+		if strings.HasPrefix(name, string(t)+":") {
+			return true
+		}
+	}
+	return false
+}
+
+// Manager keeps the dispatcher in sync with the chat channels in the database
+type Manager struct {
+	db         *database.DB
+	dispatcher *notify.Dispatcher
+	publicURL  string
+}
+
+// NewManager creates a new chat channel manager
+func NewManager(db *database.DB, dispatcher *notify.Dispatcher, publicURL string) *Manager {
+	return &Manager{
+		db:         db,
+		dispatcher: dispatcher,
+		publicURL:  publicURL,
+	}
+}
+
+// Sync registers every enabled chat channel with the dispatcher and removes
+// channels that were deleted, renamed or disabled
+func (m *Manager) Sync() error {
+	channels, err := m.db.GetChatChannels()
+	if err != nil {
+		return err
+	}
+
+	active := make(map[string]bool)
+	var errs []error
+	for _, channel := range channels {
+		if !channel.Enabled {
+			continue
+		}
+		n, err := New(channel, m.publicURL)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		m.dispatcher.Register(n)
+		active[n.Name()] = true
+	}
+
+	for _, name := range m.dispatcher.Channels() {
+		if isChatChannel(name) && !active[name] {
+			m.dispatcher.Unregister(name)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Test posts a sample alert to a chat channel
+func (m *Manager) Test(ctx context.Context, channel *models.ChatChannel) error {
+	n, err := New(channel, m.publicURL)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	return n.Test(ctx)
+}
+
+// newMessage builds the chat message of an event
+func newMessage(event *notify.Event, publicURL string) *message {
+	m := &message{
+		Text:      event.Message(),
+		Level:     levelInfo,
+		Timestamp: event.CreatedAt,
+	}
+	if m.Timestamp.IsZero() {
+		m.Timestamp = time.Now()
+	}
+
+	serverName, serviceName, status := "server", "service", "down"
+	if s := event.Server; s != nil {
+		serverName = s.Name
+		value := s.Name
+		if s.Hostname != "" && s.Hostname != s.Name {
+			value += " (" + s.Hostname + ")"
+		}
+		m.Fields = append(m.Fields, field{"Server", value})
+		if s.ID > 0 && publicURL != "" {
+			m.URL = fmt.Sprintf("%s/server/%d", publicURL, s.ID)
+		}
+	}
+	if s := event.Service; s != nil {
+		serviceName = s.DisplayName
+		if serviceName == "" {
+			serviceName = s.Name
+		}
+		m.Fields = append(m.Fields, field{"Service", serviceName})
+	}
+	if a := event.Alert; a != nil {
+		status = string(a.Status)
+		m.Level = statusLevel(a.Status)
+		if a.Level != "" {
+			m.Level = level(a.Level)
+		}
+		m.Fields = append(m.Fields, field{"Status", status})
+	}
+	if c := event.Check; c != nil && c.DaysToExpiry != nil {
+		m.Fields = append(m.Fields, field{"Certificate Expires In", fmt.Sprintf("%d days", *c.DaysToExpiry)})
+	}
+	if c := event.Check; c != nil && c.PacketLoss != nil && event.Type != notify.EventRecovery {
+		m.Fields = append(m.Fields, field{"Packet Loss", fmt.Sprintf("%.0f%%", *c.PacketLoss)})
+	}
+
+	switch event.Type {
+	case notify.EventRecovery:
+		m.Title = fmt.Sprintf("Recovered: %s on %s", serviceName, serverName)
+		m.Level = levelGood
+		if event.Alert != nil {
+			m.Fields = append(m.Fields, field{"Downtime", event.Alert.Downtime().Round(time.Second).String()})
+		}
+	case notify.EventReminder:
+		m.Title = fmt.Sprintf("Reminder: %s on %s is still %s", serviceName, serverName, status)
+	case notify.EventEscalation:
+		m.Title = fmt.Sprintf("Escalation: %s on %s is %s", serviceName, serverName, status)
+	default:
+		m.Title = fmt.Sprintf("Alert: %s on %s is %s", serviceName, serverName, status)
+	}
+	if event.Alert != nil && event.Alert.Type == models.AlertHostKey && event.Type == notify.EventAlert {
+		m.Title = fmt.Sprintf("SSH host key of %s changed", serverName)
+		m.Level = levelCritical
+	}
+	if event.Alert != nil && event.Alert.Type == models.AlertHostDown {
+		switch event.Type {
+		case notify.EventAlert:
+			m.Title = fmt.Sprintf("Server %s is down", serverName)
+		case notify.EventRecovery:
+			m.Title = fmt.Sprintf("Recovered: server %s is reachable again", serverName)
+		}
+	}
+
+	if event.Check != nil && event.Check.ErrorMessage != "" && event.Type != notify.EventRecovery {
+		m.Fields = append(m.Fields, field{"Error", event.Check.ErrorMessage})
+	}
+	return m
+}
+
+// statusLevel maps a service status to a message level
+func statusLevel(status models.ServiceStatus) level {
+	switch status {
+	case models.StatusStopped, models.StatusFailed:
+		return levelCritical
+	case models.StatusDegraded, models.StatusUnknown:
+		return levelWarning
+	case models.StatusRunning:
+		return levelGood
+	}
+	return levelInfo
+}
+
+// truncate shortens s to at most max characters
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-1]) + "…"
+}
@@ -0,0 +1,267 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harungecit/vigilon/internal/models"
+	"github.com/harungecit/vigilon/internal/notify"
+)
+
+const publicURL = "https://vigilon.example.com/"
+
+// alertEvent returns an alert event of nginx stopping on web-1
+func alertEvent() *notify.Event {
+	created := time.Date(2026, 3, 14, 9, 26, 53, 0, time.UTC)
+	return &notify.Event{
+		Type: notify.EventAlert,
+		Alert: &models.Alert{
+			ID:        42,
+			Status:    models.StatusStopped,
+			State:     models.AlertOpen,
+			Message:   "Service Nginx on web-1 is stopped",
+			CreatedAt: created,
+		},
+		Server:    &models.Server{ID: 7, Name: "web-1", Hostname: "web-1.internal"},
+		Service:   &models.Service{ID: 3, Name: "nginx.service", DisplayName: "Nginx"},
+		Check:     &models.ServiceCheck{ErrorMessage: "exit status 1 <stderr>"},
+		CreatedAt: created,
+	}
+}
+
+func TestSendPayloads(t *testing.T) {
+	tests := []struct {
+		kind models.ChatChannelType
+		want string
+	}{
+		{
+			kind: models.ChatSlack,
+			want: `{
+				"text": "Alert: Nginx on web-1 is stopped",
+				"attachments": [{
+					"color": "#dc2626",
+					"blocks": [
+						{"type": "header", "text": {"type": "plain_text", "text": "Alert: Nginx on web-1 is stopped"}},
+						{"type": "section", "text": {"type": "mrkdwn", "text": "Service Nginx on web-1 is stopped"}},
+						{"type": "section", "fields": [
+							{"type": "mrkdwn", "text": "*Server*\nweb-1 (web-1.internal)"},
+							{"type": "mrkdwn", "text": "*Service*\nNginx"},
+							{"type": "mrkdwn", "text": "*Status*\nstopped"},
+							{"type": "mrkdwn", "text": "*Error*\nexit status 1 &lt;stderr&gt;"}
+						]},
+						{"type": "actions", "elements": [{
+							"type": "button",
+							"text": {"type": "plain_text", "text": "Open in Vigilon"},
+							"url": "https://vigilon.example.com/server/7"
+						}]}
+					]
+				}]
+			}`,
+		},
+		{
+			kind: models.ChatTeams,
+			want: `{
+				"@type": "MessageCard",
+				"@context": "http://schema.org/extensions",
+				"summary": "Alert: Nginx on web-1 is stopped",
+				"themeColor": "dc2626",
+				"title": "Alert: Nginx on web-1 is stopped",
+				"text": "Service Nginx on web-1 is stopped",
+				"sections": [{"facts": [
+					{"name": "Server", "value": "web-1 (web-1.internal)"},
+					{"name": "Service", "value": "Nginx"},
+					{"name": "Status", "value": "stopped"},
+					{"name": "Error", "value": "exit status 1 <stderr>"}
+				]}],
+				"potentialAction": [{
+					"@type": "OpenUri",
+					"name": "Open in Vigilon",
+					"targets": [{"os": "default", "uri": "https://vigilon.example.com/server/7"}]
+				}]
+			}`,
+		},
+		{
+			kind: models.ChatTeamsAdaptive,
+			want: `{
+				"type": "message",
+				"attachments": [{
+					"contentType": "application/vnd.microsoft.card.adaptive",
+					"content": {
+						"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+						"type": "AdaptiveCard",
+						"version": "1.4",
+						"body": [
+							{"type": "Container", "style": "attention", "bleed": true, "items": [{
+								"type": "TextBlock", "text": "Alert: Nginx on web-1 is stopped",
+								"size": "Large", "weight": "Bolder", "color": "Attention", "wrap": true
+							}]},
+							{"type": "TextBlock", "text": "Service Nginx on web-1 is stopped", "wrap": true},
+							{"type": "FactSet", "facts": [
+								{"title": "Server", "value": "web-1 (web-1.internal)"},
+								{"title": "Service", "value": "Nginx"},
+								{"title": "Status", "value": "stopped"},
+								{"title": "Error", "value": "exit status 1 <stderr>"}
+							]}
+						],
+						"actions": [{"type": "Action.OpenUrl", "title": "Open in Vigilon", "url": "https://vigilon.example.com/server/7"}]
+					}
+				}]
+			}`,
+		},
+		{
+			kind: models.ChatDiscord,
+			want: `{
+				"username": "Vigilon",
+				"embeds": [{
+					"title": "Alert: Nginx on web-1 is stopped",
+					"description": "Service Nginx on web-1 is stopped",
+					"url": "https://vigilon.example.com/server/7",
+					"color": 14427686,
+					"fields": [
+						{"name": "Server", "value": "web-1 (web-1.internal)", "inline": true},
+						{"name": "Service", "value": "Nginx", "inline": true},
+						{"name": "Status", "value": "stopped", "inline": true},
+						{"name": "Error", "value": "exit status 1 <stderr>", "inline": false}
+					],
+					"timestamp": "2026-03-14T09:26:53Z",
+					"footer": {"text": "Vigilon"}
+				}]
+			}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.kind), func(t *testing.T) {
+			bodies := make(chan []byte, 1)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+					t.Errorf("request = %s with %q, want a JSON POST", r.Method, r.Header.Get("Content-Type"))
+				}
+				body, _ := io.ReadAll(r.Body)
+				bodies <- body
+			}))
+			defer srv.Close()
+
+			n, err := New(&models.ChatChannel{Name: "ops", Type: tt.kind, WebhookURL: srv.URL, Enabled: true}, publicURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := n.Send(context.Background(), alertEvent()); err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			checkJSON(t, <-bodies, tt.want)
+		})
+	}
+}
+
+func TestSendFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid_payload", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	n, err := New(&models.ChatChannel{Name: "ops", Type: models.ChatSlack, WebhookURL: srv.URL}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = n.Send(context.Background(), alertEvent())
+	if err == nil || err.Error() != "unexpected status 400 Bad Request: invalid_payload" {
+		t.Errorf("error = %v, want the status and response body", err)
+	}
+}
+
+func TestNewMessage(t *testing.T) {
+	recovery := alertEvent()
+	recovery.Type = notify.EventRecovery
+	recovery.Alert.State = models.AlertResolved
+	recovery.Alert.DowntimeSecs = 330
+
+	degraded := alertEvent()
+	degraded.Type = notify.EventReminder
+	degraded.Alert.Status = models.StatusDegraded
+
+	hostKey := alertEvent()
+	hostKey.Alert.Type = models.AlertHostKey
+
+	hostDown := alertEvent()
+	hostDown.Alert.Type = models.AlertHostDown
+	hostDown.Alert.Status = models.StatusFailed
+
+	tests := []struct {
+		name   string
+		event  *notify.Event
+		title  string
+		level  level
+		fields []string
+	}{
+		{"alert", alertEvent(), "Alert: Nginx on web-1 is stopped", levelCritical, []string{"Server", "Service", "Status", "Error"}},
+		{"recovery", recovery, "Recovered: Nginx on web-1", levelGood, []string{"Server", "Service", "Status", "Downtime"}},
+		{"reminder", degraded, "Reminder: Nginx on web-1 is still degraded", levelWarning, []string{"Server", "Service", "Status", "Error"}},
+		{"host key", hostKey, "SSH host key of web-1 changed", levelCritical, []string{"Server", "Service", "Status", "Error"}},
+		{"host down", hostDown, "Server web-1 is down", levelCritical, []string{"Server", "Service", "Status", "Error"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMessage(tt.event, "https://vigilon.example.com")
+			if m.Title != tt.title || m.Level != tt.level {
+				t.Errorf("title, level = %q, %s; want %q, %s", m.Title, m.Level, tt.title, tt.level)
+			}
+			var names []string
+			for _, f := range m.Fields {
+				names = append(names, f.Name)
+			}
+			if !reflect.DeepEqual(names, tt.fields) {
+				t.Errorf("fields = %v, want %v", names, tt.fields)
+			}
+		})
+	}
+
+	if m := newMessage(recovery, ""); m.Fields[3].Value != "5m30s" || m.URL != "" {
+		t.Errorf("downtime, url = %q, %q; want 5m30s and no link", m.Fields[3].Value, m.URL)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		channel models.ChatChannel
+		wantErr string
+	}{
+		{"valid", models.ChatChannel{Name: "ops", Type: models.ChatDiscord, WebhookURL: "https://discord.com/api/webhooks/1/abc"}, ""},
+		{"no name", models.ChatChannel{Type: models.ChatSlack, WebhookURL: "https://hooks.slack.com/x"}, "name is required"},
+		{"unknown type", models.ChatChannel{Name: "ops", Type: "irc", WebhookURL: "https://example.com"}, "type must be one of"},
+		{"plain http", models.ChatChannel{Name: "ops", Type: models.ChatSlack, WebhookURL: "http://hooks.slack.com/x"}, "must be an https URL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(&tt.channel)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// checkJSON fails the test unless got and want hold the same JSON value
+func checkJSON(t *testing.T, got []byte, want string) {
+	t.Helper()
+	var gotValue, wantValue interface{}
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatalf("body is not JSON: %v\n%s", err, got)
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatalf("invalid expected JSON: %v", err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("body =\n%s\nwant\n%s", got, want)
+	}
+}
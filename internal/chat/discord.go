@@ -0,0 +1,66 @@
+package chat
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Discord webhook payload types
+type discordPayload struct {
+	Username string         `json:"username"`
+	Embeds   []discordEmbed `json:"embeds"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	URL         string         `json:"url,omitempty"`
+	Color       int            `json:"color"`
+	Fields      []discordField `json:"fields,omitempty"`
+	Timestamp   string         `json:"timestamp"`
+	Footer      *discordFooter `json:"footer,omitempty"`
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type discordFooter struct {
+	Text string `json:"text"`
+}
+
+// formatDiscord renders a message as a status-colored embed. The title links
+// to the server page when a public URL is configured.
+func formatDiscord(m *message) interface{} {
+	color, _ := strconv.ParseInt(strings.TrimPrefix(m.Color(), "#"), 16, 32)
+
+	embed := discordEmbed{
+		Title:       truncate(m.Title, 256),
+		Description: truncate(m.Text, 4096),
+		URL:         m.URL,
+		Color:       int(color),
+		Timestamp:   m.Timestamp.UTC().Format(time.RFC3339),
+		Footer:      &discordFooter{Text: "Vigilon"},
+	}
+
+	// Discord allows at most 25 fields per embed
+	for _, f := range m.Fields {
+		if len(embed.Fields) == 25 {
+			break
+		}
+		value := f.Value
+		if value == "" {
+			value = "-" // Discord rejects empty field values
+		}
+		embed.Fields = append(embed.Fields, discordField{
+			Name:   f.Name,
+			Value:  truncate(value, 1024),
+			Inline: f.Name != "Error",
+		})
+	}
+
+	return discordPayload{Username: "Vigilon", Embeds: []discordEmbed{embed}}
+}
@@ -0,0 +1,72 @@
+package chat
+
+import "strings"
+
+var slackReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// Slack Block Kit payload types
+type slackPayload struct {
+	Text        string            `json:"text"` // Fallback for notifications
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+type slackAttachment struct {
+	Color  string       `json:"color"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type     string         `json:"type"`
+	Text     *slackText     `json:"text,omitempty"`
+	Fields   []slackText    `json:"fields,omitempty"`
+	Elements []slackElement `json:"elements,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackElement struct {
+	Type string     `json:"type"`
+	Text *slackText `json:"text,omitempty"`
+	URL  string     `json:"url,omitempty"`
+}
+
+// formatSlack renders a message as a status-colored Block Kit attachment
+func formatSlack(m *message) interface{} {
+	blocks := []slackBlock{
+		{Type: "header", Text: &slackText{Type: "plain_text", Text: truncate(m.Title, 150)}},
+		{Type: "section", Text: &slackText{Type: "mrkdwn", Text: truncate(slackEscape(m.Text), 3000)}},
+	}
+
+	// Slack allows at most 10 fields per section
+	var fields []slackText
+	for _, f := range m.Fields {
+		if len(fields) == 10 {
+			break
+		}
+		fields = append(fields, slackText{Type: "mrkdwn", Text: "*" + f.Name + "*\n" + truncate(slackEscape(f.Value), 1900)})
+	}
+	if len(fields) > 0 {
+		blocks = append(blocks, slackBlock{Type: "section", Fields: fields})
+	}
+
+	if m.URL != "" {
+		blocks = append(blocks, slackBlock{Type: "actions", Elements: []slackElement{{
+			Type: "button",
+			Text: &slackText{Type: "plain_text", Text: "Open in Vigilon"},
+			URL:  m.URL,
+		}}})
+	}
+
+	return slackPayload{
+		Text:        m.Title,
+		Attachments: []slackAttachment{{Color: m.Color(), Blocks: blocks}},
+	}
+}
+
+// slackEscape escapes the characters Slack treats as control sequences
+func slackEscape(s string) string {
+	return slackReplacer.Replace(s)
+}
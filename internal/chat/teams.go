@@ -0,0 +1,159 @@
+package chat
+
+import "strings"
+
+// Microsoft Teams MessageCard payload types (Office 365 connectors)
+type teamsCard struct {
+	Type            string         `json:"@type"`
+	Context         string         `json:"@context"`
+	Summary         string         `json:"summary"`
+	ThemeColor      string         `json:"themeColor"`
+	Title           string         `json:"title"`
+	Text            string         `json:"text"`
+	Sections        []teamsSection `json:"sections,omitempty"`
+	PotentialAction []teamsAction  `json:"potentialAction,omitempty"`
+}
+
+type teamsSection struct {
+	Facts []teamsFact `json:"facts"`
+}
+
+type teamsFact struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type teamsAction struct {
+	Type    string        `json:"@type"`
+	Name    string        `json:"name"`
+	Targets []teamsTarget `json:"targets"`
+}
+
+type teamsTarget struct {
+	OS  string `json:"os"`
+	URI string `json:"uri"`
+}
+
+// formatTeams renders a message as a status-colored MessageCard
+func formatTeams(m *message) interface{} {
+	card := teamsCard{
+		Type:       "MessageCard",
+		Context:    "http://schema.org/extensions",
+		Summary:    m.Title,
+		ThemeColor: strings.TrimPrefix(m.Color(), "#"),
+		Title:      m.Title,
+		Text:       m.Text,
+	}
+
+	if len(m.Fields) > 0 {
+		var facts []teamsFact
+		for _, f := range m.Fields {
+			facts = append(facts, teamsFact{Name: f.Name, Value: f.Value})
+		}
+		card.Sections = []teamsSection{{Facts: facts}}
+	}
+
+	if m.URL != "" {
+		card.PotentialAction = []teamsAction{{
+			Type:    "OpenUri",
+			Name:    "Open in Vigilon",
+			Targets: []teamsTarget{{OS: "default", URI: m.URL}},
+		}}
+	}
+	return card
+}
+
+// Microsoft Teams Adaptive Card payload types (Workflows)
+type adaptiveMessage struct {
+	Type        string               `json:"type"`
+	Attachments []adaptiveAttachment `json:"attachments"`
+}
+
+type adaptiveAttachment struct {
+	ContentType string       `json:"contentType"`
+	Content     adaptiveCard `json:"content"`
+}
+
+type adaptiveCard struct {
+	Schema  string            `json:"$schema"`
+	Type    string            `json:"type"`
+	Version string            `json:"version"`
+	Body    []adaptiveElement `json:"body"`
+	Actions []adaptiveAction  `json:"actions,omitempty"`
+}
+
+type adaptiveElement struct {
+	Type   string            `json:"type"`
+	Style  string            `json:"style,omitempty"`
+	Bleed  bool              `json:"bleed,omitempty"`
+	Items  []adaptiveElement `json:"items,omitempty"`
+	Text   string            `json:"text,omitempty"`
+	Size   string            `json:"size,omitempty"`
+	Weight string            `json:"weight,omitempty"`
+	Color  string            `json:"color,omitempty"`
+	Wrap   bool              `json:"wrap,omitempty"`
+	Facts  []adaptiveFact    `json:"facts,omitempty"`
+}
+
+type adaptiveFact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+type adaptiveAction struct {
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// formatTeamsAdaptive renders a message as an Adaptive Card. Cards have no
+// free colors, so the status is shown through the container style.
+func formatTeamsAdaptive(m *message) interface{} {
+	style, color := "accent", "Accent"
+	switch m.Level {
+	case levelCritical:
+		style, color = "attention", "Attention"
+	case levelWarning:
+		style, color = "warning", "Warning"
+	case levelGood:
+		style, color = "good", "Good"
+	}
+
+	body := []adaptiveElement{
+		{
+			Type:  "Container",
+			Style: style,
+			Bleed: true,
+			Items: []adaptiveElement{{
+				Type: "TextBlock", Text: m.Title, Size: "Large", Weight: "Bolder", Color: color, Wrap: true,
+			}},
+		},
+		{Type: "TextBlock", Text: m.Text, Wrap: true},
+	}
+
+	if len(m.Fields) > 0 {
+		var facts []adaptiveFact
+		for _, f := range m.Fields {
+			facts = append(facts, adaptiveFact{Title: f.Name, Value: f.Value})
+		}
+		body = append(body, adaptiveElement{Type: "FactSet", Facts: facts})
+	}
+
+	card := adaptiveCard{
+		Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+		Type:    "AdaptiveCard",
+		Version: "1.4",
+		Body:    body,
+	}
+	if m.URL != "" {
+		card.Actions = []adaptiveAction{{Type: "Action.OpenUrl", Title: "Open in Vigilon", URL: m.URL}}
+	}
+
+	return adaptiveMessage{
+		Type: "message",
+		Attachments: []adaptiveAttachment{{
+			ContentType: "application/vnd.microsoft.card.adaptive",
+			Content:     card,
+		}},
+	}
+}
@@ -11,48 +11,83 @@ import (
 
 // AppConfig represents the application configuration
 type AppConfig struct {
-	Server     ServerConfig          `yaml:"server"`
-	Database   DatabaseConfig        `yaml:"database"`
-	Telegram   models.TelegramConfig `yaml:"telegram"`
-	Monitoring MonitoringConfig      `yaml:"monitoring"`
-	Servers    []ServerDefinition    `yaml:"servers"`
+	Server        ServerConfig          `yaml:"server"`
+	Database      DatabaseConfig        `yaml:"database"`
+	Telegram      models.TelegramConfig `yaml:"telegram"`
+	Email         models.EmailConfig    `yaml:"email"`
+	Notifications NotificationConfig    `yaml:"notifications"`
+	Monitoring    MonitoringConfig      `yaml:"monitoring"`
+	Vault         VaultConfig           `yaml:"vault"`
+	Servers       []ServerDefinition    `yaml:"servers"`
 }
 
 type ServerConfig struct {
-	Host string `yaml:"host"`
-	Port int    `yaml:"port"`
+	Host      string `yaml:"host"`
+	Port      int    `yaml:"port"`
+	PublicURL string `yaml:"public_url"` // Base URL of the web UI used in notification links
 }
 
 type DatabaseConfig struct {
 	Path string `yaml:"path"`
 }
 
+type NotificationConfig struct {
+	MaxAttempts  int           `yaml:"max_attempts"`  // Delivery attempts per channel
+	RetryBackoff time.Duration `yaml:"retry_backoff"` // Initial delay between attempts, doubled after each failure
+	MaxBackoff   time.Duration `yaml:"max_backoff"`   // Upper bound for the delay between attempts
+}
+
 type MonitoringConfig struct {
-	CheckInterval time.Duration `yaml:"check_interval"`
-	RetentionDays int           `yaml:"retention_days"`
-	AlertCooldown time.Duration `yaml:"alert_cooldown"`
+	CheckInterval     time.Duration   `yaml:"check_interval"`
+	RetentionDays     int             `yaml:"retention_days"`
+	ReminderIntervals []time.Duration `yaml:"reminder_intervals"` // Delays between reminders while a service stays down; the last one repeats
+	MaxReminders      int             `yaml:"max_reminders"`      // 0 = no limit
+}
+
+// VaultConfig locates the master key of the secret vault. The
+// VIGILON_MASTER_KEY environment variable takes precedence over the file.
+type VaultConfig struct {
+	MasterKeyFile          string   `yaml:"master_key_file,omitempty"`
+	PreviousMasterKeyFiles []string `yaml:"previous_master_key_files,omitempty"` // Keys being rotated out; secrets are re-encrypted at startup
 }
 
 type ServerDefinition struct {
-	Name           string                `yaml:"name"`
-	Hostname       string                `yaml:"hostname"`
-	IPAddress      string                `yaml:"ip_address"`
-	Port           int                   `yaml:"port"`
-	OS             string                `yaml:"os"`
-	MonitoringMode models.MonitoringMode `yaml:"monitoring_mode"`
-	SSHUser        string                `yaml:"ssh_user,omitempty"`
-	SSHKeyPath     string                `yaml:"ssh_key_path,omitempty"`
-	AgentToken     string                `yaml:"agent_token,omitempty"`
-	Enabled        bool                  `yaml:"enabled"`
-	NotifyTelegram bool                  `yaml:"notify_telegram"`
-	Services       []ServiceDefinition   `yaml:"services"`
+	Name                string                `yaml:"name"`
+	Hostname            string                `yaml:"hostname"`
+	IPAddress           string                `yaml:"ip_address"`
+	Port                int                   `yaml:"port"`
+	OS                  string                `yaml:"os"`
+	MonitoringMode      models.MonitoringMode `yaml:"monitoring_mode"`
+	SSHUser             string                `yaml:"ssh_user,omitempty"`
+	SSHKeyPath          string                `yaml:"ssh_key_path,omitempty"`
+	SSHKeySecret        string                `yaml:"ssh_key_secret,omitempty"`        // Vault secret holding the private key
+	SSHAuthMethod       models.SSHAuthMethod  `yaml:"ssh_auth_method,omitempty"`       // key_file, stored_key, password or agent
+	SSHPasswordSecret   string                `yaml:"ssh_password_secret,omitempty"`   // Vault secret holding the password
+	SSHPassphraseSecret string                `yaml:"ssh_passphrase_secret,omitempty"` // Vault secret holding the key passphrase
+	SSHAgentSocket      string                `yaml:"ssh_agent_socket,omitempty"`      // Defaults to $SSH_AUTH_SOCK
+	SSHJumpHosts        []models.JumpHost     `yaml:"ssh_jump_hosts,omitempty"`        // Jump chain, first hop first
+	Transport           models.Transport      `yaml:"transport,omitempty"`             // ssh (default) or winrm
+	WinRM               *models.WinRMConfig   `yaml:"winrm,omitempty"`
+	AgentToken          string                `yaml:"agent_token,omitempty"`
+	AgentTokenSecret    string                `yaml:"agent_token_secret,omitempty"` // Vault secret holding the agent token
+	Enabled             bool                  `yaml:"enabled"`
+	NotifyTelegram      bool                  `yaml:"notify_telegram"`
+	Services            []ServiceDefinition   `yaml:"services"`
 }
 
 type ServiceDefinition struct {
-	Name        string `yaml:"name"`
-	DisplayName string `yaml:"display_name"`
-	Description string `yaml:"description"`
-	Enabled     bool   `yaml:"enabled"`
+	Name        string             `yaml:"name"`
+	DisplayName string             `yaml:"display_name"`
+	Description string             `yaml:"description"`
+	Enabled     bool               `yaml:"enabled"`
+	Type        models.ServiceType `yaml:"type,omitempty"`
+	CheckFrom   models.CheckSource `yaml:"check_from,omitempty"`
+	HTTP        *models.HTTPCheck  `yaml:"http,omitempty"`
+	TLS         *models.TLSCheck   `yaml:"tls,omitempty"`
+	TCP         *models.TCPCheck   `yaml:"tcp,omitempty"`
+	Ping        *models.PingCheck  `yaml:"ping,omitempty"`
+
+	Reachability bool `yaml:"reachability,omitempty"` // The tcp or ping check tells whether the server is up
 }
 
 // LoadFromFile loads configuration from a YAML file
@@ -83,8 +118,14 @@ func LoadFromFile(path string) (*AppConfig, error) {
 	if config.Monitoring.RetentionDays == 0 {
 		config.Monitoring.RetentionDays = 30
 	}
-	if config.Monitoring.AlertCooldown == 0 {
-		config.Monitoring.AlertCooldown = 5 * time.Minute
+	if config.Notifications.MaxAttempts == 0 {
+		config.Notifications.MaxAttempts = 5
+	}
+	if config.Notifications.RetryBackoff == 0 {
+		config.Notifications.RetryBackoff = 10 * time.Second
+	}
+	if config.Notifications.MaxBackoff == 0 {
+		config.Notifications.MaxBackoff = 5 * time.Minute
 	}
 
 	return &config, nil
@@ -117,10 +158,14 @@ func GetDefaultConfig() *AppConfig {
 		Telegram: models.TelegramConfig{
 			Enabled: false,
 		},
+		Notifications: NotificationConfig{
+			MaxAttempts:  5,
+			RetryBackoff: 10 * time.Second,
+			MaxBackoff:   5 * time.Minute,
+		},
 		Monitoring: MonitoringConfig{
 			CheckInterval: 30 * time.Second,
 			RetentionDays: 30,
-			AlertCooldown: 5 * time.Minute,
 		},
 		Servers: []ServerDefinition{},
 	}
@@ -0,0 +1,170 @@
+package control
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/harungecit/vigilon/internal/database"
+	"github.com/harungecit/vigilon/internal/models"
+	"github.com/harungecit/vigilon/internal/monitor"
+)
+
+// DefaultAgentTimeout is how long to wait for an agent to complete a command.
+// Agents poll for commands every few seconds.
+const DefaultAgentTimeout = 60 * time.Second
+
+// pollInterval is how often the result of an agent command is polled
+const pollInterval = time.Second
+
+// Result is the outcome of a service action
+type Result struct {
+	Check  *models.ServiceCheck // Status of the service after the action
+	Output string               // Output of the restart command, if any
+}
+
+// Controller runs on-demand checks and restarts of services. Pull and hybrid
+// servers are reached over SSH or WinRM, push servers through their agent.
+// Every action is recorded in the audit log.
+type Controller struct {
+	db           *database.DB
+	monitor      *monitor.Monitor
+	agentTimeout time.Duration
+}
+
+// New creates a new controller
+func New(db *database.DB, mon *monitor.Monitor) *Controller {
+	return &Controller{
+		db:           db,
+		monitor:      mon,
+		agentTimeout: DefaultAgentTimeout,
+	}
+}
+
+// Run performs an action on a service on behalf of actor and audits it, even
+// if it is rejected. source names where the request came from, e.g. "telegram".
+func (c *Controller) Run(ctx context.Context, action models.ServiceAction, serviceID int, actor, source string) (*Result, error) {
+	target := fmt.Sprintf("service #%d", serviceID)
+	service, err := c.db.GetService(serviceID)
+	if err != nil {
+		err = fmt.Errorf("service not found")
+		c.audit(action, target, actor, source, nil, err)
+		return nil, err
+	}
+	target = fmt.Sprintf("server #%d/%s", service.ServerID, service.Name)
+	server, err := c.db.GetServer(service.ServerID)
+	if err != nil {
+		err = fmt.Errorf("server not found")
+		c.audit(action, target, actor, source, nil, err)
+		return nil, err
+	}
+	target = server.Name + "/" + service.Name
+
+	var result *Result
+	switch action {
+	case models.ActionCheck, models.ActionRestart:
+		result, err = c.run(ctx, action, server, service, actor)
+	default:
+		err = fmt.Errorf("unknown action %q", action)
+	}
+
+	c.audit(action, target, actor, source, result, err)
+	return result, err
+}
+
+// run performs an action over the transport of the server
+func (c *Controller) run(ctx context.Context, action models.ServiceAction, server *models.Server, service *models.Service, actor string) (*Result, error) {
+	result := &Result{}
+
+	if action == models.ActionRestart && service.IsEndpoint() {
+		return nil, fmt.Errorf("%s is a %s check and cannot be restarted", service.Name, service.Type)
+	}
+
+	if service.CheckedByAgent(server) {
+		cmd, err := c.runAgentCommand(ctx, action, server, service, actor)
+		if err != nil {
+			return nil, err
+		}
+		result.Output = cmd.Output
+		if cmd.Status == models.CommandFailed {
+			return result, fmt.Errorf("agent: %s", cmd.Output)
+		}
+	} else if action == models.ActionRestart {
+		output, err := c.monitor.Checker(server).RestartService(ctx, service.Name)
+		result.Output = output
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// The agent has reported the new status by now; anything else is checked
+	check, err := c.monitor.CheckService(ctx, server, service)
+	if err != nil {
+		return result, err
+	}
+	result.Check = check
+	return result, nil
+}
+
+// runAgentCommand queues a command for the agent of a push server and waits
+// until the agent reports the result
+func (c *Controller) runAgentCommand(ctx context.Context, action models.ServiceAction, server *models.Server, service *models.Service, actor string) (*models.AgentCommand, error) {
+	cmd := &models.AgentCommand{
+		ServerID:    server.ID,
+		ServiceID:   service.ID,
+		Service:     service.Name,
+		Action:      action,
+		RequestedBy: actor,
+	}
+	if err := c.db.CreateAgentCommand(cmd); err != nil {
+		return nil, fmt.Errorf("failed to queue agent command: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.agentTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Make sure a late agent does not act on a request nobody waits for
+			if err := c.db.ExpireAgentCommand(cmd.ID); err != nil {
+				log.Printf("Failed to expire agent command %d: %v", cmd.ID, err)
+			}
+			return nil, fmt.Errorf("agent on %s did not respond within %v", server.Name, c.agentTimeout)
+		case <-ticker.C:
+			current, err := c.db.GetAgentCommand(cmd.ID)
+			if err != nil {
+				return nil, err
+			}
+			if current.Done() {
+				return current, nil
+			}
+		}
+	}
+}
+
+// audit records an action and its outcome in the audit log. target is the
+// server and service, e.g. "web-1/nginx.service".
+func (c *Controller) audit(action models.ServiceAction, target, actor, source string, result *Result, err error) {
+	entry := &models.AuditEntry{
+		Actor:   actor,
+		Source:  source,
+		Action:  "service." + string(action),
+		Target:  target,
+		Success: err == nil,
+	}
+	switch {
+	case err != nil:
+		entry.Details = err.Error()
+	case result.Check != nil:
+		entry.Details = "status: " + string(result.Check.Status)
+	}
+
+	if err := c.db.CreateAuditEntry(entry); err != nil {
+		log.Printf("Failed to write audit log entry for %s on %s: %v", entry.Action, entry.Target, err)
+	}
+}
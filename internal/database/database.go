@@ -2,7 +2,9 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/harungecit/vigilon/internal/models"
@@ -62,17 +64,28 @@ func (db *DB) initSchema() error {
 		monitoring_mode TEXT NOT NULL CHECK(monitoring_mode IN ('pull', 'push', 'hybrid')),
 		ssh_user TEXT,
 		ssh_key_path TEXT,
+		ssh_key_secret TEXT DEFAULT '',
+		ssh_auth_method TEXT DEFAULT '',
+		ssh_password_secret TEXT DEFAULT '',
+		ssh_passphrase_secret TEXT DEFAULT '',
+		ssh_agent_socket TEXT DEFAULT '',
 		ssh_jump_host TEXT,
 		ssh_jump_user TEXT,
 		ssh_jump_key_path TEXT,
-		agent_token TEXT,
+		ssh_jump_hosts TEXT NOT NULL DEFAULT '[]',
+		transport TEXT DEFAULT '',
+		winrm TEXT DEFAULT '',
+		agent_token_hash TEXT DEFAULT '',
+		agent_token_secret TEXT DEFAULT '',
 		check_interval INTEGER DEFAULT 0,
 		connection_status TEXT DEFAULT 'not_connected' CHECK(connection_status IN ('not_connected', 'connected', 'idle', 'disconnected')),
 		enabled BOOLEAN DEFAULT 1,
 		last_seen DATETIME,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		notify_telegram BOOLEAN DEFAULT 1
+		notify_telegram BOOLEAN DEFAULT 1,
+		tags TEXT DEFAULT '',
+		escalation_policy_id INTEGER DEFAULT 0
 	);
 
 	CREATE TABLE IF NOT EXISTS services (
@@ -82,6 +95,18 @@ func (db *DB) initSchema() error {
 		display_name TEXT NOT NULL,
 		description TEXT,
 		enabled BOOLEAN DEFAULT 1,
+		failure_threshold INTEGER DEFAULT 1,
+		recovery_threshold INTEGER DEFAULT 1,
+		flap_threshold INTEGER DEFAULT 0,
+		flap_window INTEGER DEFAULT 600,
+		escalation_policy_id INTEGER DEFAULT 0,
+		type TEXT DEFAULT '',
+		check_from TEXT DEFAULT '',
+		http_check TEXT DEFAULT '',
+		tls_check TEXT DEFAULT '',
+		tcp_check TEXT DEFAULT '',
+		ping_check TEXT DEFAULT '',
+		reachability BOOLEAN DEFAULT 0,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		FOREIGN KEY (server_id) REFERENCES servers(id) ON DELETE CASCADE,
@@ -99,9 +124,77 @@ func (db *DB) initSchema() error {
 		memory_kb INTEGER,
 		cpu_percent REAL,
 		uptime_seconds INTEGER,
+		days_to_expiry INTEGER,
+		latency_ms REAL,
+		packet_loss REAL,
 		FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE
 	);
 
+	CREATE TABLE IF NOT EXISTS service_states (
+		service_id INTEGER PRIMARY KEY,
+		status TEXT NOT NULL,
+		since DATETIME NOT NULL,
+		open_alert_id INTEGER DEFAULT 0,
+		last_notified_at DATETIME,
+		reminders INTEGER DEFAULT 0,
+		pending_count INTEGER DEFAULT 0,
+		flapping BOOLEAN DEFAULT 0,
+		last_check_id INTEGER DEFAULT 0,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS maintenance_windows (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		reason TEXT DEFAULT '',
+		scope TEXT NOT NULL CHECK(scope IN ('server', 'service', 'tag')),
+		server_id INTEGER DEFAULT 0,
+		service_id INTEGER DEFAULT 0,
+		tag TEXT DEFAULT '',
+		starts_at DATETIME,
+		ends_at DATETIME,
+		schedule TEXT DEFAULT '',
+		duration INTEGER DEFAULT 0,
+		timezone TEXT DEFAULT '',
+		created_by TEXT DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS escalation_policies (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		description TEXT DEFAULT '',
+		steps TEXT NOT NULL DEFAULT '[]',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS oncall_schedules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		description TEXT DEFAULT '',
+		timezone TEXT DEFAULT '',
+		handoff_day INTEGER DEFAULT 1,
+		handoff_time TEXT DEFAULT '09:00',
+		rotation_start DATETIME NOT NULL,
+		user_ids TEXT NOT NULL DEFAULT '[]',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS oncall_overrides (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		schedule_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		starts_at DATETIME NOT NULL,
+		ends_at DATETIME NOT NULL,
+		reason TEXT DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (schedule_id) REFERENCES oncall_schedules(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
 	CREATE TABLE IF NOT EXISTS alerts (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		service_id INTEGER NOT NULL,
@@ -114,10 +207,146 @@ func (db *DB) initSchema() error {
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		acknowledged_at DATETIME,
 		archived_at DATETIME,
+		state TEXT DEFAULT 'open' CHECK(state IN ('open', 'resolved')),
+		resolved_at DATETIME,
+		downtime_seconds INTEGER DEFAULT 0,
+		escalation_policy_id INTEGER DEFAULT 0,
+		escalation_step INTEGER DEFAULT 0,
+		escalated_at DATETIME,
+		acknowledged_by TEXT DEFAULT '',
+		type TEXT NOT NULL DEFAULT 'service',
+		severity TEXT DEFAULT '',
 		FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE,
 		FOREIGN KEY (server_id) REFERENCES servers(id) ON DELETE CASCADE
 	);
 
+	CREATE TABLE IF NOT EXISTS alert_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		alert_id INTEGER NOT NULL,
+		event TEXT NOT NULL DEFAULT 'alert',
+		channel TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending' CHECK(status IN ('pending', 'sent', 'failed')),
+		attempts INTEGER DEFAULT 0,
+		last_error TEXT DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		sent_at DATETIME,
+		FOREIGN KEY (alert_id) REFERENCES alerts(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		url TEXT NOT NULL,
+		method TEXT DEFAULT 'POST',
+		content_type TEXT DEFAULT 'application/json',
+		headers TEXT NOT NULL DEFAULT '{}',
+		secret TEXT DEFAULT '',
+		signing_secret TEXT DEFAULT '',
+		signature_header TEXT DEFAULT 'X-Vigilon-Signature',
+		body_template TEXT DEFAULT '',
+		events TEXT NOT NULL DEFAULT '[]',
+		timeout INTEGER DEFAULT 10,
+		max_attempts INTEGER DEFAULT 3,
+		retry_backoff INTEGER DEFAULT 10,
+		enabled BOOLEAN DEFAULT 1,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		webhook_id INTEGER NOT NULL,
+		alert_id INTEGER DEFAULT 0,
+		event TEXT NOT NULL,
+		attempt INTEGER DEFAULT 1,
+		status_code INTEGER DEFAULT 0,
+		success BOOLEAN DEFAULT 0,
+		error TEXT DEFAULT '',
+		duration_ms INTEGER DEFAULT 0,
+		request_body TEXT DEFAULT '',
+		response_body TEXT DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS chat_channels (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		type TEXT NOT NULL CHECK(type IN ('slack', 'teams', 'teams_adaptive', 'discord')),
+		webhook_url TEXT NOT NULL,
+		server_ids TEXT NOT NULL DEFAULT '[]',
+		tags TEXT DEFAULT '',
+		enabled BOOLEAN DEFAULT 1,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS telegram_chats (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		chat_id TEXT NOT NULL UNIQUE,
+		name TEXT DEFAULT '',
+		server_ids TEXT NOT NULL DEFAULT '[]',
+		tags TEXT DEFAULT '',
+		severities TEXT NOT NULL DEFAULT '[]',
+		enabled BOOLEAN DEFAULT 1,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS agent_commands (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		server_id INTEGER NOT NULL,
+		service_id INTEGER NOT NULL,
+		service TEXT NOT NULL,
+		action TEXT NOT NULL CHECK(action IN ('check', 'restart')),
+		status TEXT NOT NULL DEFAULT 'pending' CHECK(status IN ('pending', 'running', 'succeeded', 'failed', 'expired')),
+		output TEXT DEFAULT '',
+		requested_by TEXT DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		completed_at DATETIME,
+		FOREIGN KEY (server_id) REFERENCES servers(id) ON DELETE CASCADE,
+		FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		actor TEXT NOT NULL,
+		source TEXT NOT NULL,
+		action TEXT NOT NULL,
+		target TEXT DEFAULT '',
+		success BOOLEAN DEFAULT 1,
+		details TEXT DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS ssh_host_keys (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		address TEXT NOT NULL UNIQUE,
+		key_type TEXT NOT NULL,
+		fingerprint TEXT NOT NULL,
+		public_key TEXT NOT NULL,
+		pending_key_type TEXT DEFAULT '',
+		pending_fingerprint TEXT DEFAULT '',
+		pending_public_key TEXT DEFAULT '',
+		pending_seen_at DATETIME,
+		trusted_by TEXT NOT NULL,
+		trusted_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS secrets (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		type TEXT NOT NULL CHECK(type IN ('ssh_key', 'password', 'token')),
+		description TEXT DEFAULT '',
+		ciphertext BLOB NOT NULL,
+		key_id TEXT NOT NULL,
+		created_by TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
 	CREATE TABLE IF NOT EXISTS config (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		key TEXT NOT NULL UNIQUE,
@@ -167,6 +396,16 @@ func (db *DB) initSchema() error {
 		FOREIGN KEY (permission_id) REFERENCES permissions(id) ON DELETE CASCADE
 	);
 
+	CREATE TABLE IF NOT EXISTS user_contact_methods (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		channel TEXT NOT NULL,
+		address TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		UNIQUE(user_id, channel, address)
+	);
+
 	CREATE TABLE IF NOT EXISTS sessions (
 		id TEXT PRIMARY KEY,
 		user_id INTEGER NOT NULL,
@@ -184,6 +423,9 @@ func (db *DB) initSchema() error {
 	CREATE INDEX IF NOT EXISTS idx_service_checks_checked_at ON service_checks(checked_at);
 	CREATE INDEX IF NOT EXISTS idx_alerts_acknowledged ON alerts(acknowledged);
 	CREATE INDEX IF NOT EXISTS idx_alerts_created_at ON alerts(created_at);
+	CREATE INDEX IF NOT EXISTS idx_alert_deliveries_alert_id ON alert_deliveries(alert_id);
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries(webhook_id);
+	CREATE INDEX IF NOT EXISTS idx_agent_commands_server_status ON agent_commands(server_id, status);
 	CREATE INDEX IF NOT EXISTS idx_users_username ON users(username);
 	CREATE INDEX IF NOT EXISTS idx_users_role_id ON users(role_id);
 	CREATE INDEX IF NOT EXISTS idx_sessions_token ON sessions(token);
@@ -218,14 +460,166 @@ func (db *DB) initSchema() error {
 	// Create index for archived column (will be ignored if already exists)
 	db.conn.Exec(`CREATE INDEX IF NOT EXISTS idx_alerts_archived ON alerts(archived);`)
 
+	// Migration: Add alert lifecycle columns. Alerts created before lifecycle
+	// tracking existed are closed so they don't trigger recovery notifications.
+	if !db.columnExists("alerts", "state") {
+		db.conn.Exec(`ALTER TABLE alerts ADD COLUMN state TEXT DEFAULT 'open' CHECK(state IN ('open', 'resolved'));`)
+		db.conn.Exec(`ALTER TABLE alerts ADD COLUMN resolved_at DATETIME;`)
+		db.conn.Exec(`ALTER TABLE alerts ADD COLUMN downtime_seconds INTEGER DEFAULT 0;`)
+		db.conn.Exec(`UPDATE alerts SET state = 'resolved';`)
+	}
+	db.conn.Exec(`CREATE INDEX IF NOT EXISTS idx_alerts_service_state ON alerts(service_id, state);`)
+
+	// Migration: Add event column to alert deliveries
+	db.addColumnIfMissing("alert_deliveries", "event", "TEXT NOT NULL DEFAULT 'alert'")
+
+	// Migration: Add alerting thresholds and flap detection to services
+	db.addColumnIfMissing("services", "failure_threshold", "INTEGER DEFAULT 1")
+	db.addColumnIfMissing("services", "recovery_threshold", "INTEGER DEFAULT 1")
+	db.addColumnIfMissing("services", "flap_threshold", "INTEGER DEFAULT 0")
+	db.addColumnIfMissing("services", "flap_window", "INTEGER DEFAULT 600")
+	db.addColumnIfMissing("service_states", "pending_count", "INTEGER DEFAULT 0")
+	db.addColumnIfMissing("service_states", "flapping", "BOOLEAN DEFAULT 0")
+	db.addColumnIfMissing("service_states", "last_check_id", "INTEGER DEFAULT 0")
+
+	// Migration: Add tags to servers
+	db.addColumnIfMissing("servers", "tags", "TEXT DEFAULT ''")
+
+	// Migration: Add escalation policies
+	db.addColumnIfMissing("servers", "escalation_policy_id", "INTEGER DEFAULT 0")
+	db.addColumnIfMissing("services", "escalation_policy_id", "INTEGER DEFAULT 0")
+	db.addColumnIfMissing("alerts", "escalation_policy_id", "INTEGER DEFAULT 0")
+	db.addColumnIfMissing("alerts", "escalation_step", "INTEGER DEFAULT 0")
+	db.addColumnIfMissing("alerts", "escalated_at", "DATETIME")
+	db.addColumnIfMissing("alerts", "acknowledged_by", "TEXT DEFAULT ''")
+
+	// Migration: Add multi-hop SSH jump chains
+	db.addColumnIfMissing("servers", "ssh_jump_hosts", "TEXT NOT NULL DEFAULT '[]'")
+
+	// Migration: Add alert types
+	db.addColumnIfMissing("alerts", "type", "TEXT NOT NULL DEFAULT 'service'")
+
+	// Migration: Add vault secret references
+	db.addColumnIfMissing("servers", "ssh_key_secret", "TEXT DEFAULT ''")
+
+	// Migration: Add SSH auth methods
+	db.addColumnIfMissing("servers", "ssh_auth_method", "TEXT DEFAULT ''")
+	db.addColumnIfMissing("servers", "ssh_password_secret", "TEXT DEFAULT ''")
+	db.addColumnIfMissing("servers", "ssh_passphrase_secret", "TEXT DEFAULT ''")
+	db.addColumnIfMissing("servers", "ssh_agent_socket", "TEXT DEFAULT ''")
+
+	// Migration: Add the WinRM transport
+	db.addColumnIfMissing("servers", "transport", "TEXT DEFAULT ''")
+	db.addColumnIfMissing("servers", "winrm", "TEXT DEFAULT ''")
+
+	// Migration: Add service types and http checks
+	db.addColumnIfMissing("services", "type", "TEXT DEFAULT ''")
+	db.addColumnIfMissing("services", "check_from", "TEXT DEFAULT ''")
+	db.addColumnIfMissing("services", "http_check", "TEXT DEFAULT ''")
+
+	// Migration: Add tls checks and certificate expiry alerts
+	db.addColumnIfMissing("services", "tls_check", "TEXT DEFAULT ''")
+	db.addColumnIfMissing("service_checks", "days_to_expiry", "INTEGER")
+	db.addColumnIfMissing("alerts", "severity", "TEXT DEFAULT ''")
+
+	// Migration: Add tcp and ping checks and server reachability
+	db.addColumnIfMissing("services", "tcp_check", "TEXT DEFAULT ''")
+	db.addColumnIfMissing("services", "ping_check", "TEXT DEFAULT ''")
+	db.addColumnIfMissing("services", "reachability", "BOOLEAN DEFAULT 0")
+	db.addColumnIfMissing("service_checks", "latency_ms", "REAL")
+	db.addColumnIfMissing("service_checks", "packet_loss", "REAL")
+
+	// Migration: Store agent tokens hashed and allow vault secrets for them,
+	// webhook signing keys included
+	if !db.columnExists("servers", "agent_token_hash") {
+		db.conn.Exec(`ALTER TABLE servers ADD COLUMN agent_token_hash TEXT DEFAULT '';`)
+		if err := db.hashAgentTokens(); err != nil {
+			return fmt.Errorf("failed to hash agent tokens: %w", err)
+		}
+	}
+	db.addColumnIfMissing("servers", "agent_token_secret", "TEXT DEFAULT ''")
+	db.addColumnIfMissing("webhooks", "signing_secret", "TEXT DEFAULT ''")
+
 	// Initialize default roles and permissions
 	if err := db.initializeAuthDefaults(); err != nil {
 		return fmt.Errorf("failed to initialize auth defaults: %w", err)
 	}
 
+	// Migration: Add permissions introduced after the initial setup
+	db.addPermissionIfMissing("services.control", "Control Services", "Restart services remotely", "services", 1, 2)
+	db.addPermissionIfMissing("servers.host_keys", "Trust Host Keys", "Review and re-trust SSH host keys", "servers", 1, 2)
+
+	return nil
+}
+
+// columnExists reports whether a table has the given column
+func (db *DB) columnExists(table, column string) bool {
+	var count int
+	query := `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`
+	db.conn.QueryRow(query, table, column).Scan(&count)
+	return count > 0
+}
+
+// addPermissionIfMissing creates a permission on databases initialized before
+// it existed and grants it to the given roles
+func (db *DB) addPermissionIfMissing(name, displayName, description, category string, roleIDs ...int) {
+	result, err := db.conn.Exec(`
+		INSERT OR IGNORE INTO permissions (name, display_name, description, category)
+		VALUES (?, ?, ?, ?)
+	`, name, displayName, description, category)
+	if err != nil {
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return // Already exists; keep whatever roles it was assigned to
+	}
+	for _, roleID := range roleIDs {
+		db.conn.Exec(`
+			INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+			SELECT ?, id FROM permissions WHERE name = ?
+		`, roleID, name)
+	}
+}
+
+// hashAgentTokens replaces the plaintext agent tokens of servers created
+// before tokens were stored hashed with their digest
+func (db *DB) hashAgentTokens() error {
+	rows, err := db.conn.Query(`SELECT id, agent_token FROM servers WHERE agent_token IS NOT NULL AND agent_token != ''`)
+	if err != nil {
+		return err
+	}
+	tokens := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var token string
+		if err := rows.Scan(&id, &token); err != nil {
+			rows.Close()
+			return err
+		}
+		tokens[id] = token
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, token := range tokens {
+		query := `UPDATE servers SET agent_token_hash = ?, agent_token = NULL WHERE id = ?`
+		if _, err := db.conn.Exec(query, models.HashAgentToken(token), id); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// addColumnIfMissing adds a column to an existing table if it doesn't exist yet
+func (db *DB) addColumnIfMissing(table, column, definition string) {
+	if db.columnExists(table, column) {
+		return
+	}
+	db.conn.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", table, column, definition))
+}
+
 // initializeAuthDefaults creates default roles, permissions and super admin user
 func (db *DB) initializeAuthDefaults() error {
 	// Check if roles already exist
@@ -245,6 +639,7 @@ func (db *DB) initializeAuthDefaults() error {
 		{"servers.edit", "Edit Servers", "Modify server settings", "servers"},
 		{"servers.delete", "Delete Servers", "Remove servers", "servers"},
 		{"servers.toggle", "Enable/Disable Servers", "Enable or disable server monitoring", "servers"},
+		{"servers.host_keys", "Trust Host Keys", "Review and re-trust SSH host keys", "servers"},
 
 		// Service permissions
 		{"services.view", "View Services", "View service list and details", "services"},
@@ -252,6 +647,7 @@ func (db *DB) initializeAuthDefaults() error {
 		{"services.edit", "Edit Services", "Modify service settings", "services"},
 		{"services.delete", "Delete Services", "Remove services", "services"},
 		{"services.toggle", "Enable/Disable Services", "Enable or disable service monitoring", "services"},
+		{"services.control", "Control Services", "Restart services remotely", "services"},
 
 		// Alert permissions
 		{"alerts.view", "View Alerts", "View alerts", "alerts"},
@@ -345,22 +741,40 @@ func (db *DB) initializeAuthDefaults() error {
 
 // Server operations
 
+// CreateServer stores a new server, with the hash of its agent token in
+// place of the token
 func (db *DB) CreateServer(server *models.Server) error {
 	// Set default connection status if empty
 	if server.ConnectionStatus == "" {
 		server.ConnectionStatus = models.ConnectionNotConnected
 	}
+	if server.AgentToken != "" {
+		server.AgentTokenHash = models.HashAgentToken(server.AgentToken)
+	}
+
+	jumpHosts, err := encodeJumpHosts(server.SSHJumpHosts)
+	if err != nil {
+		return err
+	}
+	winrm, err := encodeWinRM(server.WinRM)
+	if err != nil {
+		return err
+	}
 
 	query := `
 		INSERT INTO servers (name, hostname, ip_address, port, os, monitoring_mode,
-			ssh_user, ssh_key_path, ssh_jump_host, ssh_jump_user, ssh_jump_key_path,
-			agent_token, check_interval, connection_status, enabled, notify_telegram)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ssh_user, ssh_key_path, ssh_key_secret, ssh_auth_method, ssh_password_secret,
+			ssh_passphrase_secret, ssh_agent_socket, ssh_jump_host, ssh_jump_user, ssh_jump_key_path, ssh_jump_hosts,
+			transport, winrm, agent_token_hash, agent_token_secret, check_interval, connection_status, enabled,
+			notify_telegram, tags, escalation_policy_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 	result, err := db.conn.Exec(query, server.Name, server.Hostname, server.IPAddress,
-		server.Port, server.OS, server.MonitoringMode, server.SSHUser, server.SSHKeyPath,
-		server.SSHJumpHost, server.SSHJumpUser, server.SSHJumpKeyPath,
-		server.AgentToken, server.CheckInterval, server.ConnectionStatus, server.Enabled, server.NotifyTelegram)
+		server.Port, server.OS, server.MonitoringMode, server.SSHUser, server.SSHKeyPath, server.SSHKeySecret,
+		server.SSHAuthMethod, server.SSHPasswordSecret, server.SSHPassphraseSecret, server.SSHAgentSocket,
+		server.SSHJumpHost, server.SSHJumpUser, server.SSHJumpKeyPath, jumpHosts, server.Transport, winrm,
+		server.AgentTokenHash, server.AgentTokenSecret, server.CheckInterval, server.ConnectionStatus, server.Enabled,
+		server.NotifyTelegram, joinTags(server.Tags), server.EscalationPolicyID)
 	if err != nil {
 		return err
 	}
@@ -373,36 +787,64 @@ func (db *DB) CreateServer(server *models.Server) error {
 	return nil
 }
 
-func (db *DB) GetServer(id int) (*models.Server, error) {
-	query := `
-		SELECT id, name, hostname, ip_address, port, os, monitoring_mode,
-			ssh_user, ssh_key_path, ssh_jump_host, ssh_jump_user, ssh_jump_key_path,
-			agent_token, check_interval, connection_status, enabled, last_seen,
-			created_at, updated_at, notify_telegram
-		FROM servers WHERE id = ?
-	`
+const serverColumns = `id, name, hostname, ip_address, port, os, monitoring_mode,
+	ssh_user, ssh_key_path, COALESCE(ssh_key_secret, ''), COALESCE(ssh_auth_method, ''),
+	COALESCE(ssh_password_secret, ''), COALESCE(ssh_passphrase_secret, ''), COALESCE(ssh_agent_socket, ''), ssh_jump_host, ssh_jump_user, ssh_jump_key_path, ssh_jump_hosts,
+	COALESCE(transport, ''), COALESCE(winrm, ''), COALESCE(agent_token_hash, ''), COALESCE(agent_token_secret, ''), check_interval, connection_status, enabled, last_seen,
+	created_at, updated_at, notify_telegram, COALESCE(tags, ''), escalation_policy_id`
+
+func scanServer(row interface{ Scan(...any) error }) (*models.Server, error) {
 	server := &models.Server{}
-	err := db.conn.QueryRow(query, id).Scan(
+	var tags, jumpHosts, winrm string
+	err := row.Scan(
 		&server.ID, &server.Name, &server.Hostname, &server.IPAddress,
 		&server.Port, &server.OS, &server.MonitoringMode, &server.SSHUser,
-		&server.SSHKeyPath, &server.SSHJumpHost, &server.SSHJumpUser, &server.SSHJumpKeyPath,
-		&server.AgentToken, &server.CheckInterval, &server.ConnectionStatus, &server.Enabled, &server.LastSeen,
-		&server.CreatedAt, &server.UpdatedAt, &server.NotifyTelegram,
+		&server.SSHKeyPath, &server.SSHKeySecret, &server.SSHAuthMethod, &server.SSHPasswordSecret,
+		&server.SSHPassphraseSecret, &server.SSHAgentSocket, &server.SSHJumpHost, &server.SSHJumpUser, &server.SSHJumpKeyPath, &jumpHosts,
+		&server.Transport, &winrm, &server.AgentTokenHash, &server.AgentTokenSecret, &server.CheckInterval, &server.ConnectionStatus, &server.Enabled, &server.LastSeen,
+		&server.CreatedAt, &server.UpdatedAt, &server.NotifyTelegram, &tags, &server.EscalationPolicyID,
 	)
 	if err != nil {
 		return nil, err
 	}
+	server.Tags = splitTags(tags)
+	if err := json.Unmarshal([]byte(jumpHosts), &server.SSHJumpHosts); err != nil {
+		return nil, fmt.Errorf("invalid jump hosts for server %d: %w", server.ID, err)
+	}
+	if winrm != "" {
+		if err := json.Unmarshal([]byte(winrm), &server.WinRM); err != nil {
+			return nil, fmt.Errorf("invalid WinRM settings for server %d: %w", server.ID, err)
+		}
+	}
 	return server, nil
 }
 
+// encodeJumpHosts returns the JSON encoded jump chain of a server
+func encodeJumpHosts(hops []models.JumpHost) (string, error) {
+	if hops == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(hops)
+	return string(data), err
+}
+
+// encodeWinRM returns the JSON encoded WinRM settings of a server, empty if
+// it has none
+func encodeWinRM(cfg *models.WinRMConfig) (string, error) {
+	if cfg == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(cfg)
+	return string(data), err
+}
+
+func (db *DB) GetServer(id int) (*models.Server, error) {
+	query := `SELECT ` + serverColumns + ` FROM servers WHERE id = ?`
+	return scanServer(db.conn.QueryRow(query, id))
+}
+
 func (db *DB) GetAllServers() ([]*models.Server, error) {
-	query := `
-		SELECT id, name, hostname, ip_address, port, os, monitoring_mode,
-			ssh_user, ssh_key_path, ssh_jump_host, ssh_jump_user, ssh_jump_key_path,
-			agent_token, check_interval, connection_status, enabled, last_seen,
-			created_at, updated_at, notify_telegram
-		FROM servers ORDER BY name
-	`
+	query := `SELECT ` + serverColumns + ` FROM servers ORDER BY name`
 	rows, err := db.conn.Query(query)
 	if err != nil {
 		return nil, err
@@ -411,14 +853,7 @@ func (db *DB) GetAllServers() ([]*models.Server, error) {
 
 	var servers []*models.Server
 	for rows.Next() {
-		server := &models.Server{}
-		err := rows.Scan(
-			&server.ID, &server.Name, &server.Hostname, &server.IPAddress,
-			&server.Port, &server.OS, &server.MonitoringMode, &server.SSHUser,
-			&server.SSHKeyPath, &server.SSHJumpHost, &server.SSHJumpUser, &server.SSHJumpKeyPath,
-			&server.AgentToken, &server.CheckInterval, &server.ConnectionStatus, &server.Enabled, &server.LastSeen,
-			&server.CreatedAt, &server.UpdatedAt, &server.NotifyTelegram,
-		)
+		server, err := scanServer(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -427,21 +862,61 @@ func (db *DB) GetAllServers() ([]*models.Server, error) {
 	return servers, nil
 }
 
+// UpdateServer stores the settings of a server. The stored agent token is
+// kept unless the server carries a new one.
 func (db *DB) UpdateServer(server *models.Server) error {
+	if server.AgentToken != "" {
+		server.AgentTokenHash = models.HashAgentToken(server.AgentToken)
+	}
+	jumpHosts, err := encodeJumpHosts(server.SSHJumpHosts)
+	if err != nil {
+		return err
+	}
+	winrm, err := encodeWinRM(server.WinRM)
+	if err != nil {
+		return err
+	}
+
 	query := `
 		UPDATE servers SET name = ?, hostname = ?, ip_address = ?, port = ?, os = ?,
-			monitoring_mode = ?, ssh_user = ?, ssh_key_path = ?, ssh_jump_host = ?,
-			ssh_jump_user = ?, ssh_jump_key_path = ?, agent_token = ?, check_interval = ?,
-			connection_status = ?, enabled = ?, notify_telegram = ?, updated_at = CURRENT_TIMESTAMP
+			monitoring_mode = ?, ssh_user = ?, ssh_key_path = ?, ssh_key_secret = ?, ssh_auth_method = ?,
+			ssh_password_secret = ?, ssh_passphrase_secret = ?, ssh_agent_socket = ?, ssh_jump_host = ?,
+			ssh_jump_user = ?, ssh_jump_key_path = ?, ssh_jump_hosts = ?, transport = ?, winrm = ?,
+			agent_token_hash = COALESCE(NULLIF(?, ''), agent_token_hash), agent_token_secret = ?, check_interval = ?, connection_status = ?, enabled = ?, notify_telegram = ?, tags = ?, escalation_policy_id = ?,
+			updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?
 	`
-	_, err := db.conn.Exec(query, server.Name, server.Hostname, server.IPAddress,
-		server.Port, server.OS, server.MonitoringMode, server.SSHUser, server.SSHKeyPath,
-		server.SSHJumpHost, server.SSHJumpUser, server.SSHJumpKeyPath,
-		server.AgentToken, server.CheckInterval, server.ConnectionStatus, server.Enabled, server.NotifyTelegram, server.ID)
+	_, err = db.conn.Exec(query, server.Name, server.Hostname, server.IPAddress,
+		server.Port, server.OS, server.MonitoringMode, server.SSHUser, server.SSHKeyPath, server.SSHKeySecret,
+		server.SSHAuthMethod, server.SSHPasswordSecret, server.SSHPassphraseSecret, server.SSHAgentSocket,
+		server.SSHJumpHost, server.SSHJumpUser, server.SSHJumpKeyPath, jumpHosts, server.Transport, winrm,
+		server.AgentTokenHash, server.AgentTokenSecret, server.CheckInterval, server.ConnectionStatus, server.Enabled,
+		server.NotifyTelegram, joinTags(server.Tags), server.EscalationPolicyID, server.ID)
 	return err
 }
 
+// joinTags stores tags as a comma separated list
+func joinTags(tags []string) string {
+	cleaned := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			cleaned = append(cleaned, tag)
+		}
+	}
+	return strings.Join(cleaned, ",")
+}
+
+// splitTags parses a comma separated tag list
+func splitTags(s string) []string {
+	tags := []string{}
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
 func (db *DB) UpdateServerLastSeen(id int) error {
 	query := `UPDATE servers SET last_seen = ?, connection_status = 'connected' WHERE id = ?`
 	_, err := db.conn.Exec(query, time.Now(), id)
@@ -454,21 +929,1201 @@ func (db *DB) UpdateServerConnectionStatus(id int, status models.ConnectionStatu
 	return err
 }
 
-func (db *DB) DeleteServer(id int) error {
-	query := `DELETE FROM servers WHERE id = ?`
+func (db *DB) DeleteServer(id int) error {
+	query := `DELETE FROM servers WHERE id = ?`
+	_, err := db.conn.Exec(query, id)
+	return err
+}
+
+// Service operations
+
+func (db *DB) CreateService(service *models.Service) error {
+	service.ApplyDefaults()
+
+	httpCheck, err := encodeCheckSettings(service.HTTP)
+	if err != nil {
+		return err
+	}
+	tlsCheck, err := encodeCheckSettings(service.TLS)
+	if err != nil {
+		return err
+	}
+	tcpCheck, err := encodeCheckSettings(service.TCP)
+	if err != nil {
+		return err
+	}
+	pingCheck, err := encodeCheckSettings(service.Ping)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO services (server_id, name, display_name, description, enabled,
+			type, check_from, http_check, tls_check, tcp_check, ping_check, reachability,
+			failure_threshold, recovery_threshold, flap_threshold, flap_window, escalation_policy_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	result, err := db.conn.Exec(query, service.ServerID, service.Name,
+		service.DisplayName, service.Description, service.Enabled,
+		service.Type, service.CheckFrom, httpCheck, tlsCheck, tcpCheck, pingCheck, service.Reachability,
+		service.FailureThreshold, service.RecoveryThreshold, service.FlapThreshold, service.FlapWindow,
+		service.EscalationPolicyID)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	service.ID = int(id)
+	return nil
+}
+
+const serviceColumns = `id, server_id, name, display_name, description, enabled,
+	COALESCE(type, ''), COALESCE(check_from, ''), COALESCE(http_check, ''), COALESCE(tls_check, ''),
+	COALESCE(tcp_check, ''), COALESCE(ping_check, ''), COALESCE(reachability, 0),
+	failure_threshold, recovery_threshold, flap_threshold, flap_window, escalation_policy_id,
+	created_at, updated_at`
+
+func scanService(row interface{ Scan(...any) error }) (*models.Service, error) {
+	service := &models.Service{}
+	var httpCheck, tlsCheck, tcpCheck, pingCheck string
+	err := row.Scan(
+		&service.ID, &service.ServerID, &service.Name, &service.DisplayName,
+		&service.Description, &service.Enabled,
+		&service.Type, &service.CheckFrom, &httpCheck, &tlsCheck,
+		&tcpCheck, &pingCheck, &service.Reachability,
+		&service.FailureThreshold, &service.RecoveryThreshold, &service.FlapThreshold, &service.FlapWindow,
+		&service.EscalationPolicyID, &service.CreatedAt, &service.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if httpCheck != "" {
+		if err := json.Unmarshal([]byte(httpCheck), &service.HTTP); err != nil {
+			return nil, fmt.Errorf("invalid http check for service %d: %w", service.ID, err)
+		}
+	}
+	if tlsCheck != "" {
+		if err := json.Unmarshal([]byte(tlsCheck), &service.TLS); err != nil {
+			return nil, fmt.Errorf("invalid tls check for service %d: %w", service.ID, err)
+		}
+	}
+	if tcpCheck != "" {
+		if err := json.Unmarshal([]byte(tcpCheck), &service.TCP); err != nil {
+			return nil, fmt.Errorf("invalid tcp check for service %d: %w", service.ID, err)
+		}
+	}
+	if pingCheck != "" {
+		if err := json.Unmarshal([]byte(pingCheck), &service.Ping); err != nil {
+			return nil, fmt.Errorf("invalid ping check for service %d: %w", service.ID, err)
+		}
+	}
+	return service, nil
+}
+
+// encodeCheckSettings returns the JSON encoded settings of a check, empty if
+// the service has none
+func encodeCheckSettings[T any](cfg *T) (string, error) {
+	if cfg == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(cfg)
+	return string(data), err
+}
+
+func (db *DB) GetService(id int) (*models.Service, error) {
+	query := `SELECT ` + serviceColumns + ` FROM services WHERE id = ?`
+	return scanService(db.conn.QueryRow(query, id))
+}
+
+func (db *DB) GetServicesByServer(serverID int) ([]*models.Service, error) {
+	query := `SELECT ` + serviceColumns + ` FROM services WHERE server_id = ? ORDER BY name`
+	rows, err := db.conn.Query(query, serverID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var services []*models.Service
+	for rows.Next() {
+		service, err := scanService(rows)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, service)
+	}
+	return services, nil
+}
+
+func (db *DB) UpdateService(service *models.Service) error {
+	service.ApplyDefaults()
+
+	httpCheck, err := encodeCheckSettings(service.HTTP)
+	if err != nil {
+		return err
+	}
+	tlsCheck, err := encodeCheckSettings(service.TLS)
+	if err != nil {
+		return err
+	}
+	tcpCheck, err := encodeCheckSettings(service.TCP)
+	if err != nil {
+		return err
+	}
+	pingCheck, err := encodeCheckSettings(service.Ping)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE services SET name = ?, display_name = ?, description = ?,
+			enabled = ?, type = ?, check_from = ?, http_check = ?, tls_check = ?, tcp_check = ?, ping_check = ?,
+			reachability = ?, failure_threshold = ?, recovery_threshold = ?,
+			flap_threshold = ?, flap_window = ?, escalation_policy_id = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`
+	_, err = db.conn.Exec(query, service.Name, service.DisplayName,
+		service.Description, service.Enabled, service.Type, service.CheckFrom, httpCheck, tlsCheck,
+		tcpCheck, pingCheck, service.Reachability,
+		service.FailureThreshold, service.RecoveryThreshold,
+		service.FlapThreshold, service.FlapWindow, service.EscalationPolicyID, service.ID)
+	return err
+}
+
+func (db *DB) DeleteService(id int) error {
+	query := `DELETE FROM services WHERE id = ?`
+	_, err := db.conn.Exec(query, id)
+	return err
+}
+
+// ServiceCheck operations
+
+func (db *DB) CreateServiceCheck(check *models.ServiceCheck) error {
+	query := `
+		INSERT INTO service_checks (service_id, status, response_time_ms, error_message,
+			pid, memory_kb, cpu_percent, uptime_seconds, days_to_expiry, latency_ms, packet_loss)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	result, err := db.conn.Exec(query, check.ServiceID, check.Status, check.ResponseTime,
+		check.ErrorMessage, check.PID, check.Memory, check.CPU, check.Uptime, check.DaysToExpiry,
+		check.Latency, check.PacketLoss)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	check.ID = int(id)
+	return nil
+}
+
+// serviceCheckColumns lists the columns read by scanServiceCheck, in order
+const serviceCheckColumns = `id, service_id, status, response_time_ms, error_message, checked_at,
+	pid, memory_kb, cpu_percent, uptime_seconds, days_to_expiry, latency_ms, packet_loss`
+
+// scanServiceCheck scans a row selected with serviceCheckColumns
+func scanServiceCheck(row interface{ Scan(...any) error }) (*models.ServiceCheck, error) {
+	check := &models.ServiceCheck{}
+	err := row.Scan(
+		&check.ID, &check.ServiceID, &check.Status, &check.ResponseTime,
+		&check.ErrorMessage, &check.CheckedAt, &check.PID, &check.Memory,
+		&check.CPU, &check.Uptime, &check.DaysToExpiry, &check.Latency, &check.PacketLoss,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return check, nil
+}
+
+func (db *DB) GetLatestServiceCheck(serviceID int) (*models.ServiceCheck, error) {
+	query := `
+		SELECT ` + serviceCheckColumns + `
+		FROM service_checks WHERE service_id = ?
+		ORDER BY checked_at DESC LIMIT 1
+	`
+	return scanServiceCheck(db.conn.QueryRow(query, serviceID))
+}
+
+func (db *DB) GetServiceCheckHistory(serviceID int, limit int) ([]*models.ServiceCheck, error) {
+	query := `
+		SELECT ` + serviceCheckColumns + `
+		FROM service_checks WHERE service_id = ?
+		ORDER BY checked_at DESC LIMIT ?
+	`
+	rows, err := db.conn.Query(query, serviceID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checks []*models.ServiceCheck
+	for rows.Next() {
+		check, err := scanServiceCheck(rows)
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// ServiceState operations
+
+func (db *DB) GetServiceStates() ([]*models.ServiceState, error) {
+	query := `
+		SELECT service_id, status, since, open_alert_id, last_notified_at, reminders,
+			pending_count, flapping, last_check_id, updated_at
+		FROM service_states
+	`
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var states []*models.ServiceState
+	for rows.Next() {
+		state := &models.ServiceState{}
+		err := rows.Scan(
+			&state.ServiceID, &state.Status, &state.Since, &state.OpenAlertID,
+			&state.LastNotifiedAt, &state.Reminders, &state.PendingCount, &state.Flapping, &state.LastCheckID,
+			&state.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+func (db *DB) SaveServiceState(state *models.ServiceState) error {
+	query := `
+		INSERT INTO service_states (service_id, status, since, open_alert_id, last_notified_at, reminders,
+			pending_count, flapping, last_check_id, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(service_id) DO UPDATE SET status = excluded.status, since = excluded.since,
+			open_alert_id = excluded.open_alert_id, last_notified_at = excluded.last_notified_at,
+			reminders = excluded.reminders, pending_count = excluded.pending_count,
+			flapping = excluded.flapping, last_check_id = excluded.last_check_id, updated_at = CURRENT_TIMESTAMP
+	`
+	_, err := db.conn.Exec(query, state.ServiceID, state.Status, state.Since, state.OpenAlertID,
+		state.LastNotifiedAt, state.Reminders, state.PendingCount, state.Flapping, state.LastCheckID)
+	return err
+}
+
+// MaintenanceWindow operations
+
+const maintenanceColumns = `id, name, reason, scope, server_id, service_id, tag,
+	starts_at, ends_at, schedule, duration, timezone, created_by, created_at`
+
+func scanMaintenanceWindow(row interface{ Scan(...any) error }) (*models.MaintenanceWindow, error) {
+	window := &models.MaintenanceWindow{}
+	err := row.Scan(
+		&window.ID, &window.Name, &window.Reason, &window.Scope, &window.ServerID, &window.ServiceID,
+		&window.Tag, &window.StartsAt, &window.EndsAt, &window.Schedule, &window.Duration,
+		&window.Timezone, &window.CreatedBy, &window.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return window, nil
+}
+
+func (db *DB) CreateMaintenanceWindow(window *models.MaintenanceWindow) error {
+	query := `
+		INSERT INTO maintenance_windows (name, reason, scope, server_id, service_id, tag,
+			starts_at, ends_at, schedule, duration, timezone, created_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	result, err := db.conn.Exec(query, window.Name, window.Reason, window.Scope, window.ServerID,
+		window.ServiceID, window.Tag, window.StartsAt, window.EndsAt, window.Schedule,
+		window.Duration, window.Timezone, window.CreatedBy)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	window.ID = int(id)
+	window.CreatedAt = time.Now()
+	return nil
+}
+
+func (db *DB) GetMaintenanceWindow(id int) (*models.MaintenanceWindow, error) {
+	query := `SELECT ` + maintenanceColumns + ` FROM maintenance_windows WHERE id = ?`
+	return scanMaintenanceWindow(db.conn.QueryRow(query, id))
+}
+
+func (db *DB) GetMaintenanceWindows() ([]*models.MaintenanceWindow, error) {
+	query := `SELECT ` + maintenanceColumns + ` FROM maintenance_windows ORDER BY created_at DESC, id DESC`
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var windows []*models.MaintenanceWindow
+	for rows.Next() {
+		window, err := scanMaintenanceWindow(rows)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+
+func (db *DB) UpdateMaintenanceWindow(window *models.MaintenanceWindow) error {
+	query := `
+		UPDATE maintenance_windows SET name = ?, reason = ?, scope = ?, server_id = ?, service_id = ?,
+			tag = ?, starts_at = ?, ends_at = ?, schedule = ?, duration = ?, timezone = ?
+		WHERE id = ?
+	`
+	_, err := db.conn.Exec(query, window.Name, window.Reason, window.Scope, window.ServerID,
+		window.ServiceID, window.Tag, window.StartsAt, window.EndsAt, window.Schedule,
+		window.Duration, window.Timezone, window.ID)
+	return err
+}
+
+func (db *DB) DeleteMaintenanceWindow(id int) error {
+	query := `DELETE FROM maintenance_windows WHERE id = ?`
+	_, err := db.conn.Exec(query, id)
+	return err
+}
+
+// EscalationPolicy operations
+
+const escalationPolicyColumns = `id, name, description, steps, created_at, updated_at`
+
+func scanEscalationPolicy(row interface{ Scan(...any) error }) (*models.EscalationPolicy, error) {
+	policy := &models.EscalationPolicy{}
+	var steps string
+	err := row.Scan(&policy.ID, &policy.Name, &policy.Description, &steps, &policy.CreatedAt, &policy.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(steps), &policy.Steps); err != nil {
+		return nil, fmt.Errorf("invalid steps for escalation policy %d: %w", policy.ID, err)
+	}
+	return policy, nil
+}
+
+func (db *DB) CreateEscalationPolicy(policy *models.EscalationPolicy) error {
+	steps, err := json.Marshal(policy.Steps)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO escalation_policies (name, description, steps) VALUES (?, ?, ?)`
+	result, err := db.conn.Exec(query, policy.Name, policy.Description, string(steps))
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	policy.ID = int(id)
+	return nil
+}
+
+func (db *DB) GetEscalationPolicy(id int) (*models.EscalationPolicy, error) {
+	query := `SELECT ` + escalationPolicyColumns + ` FROM escalation_policies WHERE id = ?`
+	return scanEscalationPolicy(db.conn.QueryRow(query, id))
+}
+
+func (db *DB) GetEscalationPolicies() ([]*models.EscalationPolicy, error) {
+	query := `SELECT ` + escalationPolicyColumns + ` FROM escalation_policies ORDER BY name`
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var policies []*models.EscalationPolicy
+	for rows.Next() {
+		policy, err := scanEscalationPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+func (db *DB) UpdateEscalationPolicy(policy *models.EscalationPolicy) error {
+	steps, err := json.Marshal(policy.Steps)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE escalation_policies SET name = ?, description = ?, steps = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`
+	_, err = db.conn.Exec(query, policy.Name, policy.Description, string(steps), policy.ID)
+	return err
+}
+
+// DeleteEscalationPolicy removes a policy and detaches it from servers and services
+func (db *DB) DeleteEscalationPolicy(id int) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		`UPDATE servers SET escalation_policy_id = 0 WHERE escalation_policy_id = ?`,
+		`UPDATE services SET escalation_policy_id = 0 WHERE escalation_policy_id = ?`,
+		`UPDATE alerts SET escalation_policy_id = 0 WHERE escalation_policy_id = ?`,
+		`DELETE FROM escalation_policies WHERE id = ?`,
+	} {
+		if _, err := tx.Exec(query, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// OnCallSchedule operations
+
+const onCallScheduleColumns = `id, name, description, timezone, handoff_day, handoff_time,
+	rotation_start, user_ids, created_at, updated_at`
+
+func scanOnCallSchedule(row interface{ Scan(...any) error }) (*models.OnCallSchedule, error) {
+	schedule := &models.OnCallSchedule{}
+	var userIDs string
+	err := row.Scan(
+		&schedule.ID, &schedule.Name, &schedule.Description, &schedule.Timezone,
+		&schedule.HandoffDay, &schedule.HandoffTime, &schedule.RotationStart, &userIDs,
+		&schedule.CreatedAt, &schedule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(userIDs), &schedule.UserIDs); err != nil {
+		return nil, fmt.Errorf("invalid users for schedule %d: %w", schedule.ID, err)
+	}
+	return schedule, nil
+}
+
+func (db *DB) CreateOnCallSchedule(schedule *models.OnCallSchedule) error {
+	userIDs, err := json.Marshal(schedule.UserIDs)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO oncall_schedules (name, description, timezone, handoff_day, handoff_time,
+			rotation_start, user_ids)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+	result, err := db.conn.Exec(query, schedule.Name, schedule.Description, schedule.Timezone,
+		schedule.HandoffDay, schedule.HandoffTime, schedule.RotationStart, string(userIDs))
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	schedule.ID = int(id)
+	return nil
+}
+
+// GetOnCallSchedule returns a schedule including its overrides
+func (db *DB) GetOnCallSchedule(id int) (*models.OnCallSchedule, error) {
+	query := `SELECT ` + onCallScheduleColumns + ` FROM oncall_schedules WHERE id = ?`
+	schedule, err := scanOnCallSchedule(db.conn.QueryRow(query, id))
+	if err != nil {
+		return nil, err
+	}
+
+	schedule.Overrides, err = db.GetOnCallOverrides(id)
+	if err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// GetOnCallSchedules returns all schedules including their overrides
+func (db *DB) GetOnCallSchedules() ([]*models.OnCallSchedule, error) {
+	query := `SELECT ` + onCallScheduleColumns + ` FROM oncall_schedules ORDER BY name`
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []*models.OnCallSchedule
+	for rows.Next() {
+		schedule, err := scanOnCallSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for _, schedule := range schedules {
+		if schedule.Overrides, err = db.GetOnCallOverrides(schedule.ID); err != nil {
+			return nil, err
+		}
+	}
+	return schedules, nil
+}
+
+func (db *DB) UpdateOnCallSchedule(schedule *models.OnCallSchedule) error {
+	userIDs, err := json.Marshal(schedule.UserIDs)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE oncall_schedules SET name = ?, description = ?, timezone = ?, handoff_day = ?,
+			handoff_time = ?, rotation_start = ?, user_ids = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`
+	_, err = db.conn.Exec(query, schedule.Name, schedule.Description, schedule.Timezone,
+		schedule.HandoffDay, schedule.HandoffTime, schedule.RotationStart, string(userIDs), schedule.ID)
+	return err
+}
+
+func (db *DB) DeleteOnCallSchedule(id int) error {
+	query := `DELETE FROM oncall_schedules WHERE id = ?`
+	_, err := db.conn.Exec(query, id)
+	return err
+}
+
+func (db *DB) CreateOnCallOverride(override *models.OnCallOverride) error {
+	query := `
+		INSERT INTO oncall_overrides (schedule_id, user_id, starts_at, ends_at, reason)
+		VALUES (?, ?, ?, ?, ?)
+	`
+	result, err := db.conn.Exec(query, override.ScheduleID, override.UserID,
+		override.StartsAt, override.EndsAt, override.Reason)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	override.ID = int(id)
+	override.CreatedAt = time.Now()
+	return nil
+}
+
+func (db *DB) GetOnCallOverrides(scheduleID int) ([]models.OnCallOverride, error) {
+	query := `
+		SELECT id, schedule_id, user_id, starts_at, ends_at, reason, created_at
+		FROM oncall_overrides WHERE schedule_id = ? ORDER BY starts_at
+	`
+	rows, err := db.conn.Query(query, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var overrides []models.OnCallOverride
+	for rows.Next() {
+		var o models.OnCallOverride
+		if err := rows.Scan(&o.ID, &o.ScheduleID, &o.UserID, &o.StartsAt, &o.EndsAt, &o.Reason, &o.CreatedAt); err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, o)
+	}
+	return overrides, nil
+}
+
+func (db *DB) DeleteOnCallOverride(id int) error {
+	query := `DELETE FROM oncall_overrides WHERE id = ?`
+	_, err := db.conn.Exec(query, id)
+	return err
+}
+
+// ContactMethod operations
+
+func (db *DB) CreateContactMethod(method *models.ContactMethod) error {
+	query := `INSERT INTO user_contact_methods (user_id, channel, address) VALUES (?, ?, ?)`
+	result, err := db.conn.Exec(query, method.UserID, method.Channel, method.Address)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	method.ID = int(id)
+	method.CreatedAt = time.Now()
+	return nil
+}
+
+func (db *DB) GetContactMethods(userID int) ([]models.ContactMethod, error) {
+	query := `
+		SELECT id, user_id, channel, address, created_at
+		FROM user_contact_methods WHERE user_id = ? ORDER BY channel, id
+	`
+	rows, err := db.conn.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	methods := []models.ContactMethod{}
+	for rows.Next() {
+		var m models.ContactMethod
+		if err := rows.Scan(&m.ID, &m.UserID, &m.Channel, &m.Address, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		methods = append(methods, m)
+	}
+	return methods, nil
+}
+
+// GetUserByContactMethod returns the user owning a contact method, e.g. the
+// user linked to a Telegram account
+func (db *DB) GetUserByContactMethod(channel, address string) (*models.User, error) {
+	var userID int
+	query := `SELECT user_id FROM user_contact_methods WHERE channel = ? AND address = ? ORDER BY id LIMIT 1`
+	if err := db.conn.QueryRow(query, channel, address).Scan(&userID); err != nil {
+		return nil, err
+	}
+	return db.GetUser(userID)
+}
+
+// DeleteContactMethod removes a contact method of a user
+func (db *DB) DeleteContactMethod(userID, id int) error {
+	query := `DELETE FROM user_contact_methods WHERE id = ? AND user_id = ?`
+	_, err := db.conn.Exec(query, id, userID)
+	return err
+}
+
+// Webhook operations
+
+const webhookColumns = `id, name, url, method, content_type, headers, secret, COALESCE(signing_secret, ''), signature_header,
+	body_template, events, timeout, max_attempts, retry_backoff, enabled, created_at, updated_at`
+
+// webhookDeliveryLimit is the number of delivery attempts kept per webhook
+const webhookDeliveryLimit = 500
+
+func scanWebhook(row interface{ Scan(...any) error }) (*models.Webhook, error) {
+	hook := &models.Webhook{}
+	var headers, events string
+	err := row.Scan(
+		&hook.ID, &hook.Name, &hook.URL, &hook.Method, &hook.ContentType, &headers, &hook.Secret,
+		&hook.SigningSecret, &hook.SignatureHeader, &hook.BodyTemplate, &events, &hook.Timeout, &hook.MaxAttempts,
+		&hook.RetryBackoff, &hook.Enabled, &hook.CreatedAt, &hook.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(headers), &hook.Headers); err != nil {
+		return nil, fmt.Errorf("invalid headers for webhook %d: %w", hook.ID, err)
+	}
+	if err := json.Unmarshal([]byte(events), &hook.Events); err != nil {
+		return nil, fmt.Errorf("invalid events for webhook %d: %w", hook.ID, err)
+	}
+	hook.HasSecret = hook.Secret != ""
+	return hook, nil
+}
+
+// encodeWebhook returns the JSON encoded headers and events of a webhook
+func encodeWebhook(hook *models.Webhook) (string, string, error) {
+	headers, err := json.Marshal(hook.Headers)
+	if err != nil {
+		return "", "", err
+	}
+	if hook.Headers == nil {
+		headers = []byte("{}")
+	}
+	events, err := json.Marshal(hook.Events)
+	if err != nil {
+		return "", "", err
+	}
+	if hook.Events == nil {
+		events = []byte("[]")
+	}
+	return string(headers), string(events), nil
+}
+
+func (db *DB) CreateWebhook(hook *models.Webhook) error {
+	headers, events, err := encodeWebhook(hook)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO webhooks (name, url, method, content_type, headers, secret, signing_secret,
+			signature_header, body_template, events, timeout, max_attempts, retry_backoff, enabled)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	result, err := db.conn.Exec(query, hook.Name, hook.URL, hook.Method, hook.ContentType, headers,
+		hook.Secret, hook.SigningSecret, hook.SignatureHeader, hook.BodyTemplate, events, hook.Timeout,
+		hook.MaxAttempts, hook.RetryBackoff, hook.Enabled)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	hook.ID = int(id)
+	hook.HasSecret = hook.Secret != ""
+	return nil
+}
+
+func (db *DB) GetWebhook(id int) (*models.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = ?`
+	return scanWebhook(db.conn.QueryRow(query, id))
+}
+
+func (db *DB) GetWebhooks() ([]*models.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks ORDER BY name`
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hooks []*models.Webhook
+	for rows.Next() {
+		hook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, nil
+}
+
+func (db *DB) UpdateWebhook(hook *models.Webhook) error {
+	headers, events, err := encodeWebhook(hook)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE webhooks SET name = ?, url = ?, method = ?, content_type = ?, headers = ?, secret = ?,
+			signing_secret = ?, signature_header = ?, body_template = ?, events = ?, timeout = ?, max_attempts = ?,
+			retry_backoff = ?, enabled = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`
+	_, err = db.conn.Exec(query, hook.Name, hook.URL, hook.Method, hook.ContentType, headers,
+		hook.Secret, hook.SigningSecret, hook.SignatureHeader, hook.BodyTemplate, events, hook.Timeout,
+		hook.MaxAttempts, hook.RetryBackoff, hook.Enabled, hook.ID)
+	hook.HasSecret = hook.Secret != ""
+	return err
+}
+
+func (db *DB) DeleteWebhook(id int) error {
+	query := `DELETE FROM webhooks WHERE id = ?`
+	_, err := db.conn.Exec(query, id)
+	return err
+}
+
+// CreateWebhookDelivery records a delivery attempt and drops the oldest
+// attempts beyond webhookDeliveryLimit
+func (db *DB) CreateWebhookDelivery(delivery *models.WebhookDelivery) error {
+	query := `
+		INSERT INTO webhook_deliveries (webhook_id, alert_id, event, attempt, status_code, success,
+			error, duration_ms, request_body, response_body)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	result, err := db.conn.Exec(query, delivery.WebhookID, delivery.AlertID, delivery.Event,
+		delivery.Attempt, delivery.StatusCode, delivery.Success, delivery.Error, delivery.DurationMs,
+		delivery.RequestBody, delivery.ResponseBody)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	delivery.ID = int(id)
+	delivery.CreatedAt = time.Now()
+
+	_, err = db.conn.Exec(`
+		DELETE FROM webhook_deliveries WHERE webhook_id = ? AND id <= (
+			SELECT id FROM webhook_deliveries WHERE webhook_id = ? ORDER BY id DESC LIMIT 1 OFFSET ?
+		)
+	`, delivery.WebhookID, delivery.WebhookID, webhookDeliveryLimit)
+	return err
+}
+
+// GetWebhookDeliveries returns the most recent delivery attempts of a webhook
+func (db *DB) GetWebhookDeliveries(webhookID, limit int) ([]models.WebhookDelivery, error) {
+	query := `
+		SELECT id, webhook_id, alert_id, event, attempt, status_code, success, error, duration_ms,
+			request_body, response_body, created_at
+		FROM webhook_deliveries WHERE webhook_id = ? ORDER BY id DESC LIMIT ?
+	`
+	rows, err := db.conn.Query(query, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var d models.WebhookDelivery
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.AlertID, &d.Event, &d.Attempt, &d.StatusCode,
+			&d.Success, &d.Error, &d.DurationMs, &d.RequestBody, &d.ResponseBody, &d.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, nil
+}
+
+// ChatChannel operations
+
+const chatChannelColumns = `id, name, type, webhook_url, server_ids, tags, enabled, created_at, updated_at`
+
+func scanChatChannel(row interface{ Scan(...any) error }) (*models.ChatChannel, error) {
+	channel := &models.ChatChannel{}
+	var serverIDs, tags string
+	err := row.Scan(&channel.ID, &channel.Name, &channel.Type, &channel.WebhookURL, &serverIDs, &tags,
+		&channel.Enabled, &channel.CreatedAt, &channel.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(serverIDs), &channel.ServerIDs); err != nil {
+		return nil, fmt.Errorf("invalid servers for chat channel %d: %w", channel.ID, err)
+	}
+	channel.Tags = splitTags(tags)
+	return channel, nil
+}
+
+func (db *DB) CreateChatChannel(channel *models.ChatChannel) error {
+	serverIDs, err := json.Marshal(channel.ServerIDs)
+	if err != nil {
+		return err
+	}
+	if channel.ServerIDs == nil {
+		serverIDs = []byte("[]")
+	}
+
+	query := `
+		INSERT INTO chat_channels (name, type, webhook_url, server_ids, tags, enabled)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	result, err := db.conn.Exec(query, channel.Name, channel.Type, channel.WebhookURL,
+		string(serverIDs), joinTags(channel.Tags), channel.Enabled)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	channel.ID = int(id)
+	return nil
+}
+
+func (db *DB) GetChatChannel(id int) (*models.ChatChannel, error) {
+	query := `SELECT ` + chatChannelColumns + ` FROM chat_channels WHERE id = ?`
+	return scanChatChannel(db.conn.QueryRow(query, id))
+}
+
+func (db *DB) GetChatChannels() ([]*models.ChatChannel, error) {
+	query := `SELECT ` + chatChannelColumns + ` FROM chat_channels ORDER BY name`
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var channels []*models.ChatChannel
+	for rows.Next() {
+		channel, err := scanChatChannel(rows)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, channel)
+	}
+	return channels, nil
+}
+
+func (db *DB) UpdateChatChannel(channel *models.ChatChannel) error {
+	serverIDs, err := json.Marshal(channel.ServerIDs)
+	if err != nil {
+		return err
+	}
+	if channel.ServerIDs == nil {
+		serverIDs = []byte("[]")
+	}
+
+	query := `
+		UPDATE chat_channels SET name = ?, type = ?, webhook_url = ?, server_ids = ?, tags = ?,
+			enabled = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`
+	_, err = db.conn.Exec(query, channel.Name, channel.Type, channel.WebhookURL, string(serverIDs),
+		joinTags(channel.Tags), channel.Enabled, channel.ID)
+	return err
+}
+
+func (db *DB) DeleteChatChannel(id int) error {
+	query := `DELETE FROM chat_channels WHERE id = ?`
+	_, err := db.conn.Exec(query, id)
+	return err
+}
+
+// TelegramChat operations
+
+const telegramChatColumns = `id, chat_id, name, server_ids, tags, severities, enabled, created_at, updated_at`
+
+func scanTelegramChat(row interface{ Scan(...any) error }) (*models.TelegramChat, error) {
+	chat := &models.TelegramChat{}
+	var serverIDs, tags, severities string
+	err := row.Scan(&chat.ID, &chat.ChatID, &chat.Name, &serverIDs, &tags, &severities,
+		&chat.Enabled, &chat.CreatedAt, &chat.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(serverIDs), &chat.ServerIDs); err != nil {
+		return nil, fmt.Errorf("invalid servers for telegram chat %d: %w", chat.ID, err)
+	}
+	if err := json.Unmarshal([]byte(severities), &chat.Severities); err != nil {
+		return nil, fmt.Errorf("invalid severities for telegram chat %d: %w", chat.ID, err)
+	}
+	chat.Tags = splitTags(tags)
+	return chat, nil
+}
+
+// encodeTelegramChatLists JSON-encodes the server and severity lists of a chat
+func encodeTelegramChatLists(chat *models.TelegramChat) (string, string, error) {
+	serverIDs, severities := []byte("[]"), []byte("[]")
+	var err error
+	if chat.ServerIDs != nil {
+		if serverIDs, err = json.Marshal(chat.ServerIDs); err != nil {
+			return "", "", err
+		}
+	}
+	if chat.Severities != nil {
+		if severities, err = json.Marshal(chat.Severities); err != nil {
+			return "", "", err
+		}
+	}
+	return string(serverIDs), string(severities), nil
+}
+
+func (db *DB) CreateTelegramChat(chat *models.TelegramChat) error {
+	serverIDs, severities, err := encodeTelegramChatLists(chat)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO telegram_chats (chat_id, name, server_ids, tags, severities, enabled)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	result, err := db.conn.Exec(query, chat.ChatID, chat.Name, serverIDs,
+		joinTags(chat.Tags), severities, chat.Enabled)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	chat.ID = int(id)
+	return nil
+}
+
+func (db *DB) GetTelegramChat(id int) (*models.TelegramChat, error) {
+	query := `SELECT ` + telegramChatColumns + ` FROM telegram_chats WHERE id = ?`
+	return scanTelegramChat(db.conn.QueryRow(query, id))
+}
+
+// GetTelegramChatByChatID looks up a chat by its Telegram chat ID
+func (db *DB) GetTelegramChatByChatID(chatID string) (*models.TelegramChat, error) {
+	query := `SELECT ` + telegramChatColumns + ` FROM telegram_chats WHERE chat_id = ?`
+	return scanTelegramChat(db.conn.QueryRow(query, chatID))
+}
+
+func (db *DB) GetTelegramChats() ([]*models.TelegramChat, error) {
+	query := `SELECT ` + telegramChatColumns + ` FROM telegram_chats ORDER BY name, chat_id`
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chats []*models.TelegramChat
+	for rows.Next() {
+		chat, err := scanTelegramChat(rows)
+		if err != nil {
+			return nil, err
+		}
+		chats = append(chats, chat)
+	}
+	return chats, nil
+}
+
+func (db *DB) UpdateTelegramChat(chat *models.TelegramChat) error {
+	serverIDs, severities, err := encodeTelegramChatLists(chat)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE telegram_chats SET chat_id = ?, name = ?, server_ids = ?, tags = ?, severities = ?,
+			enabled = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`
+	_, err = db.conn.Exec(query, chat.ChatID, chat.Name, serverIDs, joinTags(chat.Tags),
+		severities, chat.Enabled, chat.ID)
+	return err
+}
+
+func (db *DB) DeleteTelegramChat(id int) error {
+	query := `DELETE FROM telegram_chats WHERE id = ?`
+	_, err := db.conn.Exec(query, id)
+	return err
+}
+
+// AgentCommand operations
+
+const agentCommandColumns = `id, server_id, service_id, service, action, status, output,
+	requested_by, created_at, completed_at`
+
+func scanAgentCommand(row interface{ Scan(...any) error }) (*models.AgentCommand, error) {
+	cmd := &models.AgentCommand{}
+	err := row.Scan(&cmd.ID, &cmd.ServerID, &cmd.ServiceID, &cmd.Service, &cmd.Action, &cmd.Status,
+		&cmd.Output, &cmd.RequestedBy, &cmd.CreatedAt, &cmd.CompletedAt)
+	if err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+func (db *DB) CreateAgentCommand(cmd *models.AgentCommand) error {
+	if cmd.Status == "" {
+		cmd.Status = models.CommandPending
+	}
+
+	query := `
+		INSERT INTO agent_commands (server_id, service_id, service, action, status, requested_by)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	result, err := db.conn.Exec(query, cmd.ServerID, cmd.ServiceID, cmd.Service, cmd.Action,
+		cmd.Status, cmd.RequestedBy)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	cmd.ID = int(id)
+	cmd.CreatedAt = time.Now()
+	return nil
+}
+
+func (db *DB) GetAgentCommand(id int) (*models.AgentCommand, error) {
+	query := `SELECT ` + agentCommandColumns + ` FROM agent_commands WHERE id = ?`
+	return scanAgentCommand(db.conn.QueryRow(query, id))
+}
+
+// ClaimAgentCommands returns the pending commands of a server and marks them
+// as running so they are handed out only once
+func (db *DB) ClaimAgentCommands(serverID int) ([]*models.AgentCommand, error) {
+	query := `
+		SELECT ` + agentCommandColumns + ` FROM agent_commands
+		WHERE server_id = ? AND status = 'pending' ORDER BY id
+	`
+	rows, err := db.conn.Query(query, serverID)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []*models.AgentCommand
+	for rows.Next() {
+		cmd, err := scanAgentCommand(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		pending = append(pending, cmd)
+	}
+	rows.Close()
+
+	var claimed []*models.AgentCommand
+	for _, cmd := range pending {
+		result, err := db.conn.Exec(`UPDATE agent_commands SET status = 'running' WHERE id = ? AND status = 'pending'`, cmd.ID)
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := result.RowsAffected(); n == 1 {
+			cmd.Status = models.CommandRunning
+			claimed = append(claimed, cmd)
+		}
+	}
+	return claimed, nil
+}
+
+// CompleteAgentCommand stores the result an agent reported for one of its
+// running commands
+func (db *DB) CompleteAgentCommand(id, serverID int, success bool, output string) error {
+	status := models.CommandFailed
+	if success {
+		status = models.CommandSucceeded
+	}
+
+	query := `
+		UPDATE agent_commands SET status = ?, output = ?, completed_at = ?
+		WHERE id = ? AND server_id = ? AND status = 'running'
+	`
+	result, err := db.conn.Exec(query, status, output, time.Now(), id, serverID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ExpireAgentCommand gives up on a command the agent has not completed. A
+// pending command will no longer be handed to the agent.
+func (db *DB) ExpireAgentCommand(id int) error {
+	query := `UPDATE agent_commands SET status = 'expired' WHERE id = ? AND status IN ('pending', 'running')`
 	_, err := db.conn.Exec(query, id)
 	return err
 }
 
-// Service operations
+// Audit log operations
 
-func (db *DB) CreateService(service *models.Service) error {
+func (db *DB) CreateAuditEntry(entry *models.AuditEntry) error {
 	query := `
-		INSERT INTO services (server_id, name, display_name, description, enabled)
-		VALUES (?, ?, ?, ?, ?)
+		INSERT INTO audit_log (actor, source, action, target, success, details)
+		VALUES (?, ?, ?, ?, ?, ?)
 	`
-	result, err := db.conn.Exec(query, service.ServerID, service.Name,
-		service.DisplayName, service.Description, service.Enabled)
+	result, err := db.conn.Exec(query, entry.Actor, entry.Source, entry.Action, entry.Target,
+		entry.Success, entry.Details)
 	if err != nil {
 		return err
 	}
@@ -477,150 +2132,163 @@ func (db *DB) CreateService(service *models.Service) error {
 	if err != nil {
 		return err
 	}
-	service.ID = int(id)
+	entry.ID = int(id)
+	entry.CreatedAt = time.Now()
 	return nil
 }
 
-func (db *DB) GetService(id int) (*models.Service, error) {
-	query := `
-		SELECT id, server_id, name, display_name, description, enabled,
-			created_at, updated_at
-		FROM services WHERE id = ?
-	`
-	service := &models.Service{}
-	err := db.conn.QueryRow(query, id).Scan(
-		&service.ID, &service.ServerID, &service.Name, &service.DisplayName,
-		&service.Description, &service.Enabled, &service.CreatedAt, &service.UpdatedAt,
-	)
-	if err != nil {
-		return nil, err
-	}
-	return service, nil
-}
-
-func (db *DB) GetServicesByServer(serverID int) ([]*models.Service, error) {
+// GetAuditEntries returns audit log entries, newest first
+func (db *DB) GetAuditEntries(limit, offset int) ([]*models.AuditEntry, error) {
 	query := `
-		SELECT id, server_id, name, display_name, description, enabled,
-			created_at, updated_at
-		FROM services WHERE server_id = ? ORDER BY name
+		SELECT id, actor, source, action, target, success, details, created_at
+		FROM audit_log ORDER BY id DESC LIMIT ? OFFSET ?
 	`
-	rows, err := db.conn.Query(query, serverID)
+	rows, err := db.conn.Query(query, limit, offset)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var services []*models.Service
+	var entries []*models.AuditEntry
 	for rows.Next() {
-		service := &models.Service{}
-		err := rows.Scan(
-			&service.ID, &service.ServerID, &service.Name, &service.DisplayName,
-			&service.Description, &service.Enabled, &service.CreatedAt, &service.UpdatedAt,
-		)
-		if err != nil {
+		e := &models.AuditEntry{}
+		if err := rows.Scan(&e.ID, &e.Actor, &e.Source, &e.Action, &e.Target, &e.Success,
+			&e.Details, &e.CreatedAt); err != nil {
 			return nil, err
 		}
-		services = append(services, service)
+		entries = append(entries, e)
 	}
-	return services, nil
+	return entries, nil
 }
 
-func (db *DB) UpdateService(service *models.Service) error {
-	query := `
-		UPDATE services SET name = ?, display_name = ?, description = ?,
-			enabled = ?, updated_at = CURRENT_TIMESTAMP
-		WHERE id = ?
-	`
-	_, err := db.conn.Exec(query, service.Name, service.DisplayName,
-		service.Description, service.Enabled, service.ID)
-	return err
-}
+// Host key operations
 
-func (db *DB) DeleteService(id int) error {
-	query := `DELETE FROM services WHERE id = ?`
-	_, err := db.conn.Exec(query, id)
-	return err
-}
+const hostKeyColumns = `id, address, key_type, fingerprint, public_key, pending_key_type,
+	pending_fingerprint, pending_public_key, pending_seen_at, trusted_by, trusted_at, created_at`
 
-// ServiceCheck operations
+func scanHostKey(row interface{ Scan(...any) error }) (*models.HostKey, error) {
+	key := &models.HostKey{}
+	err := row.Scan(&key.ID, &key.Address, &key.KeyType, &key.Fingerprint, &key.PublicKey,
+		&key.PendingKeyType, &key.PendingFingerprint, &key.PendingPublicKey, &key.PendingSeenAt,
+		&key.TrustedBy, &key.TrustedAt, &key.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
 
-func (db *DB) CreateServiceCheck(check *models.ServiceCheck) error {
+// CreateHostKey trusts the first key seen for an address. It fails if the
+// address already has a key.
+func (db *DB) CreateHostKey(key *models.HostKey) error {
+	key.TrustedAt = time.Now()
 	query := `
-		INSERT INTO service_checks (service_id, status, response_time_ms, error_message,
-			pid, memory_kb, cpu_percent, uptime_seconds)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO ssh_host_keys (address, key_type, fingerprint, public_key, trusted_by, trusted_at)
+		VALUES (?, ?, ?, ?, ?, ?)
 	`
-	result, err := db.conn.Exec(query, check.ServiceID, check.Status, check.ResponseTime,
-		check.ErrorMessage, check.PID, check.Memory, check.CPU, check.Uptime)
+	result, err := db.conn.Exec(query, key.Address, key.KeyType, key.Fingerprint, key.PublicKey,
+		key.TrustedBy, key.TrustedAt)
 	if err != nil {
 		return err
 	}
-
 	id, err := result.LastInsertId()
 	if err != nil {
 		return err
 	}
-	check.ID = int(id)
+	key.ID = int(id)
 	return nil
 }
 
-func (db *DB) GetLatestServiceCheck(serviceID int) (*models.ServiceCheck, error) {
-	query := `
-		SELECT id, service_id, status, response_time_ms, error_message, checked_at,
-			pid, memory_kb, cpu_percent, uptime_seconds
-		FROM service_checks WHERE service_id = ?
-		ORDER BY checked_at DESC LIMIT 1
-	`
-	check := &models.ServiceCheck{}
-	err := db.conn.QueryRow(query, serviceID).Scan(
-		&check.ID, &check.ServiceID, &check.Status, &check.ResponseTime,
-		&check.ErrorMessage, &check.CheckedAt, &check.PID, &check.Memory,
-		&check.CPU, &check.Uptime,
-	)
-	if err != nil {
-		return nil, err
-	}
-	return check, nil
+func (db *DB) GetHostKey(id int) (*models.HostKey, error) {
+	query := `SELECT ` + hostKeyColumns + ` FROM ssh_host_keys WHERE id = ?`
+	return scanHostKey(db.conn.QueryRow(query, id))
 }
 
-func (db *DB) GetServiceCheckHistory(serviceID int, limit int) ([]*models.ServiceCheck, error) {
-	query := `
-		SELECT id, service_id, status, response_time_ms, error_message, checked_at,
-			pid, memory_kb, cpu_percent, uptime_seconds
-		FROM service_checks WHERE service_id = ?
-		ORDER BY checked_at DESC LIMIT ?
-	`
-	rows, err := db.conn.Query(query, serviceID, limit)
+// GetHostKeyByAddress returns the key trusted for a host:port address
+func (db *DB) GetHostKeyByAddress(address string) (*models.HostKey, error) {
+	query := `SELECT ` + hostKeyColumns + ` FROM ssh_host_keys WHERE address = ?`
+	return scanHostKey(db.conn.QueryRow(query, address))
+}
+
+// GetHostKeys returns all host keys, those with a pending key first
+func (db *DB) GetHostKeys() ([]*models.HostKey, error) {
+	query := `SELECT ` + hostKeyColumns + ` FROM ssh_host_keys ORDER BY pending_fingerprint = '', address`
+	rows, err := db.conn.Query(query)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var checks []*models.ServiceCheck
+	var keys []*models.HostKey
 	for rows.Next() {
-		check := &models.ServiceCheck{}
-		err := rows.Scan(
-			&check.ID, &check.ServiceID, &check.Status, &check.ResponseTime,
-			&check.ErrorMessage, &check.CheckedAt, &check.PID, &check.Memory,
-			&check.CPU, &check.Uptime,
-		)
+		key, err := scanHostKey(rows)
 		if err != nil {
 			return nil, err
 		}
-		checks = append(checks, check)
+		keys = append(keys, key)
 	}
-	return checks, nil
+	return keys, nil
+}
+
+// SetPendingHostKey records a key that did not match the trusted one
+func (db *DB) SetPendingHostKey(id int, keyType, fingerprint, publicKey string) error {
+	query := `
+		UPDATE ssh_host_keys SET pending_key_type = ?, pending_fingerprint = ?, pending_public_key = ?,
+			pending_seen_at = ?
+		WHERE id = ?
+	`
+	_, err := db.conn.Exec(query, keyType, fingerprint, publicKey, time.Now(), id)
+	return err
+}
+
+// TrustPendingHostKey replaces the trusted key with the pending one. The
+// fingerprint must match the pending key, so a key that changed again after
+// it was reviewed is not trusted by accident. It returns sql.ErrNoRows if
+// nothing was updated.
+func (db *DB) TrustPendingHostKey(id int, fingerprint, by string) error {
+	query := `
+		UPDATE ssh_host_keys SET key_type = pending_key_type, fingerprint = pending_fingerprint,
+			public_key = pending_public_key, pending_key_type = '', pending_fingerprint = '',
+			pending_public_key = '', pending_seen_at = NULL, trusted_by = ?, trusted_at = ?
+		WHERE id = ? AND pending_fingerprint != '' AND pending_fingerprint = ?
+	`
+	result, err := db.conn.Exec(query, by, time.Now(), id, fingerprint)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteHostKey forgets the key of an address; the next connection trusts
+// whatever key the host presents
+func (db *DB) DeleteHostKey(id int) error {
+	_, err := db.conn.Exec(`DELETE FROM ssh_host_keys WHERE id = ?`, id)
+	return err
 }
 
 // Alert operations
 
 func (db *DB) CreateAlert(alert *models.Alert) error {
+	if alert.State == "" {
+		alert.State = models.AlertOpen
+	}
+	if alert.Type == "" {
+		alert.Type = models.AlertService
+	}
+
+	// Set here rather than by the column default, as the alert is notified
+	// about right away
+	now := time.Now().UTC()
+
 	query := `
-		INSERT INTO alerts (service_id, server_id, status, message, sent_via)
-		VALUES (?, ?, ?, ?, ?)
+		INSERT INTO alerts (type, service_id, server_id, status, message, sent_via, state, escalation_policy_id, severity,
+			created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
-	result, err := db.conn.Exec(query, alert.ServiceID, alert.ServerID,
-		alert.Status, alert.Message, alert.SentVia)
+	result, err := db.conn.Exec(query, alert.Type, alert.ServiceID, alert.ServerID,
+		alert.Status, alert.Message, alert.SentVia, alert.State, alert.EscalationPolicyID, alert.Level, now)
 	if err != nil {
 		return err
 	}
@@ -630,6 +2298,7 @@ func (db *DB) CreateAlert(alert *models.Alert) error {
 		return err
 	}
 	alert.ID = int(id)
+	alert.CreatedAt = now
 	return nil
 }
 
@@ -639,11 +2308,38 @@ func (db *DB) GetRecentAlerts(limit int) ([]*models.Alert, error) {
 
 func (db *DB) GetRecentAlertsWithOffset(limit, offset int) ([]*models.Alert, error) {
 	query := `
-		SELECT id, service_id, server_id, status, message, sent_via,
-			acknowledged, archived, created_at, acknowledged_at, archived_at
+		SELECT ` + alertColumns + `
 		FROM alerts WHERE archived = 0 ORDER BY created_at DESC LIMIT ? OFFSET ?
 	`
-	rows, err := db.conn.Query(query, limit, offset)
+	return db.queryAlerts(query, limit, offset)
+}
+
+// alertColumns lists the columns read by scanAlert, in order
+const alertColumns = `id, service_id, server_id, status, message, sent_via,
+	acknowledged, archived, created_at, acknowledged_at, archived_at,
+	state, resolved_at, downtime_seconds, escalation_policy_id, escalation_step, escalated_at,
+	acknowledged_by, type, COALESCE(severity, '')`
+
+// scanAlert scans a row selected with alertColumns
+func scanAlert(row interface{ Scan(...any) error }) (*models.Alert, error) {
+	alert := &models.Alert{}
+	err := row.Scan(
+		&alert.ID, &alert.ServiceID, &alert.ServerID, &alert.Status,
+		&alert.Message, &alert.SentVia, &alert.Acknowledged, &alert.Archived,
+		&alert.CreatedAt, &alert.AcknowledgedAt, &alert.ArchivedAt,
+		&alert.State, &alert.ResolvedAt, &alert.DowntimeSecs,
+		&alert.EscalationPolicyID, &alert.EscalationStep, &alert.EscalatedAt,
+		&alert.AcknowledgedBy, &alert.Type, &alert.Level,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return alert, nil
+}
+
+// queryAlerts runs a query selecting alertColumns and scans every row
+func (db *DB) queryAlerts(query string, args ...any) ([]*models.Alert, error) {
+	rows, err := db.conn.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -651,12 +2347,7 @@ func (db *DB) GetRecentAlertsWithOffset(limit, offset int) ([]*models.Alert, err
 
 	var alerts []*models.Alert
 	for rows.Next() {
-		alert := &models.Alert{}
-		err := rows.Scan(
-			&alert.ID, &alert.ServiceID, &alert.ServerID, &alert.Status,
-			&alert.Message, &alert.SentVia, &alert.Acknowledged, &alert.Archived,
-			&alert.CreatedAt, &alert.AcknowledgedAt, &alert.ArchivedAt,
-		)
+		alert, err := scanAlert(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -665,9 +2356,84 @@ func (db *DB) GetRecentAlertsWithOffset(limit, offset int) ([]*models.Alert, err
 	return alerts, nil
 }
 
-func (db *DB) AcknowledgeAlert(id int) error {
-	query := `UPDATE alerts SET acknowledged = 1, acknowledged_at = ? WHERE id = ?`
-	_, err := db.conn.Exec(query, time.Now(), id)
+// GetAlert returns an alert with the delivery results of its notifications
+func (db *DB) GetAlert(id int) (*models.Alert, error) {
+	query := `SELECT ` + alertColumns + ` FROM alerts WHERE id = ?`
+	alert, err := scanAlert(db.conn.QueryRow(query, id))
+	if err != nil {
+		return nil, err
+	}
+
+	alert.Deliveries, err = db.GetAlertDeliveries(alert.ID)
+	if err != nil {
+		return nil, err
+	}
+	return alert, nil
+}
+
+// GetOpenAlertsForService returns the unresolved service alerts of a
+// service, oldest first
+func (db *DB) GetOpenAlertsForService(serviceID int) ([]*models.Alert, error) {
+	query := `
+		SELECT ` + alertColumns + `
+		FROM alerts WHERE service_id = ? AND state = 'open' AND type = 'service' ORDER BY created_at, id
+	`
+	return db.queryAlerts(query, serviceID)
+}
+
+// GetOpenAlertsByType returns the unresolved alerts of a type, oldest first
+func (db *DB) GetOpenAlertsByType(alertType models.AlertType) ([]*models.Alert, error) {
+	query := `
+		SELECT ` + alertColumns + `
+		FROM alerts WHERE type = ? AND state = 'open' ORDER BY created_at, id
+	`
+	return db.queryAlerts(query, alertType)
+}
+
+// GetDigestAlerts returns the alerts that are still open or were created or
+// resolved since the given time, oldest first
+func (db *DB) GetDigestAlerts(since time.Time) ([]*models.Alert, error) {
+	query := `
+		SELECT ` + alertColumns + `
+		FROM alerts
+		WHERE archived = 0 AND (state = 'open' OR datetime(created_at) >= datetime(?) OR datetime(resolved_at) >= datetime(?))
+		ORDER BY created_at, id
+	`
+	return db.queryAlerts(query, since.UTC(), since.UTC())
+}
+
+// ResolveAlert closes an open alert and records how long the outage lasted
+func (db *DB) ResolveAlert(id int, resolvedAt time.Time, downtime time.Duration) error {
+	query := `
+		UPDATE alerts SET state = 'resolved', resolved_at = ?, downtime_seconds = ?
+		WHERE id = ? AND state = 'open'
+	`
+	_, err := db.conn.Exec(query, resolvedAt, int64(downtime.Seconds()), id)
+	return err
+}
+
+// GetEscalatingAlerts returns open, unacknowledged alerts with an escalation policy
+func (db *DB) GetEscalatingAlerts() ([]*models.Alert, error) {
+	query := `
+		SELECT ` + alertColumns + `
+		FROM alerts
+		WHERE state = 'open' AND acknowledged = 0 AND archived = 0 AND escalation_policy_id != 0
+		ORDER BY created_at, id
+	`
+	return db.queryAlerts(query)
+}
+
+// SetAlertEscalation records that an alert reached an escalation step
+func (db *DB) SetAlertEscalation(id, step int, escalatedAt time.Time) error {
+	query := `UPDATE alerts SET escalation_step = ?, escalated_at = ? WHERE id = ?`
+	_, err := db.conn.Exec(query, step, escalatedAt, id)
+	return err
+}
+
+// AcknowledgeAlert marks an alert as acknowledged by the given user
+func (db *DB) AcknowledgeAlert(id int, by string) error {
+	query := `UPDATE alerts SET acknowledged = 1, acknowledged_at = ?, acknowledged_by = ? WHERE id = ?`
+	_, err := db.conn.Exec(query, time.Now(), by, id)
 	return err
 }
 
@@ -685,35 +2451,118 @@ func (db *DB) ArchiveAllAlerts() error {
 
 func (db *DB) GetArchivedAlerts(limit, offset int) ([]*models.Alert, error) {
 	query := `
-		SELECT id, service_id, server_id, status, message, sent_via,
-			acknowledged, archived, created_at, acknowledged_at, archived_at
+		SELECT ` + alertColumns + `
 		FROM alerts WHERE archived = 1 ORDER BY archived_at DESC LIMIT ? OFFSET ?
 	`
-	rows, err := db.conn.Query(query, limit, offset)
+	return db.queryAlerts(query, limit, offset)
+}
+
+func (db *DB) UnarchiveAlert(id int) error {
+	query := `UPDATE alerts SET archived = 0, archived_at = NULL WHERE id = ?`
+	_, err := db.conn.Exec(query, id)
+	return err
+}
+
+// Alert delivery operations
+
+func (db *DB) CreateAlertDelivery(delivery *models.AlertDelivery) error {
+	if delivery.Status == "" {
+		delivery.Status = models.DeliveryPending
+	}
+	if delivery.Event == "" {
+		delivery.Event = "alert"
+	}
+
+	query := `
+		INSERT INTO alert_deliveries (alert_id, event, channel, status, attempts, last_error)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	result, err := db.conn.Exec(query, delivery.AlertID, delivery.Event, delivery.Channel,
+		delivery.Status, delivery.Attempts, delivery.LastError)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	delivery.ID = int(id)
+	return nil
+}
+
+func (db *DB) UpdateAlertDelivery(delivery *models.AlertDelivery) error {
+	query := `
+		UPDATE alert_deliveries SET status = ?, attempts = ?, last_error = ?, sent_at = ?,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`
+	_, err := db.conn.Exec(query, delivery.Status, delivery.Attempts, delivery.LastError,
+		delivery.SentAt, delivery.ID)
+	return err
+}
+
+func (db *DB) GetAlertDeliveries(alertID int) ([]models.AlertDelivery, error) {
+	query := `
+		SELECT id, alert_id, event, channel, status, attempts, last_error, created_at, updated_at, sent_at
+		FROM alert_deliveries WHERE alert_id = ? ORDER BY id
+	`
+	rows, err := db.conn.Query(query, alertID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var alerts []*models.Alert
+	var deliveries []models.AlertDelivery
 	for rows.Next() {
-		alert := &models.Alert{}
+		delivery := models.AlertDelivery{}
 		err := rows.Scan(
-			&alert.ID, &alert.ServiceID, &alert.ServerID, &alert.Status,
-			&alert.Message, &alert.SentVia, &alert.Acknowledged, &alert.Archived,
-			&alert.CreatedAt, &alert.AcknowledgedAt, &alert.ArchivedAt,
+			&delivery.ID, &delivery.AlertID, &delivery.Event, &delivery.Channel, &delivery.Status,
+			&delivery.Attempts, &delivery.LastError, &delivery.CreatedAt, &delivery.UpdatedAt,
+			&delivery.SentAt,
 		)
 		if err != nil {
 			return nil, err
 		}
-		alerts = append(alerts, alert)
+		deliveries = append(deliveries, delivery)
 	}
-	return alerts, nil
+	return deliveries, nil
 }
 
-func (db *DB) UnarchiveAlert(id int) error {
-	query := `UPDATE alerts SET archived = 0, archived_at = NULL WHERE id = ?`
-	_, err := db.conn.Exec(query, id)
+// GetAnnouncedChannels returns the channels that delivered an alert, including
+// deliveries that are still being retried
+func (db *DB) GetAnnouncedChannels(alertID int) ([]string, error) {
+	query := `
+		SELECT DISTINCT channel FROM alert_deliveries
+		WHERE alert_id = ? AND event IN ('alert', 'escalation') AND status IN ('sent', 'pending')
+		ORDER BY channel
+	`
+	rows, err := db.conn.Query(query, alertID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var channels []string
+	for rows.Next() {
+		var channel string
+		if err := rows.Scan(&channel); err != nil {
+			return nil, err
+		}
+		channels = append(channels, channel)
+	}
+	return channels, nil
+}
+
+// RefreshAlertSentVia sets sent_via to the channels that actually delivered the alert
+func (db *DB) RefreshAlertSentVia(alertID int) error {
+	query := `
+		UPDATE alerts SET sent_via = COALESCE(
+			(SELECT group_concat(DISTINCT channel) FROM alert_deliveries
+			 WHERE alert_id = ? AND event = 'alert' AND status = 'sent'), 'none')
+		WHERE id = ?
+	`
+	_, err := db.conn.Exec(query, alertID, alertID)
 	return err
 }
 
@@ -1142,3 +2991,87 @@ func (db *DB) DeleteExpiredSessions() error {
 	_, err := db.conn.Exec(query, time.Now())
 	return err
 }
+
+// Secret operations
+
+const secretColumns = `id, name, type, description, ciphertext, key_id, created_by, created_at, updated_at`
+
+func scanSecret(row interface{ Scan(...any) error }) (*models.Secret, error) {
+	secret := &models.Secret{}
+	err := row.Scan(&secret.ID, &secret.Name, &secret.Type, &secret.Description, &secret.Ciphertext,
+		&secret.KeyID, &secret.CreatedBy, &secret.CreatedAt, &secret.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+func (db *DB) CreateSecret(secret *models.Secret) error {
+	now := time.Now()
+	query := `
+		INSERT INTO secrets (name, type, description, ciphertext, key_id, created_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	result, err := db.conn.Exec(query, secret.Name, secret.Type, secret.Description, secret.Ciphertext,
+		secret.KeyID, secret.CreatedBy, now, now)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	secret.ID = int(id)
+	secret.CreatedAt, secret.UpdatedAt = now, now
+	return nil
+}
+
+func (db *DB) GetSecret(id int) (*models.Secret, error) {
+	query := `SELECT ` + secretColumns + ` FROM secrets WHERE id = ?`
+	return scanSecret(db.conn.QueryRow(query, id))
+}
+
+func (db *DB) GetSecretByName(name string) (*models.Secret, error) {
+	query := `SELECT ` + secretColumns + ` FROM secrets WHERE name = ?`
+	return scanSecret(db.conn.QueryRow(query, name))
+}
+
+func (db *DB) GetSecrets() ([]*models.Secret, error) {
+	rows, err := db.conn.Query(`SELECT ` + secretColumns + ` FROM secrets ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var secrets []*models.Secret
+	for rows.Next() {
+		secret, err := scanSecret(rows)
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, secret)
+	}
+	return secrets, rows.Err()
+}
+
+// UpdateSecret stores a new description and value of a secret. The name and
+// type cannot change, as the value is bound to the name.
+func (db *DB) UpdateSecret(secret *models.Secret) error {
+	secret.UpdatedAt = time.Now()
+	query := `UPDATE secrets SET description = ?, ciphertext = ?, key_id = ?, updated_at = ? WHERE id = ?`
+	_, err := db.conn.Exec(query, secret.Description, secret.Ciphertext, secret.KeyID, secret.UpdatedAt, secret.ID)
+	return err
+}
+
+// RekeySecret replaces the ciphertext of a secret encrypted under another
+// master key, unless the secret changed in the meantime
+func (db *DB) RekeySecret(id int, oldKeyID string, ciphertext []byte, keyID string) error {
+	query := `UPDATE secrets SET ciphertext = ?, key_id = ? WHERE id = ? AND key_id = ?`
+	_, err := db.conn.Exec(query, ciphertext, keyID, id, oldKeyID)
+	return err
+}
+
+func (db *DB) DeleteSecret(id int) error {
+	_, err := db.conn.Exec(`DELETE FROM secrets WHERE id = ?`, id)
+	return err
+}
@@ -0,0 +1,570 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/harungecit/vigilon/internal/database"
+	"github.com/harungecit/vigilon/internal/models"
+	"github.com/harungecit/vigilon/internal/notify"
+)
+
+// TLS modes
+const (
+	TLSStartTLS = "starttls" // Plain connection upgraded with STARTTLS (default)
+	TLSImplicit = "tls"      // TLS from the first byte (SMTPS)
+	TLSNone     = "none"     // No encryption, e.g. for a local SMTP sink
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+// Template names
+const (
+	templateAlert    = "alert"
+	templateRecovery = "recovery"
+	templateDigest   = "digest"
+)
+
+// Notifier sends notifications by email
+type Notifier struct {
+	config    *models.EmailConfig
+	db        *database.DB
+	publicURL string
+	html      map[string]*htmltemplate.Template
+	text      map[string]*texttemplate.Template
+}
+
+// messageData is passed to the alert and recovery templates
+type messageData struct {
+	Event   notify.EventType
+	Title   string
+	Message string
+	Color   string
+	Alert   *models.Alert
+	Server  *models.Server
+	Service *models.Service
+	Check   *models.ServiceCheck
+
+	ServiceName string // Display name of the service, falling back to its name
+	Downtime    string
+	URL         string
+	Time        time.Time
+}
+
+// digestData is passed to the digest templates
+type digestData struct {
+	Since    time.Time
+	Until    time.Time
+	Open     []digestItem
+	Opened   []digestItem
+	Resolved []digestItem
+	URL      string
+}
+
+type digestItem struct {
+	Alert       *models.Alert
+	ServerName  string
+	ServiceName string
+	Downtime    string
+}
+
+// New creates a new email notifier. publicURL is the base URL of the web UI
+// used for links; leave it empty to omit links.
+func New(config *models.EmailConfig, db *database.DB, publicURL string) (*Notifier, error) {
+	n := &Notifier{
+		config:    config,
+		db:        db,
+		publicURL: strings.TrimRight(publicURL, "/"),
+		html:      make(map[string]*htmltemplate.Template),
+		text:      make(map[string]*texttemplate.Template),
+	}
+
+	for _, name := range []string{templateAlert, templateRecovery, templateDigest} {
+		html, err := htmltemplate.ParseFS(templateFS, "templates/layout.html", "templates/"+name+".html")
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s.html: %w", name, err)
+		}
+		text, err := texttemplate.ParseFS(templateFS, "templates/"+name+".txt")
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s.txt: %w", name, err)
+		}
+		n.html[name] = html
+		n.text[name] = text
+	}
+
+	if !config.Enabled {
+		return n, nil
+	}
+	if config.Host == "" || config.From == "" {
+		return nil, fmt.Errorf("email host and from address are required")
+	}
+	if _, err := mail.ParseAddress(config.From); err != nil {
+		return nil, fmt.Errorf("invalid from address: %w", err)
+	}
+	switch config.TLS {
+	case "":
+		config.TLS = TLSStartTLS
+	case TLSStartTLS, TLSImplicit, TLSNone:
+	default:
+		return nil, fmt.Errorf("email tls must be one of starttls, tls or none")
+	}
+	if config.Port == 0 {
+		switch config.TLS {
+		case TLSImplicit:
+			config.Port = 465
+		case TLSNone:
+			config.Port = 25
+		default:
+			config.Port = 587
+		}
+	}
+
+	return n, nil
+}
+
+// Start sends digest emails until the context is cancelled
+func (n *Notifier) Start(ctx context.Context) {
+	if !n.config.Enabled {
+		log.Println("Email notifications disabled")
+		return
+	}
+	if n.config.DigestInterval <= 0 {
+		return
+	}
+
+	log.Printf("Starting email digest (interval: %v)", n.config.DigestInterval)
+	ticker := time.NewTicker(n.config.DigestInterval)
+	defer ticker.Stop()
+
+	since := time.Now()
+	for {
+		select {
+		case now := <-ticker.C:
+			if err := n.SendDigest(ctx, nil, since, now, false); err != nil {
+				log.Printf("Failed to send email digest: %v", err)
+			}
+			since = now
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Name implements notify.Notifier
+func (n *Notifier) Name() string {
+	return "email"
+}
+
+// Enabled implements notify.Notifier
+func (n *Notifier) Enabled(server *models.Server) bool {
+	return n.config.Enabled
+}
+
+// Send implements notify.Notifier. Events addressed to specific people (e.g.
+// escalations) go to them, everything else to the default recipients.
+func (n *Notifier) Send(ctx context.Context, event *notify.Event) error {
+	to := event.AddressesFor(n.Name())
+	if len(to) == 0 {
+		to = n.defaultRecipients()
+	}
+	if len(to) == 0 {
+		return nil
+	}
+
+	subject, text, html, err := n.renderEvent(event)
+	if err != nil {
+		return err
+	}
+	return n.sendAll(ctx, to, subject, text, html)
+}
+
+// SendTest sends a sample message rendered with the given template
+// (alert, recovery or digest) to the given addresses, or the default recipients
+func (n *Notifier) SendTest(ctx context.Context, to []string, kind string) error {
+	if !n.config.Enabled {
+		return fmt.Errorf("email notifications are disabled")
+	}
+	if len(to) == 0 {
+		to = n.defaultRecipients()
+	}
+	if len(to) == 0 {
+		return fmt.Errorf("no recipients")
+	}
+
+	now := time.Now()
+	if kind == templateDigest {
+		return n.SendDigest(ctx, to, now.Add(-24*time.Hour), now, true)
+	}
+
+	resolvedAt := now
+	event := &notify.Event{
+		Type:    notify.EventAlert,
+		Server:  &models.Server{Name: "example-server", Hostname: "example.local"},
+		Service: &models.Service{Name: "example.service", DisplayName: "Example Service"},
+		Alert: &models.Alert{
+			Status:    models.StatusStopped,
+			State:     models.AlertOpen,
+			Message:   "This is a test message from Vigilon",
+			CreatedAt: now.Add(-5 * time.Minute),
+		},
+		CreatedAt: now,
+	}
+	switch kind {
+	case "", templateAlert:
+	case templateRecovery:
+		event.Type = notify.EventRecovery
+		event.Alert.State = models.AlertResolved
+		event.Alert.ResolvedAt = &resolvedAt
+		event.Alert.DowntimeSecs = 300
+	default:
+		return fmt.Errorf("template must be one of alert, recovery or digest")
+	}
+
+	subject, text, html, err := n.renderEvent(event)
+	if err != nil {
+		return err
+	}
+	return n.sendAll(ctx, to, subject, text, html)
+}
+
+// SendDigest emails a summary of the alerts between since and until. Unless
+// force is set, nothing is sent when there is nothing to report.
+func (n *Notifier) SendDigest(ctx context.Context, to []string, since, until time.Time, force bool) error {
+	if len(to) == 0 {
+		to = n.defaultRecipients()
+	}
+	if len(to) == 0 {
+		return nil
+	}
+
+	alerts, err := n.db.GetDigestAlerts(since)
+	if err != nil {
+		return err
+	}
+
+	data := digestData{Since: since, Until: until, URL: n.publicURL}
+	servers := make(map[int]string)
+	services := make(map[int]string)
+	for _, alert := range alerts {
+		item := digestItem{
+			Alert:       alert,
+			ServerName:  n.lookupName(servers, alert.ServerID, true),
+			ServiceName: n.lookupName(services, alert.ServiceID, false),
+			Downtime:    formatDuration(alert.Downtime()),
+		}
+		if alert.State == models.AlertOpen {
+			data.Open = append(data.Open, item)
+		}
+		if !alert.CreatedAt.Before(since) {
+			data.Opened = append(data.Opened, item)
+		}
+		if alert.ResolvedAt != nil && !alert.ResolvedAt.Before(since) {
+			data.Resolved = append(data.Resolved, item)
+		}
+	}
+
+	if !force && len(data.Open) == 0 && len(data.Opened) == 0 && len(data.Resolved) == 0 {
+		return nil
+	}
+
+	subject := fmt.Sprintf("[Vigilon] Digest: %d open, %d new, %d resolved",
+		len(data.Open), len(data.Opened), len(data.Resolved))
+	text, html, err := n.render(templateDigest, data)
+	if err != nil {
+		return err
+	}
+	return n.sendAll(ctx, to, subject, text, html)
+}
+
+// renderEvent returns the subject and bodies of an event
+func (n *Notifier) renderEvent(event *notify.Event) (string, string, string, error) {
+	data := messageData{
+		Event:   event.Type,
+		Message: event.Message(),
+		Alert:   event.Alert,
+		Server:  event.Server,
+		Service: event.Service,
+		Check:   event.Check,
+		Color:   "#dc2626",
+		Time:    event.CreatedAt,
+	}
+	if data.Time.IsZero() {
+		data.Time = time.Now()
+	}
+	if event.Server != nil && event.Server.ID > 0 && n.publicURL != "" {
+		data.URL = fmt.Sprintf("%s/server/%d", n.publicURL, event.Server.ID)
+	}
+
+	serviceName, serverName, status := "service", "server", "down"
+	if event.Service != nil {
+		serviceName = serviceLabel(event.Service)
+		data.ServiceName = serviceName
+	}
+	if event.Server != nil {
+		serverName = event.Server.Name
+	}
+	if event.Alert != nil {
+		status = string(event.Alert.Status)
+		data.Downtime = formatDuration(event.Alert.Downtime())
+		switch event.Alert.Severity() {
+		case models.SeverityWarning:
+			data.Color = "#ea580c"
+		case models.SeverityInfo:
+			data.Color = "#2563eb"
+		}
+	}
+
+	name := templateAlert
+	switch event.Type {
+	case notify.EventRecovery:
+		name = templateRecovery
+		data.Title = fmt.Sprintf("RECOVERED: %s on %s", serviceName, serverName)
+	case notify.EventReminder:
+		data.Title = fmt.Sprintf("REMINDER: %s on %s is still %s", serviceName, serverName, status)
+	case notify.EventEscalation:
+		data.Title = fmt.Sprintf("ESCALATION: %s on %s is %s", serviceName, serverName, status)
+		data.Color = "#7c3aed"
+	default:
+		data.Title = fmt.Sprintf("ALERT: %s on %s is %s", serviceName, serverName, status)
+	}
+
+	text, html, err := n.render(name, data)
+	if err != nil {
+		return "", "", "", err
+	}
+	return "[Vigilon] " + data.Title, text, html, nil
+}
+
+// render executes the text and HTML templates of a message
+func (n *Notifier) render(name string, data interface{}) (string, string, error) {
+	var text, html bytes.Buffer
+	if err := n.text[name].ExecuteTemplate(&text, name+".txt", data); err != nil {
+		return "", "", fmt.Errorf("failed to render %s.txt: %w", name, err)
+	}
+	if err := n.html[name].ExecuteTemplate(&html, name+".html", data); err != nil {
+		return "", "", fmt.Errorf("failed to render %s.html: %w", name, err)
+	}
+	return text.String(), html.String(), nil
+}
+
+// sendAll sends one message per recipient, joining per-recipient failures
+func (n *Notifier) sendAll(ctx context.Context, to []string, subject, text, html string) error {
+	var errs []error
+	for _, rcpt := range to {
+		msg, err := buildMessage(n.config.From, rcpt, subject, text, html, time.Now())
+		if err == nil {
+			err = n.sendMail(ctx, rcpt, msg)
+		}
+		if err != nil {
+			log.Printf("Failed to send email to %s: %v", rcpt, err)
+			errs = append(errs, fmt.Errorf("%s: %w", rcpt, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sendMail delivers a message to one recipient over SMTP
+func (n *Notifier) sendMail(ctx context.Context, to string, msg []byte) error {
+	addr := net.JoinHostPort(n.config.Host, strconv.Itoa(n.config.Port))
+	tlsConfig := &tls.Config{
+		ServerName:         n.config.Host,
+		InsecureSkipVerify: n.config.InsecureSkipVerify,
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if n.config.TLS == TLSImplicit {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	client, err := smtp.NewClient(conn, n.config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if n.config.TLS == TLSStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("server does not support STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+
+	if n.config.Username != "" {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return fmt.Errorf("server does not support authentication")
+		}
+		auth := smtp.PlainAuth("", n.config.Username, n.config.Password, n.config.Host)
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	from, err := mail.ParseAddress(n.config.From)
+	if err != nil {
+		return err
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// defaultRecipients returns the configured recipients and, if enabled, the
+// email addresses of all enabled users
+func (n *Notifier) defaultRecipients() []string {
+	seen := make(map[string]bool)
+	var to []string
+	add := func(address string) {
+		address = strings.TrimSpace(address)
+		if address == "" || seen[strings.ToLower(address)] {
+			return
+		}
+		seen[strings.ToLower(address)] = true
+		to = append(to, address)
+	}
+
+	for _, address := range n.config.Recipients {
+		add(address)
+	}
+	if n.config.NotifyUsers {
+		users, err := n.db.GetAllUsers()
+		if err != nil {
+			log.Printf("Failed to get users for email notifications: %v", err)
+		}
+		for _, user := range users {
+			if user.Enabled {
+				add(user.Email)
+			}
+		}
+	}
+	return to
+}
+
+// lookupName returns the name of a server or service, caching lookups
+func (n *Notifier) lookupName(cache map[int]string, id int, server bool) string {
+	if name, ok := cache[id]; ok {
+		return name
+	}
+
+	name := fmt.Sprintf("#%d", id)
+	if server {
+		if s, err := n.db.GetServer(id); err == nil {
+			name = s.Name
+		}
+	} else if s, err := n.db.GetService(id); err == nil {
+		name = serviceLabel(s)
+	}
+	cache[id] = name
+	return name
+}
+
+// buildMessage builds a multipart/alternative message with a plain text and an HTML part
+func buildMessage(from, to, subject, text, html string, date time.Time) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=UTF-8", text},
+		{"text/html; charset=UTF-8", html},
+	} {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", part.contentType)
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		w, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Message-ID: %s\r\n", messageID(from))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", writer.Boundary())
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+// messageID returns a unique Message-ID in the domain of the sender
+func messageID(from string) string {
+	domain := "vigilon.local"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if at := strings.LastIndex(addr.Address, "@"); at >= 0 {
+			domain = addr.Address[at+1:]
+		}
+	}
+
+	b := make([]byte, 12)
+	rand.Read(b)
+	return fmt.Sprintf("<%s.%d@%s>", hex.EncodeToString(b), time.Now().UnixNano(), domain)
+}
+
+// serviceLabel returns the display name of a service, falling back to its name
+func serviceLabel(s *models.Service) string {
+	if s.DisplayName != "" {
+		return s.DisplayName
+	}
+	return s.Name
+}
+
+// formatDuration formats a duration for humans, e.g. "1h5m0s"
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
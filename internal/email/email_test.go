@@ -0,0 +1,516 @@
+package email
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/harungecit/vigilon/internal/database"
+	"github.com/harungecit/vigilon/internal/models"
+	"github.com/harungecit/vigilon/internal/notify"
+)
+
+// smtpSink is an SMTP server on 127.0.0.1 that keeps every message it
+// receives
+type smtpSink struct {
+	tls            *tls.Config // Offer STARTTLS with this config; nil = not offered
+	user, password string      // Credentials accepted by AUTH PLAIN; empty = AUTH not offered
+
+	mu       sync.Mutex
+	messages []sunkMessage
+}
+
+// sunkMessage is a message as the sink received it
+type sunkMessage struct {
+	from string
+	to   []string
+	data string
+	tls  bool   // Sent after STARTTLS
+	user string // Authenticated user
+}
+
+// start listens on a free port of 127.0.0.1 and returns the port
+func (s *smtpSink) start(t *testing.T) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpSink) serve(conn net.Conn) {
+	defer func() { conn.Close() }()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 sink.local ESMTP")
+
+	var msg sunkMessage
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250-sink.local")
+			if s.tls != nil && !msg.tls {
+				tp.PrintfLine("250-STARTTLS")
+			}
+			if s.user != "" {
+				tp.PrintfLine("250-AUTH PLAIN")
+			}
+			tp.PrintfLine("250 HELP")
+		case "STARTTLS":
+			tp.PrintfLine("220 Ready to start TLS")
+			tlsConn := tls.Server(conn, s.tls)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, tp = tlsConn, textproto.NewConn(tlsConn)
+			msg.tls = true
+		case "AUTH":
+			mechanism, response, _ := strings.Cut(arg, " ")
+			credentials, _ := base64.StdEncoding.DecodeString(response)
+			if mechanism != "PLAIN" || string(credentials) != "\x00"+s.user+"\x00"+s.password {
+				tp.PrintfLine("535 5.7.8 Authentication credentials invalid")
+				continue
+			}
+			msg.user = s.user
+			tp.PrintfLine("235 2.7.0 Authentication successful")
+		case "MAIL":
+			msg.from = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			msg.to = append(msg.to, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.data = string(data)
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			tp.PrintfLine("250 OK: queued")
+		case "QUIT":
+			tp.PrintfLine("221 Bye")
+			return
+		default:
+			tp.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+// received returns the messages the sink received
+func (s *smtpSink) received() []sunkMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]sunkMessage(nil), s.messages...)
+}
+
+// parsedMessage is a received multipart/alternative message
+type parsedMessage struct {
+	header mail.Header
+	text   string
+	html   string
+}
+
+// parse decodes a received message and its plain text and HTML parts
+func parse(t *testing.T, data string) *parsedMessage {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("invalid message: %v\n%s", err, data)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("content type = %q, want multipart/alternative", msg.Header.Get("Content-Type"))
+	}
+
+	parsed := &parsedMessage{header: msg.Header}
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("invalid part: %v", err)
+		}
+		// The reader decodes quoted-printable parts
+		content, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch part.Header.Get("Content-Type") {
+		case "text/plain; charset=UTF-8":
+			parsed.text = string(content)
+		case "text/html; charset=UTF-8":
+			parsed.html = string(content)
+		default:
+			t.Errorf("unexpected part %q", part.Header.Get("Content-Type"))
+		}
+	}
+	if parsed.text == "" || parsed.html == "" {
+		t.Fatalf("message lacks a plain text or HTML part:\n%s", data)
+	}
+	return parsed
+}
+
+// subject returns the decoded subject of a message
+func (m *parsedMessage) subject(t *testing.T) string {
+	t.Helper()
+	subject, err := new(mime.WordDecoder).DecodeHeader(m.header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return subject
+}
+
+// selfSignedTLS returns a server config with a certificate for 127.0.0.1
+func selfSignedTLS(t *testing.T) *tls.Config {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sink.local"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+}
+
+// newNotifier returns an enabled notifier sending to the sink on port
+func newNotifier(t *testing.T, cfg models.EmailConfig, db *database.DB) *Notifier {
+	t.Helper()
+	cfg.Enabled = true
+	cfg.Host = "127.0.0.1"
+	if cfg.From == "" {
+		cfg.From = "Vigilon <alerts@example.com>"
+	}
+	if cfg.TLS == "" {
+		cfg.TLS = TLSNone
+	}
+	n, err := New(&cfg, db, "https://vigilon.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestSendAlert(t *testing.T) {
+	sink := &smtpSink{}
+	n := newNotifier(t, models.EmailConfig{
+		Port:       sink.start(t),
+		Recipients: []string{"oncall@example.com", "manager@example.com"},
+	}, nil)
+
+	event := &notify.Event{
+		Type: notify.EventAlert,
+		Alert: &models.Alert{
+			ID:        42,
+			Status:    models.StatusStopped,
+			State:     models.AlertOpen,
+			Message:   "Service Nginx on web-1 is stopped – exit code 1",
+			CreatedAt: time.Date(2026, 3, 14, 9, 26, 53, 0, time.UTC),
+		},
+		Server:  &models.Server{ID: 7, Name: "web-1", Hostname: "web-1.internal"},
+		Service: &models.Service{ID: 3, Name: "nginx.service", DisplayName: "Nginx"},
+		Check:   &models.ServiceCheck{ErrorMessage: "nginx.service: Main process exited, code=exited, status=1/FAILURE"},
+	}
+	if err := n.Send(context.Background(), event); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	messages := sink.received()
+	if len(messages) != 2 {
+		t.Fatalf("sink received %d messages, want one per recipient", len(messages))
+	}
+	for i, want := range []string{"oncall@example.com", "manager@example.com"} {
+		msg := messages[i]
+		if msg.from != "alerts@example.com" || len(msg.to) != 1 || msg.to[0] != want {
+			t.Errorf("envelope = %s -> %v, want alerts@example.com -> %s", msg.from, msg.to, want)
+		}
+
+		parsed := parse(t, msg.data)
+		if got := parsed.header.Get("To"); got != want {
+			t.Errorf("To = %q, want %q", got, want)
+		}
+		if got := parsed.subject(t); got != "[Vigilon] ALERT: Nginx on web-1 is stopped" {
+			t.Errorf("Subject = %q", got)
+		}
+		for _, s := range []string{
+			"Service Nginx on web-1 is stopped – exit code 1",
+			"Server:  web-1 (web-1.internal)",
+			"Alert:   #42",
+			"Error:   nginx.service: Main process exited, code=exited, status=1/FAILURE",
+			"Open in Vigilon: https://vigilon.example.com/server/7",
+		} {
+			if !strings.Contains(parsed.text, s) {
+				t.Errorf("plain text part lacks %q:\n%s", s, parsed.text)
+			}
+		}
+		for _, s := range []string{"exit code 1", `href="https://vigilon.example.com/server/7"`, "#dc2626"} {
+			if !strings.Contains(parsed.html, s) {
+				t.Errorf("HTML part lacks %q:\n%s", s, parsed.html)
+			}
+		}
+	}
+}
+
+func TestSendCreatedAlert(t *testing.T) {
+	db, err := database.New(filepath.Join(t.TempDir(), "vigilon.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	sink := &smtpSink{}
+	n := newNotifier(t, models.EmailConfig{Port: sink.start(t), Recipients: []string{"oncall@example.com"}}, db)
+
+	server := &models.Server{Name: "web-1", Hostname: "web-1.internal", OS: "linux", MonitoringMode: models.ModePull, Enabled: true}
+	if err := db.CreateServer(server); err != nil {
+		t.Fatal(err)
+	}
+	nginx := &models.Service{ServerID: server.ID, Name: "nginx.service", DisplayName: "Nginx", Enabled: true}
+	if err := db.CreateService(nginx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The monitor notifies about the alert it just created, as it is
+	alert := &models.Alert{ServiceID: nginx.ID, ServerID: server.ID, Status: models.StatusStopped, Message: "nginx stopped"}
+	if err := db.CreateAlert(alert); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(alert.CreatedAt) > time.Minute {
+		t.Fatalf("created at = %v, want the current time", alert.CreatedAt)
+	}
+	event := &notify.Event{Type: notify.EventAlert, Alert: alert, Server: server, Service: nginx}
+	if err := n.Send(context.Background(), event); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	messages := sink.received()
+	if len(messages) != 1 {
+		t.Fatalf("sink received %d messages, want 1", len(messages))
+	}
+	parsed := parse(t, messages[0].data)
+	since := alert.CreatedAt.Format("2006-01-02 15:04:05 MST")
+	if !strings.Contains(parsed.text, "Since:   "+since) {
+		t.Errorf("plain text part lacks the alert time %s:\n%s", since, parsed.text)
+	}
+	if !strings.Contains(parsed.html, since) {
+		t.Errorf("HTML part lacks the alert time %s:\n%s", since, parsed.html)
+	}
+
+	// It reads back the same
+	stored, err := db.GetAlert(alert.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stored.CreatedAt.Equal(alert.CreatedAt) {
+		t.Errorf("stored created at = %v, want %v", stored.CreatedAt, alert.CreatedAt)
+	}
+}
+
+func TestSendMail(t *testing.T) {
+	tests := []struct {
+		name     string
+		sink     *smtpSink
+		cfg      models.EmailConfig
+		wantErr  string
+		wantTLS  bool
+		wantUser string
+	}{
+		{
+			name: "plain",
+			sink: &smtpSink{},
+			cfg:  models.EmailConfig{TLS: TLSNone},
+		},
+		{
+			name:    "starttls",
+			sink:    &smtpSink{tls: selfSignedTLS(t)},
+			cfg:     models.EmailConfig{TLS: TLSStartTLS, InsecureSkipVerify: true},
+			wantTLS: true,
+		},
+		{
+			name:    "starttls refused",
+			sink:    &smtpSink{},
+			cfg:     models.EmailConfig{TLS: TLSStartTLS},
+			wantErr: "server does not support STARTTLS",
+		},
+		{
+			name:    "untrusted certificate",
+			sink:    &smtpSink{tls: selfSignedTLS(t)},
+			cfg:     models.EmailConfig{TLS: TLSStartTLS},
+			wantErr: "certificate",
+		},
+		{
+			name:     "auth",
+			sink:     &smtpSink{tls: selfSignedTLS(t), user: "vigilon", password: "s3cret"},
+			cfg:      models.EmailConfig{TLS: TLSStartTLS, InsecureSkipVerify: true, Username: "vigilon", Password: "s3cret"},
+			wantTLS:  true,
+			wantUser: "vigilon",
+		},
+		{
+			name:    "wrong password",
+			sink:    &smtpSink{user: "vigilon", password: "s3cret"},
+			cfg:     models.EmailConfig{TLS: TLSNone, Username: "vigilon", Password: "wrong"},
+			wantErr: "535",
+		},
+		{
+			name:    "auth not offered",
+			sink:    &smtpSink{},
+			cfg:     models.EmailConfig{TLS: TLSNone, Username: "vigilon", Password: "s3cret"},
+			wantErr: "server does not support authentication",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Port = tt.sink.start(t)
+			n := newNotifier(t, tt.cfg, nil)
+
+			err := n.SendTest(context.Background(), []string{"oncall@example.com"}, "alert")
+			checkError(t, err, tt.wantErr)
+
+			messages := tt.sink.received()
+			if tt.wantErr != "" {
+				if len(messages) != 0 {
+					t.Errorf("sink received %d messages, want none", len(messages))
+				}
+				return
+			}
+			if len(messages) != 1 {
+				t.Fatalf("sink received %d messages, want 1", len(messages))
+			}
+			if messages[0].tls != tt.wantTLS || messages[0].user != tt.wantUser {
+				t.Errorf("tls, user = %v, %q; want %v, %q", messages[0].tls, messages[0].user, tt.wantTLS, tt.wantUser)
+			}
+			parse(t, messages[0].data)
+		})
+	}
+}
+
+func TestSendDigest(t *testing.T) {
+	db, err := database.New(filepath.Join(t.TempDir(), "vigilon.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	sink := &smtpSink{}
+	n := newNotifier(t, models.EmailConfig{Port: sink.start(t), Recipients: []string{"manager@example.com"}}, db)
+	now := time.Now()
+
+	// Nothing to report: no email
+	if err := n.SendDigest(context.Background(), nil, now.Add(-24*time.Hour), now, false); err != nil {
+		t.Fatalf("SendDigest: %v", err)
+	}
+	if messages := sink.received(); len(messages) != 0 {
+		t.Fatalf("sink received %d messages for an empty digest, want none", len(messages))
+	}
+
+	server := &models.Server{Name: "web-1", Hostname: "web-1.internal", OS: "linux", MonitoringMode: models.ModePull, Enabled: true}
+	if err := db.CreateServer(server); err != nil {
+		t.Fatal(err)
+	}
+	nginx := &models.Service{ServerID: server.ID, Name: "nginx.service", DisplayName: "Nginx", Enabled: true}
+	redis := &models.Service{ServerID: server.ID, Name: "redis.service", Enabled: true}
+	for _, service := range []*models.Service{nginx, redis} {
+		if err := db.CreateService(service); err != nil {
+			t.Fatal(err)
+		}
+	}
+	open := &models.Alert{ServiceID: nginx.ID, ServerID: server.ID, Status: models.StatusStopped, Message: "nginx stopped"}
+	resolved := &models.Alert{ServiceID: redis.ID, ServerID: server.ID, Status: models.StatusFailed, Message: "redis failed"}
+	for _, alert := range []*models.Alert{open, resolved} {
+		if err := db.CreateAlert(alert); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.ResolveAlert(resolved.ID, now, 5*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := n.SendDigest(context.Background(), nil, now.Add(-time.Hour), now, false); err != nil {
+		t.Fatalf("SendDigest: %v", err)
+	}
+	messages := sink.received()
+	if len(messages) != 1 {
+		t.Fatalf("sink received %d messages, want 1", len(messages))
+	}
+
+	parsed := parse(t, messages[0].data)
+	if got := parsed.subject(t); got != "[Vigilon] Digest: 1 open, 2 new, 1 resolved" {
+		t.Errorf("Subject = %q", got)
+	}
+	for _, s := range []string{
+		"Open alerts: 1",
+		"New alerts: 2",
+		"Resolved alerts: 1",
+		"Nginx on web-1 is stopped since",
+		"redis.service on web-1 recovered after 5m0s",
+	} {
+		if !strings.Contains(parsed.text, s) {
+			t.Errorf("plain text part lacks %q:\n%s", s, parsed.text)
+		}
+	}
+	for _, s := range []string{"Nginx on web-1", "down for 5m0s"} {
+		if !strings.Contains(parsed.html, s) {
+			t.Errorf("HTML part lacks %q:\n%s", s, parsed.html)
+		}
+	}
+}
+
+// checkError fails the test unless err contains want, or is nil when want
+// is empty
+func checkError(t *testing.T, err error, want string) {
+	t.Helper()
+	switch {
+	case want == "" && err != nil:
+		t.Errorf("unexpected error: %v", err)
+	case want != "" && err == nil:
+		t.Errorf("error = nil, want %q", want)
+	case want != "" && !strings.Contains(err.Error(), want):
+		t.Errorf("error = %v, want %q", err, want)
+	}
+}
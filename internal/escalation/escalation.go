@@ -0,0 +1,241 @@
+package escalation
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/harungecit/vigilon/internal/database"
+	"github.com/harungecit/vigilon/internal/maintenance"
+	"github.com/harungecit/vigilon/internal/models"
+	"github.com/harungecit/vigilon/internal/notify"
+	"github.com/harungecit/vigilon/internal/oncall"
+)
+
+// Validate checks that a policy is complete and consistent
+func Validate(policy *models.EscalationPolicy) error {
+	if policy.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(policy.Steps) == 0 {
+		return fmt.Errorf("at least one step is required")
+	}
+
+	for i, step := range policy.Steps {
+		if step.Delay < 0 {
+			return fmt.Errorf("step %d: delay must not be negative", i+1)
+		}
+		if len(step.Channels) == 0 && len(step.Recipients) == 0 && len(step.Users) == 0 && len(step.Schedules) == 0 {
+			return fmt.Errorf("step %d: at least one channel, recipient, user or schedule is required", i+1)
+		}
+		for _, r := range step.Recipients {
+			if r.Channel == "" || r.Address == "" {
+				return fmt.Errorf("step %d: recipients need a channel and an address", i+1)
+			}
+		}
+	}
+	return nil
+}
+
+// Scheduler advances unacknowledged alerts through their escalation policy
+type Scheduler struct {
+	db         *database.DB
+	dispatcher *notify.Dispatcher
+	interval   time.Duration
+}
+
+// NewScheduler creates a new escalation scheduler
+func NewScheduler(db *database.DB, dispatcher *notify.Dispatcher, interval time.Duration) *Scheduler {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	return &Scheduler{
+		db:         db,
+		dispatcher: dispatcher,
+		interval:   interval,
+	}
+}
+
+// Start runs the scheduler until the context is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	log.Println("Starting escalation scheduler...")
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.run(time.Now())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// run escalates every alert whose next step is due
+func (s *Scheduler) run(now time.Time) {
+	alerts, err := s.db.GetEscalatingAlerts()
+	if err != nil {
+		log.Printf("Failed to get escalating alerts: %v", err)
+		return
+	}
+	if len(alerts) == 0 {
+		return
+	}
+
+	var windows []*models.MaintenanceWindow
+	if all, err := s.db.GetMaintenanceWindows(); err == nil {
+		windows = maintenance.Active(all, now)
+	}
+
+	policies := make(map[int]*models.EscalationPolicy)
+	for _, alert := range alerts {
+		policy, ok := policies[alert.EscalationPolicyID]
+		if !ok {
+			policy, err = s.db.GetEscalationPolicy(alert.EscalationPolicyID)
+			if err != nil {
+				log.Printf("Failed to get escalation policy %d: %v", alert.EscalationPolicyID, err)
+			}
+			policies[alert.EscalationPolicyID] = policy
+		}
+		step, ok := dueStep(alert, policy, now)
+		if !ok {
+			continue
+		}
+
+		server, err := s.db.GetServer(alert.ServerID)
+		if err != nil {
+			log.Printf("Failed to get server %d: %v", alert.ServerID, err)
+			continue
+		}
+		service, err := s.db.GetService(alert.ServiceID)
+		if err != nil {
+			log.Printf("Failed to get service %d: %v", alert.ServiceID, err)
+			continue
+		}
+
+		// Escalation waits while the service is in maintenance
+		if maintenance.Match(windows, server, service) != nil {
+			continue
+		}
+
+		s.escalate(alert, policy, step, server, service, now)
+	}
+}
+
+// dueStep returns the next step of the policy if the alert is due for it at
+// now. The delay of a step counts from the previous step, or from when the
+// alert was created for the first one.
+func dueStep(alert *models.Alert, policy *models.EscalationPolicy, now time.Time) (models.EscalationStep, bool) {
+	if policy == nil || alert.EscalationStep >= len(policy.Steps) {
+		return models.EscalationStep{}, false
+	}
+
+	step := policy.Steps[alert.EscalationStep]
+	since := alert.CreatedAt
+	if alert.EscalatedAt != nil {
+		since = *alert.EscalatedAt
+	}
+	if now.Sub(since) < time.Duration(step.Delay)*time.Minute {
+		return models.EscalationStep{}, false
+	}
+	return step, true
+}
+
+// escalate notifies the channels and recipients of a step and records progress
+func (s *Scheduler) escalate(alert *models.Alert, policy *models.EscalationPolicy, step models.EscalationStep, server *models.Server, service *models.Service, now time.Time) {
+	stepNumber := alert.EscalationStep + 1
+	recipients := append(append([]models.Recipient{}, step.Recipients...), s.resolveRecipients(step, now)...)
+
+	// Recipients imply their channel
+	channels := append([]string{}, step.Channels...)
+	for _, r := range recipients {
+		if !contains(channels, r.Channel) {
+			channels = append(channels, r.Channel)
+		}
+	}
+
+	message := fmt.Sprintf("⏫ Escalation %d/%d (%s): alert #%d has not been acknowledged for %s\n%s",
+		stepNumber, len(policy.Steps), policy.Name, alert.ID,
+		now.Sub(alert.CreatedAt).Round(time.Minute), alert.Message)
+
+	s.dispatcher.Dispatch(&notify.Event{
+		Type:       notify.EventEscalation,
+		Alert:      alert,
+		Server:     server,
+		Service:    service,
+		Text:       message,
+		Channels:   channels,
+		Recipients: recipients,
+	})
+
+	if err := s.db.SetAlertEscalation(alert.ID, stepNumber, now); err != nil {
+		log.Printf("Failed to update escalation of alert %d: %v", alert.ID, err)
+		return
+	}
+
+	log.Printf("Alert %d escalated to step %d/%d of policy '%s'", alert.ID, stepNumber, len(policy.Steps), policy.Name)
+}
+
+// resolveRecipients turns the users and on-call schedules of a step into the
+// contact methods of the users that should be notified at t
+func (s *Scheduler) resolveRecipients(step models.EscalationStep, t time.Time) []models.Recipient {
+	userIDs := append([]int{}, step.Users...)
+	for _, scheduleID := range step.Schedules {
+		schedule, err := s.db.GetOnCallSchedule(scheduleID)
+		if err != nil {
+			log.Printf("Failed to get on-call schedule %d: %v", scheduleID, err)
+			continue
+		}
+		shift, err := oncall.At(schedule, t)
+		if err != nil {
+			log.Printf("Failed to resolve on-call schedule %s: %v", schedule.Name, err)
+			continue
+		}
+		userIDs = append(userIDs, shift.UserID)
+	}
+
+	var recipients []models.Recipient
+	seen := make(map[int]bool)
+	for _, userID := range userIDs {
+		if seen[userID] {
+			continue
+		}
+		seen[userID] = true
+
+		user, err := s.db.GetUser(userID)
+		if err != nil || !user.Enabled {
+			continue
+		}
+
+		methods, err := s.db.GetContactMethods(userID)
+		if err != nil {
+			log.Printf("Failed to get contact methods of user %s: %v", user.Username, err)
+			continue
+		}
+		hasEmail := false
+		for _, m := range methods {
+			recipients = append(recipients, models.Recipient{Channel: m.Channel, Address: m.Address})
+			hasEmail = hasEmail || m.Channel == "email"
+		}
+
+		// The account's email address is used unless an email contact method is set
+		if !hasEmail && user.Email != "" {
+			recipients = append(recipients, models.Recipient{Channel: "email", Address: user.Email})
+		} else if len(methods) == 0 {
+			log.Printf("User %s has no contact methods, cannot escalate to them", user.Username)
+		}
+	}
+	return recipients
+}
+
+// contains reports whether list contains s
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
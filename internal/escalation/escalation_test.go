@@ -0,0 +1,164 @@
+package escalation
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/harungecit/vigilon/internal/database"
+	"github.com/harungecit/vigilon/internal/models"
+	"github.com/harungecit/vigilon/internal/notify"
+)
+
+func TestDueStep(t *testing.T) {
+	created := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	escalated := created.Add(20 * time.Minute)
+	policy := &models.EscalationPolicy{Name: "ops", Steps: []models.EscalationStep{
+		{Delay: 0, Channels: []string{"chat"}},
+		{Delay: 5, Channels: []string{"email"}},
+		{Delay: 15, Channels: []string{"webhook"}},
+	}}
+
+	tests := []struct {
+		name        string
+		step        int        // Steps the alert already reached
+		escalatedAt *time.Time // When it reached the last one
+		policy      *models.EscalationPolicy
+		after       time.Duration // Since the alert was created
+		want        string        // Channel of the due step, empty if none
+	}{
+		{"first step right away", 0, nil, policy, 0, "chat"},
+		{"second step early", 1, &created, policy, 4*time.Minute + 59*time.Second, ""},
+		{"second step due", 1, &created, policy, 5 * time.Minute, "email"},
+		{"delay from previous step", 2, &escalated, policy, 30 * time.Minute, ""},
+		{"delay from previous step due", 2, &escalated, policy, 35 * time.Minute, "webhook"},
+		{"delay from creation without escalation", 1, nil, policy, 5 * time.Minute, "email"},
+		{"all steps done", 3, &escalated, policy, 24 * time.Hour, ""},
+		{"policy deleted", 0, nil, nil, time.Hour, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alert := &models.Alert{CreatedAt: created, EscalationStep: tt.step, EscalatedAt: tt.escalatedAt}
+			step, ok := dueStep(alert, tt.policy, created.Add(tt.after))
+			got := ""
+			if ok {
+				got = step.Channels[0]
+			}
+			if got != tt.want {
+				t.Errorf("due step = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// recorder is a notification channel that records the events it receives
+type recorder struct {
+	events chan *notify.Event
+}
+
+func (r *recorder) Name() string                { return "chat" }
+func (r *recorder) Enabled(*models.Server) bool { return true }
+func (r *recorder) Send(_ context.Context, event *notify.Event) error {
+	r.events <- event
+	return nil
+}
+
+func TestSchedulerRun(t *testing.T) {
+	db, err := database.New(filepath.Join(t.TempDir(), "vigilon.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	dispatcher := notify.NewDispatcher(db, notify.RetryPolicy{})
+	rec := &recorder{events: make(chan *notify.Event, 10)}
+	dispatcher.Register(rec)
+	ctx, cancel := context.WithCancel(context.Background())
+	go dispatcher.Start(ctx)
+	defer dispatcher.Wait()
+	defer cancel()
+
+	policy := &models.EscalationPolicy{Name: "ops", Steps: []models.EscalationStep{
+		{Delay: 0, Channels: []string{"chat"}},
+		{Delay: 10, Recipients: []models.Recipient{{Channel: "chat", Address: "lead"}}},
+	}}
+	if err := db.CreateEscalationPolicy(policy); err != nil {
+		t.Fatal(err)
+	}
+	server := &models.Server{Name: "web-1", Hostname: "web-1.internal", OS: "linux", MonitoringMode: models.ModePull, Enabled: true}
+	if err := db.CreateServer(server); err != nil {
+		t.Fatal(err)
+	}
+	nginx := &models.Service{ServerID: server.ID, Name: "nginx.service", Enabled: true}
+	if err := db.CreateService(nginx); err != nil {
+		t.Fatal(err)
+	}
+	alert := &models.Alert{ServiceID: nginx.ID, ServerID: server.ID, Status: models.StatusStopped,
+		Message: "nginx stopped", EscalationPolicyID: policy.ID}
+	if err := db.CreateAlert(alert); err != nil {
+		t.Fatal(err)
+	}
+	acknowledged := &models.Alert{ServiceID: nginx.ID, ServerID: server.ID, Status: models.StatusStopped,
+		Message: "nginx stopped before", EscalationPolicyID: policy.ID}
+	if err := db.CreateAlert(acknowledged); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AcknowledgeAlert(acknowledged.ID, "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewScheduler(db, dispatcher, time.Minute)
+	tests := []struct {
+		after        time.Duration // Since the alert was created
+		wantStep     int
+		wantAddress  string // Recipient notified on this run, empty if none
+		wantNotified bool
+	}{
+		{0, 1, "", true},
+		{9 * time.Minute, 1, "", false},
+		{10 * time.Minute, 2, "lead", true},
+		{time.Hour, 2, "", false},
+	}
+	for _, tt := range tests {
+		s.run(alert.CreatedAt.Add(tt.after))
+		wait := 100 * time.Millisecond
+		if tt.wantNotified {
+			wait = 5 * time.Second
+		}
+
+		stored, err := db.GetAlert(alert.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.EscalationStep != tt.wantStep {
+			t.Errorf("after %s: step = %d, want %d", tt.after, stored.EscalationStep, tt.wantStep)
+		}
+
+		select {
+		case event := <-rec.events:
+			if !tt.wantNotified {
+				t.Errorf("after %s: unexpected %s event for alert %d", tt.after, event.Type, event.Alert.ID)
+				continue
+			}
+			if event.Type != notify.EventEscalation || event.Alert.ID != alert.ID {
+				t.Errorf("after %s: %s event for alert %d, want escalation of %d", tt.after, event.Type, event.Alert.ID, alert.ID)
+			}
+			address := ""
+			if len(event.Recipients) > 0 {
+				address = event.Recipients[0].Address
+			}
+			if address != tt.wantAddress {
+				t.Errorf("after %s: recipient = %q, want %q", tt.after, address, tt.wantAddress)
+			}
+		case <-time.After(wait):
+			if tt.wantNotified {
+				t.Errorf("after %s: no escalation sent", tt.after)
+			}
+		}
+	}
+
+	if stored, err := db.GetAlert(acknowledged.ID); err != nil || stored.EscalationStep != 0 {
+		t.Errorf("acknowledged alert escalated: %+v, %v", stored, err)
+	}
+}
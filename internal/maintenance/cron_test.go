@@ -0,0 +1,102 @@
+package maintenance
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		wantErr string
+	}{
+		{"every minute", "* * * * *", ""},
+		{"names", "0 2 * jan-MAR Mon-fri", ""},
+		{"lists, ranges and steps", "0,30 9-17/2 1,15 * *", ""},
+		{"sunday as 7", "0 0 * * 7", ""},
+		{"too few fields", "0 2 * *", "cron expression must have 5 fields, got 4"},
+		{"too many fields", "0 0 2 * * *", "cron expression must have 5 fields, got 6"},
+		{"minute out of range", "60 * * * *", "minute: value 60 out of range 0-59"},
+		{"hour out of range", "0 24 * * *", "hour: value 24 out of range 0-23"},
+		{"day of month zero", "0 0 0 * *", "day of month: value 0 out of range 1-31"},
+		{"unknown month name", "0 0 1 foo *", `month: invalid value "foo"`},
+		{"day of week out of range", "0 0 * * 8", "day of week: value 8 out of range 0-7"},
+		{"reversed range", "0 17-9 * * *", `hour: invalid range "17-9"`},
+		{"range out of bounds", "0 0 * 11-13 *", "month: value 13 out of range 1-12"},
+		{"zero step", "*/0 * * * *", `minute: invalid step in "*/0"`},
+		{"invalid step", "*/x * * * *", `minute: invalid step in "*/x"`},
+		{"empty list entry", "0, * * * *", `minute: invalid value ""`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseCron(tt.expr)
+			checkError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestScheduleMatches(t *testing.T) {
+	at := func(date, clock string) time.Time {
+		t, err := time.Parse("2006-01-02 15:04:05", date+" "+clock)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		at   time.Time
+		want bool
+	}{
+		{"step from start", "*/15 * * * *", at("2026-03-02", "10:45:00"), true},
+		{"step between", "*/15 * * * *", at("2026-03-02", "10:50:00"), false},
+		{"seconds ignored", "*/15 * * * *", at("2026-03-02", "10:45:59"), true},
+		{"range step in range", "0-30/10 * * * *", at("2026-03-02", "10:20:00"), true},
+		{"range step off step", "0-30/10 * * * *", at("2026-03-02", "10:25:00"), false},
+		{"range step past range", "0-30/10 * * * *", at("2026-03-02", "10:40:00"), false},
+		{"value step runs to max", "5/20 * * * *", at("2026-03-02", "10:45:00"), true},
+		{"value step off step", "5/20 * * * *", at("2026-03-02", "10:15:00"), false},
+		{"list", "0 0 1 jan,jul *", at("2026-07-01", "00:00:00"), true},
+		{"list other month", "0 0 1 jan,jul *", at("2026-06-01", "00:00:00"), false},
+		{"weekday range", "0 9-17 * * mon-fri", at("2026-03-02", "12:00:00"), true},
+		{"weekday range on saturday", "0 9-17 * * mon-fri", at("2026-03-07", "12:00:00"), false},
+		{"weekday range after hours", "0 9-17 * * mon-fri", at("2026-03-02", "18:00:00"), false},
+		{"sunday as 7", "0 0 * * 7", at("2026-03-01", "00:00:00"), true},
+		{"sunday as 0", "0 0 * * 0", at("2026-03-01", "00:00:00"), true},
+
+		// Both days restricted: either one matching is enough
+		{"both days, weekday only", "0 0 13 * fri", at("2026-03-06", "00:00:00"), true},
+		{"both days, day of month only", "0 0 13 * fri", at("2026-01-13", "00:00:00"), true},
+		{"both days, both", "0 0 13 * fri", at("2026-03-13", "00:00:00"), true},
+		{"both days, neither", "0 0 13 * fri", at("2026-03-11", "00:00:00"), false},
+		{"day of month only", "0 0 13 * *", at("2026-03-06", "00:00:00"), false},
+		{"weekday only", "0 0 * * fri", at("2026-01-13", "00:00:00"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCron(%q): %v", tt.expr, err)
+			}
+			if got := s.Matches(tt.at); got != tt.want {
+				t.Errorf("%q matches %s (%s) = %v, want %v", tt.expr, tt.at.Format(time.DateTime), tt.at.Weekday(), got, tt.want)
+			}
+		})
+	}
+}
+
+func checkError(t *testing.T, err error, want string) {
+	t.Helper()
+	switch {
+	case want == "" && err != nil:
+		t.Errorf("unexpected error: %v", err)
+	case want != "" && err == nil:
+		t.Errorf("error = nil, want %q", want)
+	case want != "" && !strings.Contains(err.Error(), want):
+		t.Errorf("error = %q, want %q", err, want)
+	}
+}
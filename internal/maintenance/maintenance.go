@@ -0,0 +1,161 @@
+package maintenance
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/harungecit/vigilon/internal/models"
+)
+
+// MaxDuration bounds a single occurrence of a recurring window
+const MaxDuration = 7 * 24 * time.Hour
+
+// Validate checks that a window is complete and consistent
+func Validate(w *models.MaintenanceWindow) error {
+	if w.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	switch w.Scope {
+	case models.ScopeServer:
+		if w.ServerID == 0 {
+			return fmt.Errorf("server_id is required for server scope")
+		}
+	case models.ScopeService:
+		if w.ServiceID == 0 {
+			return fmt.Errorf("service_id is required for service scope")
+		}
+	case models.ScopeTag:
+		if w.Tag == "" {
+			return fmt.Errorf("tag is required for tag scope")
+		}
+	default:
+		return fmt.Errorf("scope must be one of server, service or tag")
+	}
+
+	if w.StartsAt != nil && w.EndsAt != nil && !w.EndsAt.After(*w.StartsAt) {
+		return fmt.Errorf("ends_at must be after starts_at")
+	}
+
+	if !w.Recurring() {
+		if w.StartsAt == nil || w.EndsAt == nil {
+			return fmt.Errorf("starts_at and ends_at are required for one-off windows")
+		}
+		return nil
+	}
+
+	if _, err := ParseCron(w.Schedule); err != nil {
+		return fmt.Errorf("invalid schedule: %w", err)
+	}
+	duration := time.Duration(w.Duration) * time.Second
+	if duration <= 0 || duration > MaxDuration {
+		return fmt.Errorf("duration must be between 1 second and %s", MaxDuration)
+	}
+	if _, err := location(w); err != nil {
+		return fmt.Errorf("invalid timezone: %w", err)
+	}
+	return nil
+}
+
+// IsActive reports whether the window is in effect at t
+func IsActive(w *models.MaintenanceWindow, t time.Time) bool {
+	_, ok := currentEnd(w, t)
+	return ok
+}
+
+// Until returns when the occurrence of the window that is active at t ends
+func Until(w *models.MaintenanceWindow, t time.Time) (time.Time, bool) {
+	return currentEnd(w, t)
+}
+
+// Active returns the windows that are in effect at t
+func Active(windows []*models.MaintenanceWindow, t time.Time) []*models.MaintenanceWindow {
+	var active []*models.MaintenanceWindow
+	for _, w := range windows {
+		if IsActive(w, t) {
+			active = append(active, w)
+		}
+	}
+	return active
+}
+
+// Covers reports whether the window's scope includes the service
+func Covers(w *models.MaintenanceWindow, server *models.Server, service *models.Service) bool {
+	switch w.Scope {
+	case models.ScopeServer:
+		return server != nil && w.ServerID == server.ID
+	case models.ScopeService:
+		return service != nil && w.ServiceID == service.ID
+	case models.ScopeTag:
+		return server != nil && server.HasTag(w.Tag)
+	}
+	return false
+}
+
+// Match returns the first window that covers the service, or nil
+func Match(windows []*models.MaintenanceWindow, server *models.Server, service *models.Service) *models.MaintenanceWindow {
+	for _, w := range windows {
+		if Covers(w, server, service) {
+			return w
+		}
+	}
+	return nil
+}
+
+// Find returns the first window covering the service that is in effect at t, or nil
+func Find(windows []*models.MaintenanceWindow, server *models.Server, service *models.Service, t time.Time) *models.MaintenanceWindow {
+	for _, w := range windows {
+		if Covers(w, server, service) && IsActive(w, t) {
+			return w
+		}
+	}
+	return nil
+}
+
+// currentEnd returns the end of the occurrence active at t
+func currentEnd(w *models.MaintenanceWindow, t time.Time) (time.Time, bool) {
+	if w.StartsAt != nil && t.Before(*w.StartsAt) {
+		return time.Time{}, false
+	}
+	if w.EndsAt != nil && !t.Before(*w.EndsAt) {
+		return time.Time{}, false
+	}
+
+	if !w.Recurring() {
+		if w.StartsAt == nil || w.EndsAt == nil {
+			return time.Time{}, false
+		}
+		return *w.EndsAt, true
+	}
+
+	schedule, err := ParseCron(w.Schedule)
+	if err != nil {
+		return time.Time{}, false
+	}
+	loc, err := location(w)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	// Look back for a start time whose occurrence still covers t
+	duration := min(time.Duration(w.Duration)*time.Second, MaxDuration)
+	now := t.In(loc)
+	for start := now.Truncate(time.Minute); now.Sub(start) < duration; start = start.Add(-time.Minute) {
+		if schedule.Matches(start) {
+			end := start.Add(duration)
+			if w.EndsAt != nil && end.After(*w.EndsAt) {
+				end = *w.EndsAt
+			}
+			return end, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// location returns the time zone the window's schedule is evaluated in
+func location(w *models.MaintenanceWindow) (*time.Location, error) {
+	if w.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(w.Timezone)
+}
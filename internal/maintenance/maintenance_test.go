@@ -0,0 +1,162 @@
+package maintenance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/harungecit/vigilon/internal/models"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse(time.DateTime, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func datePtr(s string) *time.Time {
+	t := date(s)
+	return &t
+}
+
+func TestUntil(t *testing.T) {
+	oneOff := &models.MaintenanceWindow{StartsAt: datePtr("2026-03-02 10:00:00"), EndsAt: datePtr("2026-03-02 12:00:00")}
+	nightly := &models.MaintenanceWindow{Schedule: "0 23 * * *", Duration: 2 * 3600, Timezone: "UTC"}
+	saturday := &models.MaintenanceWindow{Schedule: "30 22 * * sat", Duration: 3 * 3600, Timezone: "UTC"}
+	// 02:00 in Istanbul is 23:00 UTC the day before
+	istanbul := &models.MaintenanceWindow{Schedule: "0 2 * * mon", Duration: 3600, Timezone: "Europe/Istanbul"}
+	bounded := &models.MaintenanceWindow{Schedule: "0 23 * * *", Duration: 2 * 3600, Timezone: "UTC",
+		StartsAt: datePtr("2026-03-02 00:00:00"), EndsAt: datePtr("2026-03-05 00:30:00")}
+
+	tests := []struct {
+		name    string
+		window  *models.MaintenanceWindow
+		at      string
+		wantEnd string // Empty if the window is not active
+	}{
+		{"one-off before", oneOff, "2026-03-02 09:59:59", ""},
+		{"one-off start", oneOff, "2026-03-02 10:00:00", "2026-03-02 12:00:00"},
+		{"one-off last second", oneOff, "2026-03-02 11:59:59", "2026-03-02 12:00:00"},
+		{"one-off end", oneOff, "2026-03-02 12:00:00", ""},
+		{"one-off without end", &models.MaintenanceWindow{StartsAt: datePtr("2026-03-02 10:00:00")}, "2026-03-02 11:00:00", ""},
+
+		{"nightly before", nightly, "2026-03-02 22:59:00", ""},
+		{"nightly start", nightly, "2026-03-02 23:00:00", "2026-03-03 01:00:00"},
+		{"nightly after midnight", nightly, "2026-03-03 00:59:59", "2026-03-03 01:00:00"},
+		{"nightly end", nightly, "2026-03-03 01:00:00", ""},
+
+		// Sunday morning belongs to the occurrence started on Saturday
+		{"weekly after midnight", saturday, "2026-03-08 01:00:00", "2026-03-08 01:30:00"},
+		{"weekly end", saturday, "2026-03-08 01:30:00", ""},
+		{"weekly other night", saturday, "2026-03-07 01:00:00", ""},
+
+		{"time zone", istanbul, "2026-03-01 23:30:00", "2026-03-02 00:00:00"},
+		{"time zone other day", istanbul, "2026-03-02 23:30:00", ""},
+
+		{"recurring before start", bounded, "2026-03-01 23:30:00", ""},
+		{"recurring within bounds", bounded, "2026-03-02 23:30:00", "2026-03-03 01:00:00"},
+		{"recurring cut at end", bounded, "2026-03-05 00:10:00", "2026-03-05 00:30:00"},
+		{"recurring after end", bounded, "2026-03-05 00:45:00", ""},
+
+		{"invalid schedule", &models.MaintenanceWindow{Schedule: "0 25 * * *", Duration: 3600}, "2026-03-02 01:00:00", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at := date(tt.at)
+			end, ok := Until(tt.window, at)
+			if active := IsActive(tt.window, at); active != ok {
+				t.Errorf("IsActive = %v, Until = %v", active, ok)
+			}
+			switch {
+			case tt.wantEnd == "" && ok:
+				t.Errorf("active until %s, want inactive", end.UTC().Format(time.DateTime))
+			case tt.wantEnd != "" && !ok:
+				t.Errorf("inactive, want active until %s", tt.wantEnd)
+			case tt.wantEnd != "" && !end.Equal(date(tt.wantEnd)):
+				t.Errorf("active until %s, want %s", end.UTC().Format(time.DateTime), tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	web := &models.Server{ID: 1, Name: "web-1", Tags: []string{"prod", "web"}}
+	db := &models.Server{ID: 2, Name: "db-1", Tags: []string{"prod"}}
+	nginx := &models.Service{ID: 10, ServerID: web.ID}
+	postgres := &models.Service{ID: 20, ServerID: db.ID}
+
+	always := datePtr("2026-01-01 00:00:00")
+	later := datePtr("2027-01-01 00:00:00")
+	byServer := &models.MaintenanceWindow{Name: "server", Scope: models.ScopeServer, ServerID: web.ID, StartsAt: always, EndsAt: later}
+	byService := &models.MaintenanceWindow{Name: "service", Scope: models.ScopeService, ServiceID: postgres.ID, StartsAt: always, EndsAt: later}
+	byTag := &models.MaintenanceWindow{Name: "tag", Scope: models.ScopeTag, Tag: "WEB", StartsAt: always, EndsAt: later}
+	expired := &models.MaintenanceWindow{Name: "expired", Scope: models.ScopeTag, Tag: "prod",
+		StartsAt: datePtr("2025-01-01 00:00:00"), EndsAt: datePtr("2025-01-02 00:00:00")}
+
+	tests := []struct {
+		name      string
+		windows   []*models.MaintenanceWindow
+		server    *models.Server
+		service   *models.Service
+		wantMatch string // Name of the window Match returns
+		wantFind  string // Name of the window Find returns at 2026-03-02
+	}{
+		{"server", []*models.MaintenanceWindow{byService, byServer}, web, nginx, "server", "server"},
+		{"server without service", []*models.MaintenanceWindow{byServer}, web, nil, "server", "server"},
+		{"other server", []*models.MaintenanceWindow{byServer}, db, postgres, "", ""},
+		{"service", []*models.MaintenanceWindow{byServer, byService}, db, postgres, "service", "service"},
+		{"service of another server", []*models.MaintenanceWindow{byService}, web, nginx, "", ""},
+		{"tag ignores case", []*models.MaintenanceWindow{byTag}, web, nginx, "tag", "tag"},
+		{"first covering window", []*models.MaintenanceWindow{byTag, byServer}, web, nginx, "tag", "tag"},
+		{"expired window skipped by find", []*models.MaintenanceWindow{expired, byServer}, web, nginx, "expired", "server"},
+		{"no windows", nil, web, nginx, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := func(w *models.MaintenanceWindow) string {
+				if w == nil {
+					return ""
+				}
+				return w.Name
+			}
+			if got := name(Match(tt.windows, tt.server, tt.service)); got != tt.wantMatch {
+				t.Errorf("Match = %q, want %q", got, tt.wantMatch)
+			}
+			if got := name(Find(tt.windows, tt.server, tt.service, date("2026-03-02 12:00:00"))); got != tt.wantFind {
+				t.Errorf("Find = %q, want %q", got, tt.wantFind)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		window  models.MaintenanceWindow
+		wantErr string
+	}{
+		{"one-off", models.MaintenanceWindow{Name: "upgrade", Scope: models.ScopeServer, ServerID: 1,
+			StartsAt: datePtr("2026-03-02 10:00:00"), EndsAt: datePtr("2026-03-02 12:00:00")}, ""},
+		{"recurring", models.MaintenanceWindow{Name: "backup", Scope: models.ScopeTag, Tag: "db",
+			Schedule: "0 2 * * sun", Duration: 3600, Timezone: "Europe/Istanbul"}, ""},
+		{"no name", models.MaintenanceWindow{Scope: models.ScopeServer, ServerID: 1}, "name is required"},
+		{"unknown scope", models.MaintenanceWindow{Name: "x", Scope: "cluster"}, "scope must be one of"},
+		{"service scope without service", models.MaintenanceWindow{Name: "x", Scope: models.ScopeService}, "service_id is required"},
+		{"ends before start", models.MaintenanceWindow{Name: "x", Scope: models.ScopeTag, Tag: "db",
+			StartsAt: datePtr("2026-03-02 12:00:00"), EndsAt: datePtr("2026-03-02 10:00:00")}, "ends_at must be after starts_at"},
+		{"one-off without end", models.MaintenanceWindow{Name: "x", Scope: models.ScopeTag, Tag: "db",
+			StartsAt: datePtr("2026-03-02 12:00:00")}, "starts_at and ends_at are required"},
+		{"invalid schedule", models.MaintenanceWindow{Name: "x", Scope: models.ScopeTag, Tag: "db",
+			Schedule: "0 2 * *", Duration: 3600}, "invalid schedule: cron expression must have 5 fields"},
+		{"too long", models.MaintenanceWindow{Name: "x", Scope: models.ScopeTag, Tag: "db",
+			Schedule: "0 2 * * *", Duration: 8 * 24 * 3600}, "duration must be between"},
+		{"unknown time zone", models.MaintenanceWindow{Name: "x", Scope: models.ScopeTag, Tag: "db",
+			Schedule: "0 2 * * *", Duration: 3600, Timezone: "Mars/Olympus"}, "invalid timezone"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkError(t, Validate(&tt.window), tt.wantErr)
+		})
+	}
+}
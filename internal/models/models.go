@@ -1,6 +1,14 @@
 package models
 
-import "time"
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // MonitoringMode defines how the server is monitored
 type MonitoringMode string
@@ -22,6 +30,44 @@ const (
 	StatusDegraded ServiceStatus = "degraded"
 )
 
+// Severity ranks how urgent an alert is
+type Severity string
+
+const (
+	SeverityCritical Severity = "critical"
+	SeverityWarning  Severity = "warning"
+	SeverityInfo     Severity = "info"
+)
+
+// ValidSeverity reports whether s is a known severity
+func ValidSeverity(s Severity) bool {
+	return s == SeverityCritical || s == SeverityWarning || s == SeverityInfo
+}
+
+// Rank orders severities from info (1) to critical (3); unknown ones rank 0
+func (s Severity) Rank() int {
+	switch s {
+	case SeverityInfo:
+		return 1
+	case SeverityWarning:
+		return 2
+	case SeverityCritical:
+		return 3
+	}
+	return 0
+}
+
+// Severity returns the alert severity of a service status
+func (s ServiceStatus) Severity() Severity {
+	switch s {
+	case StatusStopped, StatusFailed:
+		return SeverityCritical
+	case StatusDegraded, StatusUnknown:
+		return SeverityWarning
+	}
+	return SeverityInfo
+}
+
 // ConnectionStatus represents the connection state of a server
 type ConnectionStatus string
 
@@ -34,38 +80,472 @@ const (
 
 // Server represents a monitored server
 type Server struct {
-	ID               int              `json:"id"`
-	Name             string           `json:"name"`
-	Hostname         string           `json:"hostname"`
-	IPAddress        string           `json:"ip_address"`
-	Port             int              `json:"port"`
-	OS               string           `json:"os"` // linux, windows, etc.
-	MonitoringMode   MonitoringMode   `json:"monitoring_mode"`
-	SSHUser          string           `json:"ssh_user,omitempty"`
-	SSHKeyPath       string           `json:"ssh_key_path,omitempty"`
-	SSHJumpHost      string           `json:"ssh_jump_host,omitempty"`     // Jump host for SSH tunnel
-	SSHJumpUser      string           `json:"ssh_jump_user,omitempty"`     // Jump host user
-	SSHJumpKeyPath   string           `json:"ssh_jump_key_path,omitempty"` // Jump host key
-	AgentToken       string           `json:"agent_token,omitempty"`
-	CheckInterval    int              `json:"check_interval"` // Check interval in seconds (0 = use default)
-	ConnectionStatus ConnectionStatus `json:"connection_status"`
-	Enabled          bool             `json:"enabled"`
-	LastSeen         *time.Time       `json:"last_seen"`
-	CreatedAt        time.Time        `json:"created_at"`
-	UpdatedAt        time.Time        `json:"updated_at"`
-	NotifyTelegram   bool             `json:"notify_telegram"`
+	ID                  int              `json:"id"`
+	Name                string           `json:"name"`
+	Hostname            string           `json:"hostname"`
+	IPAddress           string           `json:"ip_address"`
+	Port                int              `json:"port"`
+	OS                  string           `json:"os"` // linux, windows, etc.
+	MonitoringMode      MonitoringMode   `json:"monitoring_mode"`
+	SSHUser             string           `json:"ssh_user,omitempty"`
+	SSHKeyPath          string           `json:"ssh_key_path,omitempty"`
+	SSHKeySecret        string           `json:"ssh_key_secret,omitempty"`        // Vault secret holding the private key, instead of a key file
+	SSHAuthMethod       SSHAuthMethod    `json:"ssh_auth_method,omitempty"`       // Empty = key file, or the stored key if one is set
+	SSHPasswordSecret   string           `json:"ssh_password_secret,omitempty"`   // Vault secret holding the login password
+	SSHPassphraseSecret string           `json:"ssh_passphrase_secret,omitempty"` // Vault secret holding the passphrase of an encrypted key
+	SSHAgentSocket      string           `json:"ssh_agent_socket,omitempty"`      // Empty = $SSH_AUTH_SOCK
+	SSHJumpHost         string           `json:"ssh_jump_host,omitempty"`         // Jump host for SSH tunnel
+	SSHJumpUser         string           `json:"ssh_jump_user,omitempty"`         // Jump host user
+	SSHJumpKeyPath      string           `json:"ssh_jump_key_path,omitempty"`     // Jump host key
+	SSHJumpHosts        []JumpHost       `json:"ssh_jump_hosts,omitempty"`        // Jump chain, first hop first; replaces the single jump host
+	Transport           Transport        `json:"transport,omitempty"`             // How pull mode reaches the server; empty = ssh
+	WinRM               *WinRMConfig     `json:"winrm,omitempty"`                 // Settings of the winrm transport
+	AgentToken          string           `json:"agent_token,omitempty"`           // Set on create or update only; stored as AgentTokenHash
+	AgentTokenHash      string           `json:"-"`                               // Hex SHA-256 of the agent token
+	AgentTokenSecret    string           `json:"agent_token_secret,omitempty"`    // Vault secret holding the agent token, instead of agent_token
+	CheckInterval       int              `json:"check_interval"`                  // Check interval in seconds (0 = use default)
+	ConnectionStatus    ConnectionStatus `json:"connection_status"`
+	Enabled             bool             `json:"enabled"`
+	LastSeen            *time.Time       `json:"last_seen"`
+	CreatedAt           time.Time        `json:"created_at"`
+	UpdatedAt           time.Time        `json:"updated_at"`
+	NotifyTelegram      bool             `json:"notify_telegram"`
+	Tags                []string         `json:"tags"` // Free-form labels used to group servers (e.g. maintenance scopes)
+
+	EscalationPolicyID int `json:"escalation_policy_id,omitempty"` // Default policy for alerts of this server's services
+}
+
+// JumpHost is one hop of an SSH jump chain. User and key default to the
+// local user and the default keys, like for the target server.
+type JumpHost struct {
+	Host      string `json:"host" yaml:"host"`
+	Port      int    `json:"port,omitempty" yaml:"port,omitempty"` // 0 = 22
+	User      string `json:"user,omitempty" yaml:"user,omitempty"`
+	KeyPath   string `json:"key_path,omitempty" yaml:"key_path,omitempty"`
+	KeySecret string `json:"key_secret,omitempty" yaml:"key_secret,omitempty"` // Vault secret, instead of a key file
+
+	AuthMethod       SSHAuthMethod `json:"auth_method,omitempty" yaml:"auth_method,omitempty"`
+	PasswordSecret   string        `json:"password_secret,omitempty" yaml:"password_secret,omitempty"`
+	PassphraseSecret string        `json:"passphrase_secret,omitempty" yaml:"passphrase_secret,omitempty"`
+	AgentSocket      string        `json:"agent_socket,omitempty" yaml:"agent_socket,omitempty"`
+}
+
+// Transport is how pull and hybrid mode run commands on a server
+type Transport string
+
+const (
+	TransportSSH   Transport = "ssh"   // OpenSSH, on Linux or Windows
+	TransportWinRM Transport = "winrm" // WS-Management, Windows only
+)
+
+// WinRMAuth is how Vigilon authenticates to the WinRM service
+type WinRMAuth string
+
+const (
+	WinRMAuthNTLM  WinRMAuth = "ntlm"
+	WinRMAuthBasic WinRMAuth = "basic"
+)
+
+// WinRMConfig holds the WinRM settings of a server. The server port is the
+// WinRM port, 5985 for HTTP and 5986 for HTTPS by default.
+type WinRMConfig struct {
+	HTTPS          bool      `json:"https,omitempty" yaml:"https,omitempty"`
+	Insecure       bool      `json:"insecure,omitempty" yaml:"insecure,omitempty"` // Skip verifying the HTTPS certificate
+	Auth           WinRMAuth `json:"auth,omitempty" yaml:"auth,omitempty"`         // Empty = ntlm
+	User           string    `json:"user" yaml:"user"`                             // user, DOMAIN\user or user@domain
+	PasswordSecret string    `json:"password_secret" yaml:"password_secret"`       // Vault secret holding the password
+}
+
+// Valid reports whether the WinRM auth is known. Empty selects NTLM.
+func (a WinRMAuth) Valid() bool {
+	switch a {
+	case "", WinRMAuthNTLM, WinRMAuthBasic:
+		return true
+	}
+	return false
+}
+
+// UsesWinRM reports whether pull mode reaches the server over WinRM
+func (s *Server) UsesWinRM() bool {
+	return s.Transport == TransportWinRM
+}
+
+// SSHAuthMethod is how Vigilon authenticates to a server or jump host
+type SSHAuthMethod string
+
+const (
+	AuthKeyFile   SSHAuthMethod = "key_file"   // Private key file, or the default keys in ~/.ssh
+	AuthStoredKey SSHAuthMethod = "stored_key" // Private key from the vault
+	AuthPassword  SSHAuthMethod = "password"   // Password from the vault
+	AuthAgent     SSHAuthMethod = "agent"      // Keys of an ssh-agent
+)
+
+// ResolveAuthMethod returns the auth method in effect: the configured one,
+// else the stored key if one is referenced, else the key file
+func ResolveAuthMethod(method SSHAuthMethod, keySecret string) SSHAuthMethod {
+	if method != "" {
+		return method
+	}
+	if keySecret != "" {
+		return AuthStoredKey
+	}
+	return AuthKeyFile
+}
+
+// Valid reports whether the auth method is known. Empty selects the default.
+func (m SSHAuthMethod) Valid() bool {
+	switch m {
+	case "", AuthKeyFile, AuthStoredKey, AuthPassword, AuthAgent:
+		return true
+	}
+	return false
+}
+
+// SecretRef is a vault secret used by a server and the type it must have
+type SecretRef struct {
+	Name string
+	Type SecretType
+}
+
+// JumpChain returns the hops to the server in connection order: the jump
+// chain if set, else the single jump host, whose address may carry a port
+// ("bastion:2222"). It is empty for direct connections.
+func (s *Server) JumpChain() []JumpHost {
+	if len(s.SSHJumpHosts) > 0 {
+		return s.SSHJumpHosts
+	}
+	if s.SSHJumpHost == "" {
+		return nil
+	}
+
+	hop := JumpHost{Host: s.SSHJumpHost, User: s.SSHJumpUser, KeyPath: s.SSHJumpKeyPath}
+	if host, port, err := net.SplitHostPort(s.SSHJumpHost); err == nil {
+		hop.Host = host
+		hop.Port, _ = strconv.Atoi(port)
+	}
+	return []JumpHost{hop}
+}
+
+// SecretRefs returns the vault secrets the server and its jump hosts use
+func (s *Server) SecretRefs() []SecretRef {
+	var refs []SecretRef
+	if s.UsesWinRM() {
+		var password string
+		if s.WinRM != nil {
+			password = s.WinRM.PasswordSecret
+		}
+		refs = append(refs, SecretRef{password, SecretPassword})
+	} else {
+		refs = authSecretRefs(ResolveAuthMethod(s.SSHAuthMethod, s.SSHKeySecret), s.SSHKeySecret, s.SSHPasswordSecret, s.SSHPassphraseSecret)
+		for _, hop := range s.JumpChain() {
+			refs = append(refs, authSecretRefs(ResolveAuthMethod(hop.AuthMethod, hop.KeySecret), hop.KeySecret, hop.PasswordSecret, hop.PassphraseSecret)...)
+		}
+	}
+	if s.AgentTokenSecret != "" {
+		refs = append(refs, SecretRef{s.AgentTokenSecret, SecretToken})
+	}
+	return refs
+}
+
+// HashAgentToken returns the hex SHA-256 digest an agent token is stored as
+func HashAgentToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// MatchesAgentToken reports in constant time whether token is the stored
+// agent token of the server
+func (s *Server) MatchesAgentToken(token string) bool {
+	if token == "" || s.AgentTokenHash == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(HashAgentToken(token)), []byte(s.AgentTokenHash)) == 1
+}
+
+// authSecretRefs returns the secrets an auth method uses
+func authSecretRefs(method SSHAuthMethod, keySecret, passwordSecret, passphraseSecret string) []SecretRef {
+	var refs []SecretRef
+	switch method {
+	case AuthStoredKey:
+		refs = append(refs, SecretRef{keySecret, SecretSSHKey})
+	case AuthPassword:
+		refs = append(refs, SecretRef{passwordSecret, SecretPassword})
+	}
+	if passphraseSecret != "" && (method == AuthKeyFile || method == AuthStoredKey) {
+		refs = append(refs, SecretRef{passphraseSecret, SecretPassword})
+	}
+	return refs
+}
+
+// HostKey is the SSH host key trusted for an address, a server or a jump
+// host. The first key seen is trusted; a different key is refused and kept
+// as pending until an admin trusts it.
+type HostKey struct {
+	ID                 int        `json:"id"`
+	Address            string     `json:"address"` // host:port as configured
+	KeyType            string     `json:"key_type"`
+	Fingerprint        string     `json:"fingerprint"` // SHA256:...
+	PublicKey          string     `json:"public_key"`  // authorized_keys format
+	PendingKeyType     string     `json:"pending_key_type,omitempty"`
+	PendingFingerprint string     `json:"pending_fingerprint,omitempty"` // Mismatching key last presented by the host
+	PendingPublicKey   string     `json:"pending_public_key,omitempty"`
+	PendingSeenAt      *time.Time `json:"pending_seen_at,omitempty"`
+	TrustedBy          string     `json:"trusted_by"` // "first use" or the user who trusted the key
+	TrustedAt          time.Time  `json:"trusted_at"`
+	CreatedAt          time.Time  `json:"created_at"`
+}
+
+// SSHAddress returns the host:port address an SSH host key is stored under
+func SSHAddress(host string, port int) string {
+	if port == 0 {
+		port = 22
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// HasPending reports whether the host presented a key that is not trusted
+func (k *HostKey) HasPending() bool {
+	return k.PendingFingerprint != ""
+}
+
+// HasTag reports whether the server is labelled with tag (case-insensitive)
+func (s *Server) HasTag(tag string) bool {
+	for _, t := range s.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
 }
 
 // Service represents a service to monitor on a server
 type Service struct {
-	ID          int       `json:"id"`
-	ServerID    int       `json:"server_id"`
-	Name        string    `json:"name"` // e.g., "rftt.service", "nginx", etc.
-	DisplayName string    `json:"display_name"`
-	Description string    `json:"description"`
-	Enabled     bool      `json:"enabled"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          int    `json:"id"`
+	ServerID    int    `json:"server_id"`
+	Name        string `json:"name"` // e.g., "rftt.service", "nginx", etc.
+	DisplayName string `json:"display_name"`
+	Description string `json:"description"`
+	Enabled     bool   `json:"enabled"`
+
+	Type      ServiceType `json:"type,omitempty"`       // Empty = the service manager of the server (systemd or Windows)
+	CheckFrom CheckSource `json:"check_from,omitempty"` // Where endpoint checks run; empty = the Vigilon server
+	HTTP      *HTTPCheck  `json:"http,omitempty"`       // Settings of an http check
+	TLS       *TLSCheck   `json:"tls,omitempty"`        // Settings of a tls check
+	TCP       *TCPCheck   `json:"tcp,omitempty"`        // Settings of a tcp check
+	Ping      *PingCheck  `json:"ping,omitempty"`       // Settings of a ping check
+
+	Reachability bool `json:"reachability,omitempty"` // A tcp or ping check that tells whether the server itself is up
+
+	// Alerting sensitivity
+	FailureThreshold  int `json:"failure_threshold"`  // Consecutive failed checks before alerting
+	RecoveryThreshold int `json:"recovery_threshold"` // Consecutive successful checks before recovering
+	FlapThreshold     int `json:"flap_threshold"`     // Status changes within FlapWindow that mark the service as flapping (0 = disabled)
+	FlapWindow        int `json:"flap_window"`        // Flap detection window in seconds
+
+	EscalationPolicyID int `json:"escalation_policy_id,omitempty"` // Overrides the server's policy
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ApplyDefaults fills unset alerting settings with their defaults
+func (s *Service) ApplyDefaults() {
+	if s.FailureThreshold < 1 {
+		s.FailureThreshold = 1
+	}
+	if s.RecoveryThreshold < 1 {
+		s.RecoveryThreshold = 1
+	}
+	if s.FlapThreshold < 0 {
+		s.FlapThreshold = 0
+	}
+	if s.FlapWindow <= 0 {
+		s.FlapWindow = 600
+	}
+}
+
+// ServiceType is what a service is and how it is checked
+type ServiceType string
+
+const (
+	ServiceTypeSystemd ServiceType = "systemd"         // systemd unit, checked on the server
+	ServiceTypeWindows ServiceType = "windows_service" // Windows service, checked on the server
+	ServiceTypeHTTP    ServiceType = "http"            // HTTP(S) endpoint, requested over the network
+	ServiceTypeTLS     ServiceType = "tls"             // TLS certificate of an endpoint, checked over the network
+	ServiceTypeTCP     ServiceType = "tcp"             // TCP port, connected to over the network
+	ServiceTypePing    ServiceType = "ping"            // Host answering ICMP echo requests
+)
+
+// Valid reports whether the service type is known. Empty selects the
+// service manager of the server.
+func (t ServiceType) Valid() bool {
+	switch t {
+	case "", ServiceTypeSystemd, ServiceTypeWindows, ServiceTypeHTTP, ServiceTypeTLS, ServiceTypeTCP, ServiceTypePing:
+		return true
+	}
+	return false
+}
+
+// IsEndpoint reports whether the service is a network endpoint rather than
+// a process managed on the server. Endpoints cannot be restarted.
+func (s *Service) IsEndpoint() bool {
+	switch s.Type {
+	case ServiceTypeHTTP, ServiceTypeTLS, ServiceTypeTCP, ServiceTypePing:
+		return true
+	}
+	return false
+}
+
+// CheckSource is where the checks of an endpoint run
+type CheckSource string
+
+const (
+	CheckFromServer CheckSource = "server" // The Vigilon server
+	CheckFromAgent  CheckSource = "agent"  // The agent of a push server, for internal-only endpoints
+)
+
+// CheckedByAgent reports whether the status of the service comes from the
+// agent of its server. Endpoints of push servers are checked by Vigilon
+// itself unless they are set to be checked from the agent.
+func (s *Service) CheckedByAgent(server *Server) bool {
+	if server.MonitoringMode != ModePush {
+		return false
+	}
+	return !s.IsEndpoint() || s.CheckFrom == CheckFromAgent
+}
+
+// HTTPCheck holds the request and the assertions of an http service. The
+// endpoint is up when every assertion that is set holds.
+type HTTPCheck struct {
+	URL            string            `json:"url" yaml:"url"`
+	Method         string            `json:"method,omitempty" yaml:"method,omitempty"` // Empty = GET
+	Headers        map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Body           string            `json:"body,omitempty" yaml:"body,omitempty"`
+	ExpectedStatus []int             `json:"expected_status,omitempty" yaml:"expected_status,omitempty"` // Empty = any 2xx
+	BodyContains   string            `json:"body_contains,omitempty" yaml:"body_contains,omitempty"`
+	BodyRegex      string            `json:"body_regex,omitempty" yaml:"body_regex,omitempty"`
+	JSONPath       string            `json:"json_path,omitempty" yaml:"json_path,omitempty"`                 // e.g. "status" or "checks[0].state"
+	JSONValue      string            `json:"json_value,omitempty" yaml:"json_value,omitempty"`               // Expected value at JSONPath; empty = the path must exist
+	Redirects      RedirectPolicy    `json:"redirects,omitempty" yaml:"redirects,omitempty"`                 // Empty = follow
+	Timeout        int               `json:"timeout,omitempty" yaml:"timeout,omitempty"`                     // Seconds; 0 = 10
+	DegradedAfter  int               `json:"degraded_after_ms,omitempty" yaml:"degraded_after_ms,omitempty"` // Response time that marks the endpoint degraded; 0 = never
+	Insecure       bool              `json:"insecure,omitempty" yaml:"insecure,omitempty"`                   // Skip verifying the certificate
+}
+
+// RedirectPolicy is how an http check handles redirects
+type RedirectPolicy string
+
+const (
+	RedirectFollow   RedirectPolicy = "follow"    // Follow up to 10 redirects
+	RedirectNone     RedirectPolicy = "none"      // Check the redirect response itself
+	RedirectSameHost RedirectPolicy = "same_host" // Follow redirects to the same host only
+)
+
+// Valid reports whether the redirect policy is known. Empty selects follow.
+func (p RedirectPolicy) Valid() bool {
+	switch p {
+	case "", RedirectFollow, RedirectNone, RedirectSameHost:
+		return true
+	}
+	return false
+}
+
+// TLSCheck holds the connection settings and expiry thresholds of a tls
+// service. The certificate chain and host name are always verified.
+type TLSCheck struct {
+	Host       string           `json:"host" yaml:"host"`
+	Port       int              `json:"port,omitempty" yaml:"port,omitempty"`               // 0 = the default port of the protocol
+	ServerName string           `json:"server_name,omitempty" yaml:"server_name,omitempty"` // Sent as SNI and verified; empty = Host
+	StartTLS   StartTLSProtocol `json:"starttls,omitempty" yaml:"starttls,omitempty"`       // Upgrade a plaintext connection first; empty = TLS from the start
+	Timeout    int              `json:"timeout,omitempty" yaml:"timeout,omitempty"`         // Seconds; 0 = 10
+	CAFile     string           `json:"ca_file,omitempty" yaml:"ca_file,omitempty"`         // PEM bundle trusted instead of the system roots, e.g. an internal CA
+
+	ExpiryThresholds []ExpiryThreshold `json:"expiry_thresholds,omitempty" yaml:"expiry_thresholds,omitempty"` // Empty = DefaultExpiryThresholds
+}
+
+// TCPCheck holds the port and the optional exchange of a tcp service. The
+// port is up once it accepts the connection and, if Expect is set, the
+// reply contains Expect.
+type TCPCheck struct {
+	Host          string `json:"host" yaml:"host"`
+	Port          int    `json:"port" yaml:"port"`
+	Send          string `json:"send,omitempty" yaml:"send,omitempty"`                           // Written once connected, e.g. "PING\r\n"
+	Expect        string `json:"expect,omitempty" yaml:"expect,omitempty"`                       // Expected in the reply or banner, e.g. "SSH-2.0"
+	Timeout       int    `json:"timeout,omitempty" yaml:"timeout,omitempty"`                     // Seconds; 0 = 10
+	DegradedAfter int    `json:"degraded_after_ms,omitempty" yaml:"degraded_after_ms,omitempty"` // Connect time that marks the port degraded; 0 = never
+}
+
+// PingCheck holds the settings of a ping service, which sends ICMP echo
+// requests to a host
+type PingCheck struct {
+	Host          string `json:"host" yaml:"host"`
+	Count         int    `json:"count,omitempty" yaml:"count,omitempty"`                         // Echo requests per check; 0 = 3
+	Timeout       int    `json:"timeout,omitempty" yaml:"timeout,omitempty"`                     // Seconds for the whole check; 0 = 10
+	DegradedAfter int    `json:"degraded_after_ms,omitempty" yaml:"degraded_after_ms,omitempty"` // Average round trip that marks the host degraded; 0 = never
+	MaxLoss       int    `json:"max_loss,omitempty" yaml:"max_loss,omitempty"`                   // Packet loss in percent tolerated before the host is degraded
+}
+
+// StartTLSProtocol is the plaintext protocol a tls check upgrades
+type StartTLSProtocol string
+
+const (
+	StartTLSSMTP     StartTLSProtocol = "smtp"
+	StartTLSIMAP     StartTLSProtocol = "imap"
+	StartTLSPostgres StartTLSProtocol = "postgres"
+)
+
+// DefaultPort returns the usual port of the protocol, 443 for plain TLS
+func (p StartTLSProtocol) DefaultPort() int {
+	switch p {
+	case StartTLSSMTP:
+		return 587
+	case StartTLSIMAP:
+		return 143
+	case StartTLSPostgres:
+		return 5432
+	}
+	return 443
+}
+
+// Valid reports whether the protocol is known. Empty means no STARTTLS.
+func (p StartTLSProtocol) Valid() bool {
+	return p == "" || p.DefaultPort() != 443
+}
+
+// ExpiryThreshold alerts with a severity once a certificate expires within
+// Days
+type ExpiryThreshold struct {
+	Days     int      `json:"days" yaml:"days"`
+	Severity Severity `json:"severity" yaml:"severity"`
+}
+
+// DefaultExpiryThresholds announce a certificate 30, 14 and 3 days before
+// it expires, more urgently each time
+var DefaultExpiryThresholds = []ExpiryThreshold{
+	{Days: 30, Severity: SeverityInfo},
+	{Days: 14, Severity: SeverityWarning},
+	{Days: 3, Severity: SeverityCritical},
+}
+
+// Thresholds returns the expiry thresholds of the check, or the defaults
+func (c *TLSCheck) Thresholds() []ExpiryThreshold {
+	if len(c.ExpiryThresholds) == 0 {
+		return DefaultExpiryThresholds
+	}
+	return c.ExpiryThresholds
+}
+
+// CrossedThreshold returns the tightest threshold crossed by a certificate
+// that expires in days
+func (c *TLSCheck) CrossedThreshold(days int) (ExpiryThreshold, bool) {
+	var crossed ExpiryThreshold
+	found := false
+	for _, t := range c.Thresholds() {
+		if days <= t.Days && (!found || t.Days < crossed.Days) {
+			crossed, found = t, true
+		}
+	}
+	return crossed, found
 }
 
 // ServiceCheck represents a monitoring check result
@@ -80,21 +560,288 @@ type ServiceCheck struct {
 	Memory       int64         `json:"memory_kb,omitempty"` // in KB
 	CPU          float64       `json:"cpu_percent,omitempty"`
 	Uptime       int64         `json:"uptime_seconds,omitempty"`
+	DaysToExpiry *int          `json:"days_to_expiry,omitempty"`      // Certificate of a tls check; negative once expired
+	Latency      *float64      `json:"latency_ms,omitempty"`          // Connect time of a tcp check, average round trip of a ping check
+	PacketLoss   *float64      `json:"packet_loss_percent,omitempty"` // Echo requests of a ping check left unanswered
 }
 
+// ServiceState is the persisted alerting state of a service, used to alert
+// on status transitions instead of on every failed check
+type ServiceState struct {
+	ServiceID      int           `json:"service_id"`
+	Status         ServiceStatus `json:"status"`                  // Last known status
+	Since          time.Time     `json:"since"`                   // When the service entered Status
+	OpenAlertID    int           `json:"open_alert_id,omitempty"` // Alert announcing the current outage
+	LastNotifiedAt *time.Time    `json:"last_notified_at,omitempty"`
+	Reminders      int           `json:"reminders"`     // Reminders sent for the open alert
+	PendingCount   int           `json:"pending_count"` // Consecutive checks disagreeing with Status
+	Flapping       bool          `json:"flapping"`      // Notifications are suppressed while flapping
+	LastCheckID    int           `json:"last_check_id"` // Last check counted towards the thresholds
+	UpdatedAt      time.Time     `json:"updated_at"`
+}
+
+// AlertState represents the lifecycle state of an alert
+type AlertState string
+
+const (
+	AlertOpen     AlertState = "open"     // Service is still not running
+	AlertResolved AlertState = "resolved" // Service recovered
+)
+
+// AlertType tells what an alert is about
+type AlertType string
+
+const (
+	AlertService  AlertType = "service"   // A service is not running
+	AlertHostKey  AlertType = "host_key"  // A server presented an unknown SSH host key
+	AlertHostDown AlertType = "host_down" // Every reachability check of a server failed
+)
+
 // Alert represents a notification sent
 type Alert struct {
-	ID             int           `json:"id"`
-	ServiceID      int           `json:"service_id"`
-	ServerID       int           `json:"server_id"`
-	Status         ServiceStatus `json:"status"`
-	Message        string        `json:"message"`
-	SentVia        string        `json:"sent_via"` // telegram, email, etc.
-	Acknowledged   bool          `json:"acknowledged"`
-	Archived       bool          `json:"archived"`
-	CreatedAt      time.Time     `json:"created_at"`
-	AcknowledgedAt *time.Time    `json:"acknowledged_at,omitempty"`
-	ArchivedAt     *time.Time    `json:"archived_at,omitempty"`
+	ID             int             `json:"id"`
+	Type           AlertType       `json:"type"`
+	ServiceID      int             `json:"service_id"` // For host key and host down alerts, the service whose check noticed it
+	ServerID       int             `json:"server_id"`
+	Status         ServiceStatus   `json:"status"`
+	Message        string          `json:"message"`
+	SentVia        string          `json:"sent_via"` // telegram, email, etc.
+	Acknowledged   bool            `json:"acknowledged"`
+	Archived       bool            `json:"archived"`
+	CreatedAt      time.Time       `json:"created_at"`
+	AcknowledgedAt *time.Time      `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy string          `json:"acknowledged_by,omitempty"`
+	ArchivedAt     *time.Time      `json:"archived_at,omitempty"`
+	State          AlertState      `json:"state"`
+	ResolvedAt     *time.Time      `json:"resolved_at,omitempty"`
+	DowntimeSecs   int64           `json:"downtime_seconds,omitempty"` // Set when the alert is resolved
+	Deliveries     []AlertDelivery `json:"deliveries,omitempty"`
+
+	EscalationPolicyID int        `json:"escalation_policy_id,omitempty"`
+	EscalationStep     int        `json:"escalation_step"` // Number of escalation steps already executed
+	EscalatedAt        *time.Time `json:"escalated_at,omitempty"`
+
+	Level Severity `json:"severity,omitempty"` // Set when the severity does not follow the status, e.g. for an expiring certificate
+}
+
+// Severity returns the severity of the alert. A changed host key may be an
+// attack, so it is always critical.
+func (a *Alert) Severity() Severity {
+	if a.Type == AlertHostKey {
+		return SeverityCritical
+	}
+	if a.Level != "" {
+		return a.Level
+	}
+	return a.Status.Severity()
+}
+
+// Downtime returns how long the service was down for a resolved alert
+func (a *Alert) Downtime() time.Duration {
+	return time.Duration(a.DowntimeSecs) * time.Second
+}
+
+// DeliveryStatus represents the state of a notification delivery
+type DeliveryStatus string
+
+const (
+	DeliveryPending DeliveryStatus = "pending"
+	DeliverySent    DeliveryStatus = "sent"
+	DeliveryFailed  DeliveryStatus = "failed"
+)
+
+// AlertDelivery records the result of sending an alert through one channel
+type AlertDelivery struct {
+	ID        int            `json:"id"`
+	AlertID   int            `json:"alert_id"`
+	Event     string         `json:"event"`   // alert, recovery, etc.
+	Channel   string         `json:"channel"` // telegram, email, etc.
+	Status    DeliveryStatus `json:"status"`
+	Attempts  int            `json:"attempts"`
+	LastError string         `json:"last_error,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	SentAt    *time.Time     `json:"sent_at,omitempty"`
+}
+
+// MaintenanceScope defines what a maintenance window applies to
+type MaintenanceScope string
+
+const (
+	ScopeServer  MaintenanceScope = "server"  // All services of a server
+	ScopeService MaintenanceScope = "service" // A single service
+	ScopeTag     MaintenanceScope = "tag"     // All services of servers with a tag
+)
+
+// MaintenanceWindow suppresses notifications for its scope while active.
+// One-off windows (including silences) run from StartsAt to EndsAt; recurring
+// windows start whenever Schedule (a cron expression) matches and last for
+// Duration seconds, optionally bounded by StartsAt/EndsAt.
+type MaintenanceWindow struct {
+	ID        int              `json:"id"`
+	Name      string           `json:"name"`
+	Reason    string           `json:"reason,omitempty"`
+	Scope     MaintenanceScope `json:"scope"`
+	ServerID  int              `json:"server_id,omitempty"`
+	ServiceID int              `json:"service_id,omitempty"`
+	Tag       string           `json:"tag,omitempty"`
+	StartsAt  *time.Time       `json:"starts_at,omitempty"`
+	EndsAt    *time.Time       `json:"ends_at,omitempty"`
+	Schedule  string           `json:"schedule,omitempty"` // e.g. "0 2 * * 0" for Sundays at 02:00
+	Duration  int              `json:"duration,omitempty"` // Length of each recurring window in seconds
+	Timezone  string           `json:"timezone,omitempty"` // Time zone of Schedule (default: server local time)
+	CreatedBy string           `json:"created_by,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// Recurring reports whether the window repeats on a cron schedule
+func (w *MaintenanceWindow) Recurring() bool {
+	return w.Schedule != ""
+}
+
+// Recipient addresses a specific destination on a notification channel,
+// e.g. a Telegram chat ID or an email address
+type Recipient struct {
+	Channel string `json:"channel"`
+	Address string `json:"address"`
+}
+
+// EscalationStep notifies additional channels or recipients when an alert is
+// still unacknowledged Delay minutes after the previous step (or the alert)
+type EscalationStep struct {
+	Delay      int         `json:"delay"`                // Minutes
+	Channels   []string    `json:"channels,omitempty"`   // Channels to notify with their default recipients
+	Recipients []Recipient `json:"recipients,omitempty"` // Specific recipients to notify
+	Users      []int       `json:"users,omitempty"`      // Users to notify through their contact methods
+	Schedules  []int       `json:"schedules,omitempty"`  // On-call schedules whose current on-call user is notified
+}
+
+// EscalationPolicy is an ordered list of escalation steps
+type EscalationPolicy struct {
+	ID          int              `json:"id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Steps       []EscalationStep `json:"steps"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// Webhook posts notification events to an HTTP endpoint
+type Webhook struct {
+	ID              int               `json:"id"`
+	Name            string            `json:"name"`
+	URL             string            `json:"url"`
+	Method          string            `json:"method"`       // Defaults to POST
+	ContentType     string            `json:"content_type"` // Defaults to application/json
+	Headers         map[string]string `json:"headers"`
+	Secret          string            `json:"secret,omitempty"`         // HMAC-SHA256 key; never returned by the API
+	SigningSecret   string            `json:"signing_secret,omitempty"` // Vault secret holding the HMAC-SHA256 key, instead of secret
+	HasSecret       bool              `json:"has_secret"`               // Set in API responses instead of Secret
+	SignatureHeader string            `json:"signature_header"`         // Defaults to X-Vigilon-Signature
+	BodyTemplate    string            `json:"body_template"`            // Go text/template; empty uses the default JSON body
+	Events          []string          `json:"events"`                   // Event types to send; empty = all
+	Timeout         int               `json:"timeout"`                  // Seconds per attempt
+	MaxAttempts     int               `json:"max_attempts"`             // Total attempts per event
+	RetryBackoff    int               `json:"retry_backoff"`            // Seconds before the first retry, doubled after each failure
+	Enabled         bool              `json:"enabled"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+}
+
+// WebhookDelivery records a single attempt to deliver an event to a webhook
+type WebhookDelivery struct {
+	ID           int       `json:"id"`
+	WebhookID    int       `json:"webhook_id"`
+	AlertID      int       `json:"alert_id,omitempty"`
+	Event        string    `json:"event"`
+	Attempt      int       `json:"attempt"`
+	StatusCode   int       `json:"status_code,omitempty"`
+	Success      bool      `json:"success"`
+	Error        string    `json:"error,omitempty"`
+	DurationMs   int64     `json:"duration_ms"`
+	RequestBody  string    `json:"request_body"`
+	ResponseBody string    `json:"response_body,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ChatChannelType identifies the chat service behind an incoming webhook
+type ChatChannelType string
+
+const (
+	ChatSlack         ChatChannelType = "slack"          // Slack Block Kit message
+	ChatTeams         ChatChannelType = "teams"          // Microsoft Teams MessageCard (Office 365 connector)
+	ChatTeamsAdaptive ChatChannelType = "teams_adaptive" // Microsoft Teams Adaptive Card (Workflows)
+	ChatDiscord       ChatChannelType = "discord"        // Discord embed
+)
+
+// ChatChannel posts formatted notifications to a chat incoming webhook.
+// Without ServerIDs and Tags it receives notifications for all servers.
+type ChatChannel struct {
+	ID         int             `json:"id"`
+	Name       string          `json:"name"`
+	Type       ChatChannelType `json:"type"`
+	WebhookURL string          `json:"webhook_url"`
+	ServerIDs  []int           `json:"server_ids"` // Only notify about these servers
+	Tags       []string        `json:"tags"`       // Only notify about servers with one of these tags
+	Enabled    bool            `json:"enabled"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+}
+
+// Routes reports whether the channel should notify about the server
+func (c *ChatChannel) Routes(server *Server) bool {
+	return matchesServer(server, c.ServerIDs, c.Tags)
+}
+
+// TelegramChat is a Telegram chat (group or private) allowed to use the bot.
+// It receives alerts for the servers, tags and severities it subscribes to;
+// empty lists subscribe to everything.
+type TelegramChat struct {
+	ID         int        `json:"id"`
+	ChatID     string     `json:"chat_id"`
+	Name       string     `json:"name"`
+	ServerIDs  []int      `json:"server_ids"` // Only alerts for these servers
+	Tags       []string   `json:"tags"`       // Only alerts for servers with one of these tags
+	Severities []Severity `json:"severities"` // Only alerts with one of these severities
+	Enabled    bool       `json:"enabled"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// Subscribes reports whether the chat wants alerts of the given severity
+// about the server. An empty severity matches any subscription.
+func (c *TelegramChat) Subscribes(server *Server, severity Severity) bool {
+	if !matchesServer(server, c.ServerIDs, c.Tags) {
+		return false
+	}
+	if severity == "" || len(c.Severities) == 0 {
+		return true
+	}
+	for _, s := range c.Severities {
+		if s == severity {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesServer reports whether a server is in serverIDs or has one of tags.
+// Empty lists match every server.
+func matchesServer(server *Server, serverIDs []int, tags []string) bool {
+	if server == nil || (len(serverIDs) == 0 && len(tags) == 0) {
+		return true
+	}
+	for _, id := range serverIDs {
+		if id == server.ID {
+			return true
+		}
+	}
+	for _, tag := range tags {
+		if server.HasTag(tag) {
+			return true
+		}
+	}
+	return false
 }
 
 // Config represents application configuration
@@ -107,9 +854,26 @@ type Config struct {
 
 // TelegramConfig holds Telegram bot configuration
 type TelegramConfig struct {
-	BotToken string   `json:"bot_token"`
-	ChatIDs  []string `json:"chat_ids"`
-	Enabled  bool     `json:"enabled"`
+	BotToken       string   `json:"bot_token" yaml:"bot_token"`
+	BotTokenSecret string   `json:"-" yaml:"bot_token_secret,omitempty"` // Vault secret holding the bot token, instead of bot_token
+	ChatIDs        []string `json:"chat_ids" yaml:"chat_ids"`
+	Enabled        bool     `json:"enabled" yaml:"enabled"`
+}
+
+// EmailConfig holds SMTP email notification configuration
+type EmailConfig struct {
+	Enabled            bool          `json:"enabled" yaml:"enabled"`
+	Host               string        `json:"host" yaml:"host"`
+	Port               int           `json:"port" yaml:"port"` // Defaults to 587 for starttls, 465 for tls and 25 for none
+	Username           string        `json:"username" yaml:"username"`
+	Password           string        `json:"-" yaml:"password"`
+	PasswordSecret     string        `json:"-" yaml:"password_secret,omitempty"` // Vault secret holding the password, instead of password
+	From               string        `json:"from" yaml:"from"`
+	TLS                string        `json:"tls" yaml:"tls"` // starttls (default), tls (implicit TLS) or none
+	InsecureSkipVerify bool          `json:"insecure_skip_verify" yaml:"insecure_skip_verify"`
+	Recipients         []string      `json:"recipients" yaml:"recipients"`           // Default recipients
+	NotifyUsers        bool          `json:"notify_users" yaml:"notify_users"`       // Also send to the email address of every enabled user
+	DigestInterval     time.Duration `json:"digest_interval" yaml:"digest_interval"` // 0 = no digest emails
 }
 
 // User represents a system user
@@ -126,6 +890,42 @@ type User struct {
 	LastLoginAt  *time.Time `json:"last_login_at,omitempty"`
 }
 
+// ContactMethod is a way to reach a user on a notification channel
+type ContactMethod struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Channel   string    `json:"channel"` // telegram, email, etc.
+	Address   string    `json:"address"` // Chat ID, email address, etc.
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OnCallSchedule is a weekly rotation of users. Each user is on call for one
+// week, handing off to the next on HandoffDay at HandoffTime in Timezone.
+type OnCallSchedule struct {
+	ID            int              `json:"id"`
+	Name          string           `json:"name"`
+	Description   string           `json:"description"`
+	Timezone      string           `json:"timezone"`       // IANA name, e.g. "Europe/Istanbul"
+	HandoffDay    time.Weekday     `json:"handoff_day"`    // 0 = Sunday
+	HandoffTime   string           `json:"handoff_time"`   // "HH:MM"
+	RotationStart time.Time        `json:"rotation_start"` // The first user's shift starts at the first hand-off on or after this time
+	UserIDs       []int            `json:"user_ids"`       // Rotation order
+	Overrides     []OnCallOverride `json:"overrides,omitempty"`
+	CreatedAt     time.Time        `json:"created_at"`
+	UpdatedAt     time.Time        `json:"updated_at"`
+}
+
+// OnCallOverride temporarily puts another user on call
+type OnCallOverride struct {
+	ID         int       `json:"id"`
+	ScheduleID int       `json:"schedule_id"`
+	UserID     int       `json:"user_id"`
+	StartsAt   time.Time `json:"starts_at"`
+	EndsAt     time.Time `json:"ends_at"`
+	Reason     string    `json:"reason,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
 // Role represents a user role with permissions
 type Role struct {
 	ID           int          `json:"id"`
@@ -166,3 +966,76 @@ type Session struct {
 	IPAddress string    `json:"ip_address"`
 	UserAgent string    `json:"user_agent"`
 }
+
+// ServiceAction is an on-demand action on a service
+type ServiceAction string
+
+const (
+	ActionCheck   ServiceAction = "check"   // Run a check now
+	ActionRestart ServiceAction = "restart" // Restart the service
+)
+
+// CommandStatus is the state of an agent command
+type CommandStatus string
+
+const (
+	CommandPending   CommandStatus = "pending"   // Waiting for the agent to pick it up
+	CommandRunning   CommandStatus = "running"   // Handed to the agent
+	CommandSucceeded CommandStatus = "succeeded" // The agent reported success
+	CommandFailed    CommandStatus = "failed"    // The agent reported a failure
+	CommandExpired   CommandStatus = "expired"   // Nobody waited for the result any more
+)
+
+// AgentCommand is an action queued for a push-mode agent
+type AgentCommand struct {
+	ID          int           `json:"id"`
+	ServerID    int           `json:"server_id"`
+	ServiceID   int           `json:"service_id"`
+	Service     string        `json:"service"` // Service (unit) name
+	Action      ServiceAction `json:"action"`
+	Status      CommandStatus `json:"status"`
+	Output      string        `json:"output,omitempty"`
+	RequestedBy string        `json:"requested_by"`
+	CreatedAt   time.Time     `json:"created_at"`
+	CompletedAt *time.Time    `json:"completed_at,omitempty"`
+}
+
+// Done reports whether the agent has finished the command
+func (c *AgentCommand) Done() bool {
+	return c.Status == CommandSucceeded || c.Status == CommandFailed
+}
+
+// AuditEntry records an action taken by a user
+type AuditEntry struct {
+	ID        int       `json:"id"`
+	Actor     string    `json:"actor"`  // Username
+	Source    string    `json:"source"` // Where the action came from: web, api, telegram
+	Action    string    `json:"action"` // e.g. service.restart
+	Target    string    `json:"target"` // e.g. web-01/nginx.service
+	Success   bool      `json:"success"`
+	Details   string    `json:"details,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SecretType tells what a vault secret holds
+type SecretType string
+
+const (
+	SecretSSHKey   SecretType = "ssh_key"  // PEM encoded private key
+	SecretPassword SecretType = "password" // Login password
+	SecretToken    SecretType = "token"    // API or bot token
+)
+
+// Secret is a value stored encrypted in the vault. The value itself is never
+// sent to clients.
+type Secret struct {
+	ID          int        `json:"id"`
+	Name        string     `json:"name"` // Referenced from servers and the config file
+	Type        SecretType `json:"type"`
+	Description string     `json:"description,omitempty"`
+	Ciphertext  []byte     `json:"-"`
+	KeyID       string     `json:"key_id"` // Master key the value is encrypted with
+	CreatedBy   string     `json:"created_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
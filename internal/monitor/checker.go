@@ -0,0 +1,204 @@
+package monitor
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/harungecit/vigilon/internal/models"
+)
+
+// ServiceInfo holds detailed information about a service
+type ServiceInfo struct {
+	PID    int
+	Memory int64   // in KB
+	CPU    float64 // percentage
+	Uptime int64   // in seconds
+}
+
+// RemoteChecker checks services by running commands on the server, over SSH
+// or WinRM
+type RemoteChecker struct {
+	server *models.Server
+	exec   RemoteExecutor
+}
+
+// NewRemoteChecker creates a checker that runs its commands with exec
+func NewRemoteChecker(exec RemoteExecutor, server *models.Server) *RemoteChecker {
+	return &RemoteChecker{server: server, exec: exec}
+}
+
+// CheckService checks a service status on the server
+func (c *RemoteChecker) CheckService(ctx context.Context, serviceName string) (models.ServiceStatus, *ServiceInfo, error) {
+	// Determine OS type and use appropriate command
+	switch c.server.OS {
+	case "linux":
+		return c.checkLinuxService(ctx, serviceName)
+	case "windows":
+		return c.checkWindowsService(ctx, serviceName)
+	default:
+		return models.StatusUnknown, nil, fmt.Errorf("unsupported OS: %s", c.server.OS)
+	}
+}
+
+// checkLinuxService checks a systemd service on Linux
+func (c *RemoteChecker) checkLinuxService(ctx context.Context, serviceName string) (models.ServiceStatus, *ServiceInfo, error) {
+	probes, err := c.CheckServices(ctx, []string{serviceName})
+	if err != nil {
+		return models.StatusUnknown, nil, err
+	}
+	probe := probes[serviceName]
+	return probe.Status, probe.Info, probe.Err
+}
+
+// CheckServices checks several systemd services in a single command. Names
+// that are not valid service names get an error of their own; the returned
+// error means none of the services could be checked.
+func (c *RemoteChecker) CheckServices(ctx context.Context, serviceNames []string) (map[string]*ServiceProbe, error) {
+	if c.server.OS != "linux" {
+		return nil, fmt.Errorf("batch checks are not supported on %s", c.server.OS)
+	}
+
+	probes := make(map[string]*ServiceProbe, len(serviceNames))
+	var units []string
+	for _, name := range serviceNames {
+		if !ValidServiceName(name) {
+			probes[name] = &ServiceProbe{Status: models.StatusUnknown, Err: fmt.Errorf("invalid service name %q", name)}
+			continue
+		}
+		units = append(units, name)
+	}
+	if len(units) == 0 {
+		return probes, nil
+	}
+
+	output, err := c.execute(ctx, systemdProbeScript(units))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check service: %w", err)
+	}
+	results, err := parseSystemdProbe(output, units)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check service: %w", err)
+	}
+	for name, probe := range results {
+		probes[name] = probe
+	}
+	return probes, nil
+}
+
+// checkWindowsService checks a Windows service
+func (c *RemoteChecker) checkWindowsService(ctx context.Context, serviceName string) (models.ServiceStatus, *ServiceInfo, error) {
+	// The name ends up in a PowerShell command line
+	if !ValidServiceName(serviceName) {
+		return models.StatusUnknown, nil, fmt.Errorf("invalid service name %q", serviceName)
+	}
+
+	// Check service status using PowerShell
+	statusCmd := fmt.Sprintf("powershell -Command \"Get-Service -Name %s | Select-Object -ExpandProperty Status\"", serviceName)
+	output, err := c.execute(ctx, statusCmd)
+
+	if err != nil {
+		return models.StatusUnknown, nil, fmt.Errorf("failed to check service: %w", err)
+	}
+
+	output = strings.TrimSpace(output)
+	status := models.StatusUnknown
+
+	switch strings.ToLower(output) {
+	case "running":
+		status = models.StatusRunning
+	case "stopped":
+		status = models.StatusStopped
+	case "paused":
+		status = models.StatusDegraded
+	default:
+		status = models.StatusUnknown
+	}
+
+	// Get service info if running
+	var info *ServiceInfo
+	if status == models.StatusRunning {
+		info = c.getWindowsServiceInfo(ctx, serviceName)
+	}
+
+	return status, info, nil
+}
+
+// getWindowsServiceInfo gets detailed info about a Windows service
+func (c *RemoteChecker) getWindowsServiceInfo(ctx context.Context, serviceName string) *ServiceInfo {
+	info := &ServiceInfo{}
+
+	// Get process ID
+	pidCmd := fmt.Sprintf("powershell -Command \"Get-CimInstance Win32_Service -Filter \\\"Name='%s'\\\" | Select-Object -ExpandProperty ProcessId\"", serviceName)
+	if output, err := c.execute(ctx, pidCmd); err == nil {
+		if pid, err := strconv.Atoi(strings.TrimSpace(output)); err == nil && pid > 0 {
+			info.PID = pid
+
+			// Get memory and CPU usage
+			perfCmd := fmt.Sprintf("powershell -Command \"Get-Process -Id %d | Select-Object @{N='WS';E={$_.WS/1KB}},CPU | ConvertTo-Csv -NoTypeInformation\"", pid)
+			if output, err := c.execute(ctx, perfCmd); err == nil {
+				records, _ := csv.NewReader(strings.NewReader(output)).ReadAll()
+				if len(records) > 1 && len(records[1]) >= 2 {
+					fields := records[1]
+					if mem, err := strconv.ParseFloat(fields[0], 64); err == nil {
+						info.Memory = int64(mem)
+					}
+					if cpu, err := strconv.ParseFloat(fields[1], 64); err == nil {
+						info.CPU = cpu
+					}
+				}
+			}
+		}
+	}
+
+	return info
+}
+
+// RestartService restarts a service on the server. Non-root users need
+// passwordless sudo for systemctl on Linux.
+func (c *RemoteChecker) RestartService(ctx context.Context, serviceName string) (string, error) {
+	if !ValidServiceName(serviceName) {
+		return "", fmt.Errorf("invalid service name %q", serviceName)
+	}
+
+	var restartCmd string
+	switch c.server.OS {
+	case "linux":
+		restartCmd = fmt.Sprintf("if [ \"$(id -u)\" -eq 0 ]; then systemctl restart %[1]s; else sudo -n systemctl restart %[1]s; fi", serviceName)
+	case "windows":
+		restartCmd = fmt.Sprintf("powershell -Command \"Restart-Service -Name '%s' -Force\"", serviceName)
+	default:
+		return "", fmt.Errorf("unsupported OS: %s", c.server.OS)
+	}
+
+	// Keep stderr, it explains why a restart failed
+	output, err := c.exec.CombinedOutput(ctx, c.server, restartCmd)
+	if err != nil {
+		return strings.TrimSpace(output), fmt.Errorf("restart failed: %w", err)
+	}
+	return strings.TrimSpace(output), nil
+}
+
+// ValidServiceName reports whether a service name is safe to pass to a
+// remote shell: systemd unit and Windows service names only
+func ValidServiceName(name string) bool {
+	if name == "" || len(name) > 256 {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("@._:-", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// execute runs a command on the server and returns its standard output
+func (c *RemoteChecker) execute(ctx context.Context, remoteCmd string) (string, error) {
+	return c.exec.Output(ctx, c.server, remoteCmd)
+}
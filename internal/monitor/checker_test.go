@@ -0,0 +1,385 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/harungecit/vigilon/internal/models"
+)
+
+// systemdOutput builds the output of a probe: /proc/uptime followed by one
+// block of properties per unit
+func systemdOutput(uptime string, units ...[]string) string {
+	blocks := make([]string, len(units))
+	for i, props := range units {
+		blocks[i] = strings.Join(props, "\n")
+	}
+	return uptime + "\n" + strings.Join(blocks, "\n\n") + "\n"
+}
+
+func TestCheckLinuxService(t *testing.T) {
+	running := []string{
+		"Id=nginx.service",
+		"LoadState=loaded",
+		"ActiveState=active",
+		"SubState=running",
+		"MainPID=1234",
+		"MemoryCurrent=10485760",
+		"CPUUsageNSec=6000000000",
+		"ActiveEnterTimestampMonotonic=400500000",
+	}
+
+	tests := []struct {
+		name    string
+		output  string
+		err     error
+		status  models.ServiceStatus
+		info    *ServiceInfo
+		wantErr string
+	}{
+		{
+			name:   "running",
+			output: systemdOutput("1000.50 3890.12", running),
+			status: models.StatusRunning,
+			// Active for 600s, 6s of CPU time: 1% on average
+			info: &ServiceInfo{PID: 1234, Memory: 10240, CPU: 1, Uptime: 600},
+		},
+		{
+			name:   "crlf line endings",
+			output: strings.ReplaceAll(systemdOutput("1000.50 3890.12", running), "\n", "\r\n"),
+			status: models.StatusRunning,
+			info:   &ServiceInfo{PID: 1234, Memory: 10240, CPU: 1, Uptime: 600},
+		},
+		{
+			name:   "no trailing newline",
+			output: strings.TrimSuffix(systemdOutput("1000.50 3890.12", running), "\n"),
+			status: models.StatusRunning,
+			info:   &ServiceInfo{PID: 1234, Memory: 10240, CPU: 1, Uptime: 600},
+		},
+		{
+			name:   "uptime without idle time",
+			output: systemdOutput("1000.50", running),
+			status: models.StatusRunning,
+			info:   &ServiceInfo{PID: 1234, Memory: 10240, CPU: 1, Uptime: 600},
+		},
+		{
+			name: "unset counters",
+			output: systemdOutput("1000.50 3890.12", []string{
+				"Id=cron.service", "LoadState=loaded", "ActiveState=active", "SubState=running",
+				"MainPID=88", "MemoryCurrent=[not set]", "CPUUsageNSec=[not set]",
+				"ActiveEnterTimestampMonotonic=400500000",
+			}),
+			status: models.StatusRunning,
+			info:   &ServiceInfo{PID: 88, Uptime: 600},
+		},
+		{
+			name: "counters at the maximum uint64",
+			output: systemdOutput("1000.50 3890.12", []string{
+				"Id=cron.service", "LoadState=loaded", "ActiveState=active", "SubState=running",
+				"MainPID=88", "MemoryCurrent=18446744073709551615", "CPUUsageNSec=18446744073709551615",
+				"ActiveEnterTimestampMonotonic=400500000",
+			}),
+			status: models.StatusRunning,
+			info:   &ServiceInfo{PID: 88, Uptime: 600},
+		},
+		{
+			name: "never entered active state",
+			output: systemdOutput("1000.50 3890.12", []string{
+				"Id=oneshot.service", "LoadState=loaded", "ActiveState=active", "SubState=exited",
+				"MainPID=0", "MemoryCurrent=0", "CPUUsageNSec=5000000",
+				"ActiveEnterTimestampMonotonic=0",
+			}),
+			status: models.StatusRunning,
+			info:   &ServiceInfo{},
+		},
+		{
+			name: "entered active state after the uptime reading",
+			output: systemdOutput("100.00 50.00", []string{
+				"Id=nginx.service", "LoadState=loaded", "ActiveState=active", "SubState=running",
+				"MainPID=1234", "MemoryCurrent=2048", "CPUUsageNSec=1000",
+				"ActiveEnterTimestampMonotonic=200000000",
+			}),
+			status: models.StatusRunning,
+			info:   &ServiceInfo{PID: 1234, Memory: 2},
+		},
+		{
+			name: "reloading",
+			output: systemdOutput("1000.50 3890.12", []string{
+				"Id=nginx.service", "LoadState=loaded", "ActiveState=reloading", "SubState=reload",
+				"MainPID=1234", "ActiveEnterTimestampMonotonic=400500000",
+			}),
+			status: models.StatusRunning,
+			info:   &ServiceInfo{PID: 1234, Uptime: 600},
+		},
+		{
+			name: "stopped",
+			output: systemdOutput("1000.50 3890.12", []string{
+				"Id=nginx.service", "LoadState=loaded", "ActiveState=inactive", "SubState=dead",
+				"MainPID=0", "MemoryCurrent=[not set]", "ActiveEnterTimestampMonotonic=0",
+			}),
+			status: models.StatusStopped,
+		},
+		{
+			name: "failed",
+			output: systemdOutput("1000.50 3890.12", []string{
+				"Id=nginx.service", "LoadState=loaded", "ActiveState=failed", "SubState=failed",
+				"MainPID=0",
+			}),
+			status: models.StatusFailed,
+		},
+		{
+			name: "activating",
+			output: systemdOutput("1000.50 3890.12", []string{
+				"Id=nginx.service", "LoadState=loaded", "ActiveState=activating", "SubState=auto-restart",
+			}),
+			status: models.StatusDegraded,
+		},
+		{
+			name: "unknown active state",
+			output: systemdOutput("1000.50 3890.12", []string{
+				"Id=nginx.service", "LoadState=loaded", "ActiveState=maintenance",
+			}),
+			status: models.StatusUnknown,
+		},
+		{
+			name: "unit not found",
+			output: systemdOutput("1000.50 3890.12", []string{
+				"Id=nginx.service", "LoadState=not-found", "ActiveState=inactive", "SubState=dead",
+			}),
+			status:  models.StatusUnknown,
+			wantErr: "unit nginx.service not found",
+		},
+		{
+			name:    "empty output",
+			output:  "",
+			status:  models.StatusUnknown,
+			wantErr: "empty probe output",
+		},
+		{
+			name:    "invalid uptime",
+			output:  systemdOutput("up 3 days", running),
+			status:  models.StatusUnknown,
+			wantErr: "invalid uptime",
+		},
+		{
+			name:    "missing unit block",
+			output:  "1000.50 3890.12\n",
+			status:  models.StatusUnknown,
+			wantErr: "expected 1 units in probe output, got 0",
+		},
+		{
+			name:    "command failed",
+			err:     errors.New("Process exited with status 1"),
+			status:  models.StatusUnknown,
+			wantErr: "failed to check service: Process exited with status 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exec := (&fakeExecutor{}).on("systemctl show", tt.output, tt.err)
+			checker := NewRemoteChecker(exec, &models.Server{OS: "linux"})
+
+			status, info, err := checker.CheckService(context.Background(), "nginx.service")
+			if status != tt.status {
+				t.Errorf("status = %s, want %s", status, tt.status)
+			}
+			checkError(t, err, tt.wantErr)
+			checkInfo(t, info, tt.info)
+		})
+	}
+}
+
+func TestCheckServicesBatch(t *testing.T) {
+	output := systemdOutput("1000.50 3890.12",
+		[]string{"Id=nginx.service", "LoadState=loaded", "ActiveState=active", "MainPID=10"},
+		[]string{"Id=cron.service", "LoadState=loaded", "ActiveState=failed"},
+	)
+	exec := (&fakeExecutor{}).on("systemctl show", output, nil)
+	checker := NewRemoteChecker(exec, &models.Server{OS: "linux"})
+
+	probes, err := checker.CheckServices(context.Background(), []string{"nginx.service", "bad;name", "cron.service"})
+	if err != nil {
+		t.Fatalf("CheckServices: %v", err)
+	}
+
+	calls := exec.ran()
+	if len(calls) != 1 {
+		t.Fatalf("ran %d commands, want 1", len(calls))
+	}
+	if !strings.HasSuffix(calls[0], "-- nginx.service cron.service") {
+		t.Errorf("command %q does not list the valid units in order", calls[0])
+	}
+
+	if got := probes["nginx.service"]; got.Status != models.StatusRunning || got.Info.PID != 10 {
+		t.Errorf("nginx.service = %+v, want running with PID 10", got)
+	}
+	if got := probes["cron.service"]; got.Status != models.StatusFailed {
+		t.Errorf("cron.service status = %s, want failed", got.Status)
+	}
+	if got := probes["bad;name"]; got.Status != models.StatusUnknown || got.Err == nil {
+		t.Errorf("bad;name = %+v, want unknown with an error", got)
+	}
+}
+
+func TestCheckWindowsService(t *testing.T) {
+	const (
+		statusCmd = "Get-Service -Name"
+		pidCmd    = "Win32_Service"
+		perfCmd   = "Get-Process -Id"
+	)
+	perfOutput := "\"WS\",\"CPU\"\r\n\"20480\",\"12.5\"\r\n"
+
+	tests := []struct {
+		name    string
+		exec    *fakeExecutor
+		service string
+		status  models.ServiceStatus
+		info    *ServiceInfo
+		wantErr string
+		calls   int
+	}{
+		{
+			name: "running",
+			exec: (&fakeExecutor{}).
+				on(statusCmd, "Running\r\n", nil).
+				on(pidCmd, "4242\r\n", nil).
+				on(perfCmd, perfOutput, nil),
+			status: models.StatusRunning,
+			info:   &ServiceInfo{PID: 4242, Memory: 20480, CPU: 12.5},
+			calls:  3,
+		},
+		{
+			name: "lowercase status",
+			exec: (&fakeExecutor{}).
+				on(statusCmd, "running", nil).
+				on(pidCmd, "4242", nil).
+				on(perfCmd, "\"WS\",\"CPU\"\n\"20480\",\"12.5\"\n", nil),
+			status: models.StatusRunning,
+			info:   &ServiceInfo{PID: 4242, Memory: 20480, CPU: 12.5},
+			calls:  3,
+		},
+		{
+			name: "fractional working set",
+			exec: (&fakeExecutor{}).
+				on(statusCmd, "Running\r\n", nil).
+				on(pidCmd, "4242\r\n", nil).
+				on(perfCmd, "\"WS\",\"CPU\"\r\n\"20480.75\",\"0\"\r\n", nil),
+			status: models.StatusRunning,
+			info:   &ServiceInfo{PID: 4242, Memory: 20480},
+			calls:  3,
+		},
+		{
+			name: "no CPU time",
+			exec: (&fakeExecutor{}).
+				on(statusCmd, "Running\r\n", nil).
+				on(pidCmd, "4242\r\n", nil).
+				on(perfCmd, "\"WS\",\"CPU\"\r\n\"20480\",\"\"\r\n", nil),
+			status: models.StatusRunning,
+			info:   &ServiceInfo{PID: 4242, Memory: 20480},
+			calls:  3,
+		},
+		{
+			name: "process details unavailable",
+			exec: (&fakeExecutor{}).
+				on(statusCmd, "Running\r\n", nil).
+				on(pidCmd, "4242\r\n", nil).
+				on(perfCmd, "\"WS\",\"CPU\"\r\n", nil),
+			status: models.StatusRunning,
+			info:   &ServiceInfo{PID: 4242},
+			calls:  3,
+		},
+		{
+			name: "shared process without a PID",
+			exec: (&fakeExecutor{}).
+				on(statusCmd, "Running\r\n", nil).
+				on(pidCmd, "0\r\n", nil),
+			status: models.StatusRunning,
+			info:   &ServiceInfo{},
+			calls:  2,
+		},
+		{
+			name: "PID query fails",
+			exec: (&fakeExecutor{}).
+				on(statusCmd, "Running\r\n", nil).
+				on(pidCmd, "", errors.New("access denied")),
+			status: models.StatusRunning,
+			info:   &ServiceInfo{},
+			calls:  2,
+		},
+		{
+			name:   "stopped",
+			exec:   (&fakeExecutor{}).on(statusCmd, "Stopped\r\n", nil),
+			status: models.StatusStopped,
+			calls:  1,
+		},
+		{
+			name:   "paused",
+			exec:   (&fakeExecutor{}).on(statusCmd, "Paused", nil),
+			status: models.StatusDegraded,
+			calls:  1,
+		},
+		{
+			name:   "pending",
+			exec:   (&fakeExecutor{}).on(statusCmd, "StartPending\r\n", nil),
+			status: models.StatusUnknown,
+			calls:  1,
+		},
+		{
+			name:   "empty output",
+			exec:   (&fakeExecutor{}).on(statusCmd, "", nil),
+			status: models.StatusUnknown,
+			calls:  1,
+		},
+		{
+			name:    "service not found",
+			exec:    (&fakeExecutor{}).on(statusCmd, "", &WinRMExitError{Code: 1}),
+			status:  models.StatusUnknown,
+			wantErr: "failed to check service: command exited with status 1",
+			calls:   1,
+		},
+		{
+			name:    "invalid service name",
+			exec:    &fakeExecutor{},
+			service: "Spooler; Stop-Computer",
+			status:  models.StatusUnknown,
+			wantErr: "invalid service name",
+			calls:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := tt.service
+			if service == "" {
+				service = "Spooler"
+			}
+			checker := NewRemoteChecker(tt.exec, &models.Server{OS: "windows"})
+
+			status, info, err := checker.CheckService(context.Background(), service)
+			if status != tt.status {
+				t.Errorf("status = %s, want %s", status, tt.status)
+			}
+			checkError(t, err, tt.wantErr)
+			checkInfo(t, info, tt.info)
+			if calls := len(tt.exec.ran()); calls != tt.calls {
+				t.Errorf("ran %d commands, want %d", calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestCheckServiceUnsupportedOS(t *testing.T) {
+	exec := &fakeExecutor{}
+	checker := NewRemoteChecker(exec, &models.Server{OS: "plan9"})
+
+	status, _, err := checker.CheckService(context.Background(), "nginx.service")
+	if status != models.StatusUnknown || err == nil {
+		t.Errorf("got %s, %v; want unknown with an error", status, err)
+	}
+	if len(exec.ran()) != 0 {
+		t.Errorf("ran %v, want no commands", exec.ran())
+	}
+}
@@ -0,0 +1,27 @@
+package monitor
+
+import (
+	"context"
+
+	"github.com/harungecit/vigilon/internal/models"
+)
+
+// RemoteExecutor runs commands on a server in pull and hybrid mode. The SSH
+// pool and the WinRM client implement it.
+type RemoteExecutor interface {
+	// Output runs a command and returns its standard output. A non-zero exit
+	// status is returned as an error.
+	Output(ctx context.Context, server *models.Server, cmd string) (string, error)
+
+	// CombinedOutput runs a command and returns its standard output and
+	// standard error
+	CombinedOutput(ctx context.Context, server *models.Server, cmd string) (string, error)
+}
+
+// executor returns the executor of the transport a server uses
+func (m *Monitor) executor(server *models.Server) RemoteExecutor {
+	if server.UsesWinRM() {
+		return m.winrm
+	}
+	return m.sshPool
+}
@@ -0,0 +1,62 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/harungecit/vigilon/internal/models"
+)
+
+// fakeExecutor is a scripted RemoteExecutor. Each command is answered by the
+// first rule whose pattern it contains; commands without a rule fail. The
+// commands it ran are recorded in order.
+type fakeExecutor struct {
+	mu    sync.Mutex
+	rules []fakeRule
+	calls []string
+}
+
+type fakeRule struct {
+	pattern string
+	output  string
+	err     error
+}
+
+// on adds a rule answering commands that contain pattern
+func (f *fakeExecutor) on(pattern, output string, err error) *fakeExecutor {
+	f.rules = append(f.rules, fakeRule{pattern: pattern, output: output, err: err})
+	return f
+}
+
+func (f *fakeExecutor) Output(ctx context.Context, server *models.Server, cmd string) (string, error) {
+	return f.run(ctx, cmd)
+}
+
+func (f *fakeExecutor) CombinedOutput(ctx context.Context, server *models.Server, cmd string) (string, error) {
+	return f.run(ctx, cmd)
+}
+
+func (f *fakeExecutor) run(ctx context.Context, cmd string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, cmd)
+
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	for _, rule := range f.rules {
+		if strings.Contains(cmd, rule.pattern) {
+			return rule.output, rule.err
+		}
+	}
+	return "", fmt.Errorf("unexpected command %q", cmd)
+}
+
+// ran returns the commands the executor ran
+func (f *fakeExecutor) ran() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
@@ -0,0 +1,192 @@
+package monitor
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/harungecit/vigilon/internal/models"
+	"github.com/harungecit/vigilon/internal/notify"
+	"golang.org/x/crypto/ssh"
+)
+
+// HostKeyStore persists the SSH host keys trusted for each address
+type HostKeyStore interface {
+	GetHostKeyByAddress(address string) (*models.HostKey, error)
+	CreateHostKey(key *models.HostKey) error
+	SetPendingHostKey(id int, keyType, fingerprint, publicKey string) error
+}
+
+// HostKeyError is returned when a host presents a key other than the
+// trusted one. The connection is refused until an admin trusts the new key.
+type HostKeyError struct {
+	Address     string
+	Trusted     string // Fingerprint of the trusted key
+	Fingerprint string // Fingerprint of the presented key
+}
+
+func (e *HostKeyError) Error() string {
+	return fmt.Sprintf("host key of %s changed: trusted %s, presented %s", e.Address, e.Trusted, e.Fingerprint)
+}
+
+// hostKeyConfig returns the host key callback of an address and the host key
+// algorithms to ask for. Once a key is trusted only its type is negotiated,
+// so a host that also has keys of other types does not look changed.
+func (p *SSHPool) hostKeyConfig(address string) (ssh.HostKeyCallback, []string, error) {
+	known, err := p.hostKeys.GetHostKeyByAddress(address)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, fmt.Errorf("failed to load host key of %s: %w", address, err)
+	}
+
+	var algorithms []string
+	if known != nil {
+		algorithms = hostKeyAlgorithms(known.KeyType)
+	}
+	callback := func(_ string, _ net.Addr, key ssh.PublicKey) error {
+		return p.checkHostKey(address, known, key)
+	}
+	return callback, algorithms, nil
+}
+
+// checkHostKey trusts the first key of an address and refuses any other key
+// afterwards, recording it as pending for review
+func (p *SSHPool) checkHostKey(address string, known *models.HostKey, key ssh.PublicKey) error {
+	fingerprint := ssh.FingerprintSHA256(key)
+	publicKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+
+	if known == nil {
+		known = &models.HostKey{
+			Address:     address,
+			KeyType:     key.Type(),
+			Fingerprint: fingerprint,
+			PublicKey:   publicKey,
+			TrustedBy:   "first use",
+		}
+		if err := p.hostKeys.CreateHostKey(known); err == nil {
+			log.Printf("Trusted SSH host key of %s on first use: %s %s", address, key.Type(), fingerprint)
+			return nil
+		}
+
+		// Another connection may have trusted a key in the meantime
+		var err error
+		if known, err = p.hostKeys.GetHostKeyByAddress(address); err != nil {
+			return fmt.Errorf("failed to store host key of %s: %w", address, err)
+		}
+	}
+
+	if known.Fingerprint == fingerprint {
+		return nil
+	}
+	if known.PendingFingerprint != fingerprint {
+		log.Printf("SSH host key of %s changed: trusted %s, presented %s", address, known.Fingerprint, fingerprint)
+		if err := p.hostKeys.SetPendingHostKey(known.ID, key.Type(), fingerprint, publicKey); err != nil {
+			log.Printf("Failed to record pending host key of %s: %v", address, err)
+		}
+	}
+	return &HostKeyError{Address: address, Trusted: known.Fingerprint, Fingerprint: fingerprint}
+}
+
+// hostKeyAlgorithms returns the signature algorithms of a host key type
+func hostKeyAlgorithms(keyType string) []string {
+	if keyType == ssh.KeyAlgoRSA {
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	}
+	return []string{keyType}
+}
+
+// handleHostKeyError opens a host key alert for a server, unless it already
+// has one. It is sent even during maintenance, as it may be an attack.
+func (m *Monitor) handleHostKeyError(server *models.Server, service *models.Service, check *models.ServiceCheck, hostKeyErr *HostKeyError) {
+	var out outbox
+	defer m.send(&out) // Runs after m.mu is released
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.hostKeys[server.ID] != 0 {
+		return
+	}
+
+	message := fmt.Sprintf("🔐 SSH host key of %s changed while checking server '%s'\nTrusted: %s\nPresented: %s\nChecks are paused until the new key is trusted.",
+		hostKeyErr.Address, server.Name, hostKeyErr.Trusted, hostKeyErr.Fingerprint)
+
+	alert := &models.Alert{
+		Type:      models.AlertHostKey,
+		ServiceID: service.ID,
+		ServerID:  server.ID,
+		Status:    models.StatusUnknown,
+		Message:   message,
+		SentVia:   "pending",
+
+		EscalationPolicyID: server.EscalationPolicyID,
+	}
+	if err := m.db.CreateAlert(alert); err != nil {
+		log.Printf("Failed to create alert: %v", err)
+		return
+	}
+
+	out.add(&notify.Event{
+		Type:    notify.EventAlert,
+		Alert:   alert,
+		Server:  server,
+		Service: service,
+		Check:   check,
+	})
+	m.hostKeys[server.ID] = alert.ID
+
+	log.Printf("Alert created: %s", message)
+}
+
+// resolveHostKeyAlert closes the host key alert of a server once its host
+// key is verified again, after the new key was trusted or the old one is back
+func (m *Monitor) resolveHostKeyAlert(server *models.Server, service *models.Service, check *models.ServiceCheck) {
+	m.mu.Lock()
+	alertID := m.hostKeys[server.ID]
+	delete(m.hostKeys, server.ID)
+	m.mu.Unlock()
+
+	if alertID == 0 {
+		return
+	}
+
+	alert, err := m.db.GetAlert(alertID)
+	if err != nil {
+		log.Printf("Failed to get alert %d: %v", alertID, err)
+		return
+	}
+
+	now := time.Now()
+	downtime := now.Sub(alert.CreatedAt).Round(time.Second)
+	if err := m.db.ResolveAlert(alert.ID, now, downtime); err != nil {
+		log.Printf("Failed to resolve alert %d: %v", alert.ID, err)
+		return
+	}
+	alert.State = models.AlertResolved
+	alert.ResolvedAt = &now
+	alert.DowntimeSecs = int64(downtime.Seconds())
+
+	message := fmt.Sprintf("✅ SSH host key of server '%s' verified again, checks resumed after %s", server.Name, downtime)
+	log.Printf("Alert resolved: %s", message)
+
+	channels, err := m.db.GetAnnouncedChannels(alert.ID)
+	if err != nil {
+		log.Printf("Failed to get channels for alert %d: %v", alert.ID, err)
+		return
+	}
+	if len(channels) == 0 {
+		return
+	}
+
+	m.dispatcher.Dispatch(&notify.Event{
+		Type:     notify.EventRecovery,
+		Alert:    alert,
+		Server:   server,
+		Service:  service,
+		Check:    check,
+		Text:     message,
+		Channels: channels,
+	})
+}
@@ -2,45 +2,79 @@ package monitor
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/harungecit/vigilon/internal/database"
+	"github.com/harungecit/vigilon/internal/maintenance"
 	"github.com/harungecit/vigilon/internal/models"
+	"github.com/harungecit/vigilon/internal/netcheck"
+	"github.com/harungecit/vigilon/internal/notify"
+	"golang.org/x/crypto/ssh"
 )
 
+// ReminderPolicy controls how often a service that stays down is re-announced
+type ReminderPolicy struct {
+	Intervals    []time.Duration // Delay before each reminder; the last one repeats (empty = no reminders)
+	MaxReminders int             // Reminders per outage (0 = no limit)
+}
+
 // Monitor handles service monitoring
 type Monitor struct {
-	db            *database.DB
-	interval      time.Duration
-	alertCooldown time.Duration
-	lastAlerts    map[string]time.Time // key: "serverID:serviceID"
-	mu            sync.RWMutex
-	stopCh        chan struct{}
-	wg            sync.WaitGroup
-	maxWorkers    int           // Maximum concurrent workers
-	workerSem     chan struct{} // Semaphore for limiting workers
-}
-
-// New creates a new Monitor instance
-func New(db *database.DB, interval, alertCooldown time.Duration) *Monitor {
+	db         *database.DB
+	dispatcher *notify.Dispatcher
+	interval   time.Duration
+	reminders  ReminderPolicy
+	states     map[int]*models.ServiceState // key: service ID
+	flaps      map[int]*flapHistory         // key: service ID
+	hostKeys   map[int]int                  // Open host key alert, key: server ID
+	hosts      map[int]*hostState           // Servers with reachability checks, key: server ID
+	windows    []*models.MaintenanceWindow  // Maintenance windows active in the current cycle
+	mu         sync.Mutex
+	stopCh     chan struct{}
+	wg         sync.WaitGroup
+	maxWorkers int           // Maximum concurrent workers
+	workerSem  chan struct{} // Semaphore for limiting workers
+	sshPool    *SSHPool      // SSH connections of pull and hybrid servers
+	winrm      *WinRMPool    // WinRM endpoints of Windows servers that use it
+}
+
+// flapHistory tracks the recent status changes of a service
+type flapHistory struct {
+	last    models.ServiceStatus
+	changes []time.Time
+}
+
+// New creates a new Monitor instance. secrets holds the SSH keys and
+// passwords servers reference from the vault.
+func New(db *database.DB, secrets SecretStore, dispatcher *notify.Dispatcher, interval time.Duration, reminders ReminderPolicy) *Monitor {
 	maxWorkers := 10 // Limit concurrent workers to 10
 	return &Monitor{
-		db:            db,
-		interval:      interval,
-		alertCooldown: alertCooldown,
-		lastAlerts:    make(map[string]time.Time),
-		stopCh:        make(chan struct{}),
-		maxWorkers:    maxWorkers,
-		workerSem:     make(chan struct{}, maxWorkers),
+		db:         db,
+		dispatcher: dispatcher,
+		interval:   interval,
+		reminders:  reminders,
+		states:     make(map[int]*models.ServiceState),
+		flaps:      make(map[int]*flapHistory),
+		hostKeys:   make(map[int]int),
+		hosts:      make(map[int]*hostState),
+		stopCh:     make(chan struct{}),
+		maxWorkers: maxWorkers,
+		workerSem:  make(chan struct{}, maxWorkers),
+		sshPool:    NewSSHPool(db, secrets),
+		winrm:      NewWinRMPool(secrets),
 	}
 }
 
 // Start begins the monitoring loop
 func (m *Monitor) Start(ctx context.Context) {
 	log.Println("Starting monitor...")
+	m.loadStates()
+
 	ticker := time.NewTicker(m.interval)
 	defer ticker.Stop()
 
@@ -59,10 +93,18 @@ func (m *Monitor) Start(ctx context.Context) {
 	}
 }
 
-// Stop stops the monitoring loop
+// Stop stops the monitoring loop and closes the SSH and WinRM connections
 func (m *Monitor) Stop() {
 	close(m.stopCh)
 	m.wg.Wait()
+	m.sshPool.Close()
+	m.winrm.Close()
+}
+
+// Checker returns a checker for a server that shares the monitor's SSH and
+// WinRM connections
+func (m *Monitor) Checker(server *models.Server) *RemoteChecker {
+	return NewRemoteChecker(m.executor(server), server)
 }
 
 // checkAllServers checks all enabled servers
@@ -73,6 +115,8 @@ func (m *Monitor) checkAllServers(ctx context.Context) {
 		return
 	}
 
+	m.refreshMaintenance()
+
 	for _, server := range servers {
 		// Check for idle connections (last seen > 5 minutes ago)
 		m.checkIdleStatus(server)
@@ -95,6 +139,21 @@ func (m *Monitor) checkAllServers(ctx context.Context) {
 	}
 }
 
+// refreshMaintenance loads the maintenance windows in effect for this cycle
+func (m *Monitor) refreshMaintenance() {
+	windows, err := m.db.GetMaintenanceWindows()
+	if err != nil {
+		log.Printf("Failed to get maintenance windows: %v", err)
+		return
+	}
+
+	active := maintenance.Active(windows, time.Now())
+
+	m.mu.Lock()
+	m.windows = active
+	m.mu.Unlock()
+}
+
 // checkIdleStatus checks if a server connection should be marked as idle
 func (m *Monitor) checkIdleStatus(server *models.Server) {
 	// Only check for push mode servers that are currently connected
@@ -123,33 +182,27 @@ func (m *Monitor) checkServer(ctx context.Context, server *models.Server) {
 		return
 	}
 
+	var enabled, managed, reachability []*models.Service
 	for _, service := range services {
-		if !service.Enabled {
-			continue
-		}
-
-		var check *models.ServiceCheck
-		switch server.MonitoringMode {
-		case models.ModePull:
-			check = m.checkServicePull(ctx, server, service)
-		case models.ModePush:
-			// For push mode, we just check the last reported status
-			check = m.checkServicePush(service)
-		case models.ModeHybrid:
-			check = m.checkServiceHybrid(ctx, server, service)
+		switch {
+		case !service.Enabled:
+		case service.Reachability && !service.CheckedByAgent(server):
+			reachability = append(reachability, service)
 		default:
-			log.Printf("Unknown monitoring mode %s for server %s", server.MonitoringMode, server.Name)
-			continue
+			enabled = append(enabled, service)
+			if !service.IsEndpoint() {
+				managed = append(managed, service)
+			}
 		}
+	}
 
-		if check != nil {
-			if err := m.db.CreateServiceCheck(check); err != nil {
-				log.Printf("Failed to save check result: %v", err)
-			}
+	// Whether the server is down decides if its other services alert, so
+	// its reachability is checked first
+	m.checkReachability(ctx, server, reachability)
 
-			// Check if we need to send an alert
-			m.handleAlert(server, service, check)
-		}
+	probes := m.probeServices(ctx, server, managed)
+	for _, service := range enabled {
+		m.checkService(ctx, server, service, probes[service.Name])
 	}
 
 	// Update last seen
@@ -158,20 +211,136 @@ func (m *Monitor) checkServer(ctx context.Context, server *models.Server) {
 	}
 }
 
-// checkServicePull checks a service in pull mode (SSH connection)
-func (m *Monitor) checkServicePull(ctx context.Context, server *models.Server, service *models.Service) *models.ServiceCheck {
+// CheckService checks a service now, outside the regular cycle, and handles
+// the result like a scheduled check. For push-mode servers it evaluates the
+// latest report of the agent.
+func (m *Monitor) CheckService(ctx context.Context, server *models.Server, service *models.Service) (*models.ServiceCheck, error) {
+	check := m.checkService(ctx, server, service, nil)
+	if check == nil {
+		return nil, fmt.Errorf("unknown monitoring mode %s", server.MonitoringMode)
+	}
+	return check, nil
+}
+
+// probeServices checks all services of a Linux server reached over SSH in a
+// single command, instead of one round trip per service and value. It
+// returns nil when the server is checked service by service.
+func (m *Monitor) probeServices(ctx context.Context, server *models.Server, services []*models.Service) map[string]*probeResult {
+	if server.OS != "linux" || len(services) == 0 {
+		return nil
+	}
+	if server.MonitoringMode != models.ModePull && server.MonitoringMode != models.ModeHybrid {
+		return nil
+	}
+
+	names := make([]string, len(services))
+	for i, service := range services {
+		names[i] = service.Name
+	}
+
 	start := time.Now()
-	check := &models.ServiceCheck{
-		ServiceID: service.ID,
-		CheckedAt: start,
+	probes, err := m.Checker(server).CheckServices(ctx, names)
+	elapsed := time.Since(start)
+
+	results := make(map[string]*probeResult, len(names))
+	for _, name := range names {
+		probe := probes[name]
+		if err != nil {
+			probe = &ServiceProbe{Status: models.StatusUnknown, Err: err}
+		}
+		results[name] = &probeResult{ServiceProbe: probe, start: start, elapsed: elapsed}
+	}
+	return results
+}
+
+// probeResult is the result of a service from a batch probe of its server
+type probeResult struct {
+	*ServiceProbe
+	start   time.Time
+	elapsed time.Duration
+}
+
+// checkService checks a service according to the server's monitoring mode,
+// stores the result and updates the alerting state. probe is the result of a
+// batch probe of the server, if there was one.
+func (m *Monitor) checkService(ctx context.Context, server *models.Server, service *models.Service, probe *probeResult) *models.ServiceCheck {
+	var check *models.ServiceCheck
+	var err error
+	endpoint := service.IsEndpoint() && !service.CheckedByAgent(server)
+	switch {
+	case endpoint:
+		// Endpoints are requested from here, whatever the monitoring mode
+		check = m.checkEndpoint(ctx, service)
+	case server.MonitoringMode == models.ModePull:
+		check, err = m.checkServicePull(ctx, server, service, probe)
+	case server.MonitoringMode == models.ModePush:
+		// For push mode, we just check the last reported status
+		check = m.checkServicePush(service)
+	case server.MonitoringMode == models.ModeHybrid:
+		check, err = m.checkServiceHybrid(ctx, server, service, probe)
+	default:
+		log.Printf("Unknown monitoring mode %s for server %s", server.MonitoringMode, server.Name)
+		return nil
 	}
 
-	// Use the SSH checker
-	checker := NewSSHChecker(server)
-	status, info, err := checker.CheckService(ctx, service.Name)
+	// Checks pushed by the agent are already stored
+	if check.ID == 0 {
+		if err := m.db.CreateServiceCheck(check); err != nil {
+			log.Printf("Failed to save check result: %v", err)
+		}
+	}
+
+	// Endpoint checks do not connect to the server, so they say nothing
+	// about its host key
+	if endpoint {
+		m.handleAlert(server, service, check)
+		return check
+	}
+
+	// A changed host key fails every check of the server the same way, so it
+	// is announced once for the server instead of alerting on each service
+	var hostKeyErr *HostKeyError
+	if errors.As(err, &hostKeyErr) {
+		m.handleHostKeyError(server, service, check, hostKeyErr)
+		return check
+	}
+	var exitErr *ssh.ExitError
+	if err == nil || errors.As(err, &exitErr) {
+		// The command ran, so the host key was verified
+		m.resolveHostKeyAlert(server, service, check)
+	}
 
-	check.ResponseTime = time.Since(start).Milliseconds()
-	check.Status = status
+	// Check if we need to send an alert
+	m.handleAlert(server, service, check)
+	return check
+}
+
+// checkServicePull checks a service in pull mode (SSH or WinRM), using
+// the batch probe result when there is one. The error is the reason the
+// check failed, also recorded in the check.
+func (m *Monitor) checkServicePull(ctx context.Context, server *models.Server, service *models.Service, probe *probeResult) (*models.ServiceCheck, error) {
+	var status models.ServiceStatus
+	var info *ServiceInfo
+	var err error
+	var start time.Time
+	var elapsed time.Duration
+
+	if probe != nil {
+		status, info, err = probe.Status, probe.Info, probe.Err
+		start, elapsed = probe.start, probe.elapsed
+	} else {
+		// Run the check on the server
+		start = time.Now()
+		status, info, err = m.Checker(server).CheckService(ctx, service.Name)
+		elapsed = time.Since(start)
+	}
+
+	check := &models.ServiceCheck{
+		ServiceID:    service.ID,
+		CheckedAt:    start,
+		ResponseTime: elapsed.Milliseconds(),
+		Status:       status,
+	}
 
 	if err != nil {
 		check.ErrorMessage = err.Error()
@@ -184,6 +353,26 @@ func (m *Monitor) checkServicePull(ctx context.Context, server *models.Server, s
 		check.Uptime = info.Uptime
 	}
 
+	return check, err
+}
+
+// checkEndpoint checks a network endpoint from the Vigilon server
+func (m *Monitor) checkEndpoint(ctx context.Context, service *models.Service) *models.ServiceCheck {
+	start := time.Now()
+	result := netcheck.Check(ctx, service)
+
+	check := &models.ServiceCheck{
+		ServiceID:    service.ID,
+		CheckedAt:    start,
+		ResponseTime: result.Elapsed.Milliseconds(),
+		Status:       result.Status,
+		DaysToExpiry: result.DaysToExpiry(),
+		Latency:      result.LatencyMs(),
+		PacketLoss:   result.PacketLoss(),
+	}
+	if result.Err != nil {
+		check.ErrorMessage = result.Err.Error()
+	}
 	return check
 }
 
@@ -212,56 +401,523 @@ func (m *Monitor) checkServicePush(service *models.Service) *models.ServiceCheck
 	}
 
 	// Return the current status (already in DB from agent push)
-	return nil
+	return lastCheck
 }
 
 // checkServiceHybrid checks a service in hybrid mode (SSH + local script)
-func (m *Monitor) checkServiceHybrid(ctx context.Context, server *models.Server, service *models.Service) *models.ServiceCheck {
+func (m *Monitor) checkServiceHybrid(ctx context.Context, server *models.Server, service *models.Service, probe *probeResult) (*models.ServiceCheck, error) {
 	// Similar to pull mode but executes a pre-installed script
-	return m.checkServicePull(ctx, server, service)
+	return m.checkServicePull(ctx, server, service, probe)
 }
 
-// handleAlert checks if an alert should be sent
-func (m *Monitor) handleAlert(server *models.Server, service *models.Service, check *models.ServiceCheck) {
-	// Only alert on non-running status
-	if check.Status == models.StatusRunning {
+// loadStates rebuilds the per-service alerting state from the database
+func (m *Monitor) loadStates() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	states, err := m.db.GetServiceStates()
+	if err != nil {
+		log.Printf("Failed to load service states: %v", err)
+	}
+	for _, state := range states {
+		// The alert may have been resolved while we were not running
+		if state.OpenAlertID != 0 {
+			alert, err := m.db.GetAlert(state.OpenAlertID)
+			if err != nil || alert.State != models.AlertOpen {
+				state.OpenAlertID = 0
+				state.LastNotifiedAt = nil
+				state.Reminders = 0
+			}
+		}
+		m.states[state.ServiceID] = state
+	}
+
+	servers, err := m.db.GetAllServers()
+	if err != nil {
+		log.Printf("Failed to get servers: %v", err)
 		return
 	}
+	for _, server := range servers {
+		services, err := m.db.GetServicesByServer(server.ID)
+		if err != nil {
+			log.Printf("Failed to get services for server %s: %v", server.Name, err)
+			continue
+		}
+		for _, service := range services {
+			if _, ok := m.states[service.ID]; !ok {
+				m.seedState(service)
+			}
+		}
+	}
+
+	hostKeyAlerts, err := m.db.GetOpenAlertsByType(models.AlertHostKey)
+	if err != nil {
+		log.Printf("Failed to load host key alerts: %v", err)
+	}
+	for _, alert := range hostKeyAlerts {
+		m.hostKeys[alert.ServerID] = alert.ID
+	}
+
+	hostDownAlerts, err := m.db.GetOpenAlertsByType(models.AlertHostDown)
+	if err != nil {
+		log.Printf("Failed to load host down alerts: %v", err)
+	}
+	for _, alert := range hostDownAlerts {
+		m.hosts[alert.ServerID] = &hostState{down: true, alertID: alert.ID}
+	}
+
+	log.Printf("Loaded alerting state for %d services", len(m.states))
+}
+
+// seedState derives the state of a service that has none persisted yet from
+// its open alerts and latest check, so existing outages are not re-announced
+func (m *Monitor) seedState(service *models.Service) {
+	state := &models.ServiceState{ServiceID: service.ID}
+
+	openAlerts, err := m.db.GetOpenAlertsForService(service.ID)
+	if err == nil && len(openAlerts) > 0 {
+		latest := openAlerts[len(openAlerts)-1]
+		state.Status = latest.Status
+		state.Since = openAlerts[0].CreatedAt
+		state.OpenAlertID = latest.ID
+		state.LastNotifiedAt = &latest.CreatedAt
+	} else if check, err := m.db.GetLatestServiceCheck(service.ID); err == nil {
+		state.Status = check.Status
+		state.Since = check.CheckedAt
+	} else {
+		// Nothing known yet, the first check creates the state
+		return
+	}
+
+	m.states[service.ID] = state
+	if err := m.db.SaveServiceState(state); err != nil {
+		log.Printf("Failed to save state for service %s: %v", service.Name, err)
+	}
+}
+
+// handleAlert updates the state of a service and notifies on transitions.
+// The state is saved and the notifications are sent once m.mu is released.
+func (m *Monitor) handleAlert(server *models.Server, service *models.Service, check *models.ServiceCheck) {
+	var out outbox
+	m.mu.Lock()
+
+	now := time.Now()
+	state, ok := m.states[service.ID]
+	if !ok {
+		// Start from running so the failure threshold applies to the first checks too
+		state = &models.ServiceState{ServiceID: service.ID, Status: models.StatusRunning, Since: now}
+		m.states[service.ID] = state
+	}
+
+	// Checks are still recorded during maintenance, but notifications wait
+	// until the window ends and the state is reconciled
+	window := maintenance.Match(m.windows, server, service)
+
+	// The same goes for a server that is down: its host down alert stands for
+	// the services that fail with it
+	hostDown := m.hostDown(server)
+
+	// In push mode the latest report of the agent is evaluated every cycle
+	// until it reports again, but it counts as a single check
+	changed := !ok
+	if check.ID == 0 || check.ID != state.LastCheckID {
+		state.LastCheckID = check.ID
+		if updateStatus(service, state, check.Status, now) {
+			changed = true
+		}
+		if m.updateFlapping(server, service, check, state, now, window == nil && !hostDown, &out) {
+			changed = true
+		}
+	}
+
+	if window != nil && changed {
+		log.Printf("Notifications for service %s on server %s suppressed by maintenance window '%s'",
+			service.Name, server.Name, window.Name)
+	} else if hostDown && changed {
+		log.Printf("Notifications for service %s on server %s suppressed while the server is down",
+			service.Name, server.Name)
+	}
+
+	// Notifications are also paused while the service is flapping and the state
+	// is reconciled once it settles
+	if !state.Flapping && window == nil && !hostDown {
+		switch {
+		case state.Status == models.StatusRunning:
+			// A running service closes any open alert
+			if state.OpenAlertID != 0 {
+				m.resolveAlerts(server, service, check, &out)
+				state.OpenAlertID = 0
+				state.LastNotifiedAt = nil
+				state.Reminders = 0
+				changed = true
+			}
+		case state.OpenAlertID == 0:
+			// Transition into a non-running state. A service that is already
+			// recovering once notifications resume is not announced.
+			if check.Status != models.StatusRunning && m.createAlert(server, service, check, state, &out) {
+				changed = true
+			}
+		case m.expiryEscalated(service, check, state):
+			// A certificate closer to expiry is announced again at each threshold
+			if m.createAlert(server, service, check, state, &out) {
+				changed = true
+			}
+		default:
+			// Still down (possibly in a different non-running state): same outage
+			if m.sendReminder(server, service, check, state, now, &out) {
+				changed = true
+			}
+		}
+	}
+
+	var saved *models.ServiceState
+	if changed {
+		snapshot := *state
+		saved = &snapshot
+	}
+	m.mu.Unlock()
 
-	// Check cooldown
-	alertKey := fmt.Sprintf("%d:%d", server.ID, service.ID)
-	m.mu.RLock()
-	lastAlert, exists := m.lastAlerts[alertKey]
-	m.mu.RUnlock()
+	if saved != nil {
+		if err := m.db.SaveServiceState(saved); err != nil {
+			log.Printf("Failed to save state for service %s: %v", service.Name, err)
+		}
+	}
+	m.send(&out)
+}
+
+// outbox holds the notifications raised while m.mu is held. They are sent
+// after it is released, as a full notification queue blocks Dispatch and
+// would hold up the checks of every server.
+type outbox []*notify.Event
+
+func (o *outbox) add(event *notify.Event) {
+	*o = append(*o, event)
+}
+
+// send dispatches the notifications of an outbox. The caller must not hold m.mu.
+func (m *Monitor) send(out *outbox) {
+	for _, event := range *out {
+		m.dispatcher.Dispatch(event)
+	}
+}
 
-	if exists && time.Since(lastAlert) < m.alertCooldown {
+// updateStatus applies a check result to the confirmed status of a service.
+// Going from running to not running (or back) takes the configured number of
+// consecutive checks; moving between non-running statuses is immediate.
+func updateStatus(service *models.Service, state *models.ServiceState, status models.ServiceStatus, now time.Time) bool {
+	if status == state.Status {
+		if state.PendingCount == 0 {
+			return false
+		}
+		state.PendingCount = 0
+		return true
+	}
+
+	healthy := status == models.StatusRunning
+	if healthy == (state.Status == models.StatusRunning) {
+		state.Status = status
+		state.Since = now
+		state.PendingCount = 0
+		return true
+	}
+
+	threshold := service.FailureThreshold
+	if healthy {
+		threshold = service.RecoveryThreshold
+	}
+
+	state.PendingCount++
+	if state.PendingCount >= threshold {
+		state.Status = status
+		state.Since = now
+		state.PendingCount = 0
+	}
+	return true
+}
+
+// updateFlapping counts status changes in the flap window and raises a single
+// alert when a service starts flapping, unless notifications are suppressed
+func (m *Monitor) updateFlapping(server *models.Server, service *models.Service, check *models.ServiceCheck, state *models.ServiceState, now time.Time, announce bool, out *outbox) bool {
+	if service.FlapThreshold < 2 {
+		delete(m.flaps, service.ID)
+		if state.Flapping {
+			state.Flapping = false
+			return true
+		}
+		return false
+	}
+
+	history, ok := m.flaps[service.ID]
+	if !ok {
+		history = &flapHistory{last: check.Status}
+		// Still flapping before a restart: wait a full window before settling
+		if state.Flapping {
+			history.changes = []time.Time{now}
+		}
+		m.flaps[service.ID] = history
+	}
+
+	if check.Status != history.last {
+		history.last = check.Status
+		history.changes = append(history.changes, now)
+	}
+
+	window := time.Duration(service.FlapWindow) * time.Second
+	cutoff := now.Add(-window)
+	expired := 0
+	for expired < len(history.changes) && history.changes[expired].Before(cutoff) {
+		expired++
+	}
+	history.changes = history.changes[expired:]
+
+	switch {
+	case !state.Flapping && len(history.changes) >= service.FlapThreshold:
+		state.Flapping = true
+		if announce {
+			m.createFlappingAlert(server, service, check, state, len(history.changes), window, out)
+		}
+		return true
+	case state.Flapping && len(history.changes) == 0:
+		state.Flapping = false
+		log.Printf("Service %s on server %s is no longer flapping", service.Name, server.Name)
+		return true
+	}
+	return false
+}
+
+// createFlappingAlert announces that a service is flapping. It becomes the open
+// alert of the service if there is none, so it is resolved once the service
+// settles in a running state.
+func (m *Monitor) createFlappingAlert(server *models.Server, service *models.Service, check *models.ServiceCheck, state *models.ServiceState, changes int, window time.Duration, out *outbox) {
+	message := fmt.Sprintf("🔁 Service '%s' on server '%s' is flapping (%d status changes in %s)\nNotifications are paused until it settles.",
+		service.DisplayName, server.Name, changes, window)
+
+	alert := &models.Alert{
+		ServiceID: service.ID,
+		ServerID:  server.ID,
+		Status:    models.StatusDegraded,
+		Message:   message,
+		SentVia:   "pending",
+
+		EscalationPolicyID: escalationPolicyFor(server, service),
+	}
+
+	if err := m.db.CreateAlert(alert); err != nil {
+		log.Printf("Failed to create alert: %v", err)
 		return
 	}
 
-	// Create alert
+	out.add(&notify.Event{
+		Type:    notify.EventAlert,
+		Alert:   alert,
+		Server:  server,
+		Service: service,
+		Check:   check,
+	})
+
+	if state.OpenAlertID == 0 {
+		now := time.Now()
+		state.OpenAlertID = alert.ID
+		state.LastNotifiedAt = &now
+		state.Reminders = 0
+	}
+
+	log.Printf("Alert created: %s", message)
+}
+
+// createAlert opens an alert for a service that stopped running
+func (m *Monitor) createAlert(server *models.Server, service *models.Service, check *models.ServiceCheck, state *models.ServiceState, out *outbox) bool {
 	message := fmt.Sprintf("🚨 Service '%s' on server '%s' is %s",
-		service.DisplayName, server.Name, check.Status)
+		service.DisplayName, server.Name, state.Status)
 	if check.ErrorMessage != "" {
 		message += fmt.Sprintf("\nError: %s", check.ErrorMessage)
 	}
 
+	// An expiring certificate is announced with the severity of the threshold
+	threshold, expiring := expiryThreshold(service, check)
+	if expiring {
+		message = fmt.Sprintf("🔒 Certificate of '%s' on server '%s' expires in %d days (%s)",
+			service.DisplayName, server.Name, *check.DaysToExpiry, threshold.Severity)
+	}
+
 	alert := &models.Alert{
 		ServiceID: service.ID,
 		ServerID:  server.ID,
-		Status:    check.Status,
+		Status:    state.Status,
 		Message:   message,
-		SentVia:   "telegram",
+		SentVia:   "pending", // Updated by the dispatcher once channels report back
+		Level:     threshold.Severity,
+
+		EscalationPolicyID: escalationPolicyFor(server, service),
 	}
 
 	if err := m.db.CreateAlert(alert); err != nil {
 		log.Printf("Failed to create alert: %v", err)
-		return
+		return false
 	}
 
-	// Update last alert time
-	m.mu.Lock()
-	m.lastAlerts[alertKey] = time.Now()
-	m.mu.Unlock()
+	out.add(&notify.Event{
+		Type:    notify.EventAlert,
+		Alert:   alert,
+		Server:  server,
+		Service: service,
+		Check:   check,
+	})
+
+	now := time.Now()
+	state.OpenAlertID = alert.ID
+	state.LastNotifiedAt = &now
+	state.Reminders = 0
 
 	log.Printf("Alert created: %s", message)
+	return true
+}
+
+// expiryThreshold returns the expiry threshold crossed by the otherwise
+// valid certificate of a tls service
+func expiryThreshold(service *models.Service, check *models.ServiceCheck) (models.ExpiryThreshold, bool) {
+	if service.TLS == nil || check.DaysToExpiry == nil || check.Status != models.StatusDegraded {
+		return models.ExpiryThreshold{}, false
+	}
+	return service.TLS.CrossedThreshold(*check.DaysToExpiry)
+}
+
+// expiryEscalated reports whether the certificate of a tls service became
+// more urgent than its open alert announced, by crossing a tighter threshold
+// or by expiring
+func (m *Monitor) expiryEscalated(service *models.Service, check *models.ServiceCheck, state *models.ServiceState) bool {
+	if service.Type != models.ServiceTypeTLS || check.DaysToExpiry == nil {
+		return false
+	}
+	alert, err := m.db.GetAlert(state.OpenAlertID)
+	if err != nil {
+		log.Printf("Failed to get alert %d: %v", state.OpenAlertID, err)
+		return false
+	}
+
+	severity := state.Status.Severity()
+	if threshold, ok := expiryThreshold(service, check); ok {
+		severity = threshold.Severity
+	}
+	return severity.Rank() > alert.Severity().Rank()
+}
+
+// escalationPolicyFor returns the escalation policy of a service, falling back
+// to the policy of its server
+func escalationPolicyFor(server *models.Server, service *models.Service) int {
+	if service.EscalationPolicyID != 0 {
+		return service.EscalationPolicyID
+	}
+	return server.EscalationPolicyID
+}
+
+// sendReminder re-announces an open alert when the reminder schedule is due
+func (m *Monitor) sendReminder(server *models.Server, service *models.Service, check *models.ServiceCheck, state *models.ServiceState, now time.Time, out *outbox) bool {
+	intervals := m.reminders.Intervals
+	if len(intervals) == 0 || state.LastNotifiedAt == nil {
+		return false
+	}
+	if m.reminders.MaxReminders > 0 && state.Reminders >= m.reminders.MaxReminders {
+		return false
+	}
+
+	interval := intervals[min(state.Reminders, len(intervals)-1)]
+	if now.Sub(*state.LastNotifiedAt) < interval {
+		return false
+	}
+
+	alert, err := m.db.GetAlert(state.OpenAlertID)
+	if err != nil {
+		log.Printf("Failed to get alert %d: %v", state.OpenAlertID, err)
+		return false
+	}
+
+	// Remind the channels that announced the outage, or all of them if none did
+	channels, err := m.db.GetAnnouncedChannels(alert.ID)
+	if err != nil {
+		log.Printf("Failed to get channels for alert %d: %v", alert.ID, err)
+	}
+
+	downtime := now.Sub(alert.CreatedAt).Round(time.Second)
+	message := fmt.Sprintf("⏰ Reminder: Service '%s' on server '%s' is still %s (down for %s)",
+		service.DisplayName, server.Name, state.Status, downtime)
+	if check.ErrorMessage != "" {
+		message += fmt.Sprintf("\nError: %s", check.ErrorMessage)
+	}
+
+	out.add(&notify.Event{
+		Type:     notify.EventReminder,
+		Alert:    alert,
+		Server:   server,
+		Service:  service,
+		Check:    check,
+		Text:     message,
+		Channels: channels,
+	})
+
+	state.LastNotifiedAt = &now
+	state.Reminders++
+
+	log.Printf("Reminder sent: %s", message)
+	return true
+}
+
+// resolveAlerts closes the open alerts of a service that is running again and
+// sends a recovery notification on the channels that announced the outage
+func (m *Monitor) resolveAlerts(server *models.Server, service *models.Service, check *models.ServiceCheck, out *outbox) {
+	openAlerts, err := m.db.GetOpenAlertsForService(service.ID)
+	if err != nil {
+		log.Printf("Failed to get open alerts for service %s: %v", service.Name, err)
+		return
+	}
+	if len(openAlerts) == 0 {
+		return
+	}
+
+	// The outage started with the oldest open alert
+	now := time.Now()
+	first := openAlerts[0]
+	downtime := now.Sub(first.CreatedAt).Round(time.Second)
+
+	var channels []string
+	for _, alert := range openAlerts {
+		if err := m.db.ResolveAlert(alert.ID, now, downtime); err != nil {
+			log.Printf("Failed to resolve alert %d: %v", alert.ID, err)
+			continue
+		}
+
+		announced, err := m.db.GetAnnouncedChannels(alert.ID)
+		if err != nil {
+			log.Printf("Failed to get channels for alert %d: %v", alert.ID, err)
+			continue
+		}
+		for _, channel := range announced {
+			if !slices.Contains(channels, channel) {
+				channels = append(channels, channel)
+			}
+		}
+	}
+
+	first.State = models.AlertResolved
+	first.ResolvedAt = &now
+	first.DowntimeSecs = int64(downtime.Seconds())
+
+	message := fmt.Sprintf("✅ Service '%s' on server '%s' recovered after %s",
+		service.DisplayName, server.Name, downtime)
+	log.Printf("Alert resolved: %s", message)
+
+	// Nobody was told about the outage, so there's nobody to tell about the recovery
+	if len(channels) == 0 {
+		return
+	}
+
+	out.add(&notify.Event{
+		Type:     notify.EventRecovery,
+		Alert:    first,
+		Server:   server,
+		Service:  service,
+		Check:    check,
+		Text:     message,
+		Channels: channels,
+	})
 }
@@ -0,0 +1,87 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/harungecit/vigilon/internal/models"
+	"github.com/harungecit/vigilon/internal/notify"
+)
+
+func TestPushCheckCountedOnce(t *testing.T) {
+	m, server, _, nginx := newTestMonitor(t)
+	server.MonitoringMode = models.ModePush
+	nginx.FailureThreshold = 2
+
+	report := func() *models.ServiceCheck {
+		check := &models.ServiceCheck{ServiceID: nginx.ID, Status: models.StatusStopped}
+		if err := m.db.CreateServiceCheck(check); err != nil {
+			t.Fatal(err)
+		}
+		return check
+	}
+
+	// The agent pushed once, the monitor evaluates its report on two cycles
+	check := report()
+	m.handleAlert(server, nginx, check)
+	m.handleAlert(server, nginx, check)
+	state := m.states[nginx.ID]
+	if state.Status != models.StatusRunning || state.PendingCount != 1 {
+		t.Fatalf("status, pending = %s, %d; want running with 1 pending check", state.Status, state.PendingCount)
+	}
+	if state.LastCheckID != check.ID {
+		t.Errorf("last check = %d, want %d", state.LastCheckID, check.ID)
+	}
+
+	// The next report confirms the failure
+	m.handleAlert(server, nginx, report())
+	if state.Status != models.StatusStopped || state.PendingCount != 0 {
+		t.Errorf("status, pending = %s, %d; want stopped", state.Status, state.PendingCount)
+	}
+	if open := openAlerts(t, m, server); open[models.AlertService] != 1 {
+		t.Errorf("open alerts = %v, want one service alert", open)
+	}
+}
+
+func TestHandleAlertDispatchesUnlocked(t *testing.T) {
+	m, server, _, nginx := newTestMonitor(t)
+
+	// Nothing delivers yet, so the alert waits for room in the queue
+	for i := 0; i < 256; i++ {
+		m.dispatcher.Dispatch(&notify.Event{Type: notify.EventReminder})
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.handleAlert(server, nginx, &models.ServiceCheck{ServiceID: nginx.ID, Status: models.StatusFailed})
+	}()
+
+	// The alert is open and other checks can proceed while it is queued
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if m.mu.TryLock() {
+			state := m.states[nginx.ID]
+			open := state != nil && state.OpenAlertID != 0
+			m.mu.Unlock()
+			if open {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("monitor still locked while the alert waits for the notification queue")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case <-done:
+		t.Fatal("alert dispatched to a full queue")
+	default:
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go m.dispatcher.Start(ctx)
+	<-done
+	cancel()
+	m.dispatcher.Wait()
+}
@@ -0,0 +1,206 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/harungecit/vigilon/internal/maintenance"
+	"github.com/harungecit/vigilon/internal/models"
+	"github.com/harungecit/vigilon/internal/notify"
+)
+
+// hostState tracks whether a server answers its reachability checks
+type hostState struct {
+	down    bool
+	pending int // Consecutive cycles that disagree with down
+	alertID int // Open host down alert
+}
+
+// checkReachability runs the reachability checks of a server and updates
+// whether the server is down, before its other services are checked. The
+// checks alert like any service, but only while the server is up: a server
+// that is down is announced once with a host down alert instead.
+func (m *Monitor) checkReachability(ctx context.Context, server *models.Server, services []*models.Service) {
+	if len(services) == 0 {
+		// The server may have lost its last reachability check while down
+		m.updateHost(server, nil, nil)
+		return
+	}
+
+	checks := make([]*models.ServiceCheck, len(services))
+	for i, service := range services {
+		checks[i] = m.checkEndpoint(ctx, service)
+		if err := m.db.CreateServiceCheck(checks[i]); err != nil {
+			log.Printf("Failed to save check result: %v", err)
+		}
+	}
+
+	m.updateHost(server, services, checks)
+	for i, service := range services {
+		m.handleAlert(server, service, checks[i])
+	}
+}
+
+// updateHost applies the reachability checks of a cycle to the state of a
+// server. The server is down once every check failed for as many cycles as
+// the most sensitive failure threshold asks for, and up again once any of
+// them passes for its recovery threshold.
+func (m *Monitor) updateHost(server *models.Server, services []*models.Service, checks []*models.ServiceCheck) {
+	var out outbox
+	defer m.send(&out) // Runs after m.mu is released
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	host, ok := m.hosts[server.ID]
+	if !ok {
+		if len(services) == 0 {
+			return
+		}
+		host = &hostState{}
+		m.hosts[server.ID] = host
+	}
+
+	up := len(services) == 0
+	for _, check := range checks {
+		// An unknown status says nothing about the server, only failures count
+		if check.Status != models.StatusFailed {
+			up = true
+		}
+	}
+
+	if up != host.down {
+		host.pending = 0
+	} else {
+		threshold := 0
+		for _, service := range services {
+			t := service.FailureThreshold
+			if up {
+				t = service.RecoveryThreshold
+			}
+			if threshold == 0 || t < threshold {
+				threshold = t
+			}
+		}
+
+		host.pending++
+		if host.pending >= threshold {
+			host.down = !up
+			host.pending = 0
+			if host.down {
+				log.Printf("Server %s is down, alerts of its services are paused", server.Name)
+			} else {
+				log.Printf("Server %s is reachable again", server.Name)
+			}
+		}
+	}
+
+	switch {
+	case host.down && host.alertID == 0:
+		// Announced once the maintenance window ends, if the server is still down
+		if window := maintenance.Match(m.windows, server, nil); window != nil {
+			return
+		}
+		m.createHostDownAlert(server, services[0], checks[0], host, &out)
+	case !host.down && host.alertID != 0:
+		var service *models.Service
+		var check *models.ServiceCheck
+		if len(services) > 0 {
+			service, check = services[0], checks[0]
+		}
+		m.resolveHostDownAlert(server, service, check, host, &out)
+	}
+
+	if !host.down && host.pending == 0 && len(services) == 0 {
+		delete(m.hosts, server.ID)
+	}
+}
+
+// hostDown reports whether a server is down. The caller holds m.mu.
+func (m *Monitor) hostDown(server *models.Server) bool {
+	host, ok := m.hosts[server.ID]
+	return ok && host.down
+}
+
+// createHostDownAlert announces that a server no longer answers any of its
+// reachability checks. It stands for the alerts of all its services.
+func (m *Monitor) createHostDownAlert(server *models.Server, service *models.Service, check *models.ServiceCheck, host *hostState, out *outbox) {
+	message := fmt.Sprintf("🔌 Server '%s' is down", server.Name)
+	if check.ErrorMessage != "" {
+		message += fmt.Sprintf("\nError: %s", check.ErrorMessage)
+	}
+	message += "\nAlerts of its services are paused until it is reachable again."
+
+	alert := &models.Alert{
+		Type:      models.AlertHostDown,
+		ServiceID: service.ID,
+		ServerID:  server.ID,
+		Status:    models.StatusFailed,
+		Message:   message,
+		SentVia:   "pending",
+
+		EscalationPolicyID: server.EscalationPolicyID,
+	}
+	if err := m.db.CreateAlert(alert); err != nil {
+		log.Printf("Failed to create alert: %v", err)
+		return
+	}
+
+	out.add(&notify.Event{
+		Type:    notify.EventAlert,
+		Alert:   alert,
+		Server:  server,
+		Service: service,
+		Check:   check,
+	})
+	host.alertID = alert.ID
+
+	log.Printf("Alert created: %s", message)
+}
+
+// resolveHostDownAlert closes the host down alert of a server that is
+// reachable again. service and check are nil when the server has no
+// reachability checks left.
+func (m *Monitor) resolveHostDownAlert(server *models.Server, service *models.Service, check *models.ServiceCheck, host *hostState, out *outbox) {
+	alertID := host.alertID
+	host.alertID = 0
+
+	alert, err := m.db.GetAlert(alertID)
+	if err != nil {
+		log.Printf("Failed to get alert %d: %v", alertID, err)
+		return
+	}
+
+	now := time.Now()
+	downtime := now.Sub(alert.CreatedAt).Round(time.Second)
+	if err := m.db.ResolveAlert(alert.ID, now, downtime); err != nil {
+		log.Printf("Failed to resolve alert %d: %v", alert.ID, err)
+		return
+	}
+	alert.State = models.AlertResolved
+	alert.ResolvedAt = &now
+	alert.DowntimeSecs = int64(downtime.Seconds())
+
+	message := fmt.Sprintf("✅ Server '%s' is reachable again after %s", server.Name, downtime)
+	log.Printf("Alert resolved: %s", message)
+
+	channels, err := m.db.GetAnnouncedChannels(alert.ID)
+	if err != nil {
+		log.Printf("Failed to get channels for alert %d: %v", alert.ID, err)
+		return
+	}
+	if len(channels) == 0 {
+		return
+	}
+
+	out.add(&notify.Event{
+		Type:     notify.EventRecovery,
+		Alert:    alert,
+		Server:   server,
+		Service:  service,
+		Check:    check,
+		Text:     message,
+		Channels: channels,
+	})
+}
@@ -0,0 +1,160 @@
+package monitor
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/harungecit/vigilon/internal/database"
+	"github.com/harungecit/vigilon/internal/models"
+	"github.com/harungecit/vigilon/internal/notify"
+)
+
+// newTestMonitor returns a monitor on a fresh database with a server that
+// has a reachability check and a regular service
+func newTestMonitor(t *testing.T) (*Monitor, *models.Server, *models.Service, *models.Service) {
+	t.Helper()
+	db, err := database.New(filepath.Join(t.TempDir(), "vigilon.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	server := &models.Server{Name: "web-1", Hostname: "web-1.internal", OS: "linux", MonitoringMode: models.ModePull, Enabled: true}
+	if err := db.CreateServer(server); err != nil {
+		t.Fatal(err)
+	}
+	gateway := &models.Service{
+		ServerID:         server.ID,
+		Name:             "ping",
+		DisplayName:      "Ping",
+		Enabled:          true,
+		Type:             models.ServiceTypePing,
+		Ping:             &models.PingCheck{Host: "web-1.internal"},
+		Reachability:     true,
+		FailureThreshold: 2,
+	}
+	nginx := &models.Service{ServerID: server.ID, Name: "nginx.service", DisplayName: "Nginx", Enabled: true}
+	for _, service := range []*models.Service{gateway, nginx} {
+		if err := db.CreateService(service); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := New(db, nil, notify.NewDispatcher(db, notify.RetryPolicy{}), time.Minute, ReminderPolicy{})
+	return m, server, gateway, nginx
+}
+
+// openAlerts returns the open alerts of a server by type
+func openAlerts(t *testing.T, m *Monitor, server *models.Server) map[models.AlertType]int {
+	t.Helper()
+	alerts, err := m.db.GetRecentAlerts(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	open := make(map[models.AlertType]int)
+	for _, alert := range alerts {
+		if alert.ServerID == server.ID && alert.State == models.AlertOpen {
+			open[alert.Type]++
+		}
+	}
+	return open
+}
+
+func TestHostDown(t *testing.T) {
+	m, server, gateway, nginx := newTestMonitor(t)
+	failed := func(service *models.Service) *models.ServiceCheck {
+		return &models.ServiceCheck{ServiceID: service.ID, Status: models.StatusFailed, ErrorMessage: "no reply to 3 echo requests"}
+	}
+	running := func(service *models.Service) *models.ServiceCheck {
+		return &models.ServiceCheck{ServiceID: service.ID, Status: models.StatusRunning}
+	}
+	reachability := []*models.Service{gateway}
+
+	// The failure threshold of the reachability check applies to the server
+	m.updateHost(server, reachability, []*models.ServiceCheck{failed(gateway)})
+	if m.hostDown(server) {
+		t.Fatal("server down after one failed check, want it to wait for the threshold")
+	}
+	m.updateHost(server, reachability, []*models.ServiceCheck{failed(gateway)})
+	if !m.hostDown(server) {
+		t.Fatal("server not down after two failed checks")
+	}
+	if open := openAlerts(t, m, server); open[models.AlertHostDown] != 1 {
+		t.Fatalf("open alerts = %v, want one host down alert", open)
+	}
+
+	// Services that fail with the server are not announced on their own
+	m.handleAlert(server, gateway, failed(gateway))
+	m.handleAlert(server, nginx, failed(nginx))
+	if open := openAlerts(t, m, server); open[models.AlertService] != 0 {
+		t.Errorf("open alerts = %v, want no service alerts while the server is down", open)
+	}
+
+	// A further cycle does not announce the server again
+	m.updateHost(server, reachability, []*models.ServiceCheck{failed(gateway)})
+	if open := openAlerts(t, m, server); open[models.AlertHostDown] != 1 {
+		t.Errorf("open alerts = %v, want a single host down alert", open)
+	}
+
+	m.updateHost(server, reachability, []*models.ServiceCheck{running(gateway)})
+	if m.hostDown(server) {
+		t.Fatal("server still down after its reachability check passed")
+	}
+	if open := openAlerts(t, m, server); open[models.AlertHostDown] != 0 {
+		t.Errorf("open alerts = %v, want the host down alert resolved", open)
+	}
+
+	// Once the server is up, a service that is still down is announced
+	m.handleAlert(server, gateway, running(gateway))
+	m.handleAlert(server, nginx, failed(nginx))
+	if open := openAlerts(t, m, server); open[models.AlertService] != 1 {
+		t.Errorf("open alerts = %v, want an alert for the service still down", open)
+	}
+}
+
+func TestHostDownNeedsEveryCheckFailed(t *testing.T) {
+	m, server, gateway, _ := newTestMonitor(t)
+	gateway.FailureThreshold = 1
+	ssh := &models.Service{ID: gateway.ID + 100, Type: models.ServiceTypeTCP, Reachability: true, FailureThreshold: 1}
+	reachability := []*models.Service{gateway, ssh}
+
+	m.updateHost(server, reachability, []*models.ServiceCheck{
+		{Status: models.StatusFailed},
+		{Status: models.StatusRunning},
+	})
+	if m.hostDown(server) {
+		t.Error("server down while one of its reachability checks passes")
+	}
+
+	// A check that could not run says nothing about the server
+	m.updateHost(server, reachability, []*models.ServiceCheck{
+		{Status: models.StatusFailed},
+		{Status: models.StatusUnknown},
+	})
+	if m.hostDown(server) {
+		t.Error("server down while one of its reachability checks is unknown")
+	}
+}
+
+func TestHostDownWithoutReachabilityChecks(t *testing.T) {
+	m, server, gateway, _ := newTestMonitor(t)
+	gateway.FailureThreshold = 1
+
+	m.updateHost(server, []*models.Service{gateway}, []*models.ServiceCheck{{Status: models.StatusFailed}})
+	if !m.hostDown(server) {
+		t.Fatal("server not down")
+	}
+
+	// Removing the last reachability check brings the server back up
+	m.updateHost(server, nil, nil)
+	if m.hostDown(server) {
+		t.Error("server still down without reachability checks")
+	}
+	if open := openAlerts(t, m, server); open[models.AlertHostDown] != 0 {
+		t.Errorf("open alerts = %v, want the host down alert resolved", open)
+	}
+	if _, ok := m.hosts[server.ID]; ok {
+		t.Error("state kept for a server without reachability checks")
+	}
+}
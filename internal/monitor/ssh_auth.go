@@ -0,0 +1,206 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+
+	"github.com/harungecit/vigilon/internal/models"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// sshEndpoint is a host to authenticate to: a jump host or the server itself
+type sshEndpoint struct {
+	host             string
+	port             int
+	user             string
+	method           models.SSHAuthMethod
+	keyPath          string
+	keySecret        string
+	passwordSecret   string
+	passphraseSecret string
+	agentSocket      string
+}
+
+// serverEndpoint returns the endpoint of the server itself
+func serverEndpoint(server *models.Server) sshEndpoint {
+	return sshEndpoint{
+		host:             server.IPAddress,
+		port:             server.Port,
+		user:             server.SSHUser,
+		method:           models.ResolveAuthMethod(server.SSHAuthMethod, server.SSHKeySecret),
+		keyPath:          server.SSHKeyPath,
+		keySecret:        server.SSHKeySecret,
+		passwordSecret:   server.SSHPasswordSecret,
+		passphraseSecret: server.SSHPassphraseSecret,
+		agentSocket:      server.SSHAgentSocket,
+	}
+}
+
+// hopEndpoint returns the endpoint of a jump host
+func hopEndpoint(hop models.JumpHost) sshEndpoint {
+	return sshEndpoint{
+		host:             hop.Host,
+		port:             hop.Port,
+		user:             hop.User,
+		method:           models.ResolveAuthMethod(hop.AuthMethod, hop.KeySecret),
+		keyPath:          hop.KeyPath,
+		keySecret:        hop.KeySecret,
+		passwordSecret:   hop.PasswordSecret,
+		passphraseSecret: hop.PassphraseSecret,
+		agentSocket:      hop.AgentSocket,
+	}
+}
+
+// sshUser returns the login user, defaulting to the local user like the ssh
+// command does
+func sshUser(name string) string {
+	if name != "" {
+		return name
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return "root"
+}
+
+// sshAuth returns the auth methods of an endpoint. The returned function
+// releases what authentication needed, e.g. the agent connection, once the
+// handshake is done.
+func (p *SSHPool) sshAuth(endpoint sshEndpoint) ([]ssh.AuthMethod, func(), error) {
+	noop := func() {}
+
+	switch endpoint.method {
+	case models.AuthKeyFile:
+		auth, err := p.keyFileAuth(endpoint)
+		return auth, noop, err
+
+	case models.AuthStoredKey:
+		if endpoint.keySecret == "" {
+			return nil, nil, errors.New("no SSH key secret configured")
+		}
+		key, err := p.secrets.Secret(endpoint.keySecret)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load SSH key: %w", err)
+		}
+		signer, err := p.parsePrivateKey(key, endpoint.passphraseSecret, "secret "+endpoint.keySecret)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []ssh.AuthMethod{ssh.PublicKeys(signer)}, noop, nil
+
+	case models.AuthPassword:
+		if endpoint.passwordSecret == "" {
+			return nil, nil, errors.New("no SSH password secret configured")
+		}
+		password, err := p.secrets.Secret(endpoint.passwordSecret)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load SSH password: %w", err)
+		}
+		return passwordAuth(string(password)), noop, nil
+
+	case models.AuthAgent:
+		return agentAuth(endpoint.agentSocket)
+	}
+	return nil, nil, fmt.Errorf("unknown SSH auth method %q", endpoint.method)
+}
+
+// keyFileAuth loads the configured key file, or the default identity files
+// from ~/.ssh when no key is configured
+func (p *SSHPool) keyFileAuth(endpoint sshEndpoint) ([]ssh.AuthMethod, error) {
+	if endpoint.keyPath != "" {
+		key, err := os.ReadFile(endpoint.keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SSH key: %w", err)
+		}
+		signer, err := p.parsePrivateKey(key, endpoint.passphraseSecret, endpoint.keyPath)
+		if err != nil {
+			return nil, err
+		}
+		return []ssh.AuthMethod{ssh.PublicKeys(signer)}, nil
+	}
+
+	// Default keys are only used if they are not passphrase protected
+	var signers []ssh.Signer
+	if home, err := os.UserHomeDir(); err == nil {
+		for _, name := range defaultIdentityFiles {
+			key, err := os.ReadFile(filepath.Join(home, ".ssh", name))
+			if err != nil {
+				continue
+			}
+			if signer, err := ssh.ParsePrivateKey(key); err == nil {
+				signers = append(signers, signer)
+			}
+		}
+	}
+	if len(signers) == 0 {
+		return nil, fmt.Errorf("no SSH key configured and no default key found in ~/.ssh")
+	}
+	return []ssh.AuthMethod{ssh.PublicKeys(signers...)}, nil
+}
+
+// parsePrivateKey parses a private key, decrypting it with the passphrase
+// from the vault if one is configured. source names the key in errors.
+func (p *SSHPool) parsePrivateKey(key []byte, passphraseSecret, source string) (ssh.Signer, error) {
+	if passphraseSecret == "" {
+		signer, err := ssh.ParsePrivateKey(key)
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			return nil, fmt.Errorf("SSH key %s is passphrase protected and no passphrase secret is configured", source)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SSH key %s: %w", source, err)
+		}
+		return signer, nil
+	}
+
+	passphrase, err := p.secrets.Secret(passphraseSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load SSH key passphrase: %w", err)
+	}
+	signer, err := ssh.ParsePrivateKeyWithPassphrase(key, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt SSH key %s: %w", source, err)
+	}
+	return signer, nil
+}
+
+// passwordAuth authenticates with a password, also answering the password
+// prompt of servers that only offer keyboard-interactive authentication
+func passwordAuth(password string) []ssh.AuthMethod {
+	return []ssh.AuthMethod{
+		ssh.Password(password),
+		ssh.KeyboardInteractive(func(_, _ string, questions []string, echos []bool) ([]string, error) {
+			answers := make([]string, len(questions))
+			for i := range questions {
+				if !echos[i] {
+					answers[i] = password
+				}
+			}
+			return answers, nil
+		}),
+	}
+}
+
+// agentAuth authenticates with the keys of the ssh-agent listening on a
+// socket, $SSH_AUTH_SOCK by default. The connection to the agent has to stay
+// open until the handshake is done.
+func agentAuth(socket string) ([]ssh.AuthMethod, func(), error) {
+	if socket == "" {
+		socket = os.Getenv("SSH_AUTH_SOCK")
+	}
+	if socket == "" {
+		return nil, nil, errors.New("no ssh-agent socket configured and SSH_AUTH_SOCK is not set")
+	}
+
+	conn, err := net.DialTimeout("unix", socket, sshConnectTimeout)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to ssh-agent: %w", err)
+	}
+	client := agent.NewClient(conn)
+	return []ssh.AuthMethod{ssh.PublicKeysCallback(client.Signers)}, func() { conn.Close() }, nil
+}
@@ -0,0 +1,342 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/harungecit/vigilon/internal/models"
+	"golang.org/x/crypto/ssh"
+)
+
+// SSH connection settings, matching the options the ssh command used to run with
+const (
+	sshConnectTimeout   = 5 * time.Second  // TCP connect
+	sshHandshakeTimeout = 10 * time.Second // SSH handshake and authentication
+	sshKeepalive        = 5 * time.Second  // Keepalive interval
+	sshKeepaliveMissed  = 2                // Missed keepalives before disconnecting
+	sshIdleTimeout      = 5 * time.Minute  // Close connections unused this long
+	sshMaxSessions      = 8                // Concurrent sessions per connection (OpenSSH allows 10)
+)
+
+// defaultIdentityFiles are tried in order when a server has no key configured
+var defaultIdentityFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// SSHPool keeps one SSH connection per server and runs commands in sessions
+// multiplexed over it
+type SSHPool struct {
+	mu       sync.Mutex
+	conns    map[int]*sshConn // key: server ID
+	hostKeys HostKeyStore
+	secrets  SecretStore
+}
+
+// SecretStore returns the values of vault secrets
+type SecretStore interface {
+	Secret(name string) ([]byte, error)
+}
+
+// sshConn is the pooled connection of a server
+type sshConn struct {
+	mu       sync.Mutex
+	client   *ssh.Client
+	params   string // Connection settings the client was dialed with
+	lastUsed time.Time
+	sessions chan struct{}
+}
+
+// NewSSHPool creates an empty connection pool that verifies host keys
+// against hostKeys and loads stored private keys from secrets
+func NewSSHPool(hostKeys HostKeyStore, secrets SecretStore) *SSHPool {
+	return &SSHPool{conns: make(map[int]*sshConn), hostKeys: hostKeys, secrets: secrets}
+}
+
+// Output runs a command on a server and returns its standard output. Like
+// exec.Cmd.Output, a non-zero exit status is returned as an error.
+func (p *SSHPool) Output(ctx context.Context, server *models.Server, cmd string) (string, error) {
+	return p.run(ctx, server, cmd, false)
+}
+
+// CombinedOutput runs a command on a server and returns its standard output
+// and standard error
+func (p *SSHPool) CombinedOutput(ctx context.Context, server *models.Server, cmd string) (string, error) {
+	return p.run(ctx, server, cmd, true)
+}
+
+// Close closes every pooled connection
+func (p *SSHPool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id, conn := range p.conns {
+		conn.close()
+		delete(p.conns, id)
+	}
+}
+
+// run runs a command in a new session. A connection that turns out to be
+// dead when the session is opened is dialed again once.
+func (p *SSHPool) run(ctx context.Context, server *models.Server, cmd string, combined bool) (string, error) {
+	conn := p.conn(server)
+
+	select {
+	case conn.sessions <- struct{}{}:
+		defer func() { <-conn.sessions }()
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+
+	var session *ssh.Session
+	for attempt := 0; ; attempt++ {
+		client, err := conn.get(ctx, server, p.dialSSH)
+		if err != nil {
+			return "", err
+		}
+		session, err = client.NewSession()
+		if err == nil {
+			break
+		}
+		conn.drop(client)
+		if attempt > 0 {
+			return "", fmt.Errorf("failed to open session: %w", err)
+		}
+	}
+	defer session.Close()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+	if combined {
+		session.Stderr = &stdout
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- session.Run(cmd) }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return stdout.String(), commandError(err, stderr.String())
+		}
+		return stdout.String(), nil
+	case <-ctx.Done():
+		// Closing the session ends the command; the connection stays usable
+		session.Close()
+		return "", ctx.Err()
+	}
+}
+
+// conn returns the pool entry of a server and closes connections that have
+// been idle too long, e.g. of servers that were deleted or disabled
+func (p *SSHPool) conn(server *models.Server) *sshConn {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	for id, conn := range p.conns {
+		if id != server.ID && len(conn.sessions) == 0 && conn.idleSince(now) > sshIdleTimeout {
+			conn.close()
+			delete(p.conns, id)
+		}
+	}
+
+	conn, ok := p.conns[server.ID]
+	if !ok {
+		conn = &sshConn{sessions: make(chan struct{}, sshMaxSessions)}
+		p.conns[server.ID] = conn
+	}
+	conn.touch(now)
+	return conn
+}
+
+// get returns the connected client, dialing it if there is none yet or the
+// server's connection settings changed
+func (c *sshConn) get(ctx context.Context, server *models.Server, dial func(context.Context, *models.Server) (*ssh.Client, error)) (*ssh.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	params := sshParams(server)
+	if c.client != nil && c.params == params {
+		return c.client, nil
+	}
+	if c.client != nil {
+		c.client.Close()
+		c.client = nil
+	}
+
+	client, err := dial(ctx, server)
+	if err != nil {
+		return nil, err
+	}
+	c.client, c.params = client, params
+	return client, nil
+}
+
+// drop closes client if it is still the connection's current client
+func (c *sshConn) drop(client *ssh.Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	client.Close()
+	if c.client == client {
+		c.client = nil
+	}
+}
+
+// close closes the connection's client
+func (c *sshConn) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client != nil {
+		c.client.Close()
+		c.client = nil
+	}
+}
+
+// touch marks the connection as used
+func (c *sshConn) touch(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastUsed = now
+}
+
+// idleSince returns how long the connection has not been used
+func (c *sshConn) idleSince(now time.Time) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return now.Sub(c.lastUsed)
+}
+
+// sshParams identifies the settings a server's connection depends on
+func sshParams(server *models.Server) string {
+	params := fmt.Sprintf("%+v", serverEndpoint(server))
+	for _, hop := range server.JumpChain() {
+		params += fmt.Sprintf("|%+v", hopEndpoint(hop))
+	}
+	return params
+}
+
+// dialSSH connects and authenticates to a server, through its jump hosts if
+// it has any. Each hop is reached through the previous one, like ProxyJump,
+// and closing the returned client closes the whole chain.
+func (p *SSHPool) dialSSH(ctx context.Context, server *models.Server) (*ssh.Client, error) {
+	var hops []*ssh.Client
+	closeHops := func() {
+		for i := len(hops) - 1; i >= 0; i-- {
+			hops[i].Close()
+		}
+	}
+
+	var via *ssh.Client
+	for i, hop := range server.JumpChain() {
+		client, err := p.dialEndpoint(ctx, via, hopEndpoint(hop))
+		if err != nil {
+			closeHops()
+			return nil, fmt.Errorf("jump host %d (%s): %w", i+1, hop.Host, err)
+		}
+		hops = append(hops, client)
+		via = client
+	}
+
+	client, err := p.dialEndpoint(ctx, via, serverEndpoint(server))
+	if err != nil {
+		closeHops()
+		return nil, err
+	}
+
+	if len(hops) > 0 {
+		go func() {
+			client.Wait()
+			closeHops()
+		}()
+	}
+	go keepalive(client)
+	return client, nil
+}
+
+// dialEndpoint connects and authenticates to an endpoint, directly or
+// through the client of the previous hop
+func (p *SSHPool) dialEndpoint(ctx context.Context, via *ssh.Client, endpoint sshEndpoint) (*ssh.Client, error) {
+	auth, closeAuth, err := p.sshAuth(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAuth()
+
+	addr := models.SSHAddress(endpoint.host, endpoint.port)
+
+	hostKeyCallback, hostKeyAlgorithms, err := p.hostKeyConfig(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &ssh.ClientConfig{
+		User:              sshUser(endpoint.user),
+		Auth:              auth,
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: hostKeyAlgorithms,
+		Timeout:           sshHandshakeTimeout,
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, sshConnectTimeout)
+	defer cancel()
+
+	var netConn net.Conn
+	if via != nil {
+		netConn, err = via.DialContext(dialCtx, "tcp", addr)
+	} else {
+		netConn, err = (&net.Dialer{}).DialContext(dialCtx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+
+	// Bound the handshake by the timeout and by the caller's context. Tunneled
+	// connections have no deadlines, so the connection is closed instead.
+	handshakeCtx, cancelHandshake := context.WithTimeout(ctx, sshHandshakeTimeout)
+	defer cancelHandshake()
+	stop := context.AfterFunc(handshakeCtx, func() { netConn.Close() })
+
+	conn, chans, reqs, err := ssh.NewClientConn(netConn, addr, config)
+	if !stop() && err == nil {
+		conn.Close()
+		err = handshakeCtx.Err()
+	}
+	if err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("ssh handshake with %s failed: %w", addr, err)
+	}
+
+	return ssh.NewClient(conn, chans, reqs), nil
+}
+
+// keepalive pings the server and closes the connection once it stops
+// answering, so commands fail fast instead of hanging on a dead link
+func keepalive(client *ssh.Client) {
+	ticker := time.NewTicker(sshKeepalive)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		timer := time.AfterFunc(sshKeepalive*sshKeepaliveMissed, func() { client.Close() })
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		timer.Stop()
+		if err != nil {
+			client.Close()
+			return
+		}
+	}
+}
+
+// commandError adds the command's standard error to a failed run
+func commandError(err error, stderr string) error {
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		if msg := strings.TrimSpace(stderr); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+	}
+	return err
+}
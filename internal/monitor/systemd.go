@@ -0,0 +1,126 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/harungecit/vigilon/internal/models"
+)
+
+// systemdProperties are the unit properties a probe reads. LoadState tells
+// units that do not exist apart from stopped ones.
+var systemdProperties = []string{
+	"Id", "LoadState", "ActiveState", "SubState", "MainPID",
+	"MemoryCurrent", "CPUUsageNSec", "ActiveEnterTimestampMonotonic",
+}
+
+// ServiceProbe is the result of checking one service
+type ServiceProbe struct {
+	Status models.ServiceStatus
+	Info   *ServiceInfo
+	Err    error
+}
+
+// systemdProbeScript returns a script that prints the system uptime followed
+// by the properties of every unit, so all services of a server are checked
+// in one round trip. The names must be valid service names.
+func systemdProbeScript(units []string) string {
+	return fmt.Sprintf("cat /proc/uptime && systemctl show --property=%s -- %s",
+		strings.Join(systemdProperties, ","), strings.Join(units, " "))
+}
+
+// parseSystemdProbe parses the output of systemdProbeScript. systemctl prints
+// the units in the order they were given, separated by blank lines.
+func parseSystemdProbe(output string, units []string) (map[string]*ServiceProbe, error) {
+	lines := strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) == "" {
+		return nil, errors.New("empty probe output")
+	}
+
+	// /proc/uptime: seconds since boot, then idle time
+	fields := strings.Fields(lines[0])
+	now, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid uptime %q", lines[0])
+	}
+
+	var blocks []map[string]string
+	var current map[string]string
+	for _, line := range lines[1:] {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			current = nil
+			continue
+		}
+		if current == nil {
+			current = make(map[string]string)
+			blocks = append(blocks, current)
+		}
+		current[key] = value
+	}
+	if len(blocks) != len(units) {
+		return nil, fmt.Errorf("expected %d units in probe output, got %d", len(units), len(blocks))
+	}
+
+	probes := make(map[string]*ServiceProbe, len(units))
+	for i, unit := range units {
+		probes[unit] = systemdProbe(blocks[i], now)
+	}
+	return probes, nil
+}
+
+// systemdProbe maps the properties of a unit to a service status. now is the
+// system uptime in seconds.
+func systemdProbe(props map[string]string, now float64) *ServiceProbe {
+	if props["LoadState"] == "not-found" {
+		return &ServiceProbe{Status: models.StatusUnknown, Err: fmt.Errorf("unit %s not found", props["Id"])}
+	}
+
+	probe := &ServiceProbe{Status: systemdStatus(props["ActiveState"])}
+	if probe.Status != models.StatusRunning {
+		return probe
+	}
+
+	info := &ServiceInfo{}
+	info.PID, _ = strconv.Atoi(props["MainPID"])
+
+	// Unset counters read "[not set]" or the maximum uint64
+	if memory, err := strconv.ParseInt(props["MemoryCurrent"], 10, 64); err == nil {
+		info.Memory = memory / 1024
+	}
+
+	// The monotonic clock stops while a machine is suspended and uptime does
+	// not, which servers rarely are
+	if entered, err := strconv.ParseInt(props["ActiveEnterTimestampMonotonic"], 10, 64); err == nil && entered > 0 {
+		active := now - float64(entered)/1e6
+		if active > 0 {
+			info.Uptime = int64(active)
+
+			// Average CPU usage since the unit started, like ps %cpu
+			if cpu, err := strconv.ParseInt(props["CPUUsageNSec"], 10, 64); err == nil {
+				info.CPU = float64(cpu) / 1e9 / active * 100
+			}
+		}
+	}
+
+	probe.Info = info
+	return probe
+}
+
+// systemdStatus maps the ActiveState of a unit to a service status
+func systemdStatus(activeState string) models.ServiceStatus {
+	switch activeState {
+	case "active", "reloading":
+		return models.StatusRunning
+	case "inactive":
+		return models.StatusStopped
+	case "failed":
+		return models.StatusFailed
+	case "activating", "deactivating":
+		return models.StatusDegraded
+	default:
+		return models.StatusUnknown
+	}
+}
@@ -0,0 +1,585 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/harungecit/vigilon/internal/models"
+)
+
+// WinRM settings
+const (
+	winrmHTTPPort         = 5985
+	winrmHTTPSPort        = 5986
+	winrmOperationTimeout = 20 * time.Second // How long a Receive waits for output
+	winrmRequestTimeout   = winrmOperationTimeout + 10*time.Second
+	winrmMaxResponse      = 4 << 20
+	winrmMaxEnvelope      = 153600
+)
+
+// WS-Management URIs of the remote shell protocol (MS-WSMV)
+const (
+	wsmanShellURI      = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/cmd"
+	wsmanActionCreate  = "http://schemas.xmlsoap.org/ws/2004/09/transfer/Create"
+	wsmanActionDelete  = "http://schemas.xmlsoap.org/ws/2004/09/transfer/Delete"
+	wsmanActionCommand = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/Command"
+	wsmanActionReceive = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/Receive"
+	wsmanActionSignal  = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/Signal"
+	wsmanStateDone     = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/CommandState/Done"
+	wsmanSignalTerm    = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/signal/terminate"
+	wsmanTimedOutCode  = "2150858793" // Receive returned before the command wrote output
+)
+
+// WinRMPool runs commands on Windows servers over WinRM. Each command runs
+// in a shell of its own; the HTTP connection of a server is kept, so NTLM
+// authenticates once per connection.
+type WinRMPool struct {
+	mu      sync.Mutex
+	conns   map[int]*winrmConn // key: server ID
+	secrets SecretStore
+}
+
+// winrmConn is the WinRM endpoint of a server
+type winrmConn struct {
+	params   string // Settings the endpoint was created with
+	url      string
+	auth     models.WinRMAuth
+	user     string
+	password string
+	client   *http.Client
+	lastUsed time.Time
+
+	// NTLM authenticates the connection rather than each request, so
+	// requests take turns: one sent halfway through a handshake breaks it
+	mu sync.Mutex
+}
+
+// WinRMExitError is returned for commands that exit with a non-zero status
+type WinRMExitError struct {
+	Code int
+}
+
+func (e *WinRMExitError) Error() string {
+	return fmt.Sprintf("command exited with status %d", e.Code)
+}
+
+// wsmanFault is a SOAP fault returned by the WinRM service
+type wsmanFault struct {
+	Code    string // WSManFault code, e.g. 2150858793
+	Subcode string
+	Reason  string
+}
+
+func (f *wsmanFault) Error() string {
+	if f.Reason == "" {
+		return "WinRM fault " + f.Subcode
+	}
+	return "WinRM fault: " + f.Reason
+}
+
+// timedOut reports whether a Receive returned because the command wrote no
+// output within the operation timeout
+func (f *wsmanFault) timedOut() bool {
+	return f.Code == wsmanTimedOutCode || strings.HasSuffix(f.Subcode, ":TimedOut")
+}
+
+// NewWinRMPool creates a WinRM client that loads passwords from secrets
+func NewWinRMPool(secrets SecretStore) *WinRMPool {
+	return &WinRMPool{conns: make(map[int]*winrmConn), secrets: secrets}
+}
+
+// Output runs a command on a server and returns its standard output. A
+// non-zero exit status is returned as a *WinRMExitError.
+func (p *WinRMPool) Output(ctx context.Context, server *models.Server, cmd string) (string, error) {
+	return p.run(ctx, server, cmd, false)
+}
+
+// CombinedOutput runs a command on a server and returns its standard output
+// and standard error
+func (p *WinRMPool) CombinedOutput(ctx context.Context, server *models.Server, cmd string) (string, error) {
+	return p.run(ctx, server, cmd, true)
+}
+
+// Close closes the idle connections of every server
+func (p *WinRMPool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id, conn := range p.conns {
+		conn.client.CloseIdleConnections()
+		delete(p.conns, id)
+	}
+}
+
+// run creates a shell, runs the command in it until it is done and removes
+// the shell again
+func (p *WinRMPool) run(ctx context.Context, server *models.Server, cmd string, combined bool) (string, error) {
+	conn, err := p.conn(server)
+	if err != nil {
+		return "", err
+	}
+
+	shellID, err := conn.createShell(ctx)
+	if err != nil {
+		if errors.Is(err, errWinRMUnauthorized) {
+			// The password may have changed in the vault
+			p.drop(server.ID, conn)
+		}
+		return "", err
+	}
+	defer conn.deleteShell(shellID)
+
+	commandID, err := conn.command(ctx, shellID, cmd)
+	if err != nil {
+		return "", err
+	}
+
+	var stdout, stderr bytes.Buffer
+	errOut := &stderr
+	if combined {
+		errOut = &stdout
+	}
+	for {
+		exitCode, done, err := conn.receive(ctx, shellID, commandID, &stdout, errOut)
+		var fault *wsmanFault
+		if errors.As(err, &fault) && fault.timedOut() {
+			continue
+		}
+		if err != nil {
+			return stdout.String(), err
+		}
+		if !done {
+			continue
+		}
+		conn.signal(shellID, commandID)
+		if exitCode != 0 {
+			err := error(&WinRMExitError{Code: exitCode})
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				err = fmt.Errorf("%w: %s", err, msg)
+			}
+			return stdout.String(), err
+		}
+		return stdout.String(), nil
+	}
+}
+
+// conn returns the endpoint of a server, creating it when the server's
+// settings changed. Endpoints that have been idle too long are removed.
+func (p *WinRMPool) conn(server *models.Server) (*winrmConn, error) {
+	if server.WinRM == nil {
+		return nil, errors.New("no WinRM settings configured")
+	}
+	params := fmt.Sprintf("%s|%d|%+v", server.IPAddress, server.Port, *server.WinRM)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	for id, conn := range p.conns {
+		if id != server.ID && now.Sub(conn.lastUsed) > sshIdleTimeout {
+			conn.client.CloseIdleConnections()
+			delete(p.conns, id)
+		}
+	}
+
+	if conn, ok := p.conns[server.ID]; ok && conn.params == params {
+		conn.lastUsed = now
+		return conn, nil
+	}
+	if old, ok := p.conns[server.ID]; ok {
+		old.client.CloseIdleConnections()
+	}
+
+	conn, err := p.newConn(server, params)
+	if err != nil {
+		return nil, err
+	}
+	conn.lastUsed = now
+	p.conns[server.ID] = conn
+	return conn, nil
+}
+
+// drop removes the endpoint of a server if it is still the given one
+func (p *WinRMPool) drop(serverID int, conn *winrmConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conns[serverID] == conn {
+		conn.client.CloseIdleConnections()
+		delete(p.conns, serverID)
+	}
+}
+
+func (p *WinRMPool) newConn(server *models.Server, params string) (*winrmConn, error) {
+	cfg := server.WinRM
+	if cfg.PasswordSecret == "" {
+		return nil, errors.New("no WinRM password secret configured")
+	}
+	password, err := p.secrets.Secret(cfg.PasswordSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load WinRM password: %w", err)
+	}
+
+	scheme, port := "http", winrmHTTPPort
+	if cfg.HTTPS {
+		scheme, port = "https", winrmHTTPSPort
+	}
+	if server.Port != 0 {
+		port = server.Port
+	}
+
+	auth := cfg.Auth
+	if auth == "" {
+		auth = models.WinRMAuthNTLM
+	}
+
+	// One connection per server: the NTLM handshake has to stay on the
+	// connection it authenticates
+	transport := &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: sshConnectTimeout}).DialContext,
+		TLSClientConfig:     &tls.Config{InsecureSkipVerify: cfg.Insecure},
+		TLSHandshakeTimeout: sshHandshakeTimeout,
+		MaxConnsPerHost:     1,
+		IdleConnTimeout:     sshIdleTimeout,
+	}
+	return &winrmConn{
+		params:   params,
+		url:      fmt.Sprintf("%s://%s/wsman", scheme, net.JoinHostPort(server.IPAddress, strconv.Itoa(port))),
+		auth:     auth,
+		user:     cfg.User,
+		password: string(password),
+		client:   &http.Client{Transport: transport, Timeout: winrmRequestTimeout},
+	}, nil
+}
+
+// createShell opens a cmd shell and returns its ID
+func (c *winrmConn) createShell(ctx context.Context) (string, error) {
+	options := []wsmanOption{{"WINRS_NOPROFILE", "TRUE"}, {"WINRS_CODEPAGE", "65001"}}
+	body := `<rsp:Shell><rsp:InputStreams>stdin</rsp:InputStreams><rsp:OutputStreams>stdout stderr</rsp:OutputStreams></rsp:Shell>`
+	resp, err := c.post(ctx, wsmanActionCreate, "", options, body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create WinRM shell: %w", err)
+	}
+
+	shellID := resp.Body.Shell.ShellID
+	if shellID == "" {
+		for _, selector := range resp.Body.ResourceCreated.Selectors {
+			if selector.Name == "ShellId" {
+				shellID = selector.Value
+			}
+		}
+	}
+	if shellID == "" {
+		return "", errors.New("failed to create WinRM shell: no shell ID in response")
+	}
+	return shellID, nil
+}
+
+// deleteShell removes a shell. It also runs when the command was canceled,
+// so it does not use the command's context.
+func (c *winrmConn) deleteShell(shellID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), sshHandshakeTimeout)
+	defer cancel()
+	c.post(ctx, wsmanActionDelete, shellID, nil, "")
+}
+
+// command starts a command in a shell and returns its ID
+func (c *winrmConn) command(ctx context.Context, shellID, cmd string) (string, error) {
+	var escaped bytes.Buffer
+	xml.EscapeText(&escaped, []byte(cmd))
+
+	options := []wsmanOption{{"WINRS_CONSOLEMODE_STDIN", "TRUE"}, {"WINRS_SKIP_CMD_SHELL", "FALSE"}}
+	body := `<rsp:CommandLine><rsp:Command>` + escaped.String() + `</rsp:Command></rsp:CommandLine>`
+	resp, err := c.post(ctx, wsmanActionCommand, shellID, options, body)
+	if err != nil {
+		return "", fmt.Errorf("failed to start command: %w", err)
+	}
+	if resp.Body.CommandResponse.CommandID == "" {
+		return "", errors.New("failed to start command: no command ID in response")
+	}
+	return resp.Body.CommandResponse.CommandID, nil
+}
+
+// receive reads the output a command wrote since the last receive. done is
+// set once the command has exited.
+func (c *winrmConn) receive(ctx context.Context, shellID, commandID string, stdout, stderr io.Writer) (exitCode int, done bool, err error) {
+	body := `<rsp:Receive><rsp:DesiredStream CommandId="` + commandID + `">stdout stderr</rsp:DesiredStream></rsp:Receive>`
+	resp, err := c.post(ctx, wsmanActionReceive, shellID, nil, body)
+	if err != nil {
+		return 0, false, err
+	}
+
+	for _, stream := range resp.Body.ReceiveResponse.Streams {
+		data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(stream.Data))
+		if err != nil {
+			return 0, false, fmt.Errorf("invalid %s data: %w", stream.Name, err)
+		}
+		if stream.Name == "stderr" {
+			stderr.Write(data)
+		} else {
+			stdout.Write(data)
+		}
+	}
+
+	state := resp.Body.ReceiveResponse.CommandState
+	if state.State != wsmanStateDone {
+		return 0, false, nil
+	}
+	return state.ExitCode, true, nil
+}
+
+// signal terminates a command, which releases it on the server
+func (c *winrmConn) signal(shellID, commandID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), sshHandshakeTimeout)
+	defer cancel()
+	body := `<rsp:Signal CommandId="` + commandID + `"><rsp:Code>` + wsmanSignalTerm + `</rsp:Code></rsp:Signal>`
+	c.post(ctx, wsmanActionSignal, shellID, nil, body)
+}
+
+// wsmanOption is a shell option sent in the OptionSet header
+type wsmanOption struct {
+	name  string
+	value string
+}
+
+// wsmanResponse holds the parts of the responses Vigilon reads
+type wsmanResponse struct {
+	Body struct {
+		Shell struct {
+			ShellID string `xml:"ShellId"`
+		} `xml:"Shell"`
+		ResourceCreated struct {
+			Selectors []struct {
+				Name  string `xml:"Name,attr"`
+				Value string `xml:",chardata"`
+			} `xml:"ReferenceParameters>SelectorSet>Selector"`
+		} `xml:"ResourceCreated"`
+		CommandResponse struct {
+			CommandID string `xml:"CommandId"`
+		} `xml:"CommandResponse"`
+		ReceiveResponse struct {
+			Streams []struct {
+				Name string `xml:"Name,attr"`
+				Data string `xml:",chardata"`
+			} `xml:"Stream"`
+			CommandState struct {
+				State    string `xml:"State,attr"`
+				ExitCode int    `xml:"ExitCode"`
+			} `xml:"CommandState"`
+		} `xml:"ReceiveResponse"`
+		Fault *struct {
+			Subcode string `xml:"Code>Subcode>Value"`
+			Reason  string `xml:"Reason>Text"`
+			Detail  struct {
+				Code    string `xml:"Code,attr"`
+				Message string `xml:"Message"`
+			} `xml:"Detail>WSManFault"`
+		} `xml:"Fault"`
+	} `xml:"Body"`
+}
+
+// envelope builds the SOAP request of an action on the cmd shell
+func (c *winrmConn) envelope(action, shellID string, options []wsmanOption, body string) []byte {
+	var b strings.Builder
+	b.WriteString(`<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"` +
+		` xmlns:a="http://schemas.xmlsoap.org/ws/2004/08/addressing"` +
+		` xmlns:w="http://schemas.dmtf.org/wbem/wsman/1/wsman.xsd"` +
+		` xmlns:p="http://schemas.microsoft.com/wbem/wsman/1/wsman.xsd"` +
+		` xmlns:rsp="http://schemas.microsoft.com/wbem/wsman/1/windows/shell"><s:Header>`)
+	fmt.Fprintf(&b, `<a:To>%s</a:To>`, c.url)
+	b.WriteString(`<a:ReplyTo><a:Address s:mustUnderstand="true">http://schemas.xmlsoap.org/ws/2004/08/addressing/role/anonymous</a:Address></a:ReplyTo>`)
+	fmt.Fprintf(&b, `<w:MaxEnvelopeSize s:mustUnderstand="true">%d</w:MaxEnvelopeSize>`, winrmMaxEnvelope)
+	fmt.Fprintf(&b, `<a:MessageID>uuid:%s</a:MessageID>`, newUUID())
+	b.WriteString(`<w:Locale xml:lang="en-US" s:mustUnderstand="false"/><p:DataLocale xml:lang="en-US" s:mustUnderstand="false"/>`)
+	fmt.Fprintf(&b, `<w:OperationTimeout>PT%dS</w:OperationTimeout>`, int(winrmOperationTimeout.Seconds()))
+	fmt.Fprintf(&b, `<w:ResourceURI s:mustUnderstand="true">%s</w:ResourceURI>`, wsmanShellURI)
+	fmt.Fprintf(&b, `<a:Action s:mustUnderstand="true">%s</a:Action>`, action)
+	if shellID != "" {
+		fmt.Fprintf(&b, `<w:SelectorSet><w:Selector Name="ShellId">%s</w:Selector></w:SelectorSet>`, shellID)
+	}
+	if len(options) > 0 {
+		b.WriteString(`<w:OptionSet>`)
+		for _, option := range options {
+			fmt.Fprintf(&b, `<w:Option Name="%s">%s</w:Option>`, option.name, option.value)
+		}
+		b.WriteString(`</w:OptionSet>`)
+	}
+	b.WriteString(`</s:Header><s:Body>` + body + `</s:Body></s:Envelope>`)
+	return []byte(b.String())
+}
+
+var errWinRMUnauthorized = errors.New("WinRM authentication failed")
+
+const winrmUnencryptedHint = "NTLM over plain HTTP needs AllowUnencrypted on the service, or HTTPS"
+
+// post sends a request and parses the response. A fault is returned as a
+// *wsmanFault.
+func (c *winrmConn) post(ctx context.Context, action, shellID string, options []wsmanOption, body string) (*wsmanResponse, error) {
+	envelope := c.envelope(action, shellID, options, body)
+
+	resp, data, err := c.exchange(ctx, envelope)
+	if errors.Is(err, errWinRMUnauthorized) && c.auth == models.WinRMAuthNTLM {
+		// The connection may have been closed halfway through the
+		// handshake; a rejected request was not run, so authenticate again
+		c.client.CloseIdleConnections()
+		resp, data, err = c.exchange(ctx, envelope)
+	}
+	if err != nil {
+		if errors.Is(err, errWinRMUnauthorized) && c.plainNTLM() {
+			return nil, fmt.Errorf("%w (%s)", err, winrmUnencryptedHint)
+		}
+		return nil, err
+	}
+
+	parsed := &wsmanResponse{}
+	if err := xml.Unmarshal(data, parsed); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, c.statusError(resp)
+		}
+		return nil, fmt.Errorf("invalid WinRM response: %w", err)
+	}
+	if fault := parsed.Body.Fault; fault != nil {
+		reason := strings.TrimSpace(fault.Reason)
+		if reason == "" {
+			reason = strings.TrimSpace(fault.Detail.Message)
+		}
+		return nil, &wsmanFault{Code: fault.Detail.Code, Subcode: fault.Subcode, Reason: reason}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.statusError(resp)
+	}
+	return parsed, nil
+}
+
+// statusError describes a response that is neither OK nor a fault. Over
+// plain HTTP that is how the service refuses unencrypted NTLM requests.
+func (c *winrmConn) statusError(resp *http.Response) error {
+	if c.plainNTLM() {
+		return fmt.Errorf("WinRM returned %s (%s)", resp.Status, winrmUnencryptedHint)
+	}
+	return fmt.Errorf("WinRM returned %s", resp.Status)
+}
+
+// exchange sends a request with the configured authentication and reads the
+// response
+func (c *winrmConn) exchange(ctx context.Context, envelope []byte) (*http.Response, []byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var resp *http.Response
+	var err error
+	switch c.auth {
+	case models.WinRMAuthBasic:
+		resp, err = c.send(ctx, envelope, "Basic "+base64.StdEncoding.EncodeToString([]byte(c.user+":"+c.password)))
+	case models.WinRMAuthNTLM:
+		// An authenticated connection needs no header; otherwise the
+		// service answers 401 and the handshake runs on this connection
+		resp, err = c.send(ctx, envelope, "")
+		if err == nil && resp.StatusCode == http.StatusUnauthorized {
+			discard(resp)
+			resp, err = c.ntlmHandshake(ctx, envelope, authScheme(resp))
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown WinRM auth %q", c.auth)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, winrmMaxResponse))
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, nil, errWinRMUnauthorized
+	}
+	return resp, data, nil
+}
+
+// plainNTLM reports whether NTLM runs over plain HTTP
+func (c *winrmConn) plainNTLM() bool {
+	return c.auth == models.WinRMAuthNTLM && strings.HasPrefix(c.url, "http:")
+}
+
+// ntlmHandshake authenticates the connection and sends the request with the
+// final handshake message
+func (c *winrmConn) ntlmHandshake(ctx context.Context, envelope []byte, scheme string) (*http.Response, error) {
+	resp, err := c.send(ctx, envelope, scheme+" "+base64.StdEncoding.EncodeToString(ntlmNegotiateMessage()))
+	if err != nil {
+		return nil, err
+	}
+	token := ""
+	for _, header := range resp.Header.Values("WWW-Authenticate") {
+		if value, ok := strings.CutPrefix(header, scheme+" "); ok {
+			token = strings.TrimSpace(value)
+		}
+	}
+	discard(resp)
+	if resp.StatusCode != http.StatusUnauthorized || token == "" {
+		return nil, errWinRMUnauthorized
+	}
+
+	msg, err := base64.StdEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NTLM challenge: %w", err)
+	}
+	challenge, err := parseNTLMChallenge(msg)
+	if err != nil {
+		return nil, err
+	}
+	authenticate, err := ntlmAuthenticateMessage(challenge, c.user, c.password)
+	if err != nil {
+		return nil, err
+	}
+	return c.send(ctx, envelope, scheme+" "+base64.StdEncoding.EncodeToString(authenticate))
+}
+
+func (c *winrmConn) send(ctx context.Context, envelope []byte, authorization string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(envelope))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/soap+xml;charset=UTF-8")
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	return c.client.Do(req)
+}
+
+// authScheme returns the scheme a 401 response offers NTLM under. WinRM
+// accepts raw NTLM tokens as Negotiate, which it offers by default.
+func authScheme(resp *http.Response) string {
+	for _, header := range resp.Header.Values("WWW-Authenticate") {
+		if strings.HasPrefix(header, "Negotiate") {
+			return "Negotiate"
+		}
+	}
+	return "NTLM"
+}
+
+// discard reads and closes a response body, so the connection can be reused
+func discard(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, winrmMaxResponse))
+	resp.Body.Close()
+}
+
+// newUUID returns a random UUID for WS-Addressing message IDs
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,185 @@
+package monitor
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/md4"
+)
+
+// NTLMv2 as used by WinRM (MS-NLMP). Only authentication is implemented, so
+// unencrypted HTTP needs AllowUnencrypted on the service; HTTPS does not.
+// Requests the service refuses for it are reported with winrmUnencryptedHint.
+
+const (
+	ntlmNegotiateUnicode         = 0x00000001
+	ntlmRequestTarget            = 0x00000004
+	ntlmNegotiateNTLM            = 0x00000200
+	ntlmNegotiateAlwaysSign      = 0x00008000
+	ntlmNegotiateExtendedSession = 0x00080000
+	ntlmNegotiateTargetInfo      = 0x00800000
+	ntlmNegotiate128             = 0x20000000
+	ntlmNegotiate56              = 0x80000000
+
+	ntlmFlags = ntlmNegotiateUnicode | ntlmRequestTarget | ntlmNegotiateNTLM | ntlmNegotiateAlwaysSign |
+		ntlmNegotiateExtendedSession | ntlmNegotiateTargetInfo | ntlmNegotiate128 | ntlmNegotiate56
+
+	ntlmAvEOL       = 0 // Last pair of the target info
+	ntlmAvTimestamp = 7 // Server time as a FILETIME
+)
+
+var ntlmSignature = []byte("NTLMSSP\x00")
+
+// ntlmChallenge is the part of the server's CHALLENGE_MESSAGE the response
+// is computed from
+type ntlmChallenge struct {
+	flags      uint32
+	challenge  []byte
+	targetInfo []byte
+}
+
+// ntlmNegotiateMessage returns the NEGOTIATE_MESSAGE that starts a handshake
+func ntlmNegotiateMessage() []byte {
+	msg := make([]byte, 32)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 1)
+	binary.LittleEndian.PutUint32(msg[12:], ntlmFlags)
+	return msg
+}
+
+// parseNTLMChallenge parses the CHALLENGE_MESSAGE of the server
+func parseNTLMChallenge(msg []byte) (*ntlmChallenge, error) {
+	if len(msg) < 48 || !bytes.Equal(msg[:8], ntlmSignature) || binary.LittleEndian.Uint32(msg[8:]) != 2 {
+		return nil, errors.New("invalid NTLM challenge")
+	}
+	c := &ntlmChallenge{
+		flags:     binary.LittleEndian.Uint32(msg[20:]),
+		challenge: msg[24:32],
+	}
+	length := int(binary.LittleEndian.Uint16(msg[40:]))
+	offset := int(binary.LittleEndian.Uint32(msg[44:]))
+	if offset+length > len(msg) {
+		return nil, errors.New("invalid NTLM target info")
+	}
+	c.targetInfo = msg[offset : offset+length]
+	return c, nil
+}
+
+// timestamp returns the server time from the target info, if it sent one
+func (c *ntlmChallenge) timestamp() []byte {
+	info := c.targetInfo
+	for len(info) >= 4 {
+		id := binary.LittleEndian.Uint16(info)
+		length := int(binary.LittleEndian.Uint16(info[2:]))
+		if id == ntlmAvEOL || 4+length > len(info) {
+			break
+		}
+		if id == ntlmAvTimestamp && length == 8 {
+			return info[4:12]
+		}
+		info = info[4+length:]
+	}
+	return nil
+}
+
+// ntlmAuthenticateMessage returns the AUTHENTICATE_MESSAGE answering a
+// challenge with an NTLMv2 response. user may be "DOMAIN\user"; a UPN
+// ("user@domain") is sent as the user name with an empty domain.
+func ntlmAuthenticateMessage(c *ntlmChallenge, user, password string) ([]byte, error) {
+	domain := ""
+	if d, u, ok := strings.Cut(user, `\`); ok {
+		domain, user = d, u
+	}
+
+	clientChallenge := make([]byte, 8)
+	if _, err := rand.Read(clientChallenge); err != nil {
+		return nil, err
+	}
+
+	ntowf := ntowfv2(user, domain, password)
+
+	// With a server timestamp the LM response is empty (MS-NLMP 3.1.5.1.2)
+	timestamp := c.timestamp()
+	lmResponse := make([]byte, 24)
+	if timestamp == nil {
+		timestamp = ntlmFiletime(time.Now())
+		lmResponse = lmv2Response(ntowf, c.challenge, clientChallenge)
+	}
+	ntResponse, _ := ntlmv2Response(ntowf, c.challenge, clientChallenge, timestamp, c.targetInfo)
+
+	// The header is followed by the payload the fields point into
+	payloads := [][]byte{lmResponse, ntResponse, utf16le(domain), utf16le(user), nil, nil}
+	msg := make([]byte, 64)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 3)
+	for i, payload := range payloads {
+		field := msg[12+8*i:]
+		binary.LittleEndian.PutUint16(field, uint16(len(payload)))
+		binary.LittleEndian.PutUint16(field[2:], uint16(len(payload)))
+		binary.LittleEndian.PutUint32(field[4:], uint32(len(msg)))
+		msg = append(msg, payload...)
+	}
+	binary.LittleEndian.PutUint32(msg[60:], c.flags&ntlmFlags|ntlmNegotiateUnicode)
+	return msg, nil
+}
+
+// ntowfv2 returns the NTLMv2 hash of a password, the key of the responses
+// (MS-NLMP 3.3.2)
+func ntowfv2(user, domain, password string) []byte {
+	hash := md4.New()
+	hash.Write(utf16le(password))
+	return hmacMD5(hash.Sum(nil), utf16le(strings.ToUpper(user)+domain))
+}
+
+// lmv2Response returns the LMv2 response to a server challenge
+func lmv2Response(ntowf, serverChallenge, clientChallenge []byte) []byte {
+	return append(hmacMD5(ntowf, serverChallenge, clientChallenge), clientChallenge...)
+}
+
+// ntlmv2Response returns the NTLMv2 response to a server challenge, the
+// NTProofStr followed by the signed client data, and the session base key.
+// timestamp is a FILETIME and targetInfo the AV pairs sent by the server.
+func ntlmv2Response(ntowf, serverChallenge, clientChallenge, timestamp, targetInfo []byte) (response, sessionKey []byte) {
+	var temp bytes.Buffer
+	temp.Write([]byte{1, 1, 0, 0, 0, 0, 0, 0})
+	temp.Write(timestamp)
+	temp.Write(clientChallenge)
+	temp.Write([]byte{0, 0, 0, 0})
+	temp.Write(targetInfo)
+	temp.Write([]byte{0, 0, 0, 0})
+
+	proof := hmacMD5(ntowf, serverChallenge, temp.Bytes())
+	return append(proof, temp.Bytes()...), hmacMD5(ntowf, proof)
+}
+
+func hmacMD5(key []byte, data ...[]byte) []byte {
+	mac := hmac.New(md5.New, key)
+	for _, d := range data {
+		mac.Write(d)
+	}
+	return mac.Sum(nil)
+}
+
+// utf16le encodes a string as UTF-16LE, the NTLM string encoding
+func utf16le(s string) []byte {
+	codes := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(codes))
+	for i, code := range codes {
+		binary.LittleEndian.PutUint16(b[2*i:], code)
+	}
+	return b
+}
+
+// ntlmFiletime returns a time as a FILETIME: 100ns intervals since 1601
+func ntlmFiletime(t time.Time) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(t.UnixNano()/100+116444736000000000))
+	return b
+}
@@ -0,0 +1,398 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/harungecit/vigilon/internal/models"
+	"golang.org/x/crypto/md4"
+)
+
+type secretMap map[string]string
+
+func (m secretMap) Secret(name string) ([]byte, error) {
+	value, ok := m[name]
+	if !ok {
+		return nil, fmt.Errorf("secret %q not found", name)
+	}
+	return []byte(value), nil
+}
+
+// winrmStub is a WinRM service that authenticates with NTLMv2 and answers
+// commands from a table
+type winrmStub struct {
+	user, domain, password string
+	commands               map[string]stubResult // key: command line
+
+	mu         sync.Mutex
+	authorized map[string]bool   // key: client address
+	challenged map[string][]byte // key: client address, handshake in progress
+	challenges uint64
+	handshakes int
+	timeouts   int  // Receives to answer with a timeout fault first
+	closes     int  // Challenges to close the connection after
+	encrypted  bool // Refuse unencrypted requests like a default service
+}
+
+type stubResult struct {
+	stdout, stderr string
+	exitCode       int
+}
+
+var (
+	stubCommand   = regexp.MustCompile(`<rsp:Command>(.*)</rsp:Command>`)
+	stubCommandID = regexp.MustCompile(`CommandId="([^"]+)"`)
+)
+
+func (s *winrmStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	s.mu.Lock()
+	authorized := s.authorized[r.RemoteAddr]
+	s.mu.Unlock()
+	if (!authorized || r.Header.Get("Authorization") != "") && !s.authenticate(w, r) {
+		return
+	}
+	if s.encrypted {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	envelope := string(body)
+	switch {
+	case strings.Contains(envelope, wsmanActionCreate):
+		s.reply(w, `<rsp:Shell><rsp:ShellId>shell-1</rsp:ShellId></rsp:Shell>`)
+	case strings.Contains(envelope, wsmanActionCommand):
+		// The command ID carries the command, so Receive knows what to answer
+		cmd := html.UnescapeString(stubCommand.FindStringSubmatch(envelope)[1])
+		id := base64.RawURLEncoding.EncodeToString([]byte(cmd))
+		s.reply(w, `<rsp:CommandResponse><rsp:CommandId>`+id+`</rsp:CommandId></rsp:CommandResponse>`)
+	case strings.Contains(envelope, wsmanActionReceive):
+		s.mu.Lock()
+		timeout := s.timeouts > 0
+		s.timeouts--
+		s.mu.Unlock()
+		if timeout {
+			w.WriteHeader(http.StatusInternalServerError)
+			s.reply(w, `<s:Fault><s:Code><s:Value>s:Receiver</s:Value><s:Subcode><s:Value>w:TimedOut</s:Value></s:Subcode></s:Code>`+
+				`<s:Reason><s:Text xml:lang="en-US">The WS-Management service cannot complete the operation within the time specified in OperationTimeout.</s:Text></s:Reason>`+
+				`<s:Detail><f:WSManFault xmlns:f="http://schemas.microsoft.com/wbem/wsman/1/wsmanfault" Code="2150858793"/></s:Detail></s:Fault>`)
+			return
+		}
+		id := stubCommandID.FindStringSubmatch(envelope)[1]
+		cmd, _ := base64.RawURLEncoding.DecodeString(id)
+		result, ok := s.commands[string(cmd)]
+		if !ok {
+			result = stubResult{stderr: "'" + string(cmd) + "' is not recognized", exitCode: 1}
+		}
+		s.reply(w, fmt.Sprintf(`<rsp:ReceiveResponse>`+
+			`<rsp:Stream Name="stdout" CommandId="%[1]s">%[2]s</rsp:Stream>`+
+			`<rsp:Stream Name="stderr" CommandId="%[1]s">%[3]s</rsp:Stream>`+
+			`<rsp:CommandState CommandId="%[1]s" State="%[4]s"><rsp:ExitCode>%[5]d</rsp:ExitCode></rsp:CommandState>`+
+			`</rsp:ReceiveResponse>`, id,
+			base64.StdEncoding.EncodeToString([]byte(result.stdout)),
+			base64.StdEncoding.EncodeToString([]byte(result.stderr)),
+			wsmanStateDone, result.exitCode))
+	default:
+		s.reply(w, "")
+	}
+}
+
+// authenticate runs the server side of the NTLM handshake. It returns true
+// once the client proved it knows the password. Like WinRM, it expects the
+// handshake to continue with the next request on the connection, and each
+// handshake gets a challenge of its own.
+func (s *winrmStub) authenticate(w http.ResponseWriter, r *http.Request) bool {
+	s.mu.Lock()
+	serverChallenge := s.challenged[r.RemoteAddr]
+	delete(s.challenged, r.RemoteAddr)
+	delete(s.authorized, r.RemoteAddr)
+	s.mu.Unlock()
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Negotiate ")
+	if !ok {
+		w.Header().Add("WWW-Authenticate", "Negotiate")
+		w.WriteHeader(http.StatusUnauthorized)
+		return false
+	}
+	msg, _ := base64.StdEncoding.DecodeString(token)
+	if len(msg) < 12 {
+		w.WriteHeader(http.StatusUnauthorized)
+		return false
+	}
+
+	switch binary.LittleEndian.Uint32(msg[8:]) {
+	case 1:
+		// Target info with a timestamp, then the end of the list
+		targetInfo := []byte{ntlmAvTimestamp, 0, 8, 0, 1, 2, 3, 4, 5, 6, 7, 8, ntlmAvEOL, 0, 0, 0}
+		challenge := make([]byte, 48)
+		copy(challenge, ntlmSignature)
+		binary.LittleEndian.PutUint32(challenge[8:], 2)
+		binary.LittleEndian.PutUint32(challenge[20:], ntlmFlags)
+		s.mu.Lock()
+		s.challenges++
+		serverChallenge = binary.LittleEndian.AppendUint64(nil, s.challenges)
+		s.challenged[r.RemoteAddr] = serverChallenge
+		if s.closes > 0 {
+			s.closes--
+			w.Header().Set("Connection", "close")
+		}
+		s.mu.Unlock()
+		copy(challenge[24:], serverChallenge)
+		binary.LittleEndian.PutUint16(challenge[40:], uint16(len(targetInfo)))
+		binary.LittleEndian.PutUint16(challenge[42:], uint16(len(targetInfo)))
+		binary.LittleEndian.PutUint32(challenge[44:], 48)
+		challenge = append(challenge, targetInfo...)
+		w.Header().Add("WWW-Authenticate", "Negotiate "+base64.StdEncoding.EncodeToString(challenge))
+		w.WriteHeader(http.StatusUnauthorized)
+		return false
+
+	case 3:
+		field := func(i int) []byte {
+			length := binary.LittleEndian.Uint16(msg[12+8*i:])
+			offset := binary.LittleEndian.Uint32(msg[16+8*i:])
+			return msg[offset : offset+uint32(length)]
+		}
+		ntResponse := field(1)
+		hash := md4.New()
+		hash.Write(utf16le(s.password))
+		ntowf := hmacMD5(hash.Sum(nil), utf16le(strings.ToUpper(s.user)+s.domain))
+		if serverChallenge == nil || len(ntResponse) < 16 || !bytes.Equal(hmacMD5(ntowf, serverChallenge, ntResponse[16:]), ntResponse[:16]) ||
+			!bytes.Equal(field(2), utf16le(s.domain)) || !bytes.Equal(field(3), utf16le(s.user)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return false
+		}
+		s.mu.Lock()
+		s.authorized[r.RemoteAddr] = true
+		s.handshakes++
+		s.mu.Unlock()
+		return true
+	}
+	w.WriteHeader(http.StatusUnauthorized)
+	return false
+}
+
+func (s *winrmStub) reply(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Type", "application/soap+xml;charset=UTF-8")
+	fmt.Fprintf(w, `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope" xmlns:rsp="http://schemas.microsoft.com/wbem/wsman/1/windows/shell"><s:Header/><s:Body>%s</s:Body></s:Envelope>`, body)
+}
+
+// startWinRMStub serves a stub and returns a server pointing at it
+func startWinRMStub(t *testing.T, stub *winrmStub, passwordSecret string) *models.Server {
+	t.Helper()
+	stub.authorized = make(map[string]bool)
+	stub.challenged = make(map[string][]byte)
+	ts := httptest.NewServer(stub)
+	t.Cleanup(ts.Close)
+
+	host, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	return &models.Server{
+		ID:        1,
+		OS:        "windows",
+		IPAddress: host,
+		Port:      portNum,
+		Transport: models.TransportWinRM,
+		WinRM:     &models.WinRMConfig{User: `CORP\monitor`, PasswordSecret: passwordSecret},
+	}
+}
+
+func TestWinRMCheckService(t *testing.T) {
+	stub := &winrmStub{
+		user: "monitor", domain: "CORP", password: "s3cret",
+		timeouts: 1,
+		commands: map[string]stubResult{
+			`powershell -Command "Get-Service -Name Spooler | Select-Object -ExpandProperty Status"`:                                    {stdout: "Running\r\n"},
+			`powershell -Command "Get-CimInstance Win32_Service -Filter \"Name='Spooler'\" | Select-Object -ExpandProperty ProcessId"`:  {stdout: "4242\r\n"},
+			`powershell -Command "Get-Process -Id 4242 | Select-Object @{N='WS';E={$_.WS/1KB}},CPU | ConvertTo-Csv -NoTypeInformation"`: {stdout: "\"WS\",\"CPU\"\r\n\"20480\",\"12.5\"\r\n"},
+		},
+	}
+	server := startWinRMStub(t, stub, "win-password")
+	pool := NewWinRMPool(secretMap{"win-password": "s3cret"})
+	defer pool.Close()
+
+	status, info, err := NewRemoteChecker(pool, server).CheckService(context.Background(), "Spooler")
+	if err != nil {
+		t.Fatalf("CheckService: %v", err)
+	}
+	if status != models.StatusRunning {
+		t.Errorf("status = %s, want running", status)
+	}
+	checkInfo(t, info, &ServiceInfo{PID: 4242, Memory: 20480, CPU: 12.5})
+
+	// One handshake for the three commands: the connection stays authenticated
+	if stub.handshakes != 1 {
+		t.Errorf("%d NTLM handshakes, want 1", stub.handshakes)
+	}
+}
+
+func TestWinRMExitStatus(t *testing.T) {
+	stub := &winrmStub{user: "monitor", domain: "CORP", password: "s3cret"}
+	server := startWinRMStub(t, stub, "win-password")
+	pool := NewWinRMPool(secretMap{"win-password": "s3cret"})
+	defer pool.Close()
+
+	output, err := pool.CombinedOutput(context.Background(), server, "missing-command")
+	var exitErr *WinRMExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 1 {
+		t.Fatalf("error = %v, want exit status 1", err)
+	}
+	if !strings.Contains(output, "is not recognized") {
+		t.Errorf("combined output %q does not contain stderr", output)
+	}
+}
+
+func TestWinRMConcurrentCommands(t *testing.T) {
+	stub := &winrmStub{user: "monitor", domain: "CORP", password: "s3cret", commands: map[string]stubResult{"hostname": {stdout: "WIN-1\r\n"}}}
+	server := startWinRMStub(t, stub, "win-password")
+	pool := NewWinRMPool(secretMap{"win-password": "s3cret"})
+	defer pool.Close()
+
+	// The checks of a server share its connection and its handshake
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := pool.Output(context.Background(), server, "hostname")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Output: %v", err)
+		}
+	}
+	if stub.handshakes != 1 {
+		t.Errorf("%d NTLM handshakes, want 1", stub.handshakes)
+	}
+}
+
+func TestWinRMHandshakeConnectionClosed(t *testing.T) {
+	// The connection closes after the challenge, so the answer arrives on
+	// a new one that is not part of the handshake
+	stub := &winrmStub{user: "monitor", domain: "CORP", password: "s3cret", closes: 1, commands: map[string]stubResult{"hostname": {stdout: "WIN-1\r\n"}}}
+	server := startWinRMStub(t, stub, "win-password")
+	pool := NewWinRMPool(secretMap{"win-password": "s3cret"})
+	defer pool.Close()
+
+	output, err := pool.Output(context.Background(), server, "hostname")
+	if err != nil {
+		t.Fatalf("Output: %v", err)
+	}
+	if output != "WIN-1\r\n" {
+		t.Errorf("output = %q, want WIN-1", output)
+	}
+}
+
+func TestWinRMAuthentication(t *testing.T) {
+	stub := &winrmStub{user: "monitor", domain: "CORP", password: "s3cret"}
+	server := startWinRMStub(t, stub, "win-password")
+
+	tests := []struct {
+		name      string
+		secrets   secretMap
+		encrypted bool
+		wantErr   string
+	}{
+		{"wrong password", secretMap{"win-password": "wrong"}, false, "WinRM authentication failed"},
+		{"missing secret", secretMap{}, false, "failed to load WinRM password"},
+		{"unencrypted refused", secretMap{"win-password": "s3cret"}, true, "400 Bad Request (NTLM over plain HTTP needs AllowUnencrypted"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub.encrypted = tt.encrypted
+			pool := NewWinRMPool(tt.secrets)
+			defer pool.Close()
+			_, err := pool.Output(context.Background(), server, "hostname")
+			checkError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestNTLMv2KnownAnswers(t *testing.T) {
+	// NTLMv2 authentication example of MS-NLMP 4.2.4
+	var (
+		serverChallenge = unhex(t, "0123456789abcdef")
+		clientChallenge = unhex(t, "aaaaaaaaaaaaaaaa")
+		timestamp       = make([]byte, 8)
+		targetInfo      = unhex(t, "02000c0044006f006d00610069006e00"+ // NetBIOS domain "Domain"
+			"01000c00530065007200760065007200"+ // NetBIOS computer "Server"
+			"00000000") // EOL
+	)
+
+	ntowf := ntowfv2("User", "Domain", "Password")
+	checkHex(t, "NTOWFv2", ntowf, "0c868a403bfd7a93a3001ef22ef02e3f")
+	checkHex(t, "LMv2 response", lmv2Response(ntowf, serverChallenge, clientChallenge),
+		"86c35097ac9cec102554764a57cccc19aaaaaaaaaaaaaaaa")
+
+	response, sessionKey := ntlmv2Response(ntowf, serverChallenge, clientChallenge, timestamp, targetInfo)
+	checkHex(t, "NTProofStr", response[:16], "68cd0ab851e51c96aabc927bebef6a1c")
+	checkHex(t, "session base key", sessionKey, "8de40ccadbc14a82f15cb0ad0de95ca3")
+	if want := "0101000000000000" + "0000000000000000" + "aaaaaaaaaaaaaaaa" + "00000000" + hex.EncodeToString(targetInfo) + "00000000"; hex.EncodeToString(response[16:]) != want {
+		t.Errorf("client data = %x, want %s", response[16:], want)
+	}
+}
+
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func checkHex(t *testing.T, name string, got []byte, want string) {
+	t.Helper()
+	if hex.EncodeToString(got) != want {
+		t.Errorf("%s = %x, want %s", name, got, want)
+	}
+}
+
+func checkError(t *testing.T, err error, want string) {
+	t.Helper()
+	switch {
+	case want == "" && err != nil:
+		t.Errorf("unexpected error: %v", err)
+	case want != "" && err == nil:
+		t.Errorf("error = nil, want %q", want)
+	case want != "" && !strings.Contains(err.Error(), want):
+		t.Errorf("error = %q, want %q", err, want)
+	}
+}
+
+func checkInfo(t *testing.T, got, want *ServiceInfo) {
+	t.Helper()
+	if want == nil {
+		if got != nil {
+			t.Errorf("info = %+v, want nil", got)
+		}
+		return
+	}
+	if got == nil {
+		t.Fatalf("info = nil, want %+v", want)
+	}
+	if got.PID != want.PID || got.Memory != want.Memory || got.Uptime != want.Uptime || math.Abs(got.CPU-want.CPU) > 1e-9 {
+		t.Errorf("info = %+v, want %+v", got, want)
+	}
+}
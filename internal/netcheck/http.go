@@ -0,0 +1,201 @@
+package netcheck
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/harungecit/vigilon/internal/models"
+)
+
+// maxBodySize bounds how much of a response body is read for the assertions
+const maxBodySize = 1 << 20
+
+// maxRedirects is how many redirects a check follows at most
+const maxRedirects = 10
+
+// HTTP requests an endpoint and checks the response against the assertions
+// of cfg. A response slower than DegradedAfter that passes every assertion
+// marks the endpoint degraded.
+func HTTP(ctx context.Context, cfg *models.HTTPCheck) Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout(cfg.Timeout))
+	defer cancel()
+
+	req, err := newHTTPRequest(ctx, cfg)
+	if err != nil {
+		return Result{Status: models.StatusUnknown, Err: err}
+	}
+
+	// A fresh connection per check, so the response time includes connecting
+	// and nothing is kept open between checks
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy:             http.ProxyFromEnvironment,
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: cfg.Insecure},
+			DisableKeepAlives: true,
+			ForceAttemptHTTP2: true,
+		},
+		CheckRedirect: redirectPolicy(cfg.Redirects),
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return Result{Status: models.StatusFailed, Elapsed: time.Since(start), Err: err}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	elapsed := time.Since(start)
+	if err != nil {
+		return Result{Status: models.StatusFailed, Elapsed: elapsed, Err: fmt.Errorf("failed to read response: %w", err)}
+	}
+
+	if err := checkResponse(cfg, resp, body); err != nil {
+		return Result{Status: models.StatusFailed, Elapsed: elapsed, Err: err}
+	}
+
+	if limit := time.Duration(cfg.DegradedAfter) * time.Millisecond; limit > 0 && elapsed > limit {
+		return Result{
+			Status:  models.StatusDegraded,
+			Elapsed: elapsed,
+			Err:     fmt.Errorf("response took %dms, over the %dms threshold", elapsed.Milliseconds(), cfg.DegradedAfter),
+		}
+	}
+	return Result{Status: models.StatusRunning, Elapsed: elapsed}
+}
+
+// ValidateHTTP checks the settings of an http check
+func ValidateHTTP(cfg *models.HTTPCheck) error {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an http:// or https:// URL")
+	}
+	if _, err := newHTTPRequest(context.Background(), cfg); err != nil {
+		return err
+	}
+	for name, value := range cfg.Headers {
+		if name == "" || strings.ContainsAny(name, " \t\r\n:") || strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("invalid header %q", name)
+		}
+	}
+	for _, code := range cfg.ExpectedStatus {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("invalid expected status %d", code)
+		}
+	}
+	if cfg.BodyRegex != "" {
+		if _, err := regexp.Compile(cfg.BodyRegex); err != nil {
+			return fmt.Errorf("invalid body_regex: %w", err)
+		}
+	}
+	if cfg.JSONPath != "" {
+		if _, err := parseJSONPath(cfg.JSONPath); err != nil {
+			return err
+		}
+	} else if cfg.JSONValue != "" {
+		return fmt.Errorf("json_value needs a json_path")
+	}
+	if !cfg.Redirects.Valid() {
+		return fmt.Errorf("unknown redirect policy %q", cfg.Redirects)
+	}
+	if cfg.Timeout < 0 || cfg.DegradedAfter < 0 {
+		return fmt.Errorf("timeout and degraded_after_ms must not be negative")
+	}
+	return nil
+}
+
+// newHTTPRequest builds the request of a check
+func newHTTPRequest(ctx context.Context, cfg *models.HTTPCheck) (*http.Request, error) {
+	method := cfg.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	var body io.Reader
+	if cfg.Body != "" {
+		body = strings.NewReader(cfg.Body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(method), cfg.URL, body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	req.Header.Set("User-Agent", "Vigilon")
+	for name, value := range cfg.Headers {
+		if strings.EqualFold(name, "Host") {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+	return req, nil
+}
+
+// redirectPolicy returns the redirect handling of a check. A redirect that
+// is not followed is the response the assertions are checked against.
+func redirectPolicy(policy models.RedirectPolicy) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		switch policy {
+		case models.RedirectNone:
+			return http.ErrUseLastResponse
+		case models.RedirectSameHost:
+			if req.URL.Host != via[0].URL.Host {
+				return http.ErrUseLastResponse
+			}
+		}
+		if len(via) >= maxRedirects {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+}
+
+// checkResponse checks a response against the assertions of a check
+func checkResponse(cfg *models.HTTPCheck, resp *http.Response, body []byte) error {
+	if !expectedStatus(cfg.ExpectedStatus, resp.StatusCode) {
+		if location := resp.Header.Get("Location"); location != "" {
+			return fmt.Errorf("unexpected status %d (redirect to %s)", resp.StatusCode, location)
+		}
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	if cfg.BodyContains != "" && !strings.Contains(string(body), cfg.BodyContains) {
+		return fmt.Errorf("response does not contain %q", cfg.BodyContains)
+	}
+
+	if cfg.BodyRegex != "" {
+		re, err := regexp.Compile(cfg.BodyRegex)
+		if err != nil {
+			return fmt.Errorf("invalid body_regex: %w", err)
+		}
+		if !re.Match(body) {
+			return fmt.Errorf("response does not match %q", cfg.BodyRegex)
+		}
+	}
+
+	if cfg.JSONPath != "" {
+		return checkJSON(body, cfg.JSONPath, cfg.JSONValue)
+	}
+	return nil
+}
+
+// expectedStatus reports whether a status code is expected. No expected
+// codes means any 2xx.
+func expectedStatus(expected []int, code int) bool {
+	if len(expected) == 0 {
+		return code >= 200 && code < 300
+	}
+	for _, c := range expected {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
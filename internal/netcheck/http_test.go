@@ -0,0 +1,262 @@
+package netcheck
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harungecit/vigilon/internal/models"
+)
+
+// newTestServer serves a small API and returns its URL
+func newTestServer(t *testing.T) string {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"status":"ok","version":"2.4.1","checks":[{"name":"db","state":"up","latency":1.0}]}`)
+	})
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, r.Method+" "+r.Header.Get("X-Token")+" "+r.Host+" "+string(body))
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/health", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/elsewhere", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://other.invalid/", http.StatusFound)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		io.WriteString(w, "done")
+	})
+	mux.HandleFunc("/broken", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "database unavailable", http.StatusServiceUnavailable)
+	})
+
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return ts.URL
+}
+
+func TestHTTP(t *testing.T) {
+	base := newTestServer(t)
+
+	tests := []struct {
+		name    string
+		cfg     models.HTTPCheck
+		status  models.ServiceStatus
+		wantErr string
+	}{
+		{
+			name:   "2xx by default",
+			cfg:    models.HTTPCheck{URL: base + "/health"},
+			status: models.StatusRunning,
+		},
+		{
+			name:    "error status",
+			cfg:     models.HTTPCheck{URL: base + "/broken"},
+			status:  models.StatusFailed,
+			wantErr: "unexpected status 503",
+		},
+		{
+			name:   "expected error status",
+			cfg:    models.HTTPCheck{URL: base + "/broken", ExpectedStatus: []int{503}},
+			status: models.StatusRunning,
+		},
+		{
+			name: "method, headers and body",
+			cfg: models.HTTPCheck{
+				URL:            base + "/echo",
+				Method:         "post",
+				Headers:        map[string]string{"X-Token": "secret", "Host": "api.example.com"},
+				Body:           "ping",
+				ExpectedStatus: []int{201},
+				BodyContains:   "POST secret api.example.com ping",
+			},
+			status: models.StatusRunning,
+		},
+		{
+			name:    "body does not contain",
+			cfg:     models.HTTPCheck{URL: base + "/health", BodyContains: "degraded"},
+			status:  models.StatusFailed,
+			wantErr: `response does not contain "degraded"`,
+		},
+		{
+			name:   "body matches",
+			cfg:    models.HTTPCheck{URL: base + "/health", BodyRegex: `"version":"2\.\d+`},
+			status: models.StatusRunning,
+		},
+		{
+			name:    "body does not match",
+			cfg:     models.HTTPCheck{URL: base + "/health", BodyRegex: `"version":"3\.`},
+			status:  models.StatusFailed,
+			wantErr: "response does not match",
+		},
+		{
+			name:   "json value",
+			cfg:    models.HTTPCheck{URL: base + "/health", JSONPath: "checks[0].state", JSONValue: "up"},
+			status: models.StatusRunning,
+		},
+		{
+			name:   "json number",
+			cfg:    models.HTTPCheck{URL: base + "/health", JSONPath: "$.checks[0].latency", JSONValue: "1"},
+			status: models.StatusRunning,
+		},
+		{
+			name:    "json value differs",
+			cfg:     models.HTTPCheck{URL: base + "/health", JSONPath: "status", JSONValue: "down"},
+			status:  models.StatusFailed,
+			wantErr: "status is ok, want down",
+		},
+		{
+			name:    "json path missing",
+			cfg:     models.HTTPCheck{URL: base + "/health", JSONPath: "checks[1].state"},
+			status:  models.StatusFailed,
+			wantErr: "response has no checks[1].state",
+		},
+		{
+			name:    "not json",
+			cfg:     models.HTTPCheck{URL: base + "/slow", JSONPath: "status"},
+			status:  models.StatusFailed,
+			wantErr: "response is not JSON",
+		},
+		{
+			name:   "redirect followed",
+			cfg:    models.HTTPCheck{URL: base + "/moved", BodyContains: `"status":"ok"`},
+			status: models.StatusRunning,
+		},
+		{
+			name:   "redirect not followed",
+			cfg:    models.HTTPCheck{URL: base + "/moved", Redirects: models.RedirectNone, ExpectedStatus: []int{301}},
+			status: models.StatusRunning,
+		},
+		{
+			name:   "redirect on the same host",
+			cfg:    models.HTTPCheck{URL: base + "/moved", Redirects: models.RedirectSameHost},
+			status: models.StatusRunning,
+		},
+		{
+			name:    "redirect to another host",
+			cfg:     models.HTTPCheck{URL: base + "/elsewhere", Redirects: models.RedirectSameHost},
+			status:  models.StatusFailed,
+			wantErr: "unexpected status 302 (redirect to http://other.invalid/)",
+		},
+		{
+			name:    "slow response",
+			cfg:     models.HTTPCheck{URL: base + "/slow", DegradedAfter: 10},
+			status:  models.StatusDegraded,
+			wantErr: "over the 10ms threshold",
+		},
+		{
+			name:    "slow response failing an assertion",
+			cfg:     models.HTTPCheck{URL: base + "/slow", DegradedAfter: 10, BodyContains: "ready"},
+			status:  models.StatusFailed,
+			wantErr: "does not contain",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := HTTP(context.Background(), &tt.cfg)
+			if result.Status != tt.status {
+				t.Errorf("status = %s (%v), want %s", result.Status, result.Err, tt.status)
+			}
+			checkError(t, result.Err, tt.wantErr)
+		})
+	}
+}
+
+func TestHTTPTimeout(t *testing.T) {
+	blocked := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-blocked
+	}))
+	defer ts.Close()
+	defer close(blocked)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	result := HTTP(ctx, &models.HTTPCheck{URL: ts.URL})
+	if result.Status != models.StatusFailed {
+		t.Errorf("status = %s, want failed", result.Status)
+	}
+	checkError(t, result.Err, "context deadline exceeded")
+}
+
+func TestValidateHTTP(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     models.HTTPCheck
+		wantErr string
+	}{
+		{"valid", models.HTTPCheck{URL: "https://example.com/health", JSONPath: "a.b[2]", ExpectedStatus: []int{200, 301}}, ""},
+		{"no scheme", models.HTTPCheck{URL: "example.com"}, "url must be"},
+		{"other scheme", models.HTTPCheck{URL: "ftp://example.com"}, "url must be"},
+		{"bad method", models.HTTPCheck{URL: "http://example.com", Method: "GET /"}, "invalid request"},
+		{"bad header", models.HTTPCheck{URL: "http://example.com", Headers: map[string]string{"X-A": "b\r\nX-Injected: c"}}, "invalid header"},
+		{"bad status", models.HTTPCheck{URL: "http://example.com", ExpectedStatus: []int{42}}, "invalid expected status 42"},
+		{"bad regex", models.HTTPCheck{URL: "http://example.com", BodyRegex: "("}, "invalid body_regex"},
+		{"bad json path", models.HTTPCheck{URL: "http://example.com", JSONPath: "a[x]"}, `bad index "x"`},
+		{"value without path", models.HTTPCheck{URL: "http://example.com", JSONValue: "ok"}, "json_value needs a json_path"},
+		{"bad redirects", models.HTTPCheck{URL: "http://example.com", Redirects: "sometimes"}, "unknown redirect policy"},
+		{"negative timeout", models.HTTPCheck{URL: "http://example.com", Timeout: -1}, "must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkError(t, ValidateHTTP(&tt.cfg), tt.wantErr)
+		})
+	}
+}
+
+func TestParseJSONPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    []jsonStep
+		wantErr string
+	}{
+		{"status", []jsonStep{{"status", -1}}, ""},
+		{"$.data.items[2]", []jsonStep{{"data", -1}, {"items", -1}, {"", 2}}, ""},
+		{"[0].name", []jsonStep{{"", 0}, {"name", -1}}, ""},
+		{"$", nil, ""},
+		{"a..b", nil, "empty key"},
+		{"a[1", nil, "missing ]"},
+		{"a[-1]", nil, "bad index"},
+		{"a[0]b", nil, `unexpected "b"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			steps, err := parseJSONPath(tt.path)
+			checkError(t, err, tt.wantErr)
+			if err != nil {
+				return
+			}
+			if len(steps) != len(tt.want) {
+				t.Fatalf("steps = %v, want %v", steps, tt.want)
+			}
+			for i := range steps {
+				if steps[i] != tt.want[i] {
+					t.Errorf("steps = %v, want %v", steps, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// checkError fails the test unless err contains want, or is nil when want
+// is empty
+func checkError(t *testing.T, err error, want string) {
+	t.Helper()
+	switch {
+	case want == "" && err != nil:
+		t.Errorf("unexpected error: %v", err)
+	case want != "" && err == nil:
+		t.Errorf("error = nil, want %q", want)
+	case want != "" && !strings.Contains(err.Error(), want):
+		t.Errorf("error = %v, want %q", err, want)
+	}
+}
@@ -0,0 +1,119 @@
+package netcheck
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonStep is one step of a JSON path: an object key or an array index
+type jsonStep struct {
+	key   string
+	index int // -1 for a key
+}
+
+// parseJSONPath parses a path of object keys and array indexes, like
+// "status", "checks[0].state" or "$.data.items[2]"
+func parseJSONPath(path string) ([]jsonStep, error) {
+	rest, rooted := strings.CutPrefix(path, "$")
+	if !rooted && rest != "" && rest[0] != '.' && rest[0] != '[' {
+		rest = "." + rest
+	}
+
+	var steps []jsonStep
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid JSON path %q: empty key", path)
+			}
+			steps = append(steps, jsonStep{key: rest[:end], index: -1})
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSON path %q: missing ]", path)
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid JSON path %q: bad index %q", path, rest[1:end])
+			}
+			steps = append(steps, jsonStep{index: index})
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid JSON path %q: unexpected %q", path, rest[:1])
+		}
+	}
+	return steps, nil
+}
+
+// checkJSON checks that a JSON document has a value at path, equal to want
+// unless want is empty
+func checkJSON(body []byte, path, want string) error {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("response is not JSON: %w", err)
+	}
+
+	for _, step := range steps {
+		var ok bool
+		if step.index < 0 {
+			var object map[string]any
+			if object, ok = value.(map[string]any); ok {
+				value, ok = object[step.key]
+			}
+		} else {
+			var array []any
+			if array, ok = value.([]any); ok && step.index < len(array) {
+				value = array[step.index]
+			} else {
+				ok = false
+			}
+		}
+		if !ok {
+			return fmt.Errorf("response has no %s", path)
+		}
+	}
+
+	if want != "" && !jsonEqual(value, want) {
+		return fmt.Errorf("%s is %s, want %s", path, jsonString(value), want)
+	}
+	return nil
+}
+
+// jsonEqual reports whether a JSON value equals the expected text. Numbers
+// compare by value, so 1 matches "1.0".
+func jsonEqual(value any, want string) bool {
+	if number, ok := value.(json.Number); ok {
+		got, err1 := number.Float64()
+		expected, err2 := strconv.ParseFloat(want, 64)
+		if err1 == nil && err2 == nil {
+			return got == expected
+		}
+	}
+	return jsonString(value) == want
+}
+
+// jsonString returns a JSON value as text: strings without quotes, anything
+// else as JSON
+func jsonString(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
@@ -0,0 +1,109 @@
+// Package netcheck checks services that are reached over the network, such
+// as HTTP endpoints, TLS certificates, TCP ports and hosts answering ping.
+// The checks run wherever they are called from: the Vigilon server, or the
+// agent of a push server for internal-only endpoints.
+package netcheck
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/harungecit/vigilon/internal/models"
+)
+
+// DefaultTimeout bounds a check that does not set its own timeout
+const DefaultTimeout = 10 * time.Second
+
+// Result is the outcome of a network check
+type Result struct {
+	Status  models.ServiceStatus
+	Elapsed time.Duration // Time to the complete response
+	Err     error         // Why the service is not running, if it is not
+
+	CertExpiry time.Time     // When the certificate of a tls check expires
+	Latency    time.Duration // Connect time of a tcp check, average round trip of a ping check
+	Sent       int           // Echo requests of a ping check
+	Received   int           // Echo replies of a ping check
+}
+
+// Check checks an endpoint service according to its type
+func Check(ctx context.Context, service *models.Service) Result {
+	switch service.Type {
+	case models.ServiceTypeHTTP:
+		if service.HTTP == nil {
+			return Result{Status: models.StatusUnknown, Err: fmt.Errorf("http check of %s is not configured", service.Name)}
+		}
+		return HTTP(ctx, service.HTTP)
+	case models.ServiceTypeTLS:
+		if service.TLS == nil {
+			return Result{Status: models.StatusUnknown, Err: fmt.Errorf("tls check of %s is not configured", service.Name)}
+		}
+		return TLS(ctx, service.TLS)
+	case models.ServiceTypeTCP:
+		if service.TCP == nil {
+			return Result{Status: models.StatusUnknown, Err: fmt.Errorf("tcp check of %s is not configured", service.Name)}
+		}
+		return TCP(ctx, service.TCP)
+	case models.ServiceTypePing:
+		if service.Ping == nil {
+			return Result{Status: models.StatusUnknown, Err: fmt.Errorf("ping check of %s is not configured", service.Name)}
+		}
+		return Ping(ctx, service.Ping)
+	}
+	return Result{Status: models.StatusUnknown, Err: fmt.Errorf("%s is not a network service", service.Name)}
+}
+
+// Validate checks the settings of an endpoint service before it is saved
+func Validate(service *models.Service) error {
+	if service.Reachability {
+		if service.Type != models.ServiceTypeTCP && service.Type != models.ServiceTypePing {
+			return fmt.Errorf("only tcp and ping checks can tell whether the server is reachable")
+		}
+		if service.CheckFrom == models.CheckFromAgent {
+			return fmt.Errorf("reachability checks must run from the Vigilon server")
+		}
+	}
+
+	switch service.Type {
+	case models.ServiceTypeHTTP:
+		if service.HTTP == nil {
+			return fmt.Errorf("http settings are required for an http service")
+		}
+		return ValidateHTTP(service.HTTP)
+	case models.ServiceTypeTLS:
+		if service.TLS == nil {
+			return fmt.Errorf("tls settings are required for a tls service")
+		}
+		return ValidateTLS(service.TLS)
+	case models.ServiceTypeTCP:
+		if service.TCP == nil {
+			return fmt.Errorf("tcp settings are required for a tcp service")
+		}
+		return ValidateTCP(service.TCP)
+	case models.ServiceTypePing:
+		if service.Ping == nil {
+			return fmt.Errorf("ping settings are required for a ping service")
+		}
+		return ValidatePing(service.Ping)
+	}
+	return nil
+}
+
+// LatencyMs returns the latency of a tcp or ping check in milliseconds, or
+// nil when none was measured
+func (r Result) LatencyMs() *float64 {
+	if r.Latency <= 0 {
+		return nil
+	}
+	ms := float64(r.Latency.Microseconds()) / 1000
+	return &ms
+}
+
+// timeout returns the timeout of a check in seconds, or the default
+func timeout(seconds int) time.Duration {
+	if seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return DefaultTimeout
+}
@@ -0,0 +1,243 @@
+package netcheck
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/harungecit/vigilon/internal/models"
+)
+
+const (
+	defaultPingCount = 3
+	maxPingCount     = 100
+	pingInterval     = 200 * time.Millisecond // Least time between two echo requests
+)
+
+// ICMP message types of echo requests and replies
+const (
+	icmpv4EchoRequest = 8
+	icmpv4EchoReply   = 0
+	icmpv6EchoRequest = 128
+	icmpv6EchoReply   = 129
+)
+
+// icmpSocket sends echo requests to a single host
+type icmpSocket struct {
+	net.PacketConn
+	dst net.Addr // Address of the host, in the form the connection expects
+	v6  bool
+}
+
+// Ping sends echo requests to a host one after the other. The host is down
+// when none is answered; losing more than MaxLoss percent of them, or an
+// average round trip over DegradedAfter, marks it degraded.
+func Ping(ctx context.Context, cfg *models.PingCheck) Result {
+	limit := timeout(cfg.Timeout)
+	ctx, cancel := context.WithTimeout(ctx, limit)
+	defer cancel()
+
+	count := cfg.Count
+	if count <= 0 {
+		count = defaultPingCount
+	}
+
+	start := time.Now()
+	ip, err := resolveIP(ctx, cfg.Host)
+	if err != nil {
+		return Result{Status: models.StatusFailed, Elapsed: time.Since(start), Err: err}
+	}
+	socket, err := openICMP(ip)
+	if err != nil {
+		return Result{Status: models.StatusUnknown, Elapsed: time.Since(start), Err: fmt.Errorf("cannot send ICMP echo requests: %w", err)}
+	}
+	defer socket.Close()
+
+	// The kernel may rewrite the identifier of unprivileged echo requests, so
+	// replies are told apart by sequence number and a random payload
+	payload := make([]byte, 16)
+	rand.Read(payload)
+	wait := limit / time.Duration(count)
+
+	var result Result
+	var total time.Duration
+	for seq := 0; seq < count && ctx.Err() == nil; seq++ {
+		sent := time.Now()
+		result.Sent++
+		rtt, err := socket.echo(ctx, seq, payload, sent.Add(wait))
+		if err == nil {
+			result.Received++
+			total += rtt
+		} else if !errors.Is(err, os.ErrDeadlineExceeded) {
+			result.Elapsed = time.Since(start)
+			result.Status = models.StatusFailed
+			result.Err = err
+			return result
+		}
+
+		if pause := pingInterval - time.Since(sent); seq < count-1 && pause > 0 {
+			select {
+			case <-time.After(pause):
+			case <-ctx.Done():
+			}
+		}
+	}
+	result.Elapsed = time.Since(start)
+
+	if result.Received == 0 {
+		result.Status = models.StatusFailed
+		result.Err = fmt.Errorf("no reply to %d echo requests", result.Sent)
+		return result
+	}
+	result.Latency = total / time.Duration(result.Received)
+
+	if loss := *result.PacketLoss(); loss > float64(cfg.MaxLoss) {
+		result.Status = models.StatusDegraded
+		result.Err = fmt.Errorf("%.0f%% packet loss, %d of %d echo requests unanswered", loss, result.Sent-result.Received, result.Sent)
+		return result
+	}
+	if threshold := time.Duration(cfg.DegradedAfter) * time.Millisecond; threshold > 0 && result.Latency > threshold {
+		result.Status = models.StatusDegraded
+		result.Err = fmt.Errorf("average round trip of %.1fms, over the %dms threshold", *result.LatencyMs(), cfg.DegradedAfter)
+		return result
+	}
+	result.Status = models.StatusRunning
+	return result
+}
+
+// ValidatePing checks the settings of a ping check
+func ValidatePing(cfg *models.PingCheck) error {
+	if cfg.Host == "" {
+		return fmt.Errorf("host is required")
+	}
+	if cfg.Count < 0 || cfg.Count > maxPingCount {
+		return fmt.Errorf("count must be between 1 and %d, or 0 for %d", maxPingCount, defaultPingCount)
+	}
+	if cfg.MaxLoss < 0 || cfg.MaxLoss > 100 {
+		return fmt.Errorf("max_loss must be a percentage")
+	}
+	if cfg.Timeout < 0 || cfg.DegradedAfter < 0 {
+		return fmt.Errorf("timeout and degraded_after_ms must not be negative")
+	}
+	return nil
+}
+
+// PacketLoss returns the percentage of echo requests of a ping check that
+// were not answered, or nil for other checks
+func (r Result) PacketLoss() *float64 {
+	if r.Sent == 0 {
+		return nil
+	}
+	loss := float64(r.Sent-r.Received) * 100 / float64(r.Sent)
+	return &loss
+}
+
+// resolveIP returns the address of a host, preferring IPv4
+func resolveIP(ctx context.Context, host string) (*net.IPAddr, error) {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		if addr.IP.To4() != nil {
+			return &addr, nil
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no address for %s", host)
+	}
+	return &addrs[0], nil
+}
+
+// openRawICMP opens a raw ICMP socket, which needs administrator rights
+// (CAP_NET_RAW on Linux). It receives every ICMP message of the host.
+func openRawICMP(ip *net.IPAddr) (*icmpSocket, error) {
+	v6 := ip.IP.To4() == nil
+	network := "ip4:icmp"
+	if v6 {
+		network = "ip6:ipv6-icmp"
+	}
+
+	conn, err := net.ListenPacket(network, "")
+	if err != nil {
+		return nil, err
+	}
+	return &icmpSocket{PacketConn: conn, dst: ip, v6: v6}, nil
+}
+
+// echo sends one echo request and waits until its reply arrives or the
+// deadline passes
+func (s *icmpSocket) echo(ctx context.Context, seq int, payload []byte, deadline time.Time) (time.Duration, error) {
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := s.SetDeadline(deadline); err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	if _, err := s.WriteTo(echoRequest(s.v6, seq, payload), s.dst); err != nil {
+		return 0, fmt.Errorf("failed to send echo request: %w", err)
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := s.ReadFrom(buf)
+		if err != nil {
+			return 0, err
+		}
+		if isEchoReply(s.v6, buf[:n], seq, payload) {
+			return time.Since(start), nil
+		}
+	}
+}
+
+// echoRequest builds an ICMP echo request. The kernel computes the checksum
+// of ICMPv6 messages.
+func echoRequest(v6 bool, seq int, payload []byte) []byte {
+	msg := make([]byte, 8+len(payload))
+	msg[0] = icmpv4EchoRequest
+	if v6 {
+		msg[0] = icmpv6EchoRequest
+	}
+	binary.BigEndian.PutUint16(msg[4:], uint16(os.Getpid()))
+	binary.BigEndian.PutUint16(msg[6:], uint16(seq))
+	copy(msg[8:], payload)
+	if !v6 {
+		binary.BigEndian.PutUint16(msg[2:], checksum(msg))
+	}
+	return msg
+}
+
+// isEchoReply reports whether an ICMP message answers the echo request with
+// the sequence number and payload
+func isEchoReply(v6 bool, msg []byte, seq int, payload []byte) bool {
+	reply := byte(icmpv4EchoReply)
+	if v6 {
+		reply = icmpv6EchoReply
+	}
+	if len(msg) < 8+len(payload) || msg[0] != reply || msg[1] != 0 {
+		return false
+	}
+	return binary.BigEndian.Uint16(msg[6:]) == uint16(seq) && string(msg[8:8+len(payload)]) == string(payload)
+}
+
+// checksum computes the Internet checksum of RFC 1071
+func checksum(msg []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(msg); i += 2 {
+		sum += uint32(msg[i])<<8 | uint32(msg[i+1])
+	}
+	if len(msg)%2 == 1 {
+		sum += uint32(msg[len(msg)-1]) << 8
+	}
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	return ^uint16(sum)
+}
@@ -0,0 +1,41 @@
+package netcheck
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// openICMP opens an unprivileged ICMP datagram socket ("ping socket"), which
+// needs no capabilities but the group of the process to be allowed by the
+// net.ipv4.ping_group_range sysctl. The kernel picks the identifier of the
+// echo requests and only passes the replies to them back. Where ping sockets
+// are not allowed, a process with CAP_NET_RAW falls back to a raw socket.
+func openICMP(ip *net.IPAddr) (*icmpSocket, error) {
+	v6 := ip.IP.To4() == nil
+	family, proto := syscall.AF_INET, syscall.IPPROTO_ICMP
+	if v6 {
+		family, proto = syscall.AF_INET6, syscall.IPPROTO_ICMPV6
+	}
+
+	fd, err := syscall.Socket(family, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, proto)
+	if errors.Is(err, syscall.EACCES) || errors.Is(err, syscall.EPERM) {
+		if socket, rawErr := openRawICMP(ip); rawErr == nil {
+			return socket, nil
+		}
+		return nil, fmt.Errorf("%w (the group of the process must be within net.ipv4.ping_group_range)", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	file := os.NewFile(uintptr(fd), "icmp")
+	defer file.Close()
+
+	conn, err := net.FilePacketConn(file)
+	if err != nil {
+		return nil, err
+	}
+	return &icmpSocket{PacketConn: conn, dst: &net.UDPAddr{IP: ip.IP, Zone: ip.Zone}, v6: v6}, nil
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package netcheck
+
+import "net"
+
+// openICMP opens the socket of a ping check. Outside of Linux only raw
+// sockets are used, so the agent must run with administrator rights.
+func openICMP(ip *net.IPAddr) (*icmpSocket, error) {
+	return openRawICMP(ip)
+}
@@ -0,0 +1,111 @@
+package netcheck
+
+import (
+	"context"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/harungecit/vigilon/internal/models"
+)
+
+func TestEchoRequest(t *testing.T) {
+	payload := []byte("vigilon-payload!")
+	msg := echoRequest(false, 7, payload)
+	if msg[0] != icmpv4EchoRequest || msg[1] != 0 {
+		t.Fatalf("type/code = %d/%d, want an echo request", msg[0], msg[1])
+	}
+	if seq := binary.BigEndian.Uint16(msg[6:]); seq != 7 {
+		t.Errorf("sequence = %d, want 7", seq)
+	}
+	// The checksum of a message that includes its checksum is zero
+	if sum := checksum(msg); sum != 0 {
+		t.Errorf("checksum does not verify: %#04x", sum)
+	}
+
+	if msg := echoRequest(true, 7, payload); msg[0] != icmpv6EchoRequest || msg[2] != 0 || msg[3] != 0 {
+		t.Errorf("ICMPv6 request = % x, want type 128 and no checksum", msg[:4])
+	}
+}
+
+func TestIsEchoReply(t *testing.T) {
+	payload := []byte("vigilon-payload!")
+	reply := func(v6 bool, seq int, payload []byte) []byte {
+		msg := echoRequest(v6, seq, payload)
+		msg[0] = icmpv4EchoReply
+		if v6 {
+			msg[0] = icmpv6EchoReply
+		}
+		return msg
+	}
+
+	tests := []struct {
+		name string
+		v6   bool
+		msg  []byte
+		want bool
+	}{
+		{"reply", false, reply(false, 2, payload), true},
+		{"icmpv6 reply", true, reply(true, 2, payload), true},
+		{"own request", false, echoRequest(false, 2, payload), false},
+		{"other sequence", false, reply(false, 1, payload), false},
+		{"other payload", false, reply(false, 2, []byte("someone-else-pin")), false},
+		{"icmpv4 reply on icmpv6", true, reply(false, 2, payload), false},
+		{"truncated", false, reply(false, 2, payload)[:12], false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isEchoReply(tt.v6, tt.msg, 2, payload); got != tt.want {
+				t.Errorf("isEchoReply = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPingLoopback(t *testing.T) {
+	result := Ping(context.Background(), &models.PingCheck{Host: "127.0.0.1", Count: 2})
+	if result.Status == models.StatusUnknown && strings.Contains(result.Err.Error(), "cannot send ICMP echo requests") {
+		t.Skipf("ICMP sockets are not allowed here: %v", result.Err)
+	}
+	if result.Status != models.StatusRunning {
+		t.Fatalf("status = %s (%v), want running", result.Status, result.Err)
+	}
+	if result.Sent != 2 || result.Received != 2 {
+		t.Errorf("received %d of %d replies, want 2 of 2", result.Received, result.Sent)
+	}
+	if loss := result.PacketLoss(); loss == nil || *loss != 0 {
+		t.Errorf("packet loss = %v, want 0", loss)
+	}
+	if result.LatencyMs() == nil {
+		t.Errorf("latency not recorded")
+	}
+}
+
+func TestPacketLoss(t *testing.T) {
+	if loss := (Result{}).PacketLoss(); loss != nil {
+		t.Errorf("packet loss of a non-ping result = %v, want nil", *loss)
+	}
+	if loss := (Result{Sent: 4, Received: 3}).PacketLoss(); loss == nil || *loss != 25 {
+		t.Errorf("packet loss = %v, want 25", loss)
+	}
+}
+
+func TestValidatePing(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     models.PingCheck
+		wantErr string
+	}{
+		{"valid", models.PingCheck{Host: "10.0.0.1", Count: 5, MaxLoss: 20}, ""},
+		{"defaults", models.PingCheck{Host: "router.local"}, ""},
+		{"no host", models.PingCheck{}, "host is required"},
+		{"too many", models.PingCheck{Host: "10.0.0.1", Count: 1000}, "count must be between 1 and 100"},
+		{"bad loss", models.PingCheck{Host: "10.0.0.1", MaxLoss: 120}, "max_loss must be a percentage"},
+		{"negative timeout", models.PingCheck{Host: "10.0.0.1", Timeout: -1}, "must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkError(t, ValidatePing(&tt.cfg), tt.wantErr)
+		})
+	}
+}
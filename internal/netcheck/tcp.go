@@ -0,0 +1,100 @@
+package netcheck
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/harungecit/vigilon/internal/models"
+)
+
+// maxReplySize bounds how much of a reply is read looking for the expected
+// text
+const maxReplySize = 64 << 10
+
+// TCP connects to a port and, if set, sends a request and waits for the
+// expected text in the reply. A connect time over DegradedAfter marks the
+// port degraded.
+func TCP(ctx context.Context, cfg *models.TCPCheck) Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout(cfg.Timeout))
+	defer cancel()
+
+	start := time.Now()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)))
+	latency := time.Since(start)
+	if err != nil {
+		return Result{Status: models.StatusFailed, Elapsed: latency, Err: err}
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if cfg.Send != "" {
+		if _, err := io.WriteString(conn, cfg.Send); err != nil {
+			return Result{Status: models.StatusFailed, Elapsed: time.Since(start), Latency: latency, Err: fmt.Errorf("failed to send: %w", err)}
+		}
+	}
+	if cfg.Expect != "" {
+		if err := expectReply(conn, cfg.Expect); err != nil {
+			return Result{Status: models.StatusFailed, Elapsed: time.Since(start), Latency: latency, Err: err}
+		}
+	}
+	elapsed := time.Since(start)
+
+	if limit := time.Duration(cfg.DegradedAfter) * time.Millisecond; limit > 0 && latency > limit {
+		return Result{
+			Status:  models.StatusDegraded,
+			Elapsed: elapsed,
+			Latency: latency,
+			Err:     fmt.Errorf("connecting took %dms, over the %dms threshold", latency.Milliseconds(), cfg.DegradedAfter),
+		}
+	}
+	return Result{Status: models.StatusRunning, Elapsed: elapsed, Latency: latency}
+}
+
+// ValidateTCP checks the settings of a tcp check
+func ValidateTCP(cfg *models.TCPCheck) error {
+	if cfg.Host == "" {
+		return fmt.Errorf("host is required")
+	}
+	if cfg.Port < 1 || cfg.Port > 65535 {
+		return fmt.Errorf("invalid port %d", cfg.Port)
+	}
+	if cfg.Timeout < 0 || cfg.DegradedAfter < 0 {
+		return fmt.Errorf("timeout and degraded_after_ms must not be negative")
+	}
+	return nil
+}
+
+// expectReply reads from conn until the reply contains expect. The server
+// closing the connection or the deadline passing first fails the check.
+func expectReply(conn net.Conn, expect string) error {
+	var reply []byte
+	buf := make([]byte, 4096)
+	for len(reply) < maxReplySize {
+		n, err := conn.Read(buf)
+		reply = append(reply, buf[:n]...)
+		if bytes.Contains(reply, []byte(expect)) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reply does not contain %q (got %q): %w", expect, excerpt(reply), err)
+		}
+	}
+	return fmt.Errorf("reply does not contain %q (got %q)", expect, excerpt(reply))
+}
+
+// excerpt returns the start of a reply, for error messages
+func excerpt(reply []byte) string {
+	const size = 64
+	if len(reply) > size {
+		return string(reply[:size]) + "..."
+	}
+	return string(reply)
+}
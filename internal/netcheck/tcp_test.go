@@ -0,0 +1,166 @@
+package netcheck
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harungecit/vigilon/internal/models"
+)
+
+// serveTCP runs handle for every connection and returns the port it listens
+// on
+func serveTCP(t *testing.T, handle func(net.Conn)) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestTCP(t *testing.T) {
+	banner := serveTCP(t, func(conn net.Conn) {
+		io.WriteString(conn, "SSH-2.0-OpenSSH_9.6\r\n")
+		io.Copy(io.Discard, conn)
+	})
+	redis := serveTCP(t, func(conn net.Conn) {
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if line == "PING\r\n" {
+				io.WriteString(conn, "+PONG\r\n")
+			} else {
+				io.WriteString(conn, "-ERR unknown command\r\n")
+			}
+		}
+	})
+	closing := serveTCP(t, func(conn net.Conn) {
+		io.WriteString(conn, "421 Too many connections\r\n")
+	})
+	silent := serveTCP(t, func(conn net.Conn) {
+		io.Copy(io.Discard, conn)
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	tests := []struct {
+		name    string
+		cfg     models.TCPCheck
+		status  models.ServiceStatus
+		wantErr string
+	}{
+		{
+			name:   "port open",
+			cfg:    models.TCPCheck{Host: "127.0.0.1", Port: silent},
+			status: models.StatusRunning,
+		},
+		{
+			name:    "port closed",
+			cfg:     models.TCPCheck{Host: "127.0.0.1", Port: closed},
+			status:  models.StatusFailed,
+			wantErr: "connection refused",
+		},
+		{
+			name:   "banner",
+			cfg:    models.TCPCheck{Host: "127.0.0.1", Port: banner, Expect: "SSH-2.0"},
+			status: models.StatusRunning,
+		},
+		{
+			name:    "wrong banner",
+			cfg:     models.TCPCheck{Host: "127.0.0.1", Port: banner, Expect: "220 ", Timeout: 1},
+			status:  models.StatusFailed,
+			wantErr: `reply does not contain "220 " (got "SSH-2.0-OpenSSH_9.6\r\n")`,
+		},
+		{
+			name:   "send and expect",
+			cfg:    models.TCPCheck{Host: "127.0.0.1", Port: redis, Send: "PING\r\n", Expect: "+PONG"},
+			status: models.StatusRunning,
+		},
+		{
+			name:    "unexpected reply",
+			cfg:     models.TCPCheck{Host: "127.0.0.1", Port: redis, Send: "HELLO\r\n", Expect: "+PONG", Timeout: 1},
+			status:  models.StatusFailed,
+			wantErr: `got "-ERR unknown command\r\n"`,
+		},
+		{
+			name:    "closed before the reply",
+			cfg:     models.TCPCheck{Host: "127.0.0.1", Port: closing, Expect: "220 "},
+			status:  models.StatusFailed,
+			wantErr: "EOF",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := TCP(context.Background(), &tt.cfg)
+			if result.Status != tt.status {
+				t.Errorf("status = %s (%v), want %s", result.Status, result.Err, tt.status)
+			}
+			checkError(t, result.Err, tt.wantErr)
+			if tt.status == models.StatusRunning && result.LatencyMs() == nil {
+				t.Errorf("latency not recorded")
+			}
+		})
+	}
+}
+
+func TestTCPExpectTimeout(t *testing.T) {
+	silent := serveTCP(t, func(conn net.Conn) {
+		io.Copy(io.Discard, conn)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	result := TCP(ctx, &models.TCPCheck{Host: "127.0.0.1", Port: silent, Expect: "hello"})
+	if result.Status != models.StatusFailed {
+		t.Errorf("status = %s, want failed", result.Status)
+	}
+	if result.Err == nil || !strings.Contains(result.Err.Error(), "i/o timeout") {
+		t.Errorf("error = %v, want a timeout", result.Err)
+	}
+}
+
+func TestValidateTCP(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     models.TCPCheck
+		wantErr string
+	}{
+		{"valid", models.TCPCheck{Host: "db.internal", Port: 5432}, ""},
+		{"no host", models.TCPCheck{Port: 22}, "host is required"},
+		{"no port", models.TCPCheck{Host: "db.internal"}, "invalid port 0"},
+		{"bad port", models.TCPCheck{Host: "db.internal", Port: 65536}, "invalid port 65536"},
+		{"negative threshold", models.TCPCheck{Host: "db.internal", Port: 5432, DegradedAfter: -1}, "must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkError(t, ValidateTCP(&tt.cfg), tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package netcheck
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/harungecit/vigilon/internal/models"
+)
+
+// postgresSSLRequest is the code of the message asking a PostgreSQL server
+// to switch to TLS
+const postgresSSLRequest = 80877103
+
+// TLS connects to an endpoint, upgrading the connection first for STARTTLS,
+// and verifies its certificate chain and host name. A valid certificate
+// that expires within an expiry threshold marks the endpoint degraded; the
+// result carries the expiry date even when the certificate is invalid.
+func TLS(ctx context.Context, cfg *models.TLSCheck) Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout(cfg.Timeout))
+	defer cancel()
+
+	port := cfg.Port
+	if port == 0 {
+		port = cfg.StartTLS.DefaultPort()
+	}
+	serverName := cfg.ServerName
+	if serverName == "" {
+		serverName = cfg.Host
+	}
+
+	start := time.Now()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(cfg.Host, strconv.Itoa(port)))
+	if err != nil {
+		return Result{Status: models.StatusFailed, Elapsed: time.Since(start), Err: err}
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if err := startTLS(conn, cfg.StartTLS); err != nil {
+		return Result{Status: models.StatusFailed, Elapsed: time.Since(start), Err: fmt.Errorf("%s STARTTLS failed: %w", cfg.StartTLS, err)}
+	}
+
+	// The chain is verified below, so the expiry date is known even for a
+	// certificate that does not verify
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: serverName, InsecureSkipVerify: true})
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return Result{Status: models.StatusFailed, Elapsed: time.Since(start), Err: fmt.Errorf("TLS handshake failed: %w", err)}
+	}
+	elapsed := time.Since(start)
+
+	certs := tlsConn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return Result{Status: models.StatusFailed, Elapsed: elapsed, Err: fmt.Errorf("server sent no certificate")}
+	}
+	leaf := certs[0]
+	result := Result{Elapsed: elapsed, CertExpiry: leaf.NotAfter}
+
+	now := time.Now()
+	days := daysUntil(leaf.NotAfter, now)
+	if now.After(leaf.NotAfter) {
+		result.Status = models.StatusFailed
+		result.Err = fmt.Errorf("certificate expired on %s", leaf.NotAfter.UTC().Format(time.DateOnly))
+		return result
+	}
+
+	roots, err := loadRoots(cfg.CAFile)
+	if err != nil {
+		result.Status = models.StatusUnknown
+		result.Err = err
+		return result
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err = leaf.Verify(x509.VerifyOptions{
+		DNSName:       serverName,
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+	})
+	if err != nil {
+		result.Status = models.StatusFailed
+		result.Err = fmt.Errorf("certificate is not valid: %w", err)
+		return result
+	}
+
+	if _, crossed := cfg.CrossedThreshold(days); crossed {
+		result.Status = models.StatusDegraded
+		result.Err = fmt.Errorf("certificate expires in %d days, on %s", days, leaf.NotAfter.UTC().Format(time.DateOnly))
+		return result
+	}
+
+	result.Status = models.StatusRunning
+	return result
+}
+
+// ValidateTLS checks the settings of a tls check
+func ValidateTLS(cfg *models.TLSCheck) error {
+	if cfg.Host == "" {
+		return fmt.Errorf("host is required")
+	}
+	if cfg.Port < 0 || cfg.Port > 65535 {
+		return fmt.Errorf("invalid port %d", cfg.Port)
+	}
+	if !cfg.StartTLS.Valid() {
+		return fmt.Errorf("unknown STARTTLS protocol %q", cfg.StartTLS)
+	}
+	if cfg.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	for _, t := range cfg.ExpiryThresholds {
+		if t.Days < 1 {
+			return fmt.Errorf("expiry thresholds must be at least 1 day")
+		}
+		if !models.ValidSeverity(t.Severity) {
+			return fmt.Errorf("invalid severity %q for the %d day threshold", t.Severity, t.Days)
+		}
+	}
+	return nil
+}
+
+// loadRoots reads the CA certificates a check trusts, nil for the system
+// roots
+func loadRoots(path string) (*x509.CertPool, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates in CA file %s", path)
+	}
+	return roots, nil
+}
+
+// daysUntil returns the whole days left until t, negative once it passed
+func daysUntil(t, now time.Time) int {
+	return int(math.Floor(t.Sub(now).Hours() / 24))
+}
+
+// DaysToExpiry returns the days left until the certificate of a tls check
+// expires, or nil for other checks
+func (r Result) DaysToExpiry() *int {
+	if r.CertExpiry.IsZero() {
+		return nil
+	}
+	days := daysUntil(r.CertExpiry, time.Now())
+	return &days
+}
+
+// startTLS asks the server to switch a plaintext connection to TLS
+func startTLS(conn net.Conn, protocol models.StartTLSProtocol) error {
+	switch protocol {
+	case models.StartTLSSMTP:
+		return startTLSSMTP(conn)
+	case models.StartTLSIMAP:
+		return startTLSIMAP(conn)
+	case models.StartTLSPostgres:
+		return startTLSPostgres(conn)
+	}
+	return nil
+}
+
+// startTLSSMTP upgrades an SMTP session (RFC 3207). The server sends nothing
+// after its 220 reply until the handshake, so nothing is left buffered.
+func startTLSSMTP(conn net.Conn) error {
+	text := textproto.NewConn(conn)
+	if _, _, err := text.ReadResponse(220); err != nil {
+		return err
+	}
+	if err := text.PrintfLine("EHLO vigilon"); err != nil {
+		return err
+	}
+	_, extensions, err := text.ReadResponse(250)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(strings.ToUpper(extensions), "STARTTLS") {
+		return fmt.Errorf("server does not offer STARTTLS")
+	}
+	if err := text.PrintfLine("STARTTLS"); err != nil {
+		return err
+	}
+	_, _, err = text.ReadResponse(220)
+	return err
+}
+
+// startTLSIMAP upgrades an IMAP session (RFC 3501)
+func startTLSIMAP(conn net.Conn) error {
+	reader := bufio.NewReader(conn)
+	greeting, err := reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(greeting, "* OK") {
+		return fmt.Errorf("unexpected greeting %q", strings.TrimSpace(greeting))
+	}
+	if _, err := io.WriteString(conn, "a1 STARTTLS\r\n"); err != nil {
+		return err
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		// Untagged responses may come before the tagged one
+		if tagged, ok := strings.CutPrefix(line, "a1 "); ok {
+			if !strings.HasPrefix(tagged, "OK") {
+				return fmt.Errorf("server refused: %s", strings.TrimSpace(tagged))
+			}
+			return nil
+		}
+	}
+}
+
+// startTLSPostgres sends the SSLRequest of the PostgreSQL protocol, answered
+// with a single S (go ahead) or N (no TLS)
+func startTLSPostgres(conn net.Conn) error {
+	request := make([]byte, 8)
+	binary.BigEndian.PutUint32(request, 8)
+	binary.BigEndian.PutUint32(request[4:], postgresSSLRequest)
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+	reply := make([]byte, 1)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != 'S' {
+		return fmt.Errorf("server does not accept TLS")
+	}
+	return nil
+}
@@ -0,0 +1,344 @@
+package netcheck
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harungecit/vigilon/internal/models"
+)
+
+// testCA issues certificates for the test servers
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	file string // PEM file of the CA certificate
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Vigilon Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, file: file}
+}
+
+// issue returns a certificate for localhost that expires after validFor
+func (ca *testCA) issue(t *testing.T, validFor time.Duration) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-30 * 24 * time.Hour),
+		NotAfter:     time.Now().Add(validFor),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// serveTLS accepts connections, runs the plaintext part of a protocol and
+// then a TLS handshake with cert. It returns the port it listens on.
+func serveTLS(t *testing.T, cert tls.Certificate, plaintext func(net.Conn, *bufio.Reader) bool) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if plaintext != nil && !plaintext(conn, bufio.NewReader(conn)) {
+					return
+				}
+				tlsConn := tls.Server(conn, config)
+				if tlsConn.Handshake() == nil {
+					io.Copy(io.Discard, tlsConn)
+				}
+			}()
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestTLS(t *testing.T) {
+	ca := newTestCA(t)
+	valid := serveTLS(t, ca.issue(t, 90*24*time.Hour+time.Hour), nil)
+	expiring := serveTLS(t, ca.issue(t, 10*24*time.Hour+time.Hour), nil)
+	expired := serveTLS(t, ca.issue(t, -12*time.Hour), nil)
+
+	tests := []struct {
+		name    string
+		cfg     models.TLSCheck
+		status  models.ServiceStatus
+		days    int
+		wantErr string
+	}{
+		{
+			name:   "valid",
+			cfg:    models.TLSCheck{Host: "localhost", Port: valid, CAFile: ca.file},
+			status: models.StatusRunning,
+			days:   90,
+		},
+		{
+			name:    "untrusted",
+			cfg:     models.TLSCheck{Host: "localhost", Port: valid},
+			status:  models.StatusFailed,
+			days:    90,
+			wantErr: "certificate is not valid",
+		},
+		{
+			name:    "host name mismatch",
+			cfg:     models.TLSCheck{Host: "127.0.0.1", Port: valid, ServerName: "www.example.com", CAFile: ca.file},
+			status:  models.StatusFailed,
+			days:    90,
+			wantErr: "www.example.com",
+		},
+		{
+			name:    "within a threshold",
+			cfg:     models.TLSCheck{Host: "localhost", Port: expiring, CAFile: ca.file},
+			status:  models.StatusDegraded,
+			days:    10,
+			wantErr: "certificate expires in 10 days",
+		},
+		{
+			name: "within no custom threshold",
+			cfg: models.TLSCheck{Host: "localhost", Port: expiring, CAFile: ca.file, ExpiryThresholds: []models.ExpiryThreshold{
+				{Days: 7, Severity: models.SeverityCritical},
+			}},
+			status: models.StatusRunning,
+			days:   10,
+		},
+		{
+			name:    "expired",
+			cfg:     models.TLSCheck{Host: "localhost", Port: expired, CAFile: ca.file},
+			status:  models.StatusFailed,
+			days:    -1,
+			wantErr: "certificate expired on",
+		},
+		{
+			name:    "missing CA file",
+			cfg:     models.TLSCheck{Host: "localhost", Port: valid, CAFile: filepath.Join(t.TempDir(), "missing.pem")},
+			status:  models.StatusUnknown,
+			days:    90,
+			wantErr: "failed to read CA file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := TLS(context.Background(), &tt.cfg)
+			if result.Status != tt.status {
+				t.Errorf("status = %s (%v), want %s", result.Status, result.Err, tt.status)
+			}
+			checkError(t, result.Err, tt.wantErr)
+			days := result.DaysToExpiry()
+			if days == nil {
+				t.Fatalf("days to expiry unknown, want %d", tt.days)
+			}
+			if *days != tt.days {
+				t.Errorf("days to expiry = %d, want %d", *days, tt.days)
+			}
+		})
+	}
+}
+
+func TestTLSNotListening(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	result := TLS(context.Background(), &models.TLSCheck{Host: "127.0.0.1", Port: port})
+	if result.Status != models.StatusFailed {
+		t.Errorf("status = %s, want failed", result.Status)
+	}
+	if result.DaysToExpiry() != nil {
+		t.Errorf("days to expiry = %d, want none", *result.DaysToExpiry())
+	}
+}
+
+func TestTLSStartTLS(t *testing.T) {
+	ca := newTestCA(t)
+	cert := ca.issue(t, 90*24*time.Hour)
+
+	smtp := func(offer string) func(net.Conn, *bufio.Reader) bool {
+		return func(conn net.Conn, reader *bufio.Reader) bool {
+			io.WriteString(conn, "220 mail.example.com ESMTP\r\n")
+			if line, _ := reader.ReadString('\n'); !strings.HasPrefix(line, "EHLO") {
+				return false
+			}
+			io.WriteString(conn, "250-mail.example.com\r\n250-"+offer+"\r\n250 8BITMIME\r\n")
+			if line, _ := reader.ReadString('\n'); line != "STARTTLS\r\n" {
+				return false
+			}
+			io.WriteString(conn, "220 Ready to start TLS\r\n")
+			return true
+		}
+	}
+	imap := func(reply string) func(net.Conn, *bufio.Reader) bool {
+		return func(conn net.Conn, reader *bufio.Reader) bool {
+			io.WriteString(conn, "* OK IMAP4rev1 ready\r\n")
+			if line, _ := reader.ReadString('\n'); line != "a1 STARTTLS\r\n" {
+				return false
+			}
+			io.WriteString(conn, "* CAPABILITY IMAP4rev1\r\n"+reply+"\r\n")
+			return strings.HasPrefix(reply, "a1 OK")
+		}
+	}
+	postgres := func(reply byte) func(net.Conn, *bufio.Reader) bool {
+		return func(conn net.Conn, reader *bufio.Reader) bool {
+			request := make([]byte, 8)
+			if _, err := io.ReadFull(reader, request); err != nil {
+				return false
+			}
+			if binary.BigEndian.Uint32(request[4:]) != postgresSSLRequest {
+				return false
+			}
+			conn.Write([]byte{reply})
+			return reply == 'S'
+		}
+	}
+
+	tests := []struct {
+		name      string
+		protocol  models.StartTLSProtocol
+		plaintext func(net.Conn, *bufio.Reader) bool
+		wantErr   string
+	}{
+		{"smtp", models.StartTLSSMTP, smtp("STARTTLS"), ""},
+		{"smtp without starttls", models.StartTLSSMTP, smtp("PIPELINING"), "server does not offer STARTTLS"},
+		{"imap", models.StartTLSIMAP, imap("a1 OK Begin TLS negotiation now"), ""},
+		{"imap refused", models.StartTLSIMAP, imap("a1 BAD STARTTLS not available"), "server refused: BAD"},
+		{"postgres", models.StartTLSPostgres, postgres('S'), ""},
+		{"postgres without tls", models.StartTLSPostgres, postgres('N'), "server does not accept TLS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := serveTLS(t, cert, tt.plaintext)
+			cfg := models.TLSCheck{Host: "localhost", Port: port, StartTLS: tt.protocol, CAFile: ca.file, Timeout: 5}
+			result := TLS(context.Background(), &cfg)
+			checkError(t, result.Err, tt.wantErr)
+			if tt.wantErr == "" && result.Status != models.StatusRunning {
+				t.Errorf("status = %s, want running", result.Status)
+			}
+			if tt.wantErr != "" && !strings.Contains(result.Err.Error(), string(tt.protocol)+" STARTTLS failed") {
+				t.Errorf("error = %v, want a STARTTLS failure", result.Err)
+			}
+		})
+	}
+}
+
+func TestCrossedThreshold(t *testing.T) {
+	defaults := &models.TLSCheck{}
+	custom := &models.TLSCheck{ExpiryThresholds: []models.ExpiryThreshold{
+		{Days: 7, Severity: models.SeverityCritical},
+		{Days: 21, Severity: models.SeverityWarning},
+	}}
+
+	tests := []struct {
+		cfg  *models.TLSCheck
+		days int
+		want models.Severity // Empty when no threshold is crossed
+	}{
+		{defaults, 45, ""},
+		{defaults, 30, models.SeverityInfo},
+		{defaults, 14, models.SeverityWarning},
+		{defaults, 3, models.SeverityCritical},
+		{defaults, -2, models.SeverityCritical},
+		{custom, 30, ""},
+		{custom, 21, models.SeverityWarning},
+		{custom, 6, models.SeverityCritical},
+	}
+	for _, tt := range tests {
+		threshold, crossed := tt.cfg.CrossedThreshold(tt.days)
+		if crossed != (tt.want != "") || threshold.Severity != tt.want {
+			t.Errorf("CrossedThreshold(%d) = %v %v, want %q", tt.days, threshold, crossed, tt.want)
+		}
+	}
+}
+
+func TestValidateTLS(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     models.TLSCheck
+		wantErr string
+	}{
+		{"valid", models.TLSCheck{Host: "example.com", StartTLS: models.StartTLSSMTP}, ""},
+		{"no host", models.TLSCheck{Port: 443}, "host is required"},
+		{"bad port", models.TLSCheck{Host: "example.com", Port: 70000}, "invalid port 70000"},
+		{"bad protocol", models.TLSCheck{Host: "example.com", StartTLS: "ftp"}, `unknown STARTTLS protocol "ftp"`},
+		{"negative timeout", models.TLSCheck{Host: "example.com", Timeout: -1}, "must not be negative"},
+		{"zero days", models.TLSCheck{Host: "example.com", ExpiryThresholds: []models.ExpiryThreshold{{Days: 0, Severity: models.SeverityInfo}}}, "at least 1 day"},
+		{"bad severity", models.TLSCheck{Host: "example.com", ExpiryThresholds: []models.ExpiryThreshold{{Days: 5, Severity: "urgent"}}}, `invalid severity "urgent"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkError(t, ValidateTLS(&tt.cfg), tt.wantErr)
+		})
+	}
+}
+
+func TestStartTLSDefaultPort(t *testing.T) {
+	for protocol, want := range map[models.StartTLSProtocol]int{"": 443, "smtp": 587, "imap": 143, "postgres": 5432} {
+		if got := protocol.DefaultPort(); got != want {
+			t.Errorf("%q.DefaultPort() = %d, want %d", protocol, got, want)
+		}
+	}
+}
@@ -0,0 +1,379 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/harungecit/vigilon/internal/database"
+	"github.com/harungecit/vigilon/internal/models"
+)
+
+// RetryPolicy controls how failed deliveries are retried
+type RetryPolicy struct {
+	MaxAttempts int           // Total attempts per channel, including the first one
+	Backoff     time.Duration // Delay before the first retry, doubled after each failure
+	MaxBackoff  time.Duration // Upper bound for the retry delay
+}
+
+// DefaultRetryPolicy is used when no policy is configured
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	Backoff:     10 * time.Second,
+	MaxBackoff:  5 * time.Minute,
+}
+
+const (
+	sendTimeout  = 30 * time.Second // Bounds a single delivery attempt
+	queueTimeout = 10 * time.Second // Longest Dispatch waits for room in a full queue
+	drainTimeout = 15 * time.Second // Longest the dispatcher keeps delivering on shutdown
+)
+
+// Dispatcher fans events out to the registered notification channels
+type Dispatcher struct {
+	db        *database.DB
+	retry     RetryPolicy
+	notifiers []Notifier
+	mu        sync.RWMutex
+	queue     chan *Event
+	wg        sync.WaitGroup
+	done      chan struct{} // Closed once Start has returned
+
+	// Replaced by tests, so they need not wait
+	after     func(time.Duration) <-chan time.Time
+	queueWait time.Duration
+}
+
+// NewDispatcher creates a new notification dispatcher
+func NewDispatcher(db *database.DB, retry RetryPolicy) *Dispatcher {
+	if retry.MaxAttempts <= 0 {
+		retry.MaxAttempts = DefaultRetryPolicy.MaxAttempts
+	}
+	if retry.Backoff <= 0 {
+		retry.Backoff = DefaultRetryPolicy.Backoff
+	}
+	if retry.MaxBackoff <= 0 {
+		retry.MaxBackoff = DefaultRetryPolicy.MaxBackoff
+	}
+
+	return &Dispatcher{
+		db:        db,
+		retry:     retry,
+		queue:     make(chan *Event, 256),
+		done:      make(chan struct{}),
+		after:     time.After,
+		queueWait: queueTimeout,
+	}
+}
+
+// Register adds a notification channel to the registry
+func (d *Dispatcher) Register(n Notifier) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for i, existing := range d.notifiers {
+		if existing.Name() == n.Name() {
+			d.notifiers[i] = n
+			return
+		}
+	}
+	d.notifiers = append(d.notifiers, n)
+	log.Printf("Notification channel registered: %s", n.Name())
+}
+
+// Unregister removes a notification channel from the registry
+func (d *Dispatcher) Unregister(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for i, existing := range d.notifiers {
+		if existing.Name() == name {
+			d.notifiers = append(d.notifiers[:i], d.notifiers[i+1:]...)
+			log.Printf("Notification channel unregistered: %s", name)
+			return
+		}
+	}
+}
+
+// Channels returns the names of all registered channels
+func (d *Dispatcher) Channels() []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	names := make([]string, 0, len(d.notifiers))
+	for _, n := range d.notifiers {
+		names = append(names, n.Name())
+	}
+	return names
+}
+
+// Dispatch queues an event for delivery. It only blocks while the queue is
+// full, for up to queueTimeout; an alert event that still finds no room is
+// recorded as a failed delivery on every channel it was meant for.
+func (d *Dispatcher) Dispatch(event *Event) {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	select {
+	case d.queue <- event:
+		return
+	default:
+	}
+
+	log.Printf("Notification queue full, waiting to queue %s event", event.Type)
+	timer := time.NewTimer(d.queueWait)
+	defer timer.Stop()
+
+	select {
+	case d.queue <- event:
+	case <-timer.C:
+		log.Printf("Notification queue still full, dropping %s event", event.Type)
+		d.drop(event, "notification queue full")
+	}
+}
+
+// Start processes queued events until the context is cancelled. It then
+// delivers the events still queued and waits for running deliveries, for
+// up to drainTimeout, so that a shutdown does not lose notifications.
+func (d *Dispatcher) Start(ctx context.Context) {
+	defer close(d.done)
+	log.Println("Starting notification dispatcher...")
+
+	// Deliveries outlive ctx until the queue is drained
+	sendCtx, cancelSends := context.WithCancel(context.Background())
+	defer cancelSends()
+
+	for {
+		select {
+		case event := <-d.queue:
+			d.deliver(sendCtx, event)
+		case <-ctx.Done():
+			d.drain(sendCtx, cancelSends)
+			return
+		}
+	}
+}
+
+// Wait blocks until Start has returned
+func (d *Dispatcher) Wait() {
+	<-d.done
+}
+
+// drain delivers the queued events and waits for all deliveries. Deliveries
+// still retrying after drainTimeout are cancelled and recorded as failed.
+func (d *Dispatcher) drain(ctx context.Context, cancel context.CancelFunc) {
+	if n := len(d.queue); n > 0 {
+		log.Printf("Delivering %d queued notifications before shutdown", n)
+	}
+	timer := time.AfterFunc(drainTimeout, cancel)
+	defer timer.Stop()
+
+	for {
+		select {
+		case event := <-d.queue:
+			d.deliver(ctx, event)
+		default:
+			d.wg.Wait()
+			return
+		}
+	}
+}
+
+// drop records an alert event that could not be queued as failed on every
+// channel it was meant for
+func (d *Dispatcher) drop(event *Event, reason string) {
+	if event.Alert == nil || event.Alert.ID == 0 {
+		return
+	}
+
+	for _, n := range d.channelsFor(event) {
+		delivery := &models.AlertDelivery{
+			AlertID:   event.Alert.ID,
+			Event:     string(event.Type),
+			Channel:   n.Name(),
+			Status:    models.DeliveryFailed,
+			LastError: reason,
+		}
+		if err := d.db.CreateAlertDelivery(delivery); err != nil {
+			log.Printf("Failed to record delivery for alert %d: %v", event.Alert.ID, err)
+		}
+	}
+	if err := d.db.RefreshAlertSentVia(event.Alert.ID); err != nil {
+		log.Printf("Failed to update alert %d: %v", event.Alert.ID, err)
+	}
+}
+
+// deliver starts one delivery per matching channel
+func (d *Dispatcher) deliver(ctx context.Context, event *Event) {
+	notifiers := d.channelsFor(event)
+
+	if len(notifiers) == 0 {
+		if event.Alert != nil && event.Alert.ID > 0 {
+			if err := d.db.RefreshAlertSentVia(event.Alert.ID); err != nil {
+				log.Printf("Failed to update alert %d: %v", event.Alert.ID, err)
+			}
+		}
+		return
+	}
+
+	for _, n := range notifiers {
+		var delivery *models.AlertDelivery
+		if event.Alert != nil && event.Alert.ID > 0 {
+			delivery = &models.AlertDelivery{
+				AlertID: event.Alert.ID,
+				Event:   string(event.Type),
+				Channel: n.Name(),
+				Status:  models.DeliveryPending,
+			}
+			if err := d.db.CreateAlertDelivery(delivery); err != nil {
+				log.Printf("Failed to record delivery for alert %d: %v", event.Alert.ID, err)
+				delivery = nil
+			}
+		}
+
+		d.wg.Add(1)
+		go func(n Notifier, delivery *models.AlertDelivery) {
+			defer d.wg.Done()
+			d.sendWithRetry(ctx, n, event, delivery)
+		}(n, delivery)
+	}
+}
+
+// channelsFor returns the enabled channels that should receive the event
+func (d *Dispatcher) channelsFor(event *Event) []Notifier {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var result []Notifier
+	for _, n := range d.notifiers {
+		if len(event.Channels) > 0 && !contains(event.Channels, n.Name()) {
+			continue
+		}
+		if !n.Enabled(event.Server) {
+			continue
+		}
+		if f, ok := n.(Filter); ok && !f.Accepts(event) {
+			continue
+		}
+		result = append(result, n)
+	}
+	return result
+}
+
+// sendWithRetry sends an event through one channel, retrying with exponential backoff
+func (d *Dispatcher) sendWithRetry(ctx context.Context, n Notifier, event *Event, delivery *models.AlertDelivery) {
+	retry, timeout := d.policyFor(n)
+	backoff := retry.Backoff
+	var failed []string // Recipients left after a partial failure
+
+	for attempt := 1; attempt <= retry.MaxAttempts; attempt++ {
+		attemptCtx := withAttempt(ctx, attempt)
+		if failed != nil {
+			attemptCtx = withRetryRecipients(attemptCtx, failed)
+		}
+		sendCtx, cancel := context.WithTimeout(attemptCtx, timeout)
+		err := n.Send(sendCtx, event)
+		cancel()
+
+		if err == nil {
+			d.recordDelivery(delivery, attempt, nil)
+			return
+		}
+
+		var partial *PartialError
+		if errors.As(err, &partial) && len(partial.Failed) > 0 {
+			failed = partial.Failed
+		}
+
+		log.Printf("Failed to send %s event via %s (attempt %d/%d): %v",
+			event.Type, n.Name(), attempt, retry.MaxAttempts, err)
+
+		if attempt == retry.MaxAttempts {
+			d.recordDelivery(delivery, attempt, err)
+			return
+		}
+
+		// Keep the attempt count visible while we wait for the next retry
+		if delivery != nil {
+			delivery.Attempts = attempt
+			delivery.LastError = err.Error()
+			if err := d.db.UpdateAlertDelivery(delivery); err != nil {
+				log.Printf("Failed to update delivery %d: %v", delivery.ID, err)
+			}
+		}
+
+		select {
+		case <-d.after(backoff):
+		case <-ctx.Done():
+			d.recordDelivery(delivery, attempt, err)
+			return
+		}
+
+		backoff *= 2
+		if backoff > retry.MaxBackoff {
+			backoff = retry.MaxBackoff
+		}
+	}
+}
+
+// policyFor returns the retry policy and attempt timeout of a channel
+func (d *Dispatcher) policyFor(n Notifier) (RetryPolicy, time.Duration) {
+	retry, timeout := d.retry, sendTimeout
+
+	p, ok := n.(DeliveryPolicy)
+	if !ok {
+		return retry, timeout
+	}
+	if t := p.Timeout(); t > 0 {
+		timeout = t
+	}
+	custom := p.RetryPolicy()
+	if custom.MaxAttempts > 0 {
+		retry.MaxAttempts = custom.MaxAttempts
+	}
+	if custom.Backoff > 0 {
+		retry.Backoff = custom.Backoff
+	}
+	if custom.MaxBackoff > 0 {
+		retry.MaxBackoff = custom.MaxBackoff
+	}
+	return retry, timeout
+}
+
+// recordDelivery stores the final result of a delivery
+func (d *Dispatcher) recordDelivery(delivery *models.AlertDelivery, attempts int, sendErr error) {
+	if delivery == nil {
+		return
+	}
+
+	delivery.Attempts = attempts
+	if sendErr != nil {
+		delivery.Status = models.DeliveryFailed
+		delivery.LastError = sendErr.Error()
+	} else {
+		now := time.Now()
+		delivery.Status = models.DeliverySent
+		delivery.LastError = ""
+		delivery.SentAt = &now
+	}
+
+	if err := d.db.UpdateAlertDelivery(delivery); err != nil {
+		log.Printf("Failed to update delivery %d: %v", delivery.ID, err)
+	}
+	if err := d.db.RefreshAlertSentVia(delivery.AlertID); err != nil {
+		log.Printf("Failed to update alert %d: %v", delivery.AlertID, err)
+	}
+}
+
+// contains reports whether list contains s
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
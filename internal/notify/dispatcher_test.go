@@ -0,0 +1,250 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/harungecit/vigilon/internal/database"
+	"github.com/harungecit/vigilon/internal/models"
+)
+
+// fakeNotifier records what it is asked to send and fails as told
+type fakeNotifier struct {
+	retry RetryPolicy
+	errs  []error // Result of each attempt; later attempts succeed
+
+	mu    sync.Mutex
+	sends []sent
+}
+
+// sent is one call of Send
+type sent struct {
+	event      *Event
+	attempt    int
+	recipients []string // Restricted to on a retry
+}
+
+func (f *fakeNotifier) Name() string                { return "fake" }
+func (f *fakeNotifier) Enabled(*models.Server) bool { return true }
+func (f *fakeNotifier) Timeout() time.Duration      { return 0 }
+func (f *fakeNotifier) RetryPolicy() RetryPolicy    { return f.retry }
+
+func (f *fakeNotifier) Send(ctx context.Context, event *Event) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sends = append(f.sends, sent{event: event, attempt: Attempt(ctx), recipients: RetryRecipients(ctx)})
+	if i := len(f.sends) - 1; i < len(f.errs) {
+		return f.errs[i]
+	}
+	return nil
+}
+
+// newTestDispatcher returns a dispatcher that records its retry delays
+// instead of waiting them out
+func newTestDispatcher(t *testing.T, retry RetryPolicy, n Notifier) (*Dispatcher, *database.DB, func() []time.Duration) {
+	t.Helper()
+	db, err := database.New(filepath.Join(t.TempDir(), "vigilon.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	d := NewDispatcher(db, retry)
+	d.Register(n)
+
+	var mu sync.Mutex
+	var waits []time.Duration
+	d.after = func(wait time.Duration) <-chan time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		waits = append(waits, wait)
+		ch := make(chan time.Time, 1)
+		ch <- time.Now()
+		return ch
+	}
+	return d, db, func() []time.Duration {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(waits)
+	}
+}
+
+// newAlertEvent stores an alert and returns an alert event for it
+func newAlertEvent(t *testing.T, db *database.DB) *Event {
+	t.Helper()
+	server := &models.Server{Name: "web-1", Hostname: "web-1.internal", OS: "linux", MonitoringMode: models.ModePull, Enabled: true}
+	if err := db.CreateServer(server); err != nil {
+		t.Fatal(err)
+	}
+	service := &models.Service{ServerID: server.ID, Name: "nginx.service", Enabled: true}
+	if err := db.CreateService(service); err != nil {
+		t.Fatal(err)
+	}
+	alert := &models.Alert{ServiceID: service.ID, ServerID: server.ID, Status: models.StatusStopped, Message: "nginx stopped"}
+	if err := db.CreateAlert(alert); err != nil {
+		t.Fatal(err)
+	}
+	return &Event{Type: EventAlert, Alert: alert, Server: server, Service: service}
+}
+
+// delivery returns the only delivery of an alert
+func delivery(t *testing.T, db *database.DB, alertID int) models.AlertDelivery {
+	t.Helper()
+	deliveries, err := db.GetAlertDeliveries(alertID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 {
+		t.Fatalf("%d deliveries of alert %d, want 1", len(deliveries), alertID)
+	}
+	return deliveries[0]
+}
+
+func TestRetryBackoff(t *testing.T) {
+	failure := errors.New("connection refused")
+	retry := RetryPolicy{MaxAttempts: 6, Backoff: 10 * time.Second, MaxBackoff: 30 * time.Second}
+
+	tests := []struct {
+		name         string
+		channel      RetryPolicy // The channel's own policy
+		errs         []error
+		wantWaits    []time.Duration
+		wantStatus   models.DeliveryStatus
+		wantAttempts int
+	}{
+		{"first attempt", RetryPolicy{}, nil, nil, models.DeliverySent, 1},
+		{"doubles", RetryPolicy{}, []error{failure, failure}, []time.Duration{10 * time.Second, 20 * time.Second}, models.DeliverySent, 3},
+		{"capped", RetryPolicy{}, slices.Repeat([]error{failure}, 6),
+			[]time.Duration{10 * time.Second, 20 * time.Second, 30 * time.Second, 30 * time.Second, 30 * time.Second}, models.DeliveryFailed, 6},
+		{"channel policy", RetryPolicy{MaxAttempts: 3, Backoff: time.Second}, slices.Repeat([]error{failure}, 3),
+			[]time.Duration{time.Second, 2 * time.Second}, models.DeliveryFailed, 3},
+		{"channel cap", RetryPolicy{MaxBackoff: 15 * time.Second}, slices.Repeat([]error{failure}, 4),
+			[]time.Duration{10 * time.Second, 15 * time.Second, 15 * time.Second, 15 * time.Second}, models.DeliverySent, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &fakeNotifier{retry: tt.channel, errs: tt.errs}
+			d, db, waits := newTestDispatcher(t, retry, n)
+			event := newAlertEvent(t, db)
+
+			d.deliver(context.Background(), event)
+			d.wg.Wait()
+
+			if got := waits(); !slices.Equal(got, tt.wantWaits) {
+				t.Errorf("waits = %v, want %v", got, tt.wantWaits)
+			}
+			got := delivery(t, db, event.Alert.ID)
+			if got.Status != tt.wantStatus || got.Attempts != tt.wantAttempts {
+				t.Errorf("delivery %s after %d attempts, want %s after %d", got.Status, got.Attempts, tt.wantStatus, tt.wantAttempts)
+			}
+			if tt.wantStatus == models.DeliveryFailed && got.LastError != failure.Error() {
+				t.Errorf("last error = %q, want %q", got.LastError, failure)
+			}
+		})
+	}
+}
+
+func TestPartialRetry(t *testing.T) {
+	failure := errors.New("chat not found")
+	partial := func(failed ...string) error { return &PartialError{Failed: failed, Err: failure} }
+
+	tests := []struct {
+		name           string
+		errs           []error
+		wantRecipients [][]string // Per attempt
+	}{
+		{"narrowed", []error{partial("b", "c")}, [][]string{nil, {"b", "c"}}},
+		{"narrowed again", []error{partial("b", "c"), partial("c")}, [][]string{nil, {"b", "c"}, {"c"}}},
+		{"kept after a full failure", []error{partial("b"), failure}, [][]string{nil, {"b"}, {"b"}}},
+		{"full failure", []error{failure}, [][]string{nil, nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &fakeNotifier{errs: tt.errs}
+			d, db, _ := newTestDispatcher(t, RetryPolicy{MaxAttempts: 5}, n)
+			event := newAlertEvent(t, db)
+
+			d.deliver(context.Background(), event)
+			d.wg.Wait()
+
+			if len(n.sends) != len(tt.wantRecipients) {
+				t.Fatalf("%d attempts, want %d", len(n.sends), len(tt.wantRecipients))
+			}
+			for i, s := range n.sends {
+				if s.attempt != i+1 {
+					t.Errorf("send %d: attempt = %d", i+1, s.attempt)
+				}
+				if !slices.Equal(s.recipients, tt.wantRecipients[i]) {
+					t.Errorf("attempt %d: recipients = %v, want %v", i+1, s.recipients, tt.wantRecipients[i])
+				}
+			}
+			if got := delivery(t, db, event.Alert.ID); got.Status != models.DeliverySent {
+				t.Errorf("delivery %s, want sent", got.Status)
+			}
+		})
+	}
+}
+
+func TestDispatchDropsWhenFull(t *testing.T) {
+	n := &fakeNotifier{}
+	d, db, _ := newTestDispatcher(t, RetryPolicy{}, n)
+	d.queueWait = 10 * time.Millisecond
+
+	for i := 0; i < cap(d.queue); i++ {
+		d.Dispatch(&Event{Type: EventReminder})
+	}
+	event := newAlertEvent(t, db)
+	d.Dispatch(event)
+
+	if len(d.queue) != cap(d.queue) {
+		t.Errorf("%d events queued, want %d", len(d.queue), cap(d.queue))
+	}
+	got := delivery(t, db, event.Alert.ID)
+	if got.Channel != "fake" || got.Status != models.DeliveryFailed || got.LastError != "notification queue full" {
+		t.Errorf("delivery = %+v, want failed on fake as the queue is full", got)
+	}
+	if len(n.sends) != 0 {
+		t.Errorf("dropped event sent %d times", len(n.sends))
+	}
+}
+
+func TestDrainOnShutdown(t *testing.T) {
+	// One of the events needs a retry, which still happens on shutdown
+	n := &fakeNotifier{errs: []error{nil, errors.New("timeout")}}
+	d, db, _ := newTestDispatcher(t, RetryPolicy{MaxAttempts: 3}, n)
+
+	events := []*Event{newAlertEvent(t, db)}
+	for i := 0; i < 2; i++ {
+		event := *events[0]
+		event.Alert = &models.Alert{ServiceID: event.Service.ID, ServerID: event.Server.ID, Status: models.StatusStopped}
+		if err := db.CreateAlert(event.Alert); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, &event)
+	}
+	for _, event := range events {
+		d.Dispatch(event)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	d.Start(ctx)
+	d.Wait()
+
+	if len(d.queue) != 0 {
+		t.Errorf("%d events left in the queue", len(d.queue))
+	}
+	if len(n.sends) != 4 {
+		t.Errorf("%d sends, want 4", len(n.sends))
+	}
+	for _, event := range events {
+		if got := delivery(t, db, event.Alert.ID); got.Status != models.DeliverySent {
+			t.Errorf("alert %d: delivery %s, want sent", event.Alert.ID, got.Status)
+		}
+	}
+}
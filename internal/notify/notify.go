@@ -0,0 +1,124 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/harungecit/vigilon/internal/models"
+)
+
+// EventType identifies what happened to trigger a notification
+type EventType string
+
+const (
+	EventAlert      EventType = "alert"      // A service entered a non-running state
+	EventRecovery   EventType = "recovery"   // A service is running again after an alert
+	EventReminder   EventType = "reminder"   // A service is still down after an alert
+	EventEscalation EventType = "escalation" // An alert was not acknowledged in time
+)
+
+// Event is a notification handed to the dispatcher
+type Event struct {
+	Type       EventType
+	Alert      *models.Alert
+	Server     *models.Server
+	Service    *models.Service
+	Check      *models.ServiceCheck
+	Text       string             // Message body; defaults to the alert message
+	Channels   []string           // Restrict delivery to these channels (empty = all enabled channels)
+	Recipients []models.Recipient // Specific recipients; channels without any use their defaults
+	CreatedAt  time.Time
+}
+
+// Message returns the plain text body of the event
+func (e *Event) Message() string {
+	if e.Text != "" {
+		return e.Text
+	}
+	if e.Alert != nil {
+		return e.Alert.Message
+	}
+	return ""
+}
+
+// AddressesFor returns the recipient addresses of the event for a channel.
+// An empty result means the channel should use its configured recipients.
+func (e *Event) AddressesFor(channel string) []string {
+	var addresses []string
+	for _, r := range e.Recipients {
+		if r.Channel == channel && r.Address != "" {
+			addresses = append(addresses, r.Address)
+		}
+	}
+	return addresses
+}
+
+// Notifier is a notification channel (Telegram, email, etc.)
+type Notifier interface {
+	// Name returns the unique channel name recorded on alert deliveries
+	Name() string
+	// Enabled reports whether the channel should notify about the given server
+	Enabled(server *models.Server) bool
+	// Send delivers the event, returning an error if it should be retried
+	Send(ctx context.Context, event *Event) error
+}
+
+// Filter is implemented by channels that only want some events
+type Filter interface {
+	// Accepts reports whether the channel should receive the event
+	Accepts(event *Event) bool
+}
+
+// DeliveryPolicy is implemented by channels with their own timeout and retry
+// policy. Zero values fall back to the dispatcher's settings.
+type DeliveryPolicy interface {
+	Timeout() time.Duration
+	RetryPolicy() RetryPolicy
+}
+
+// PartialError is returned by channels that delivered an event to some of
+// its recipients only. The dispatcher retries the failed recipients alone, so
+// the others do not get the event twice.
+type PartialError struct {
+	Failed []string // Addresses of the recipients that did not get the event
+	Err    error
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("%d recipients failed: %v", len(e.Failed), e.Err)
+}
+
+func (e *PartialError) Unwrap() error {
+	return e.Err
+}
+
+type attemptKey struct{}
+
+type recipientsKey struct{}
+
+// withAttempt returns a context carrying the delivery attempt number
+func withAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attempt)
+}
+
+// Attempt returns the delivery attempt number (starting at 1) passed to Send
+func Attempt(ctx context.Context) int {
+	if attempt, ok := ctx.Value(attemptKey{}).(int); ok {
+		return attempt
+	}
+	return 1
+}
+
+// withRetryRecipients returns a context restricting a retry to the recipients
+// that failed before
+func withRetryRecipients(ctx context.Context, addresses []string) context.Context {
+	return context.WithValue(ctx, recipientsKey{}, addresses)
+}
+
+// RetryRecipients returns the recipients a retry passed to Send is restricted
+// to, or nil if the event goes to all of them
+func RetryRecipients(ctx context.Context) []string {
+	addresses, _ := ctx.Value(recipientsKey{}).([]string)
+	return addresses
+}
@@ -0,0 +1,147 @@
+package oncall
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/harungecit/vigilon/internal/models"
+)
+
+// Shift describes who is on call for a schedule and for how long
+type Shift struct {
+	UserID   int                    `json:"user_id"`
+	Start    time.Time              `json:"start"`
+	End      time.Time              `json:"end"`
+	Override *models.OnCallOverride `json:"override,omitempty"` // Set when an override is in effect
+}
+
+// Validate checks that a schedule is complete and consistent
+func Validate(schedule *models.OnCallSchedule) error {
+	if schedule.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(schedule.UserIDs) == 0 {
+		return fmt.Errorf("at least one user is required")
+	}
+	if schedule.HandoffDay < time.Sunday || schedule.HandoffDay > time.Saturday {
+		return fmt.Errorf("handoff_day must be between 0 (Sunday) and 6 (Saturday)")
+	}
+	if _, _, err := parseHandoffTime(schedule.HandoffTime); err != nil {
+		return err
+	}
+	if _, err := location(schedule); err != nil {
+		return fmt.Errorf("invalid timezone: %w", err)
+	}
+	return nil
+}
+
+// ValidateOverride checks that an override covers a valid time range
+func ValidateOverride(override *models.OnCallOverride) error {
+	if override.UserID == 0 {
+		return fmt.Errorf("user_id is required")
+	}
+	if override.StartsAt.IsZero() || override.EndsAt.IsZero() {
+		return fmt.Errorf("starts_at and ends_at are required")
+	}
+	if !override.EndsAt.After(override.StartsAt) {
+		return fmt.Errorf("ends_at must be after starts_at")
+	}
+	return nil
+}
+
+// At returns the shift that covers t. Overrides win over the rotation; when
+// several overlap, the most recently created one is used.
+func At(schedule *models.OnCallSchedule, t time.Time) (*Shift, error) {
+	if len(schedule.UserIDs) == 0 {
+		return nil, fmt.Errorf("schedule %q has no users", schedule.Name)
+	}
+
+	var override *models.OnCallOverride
+	for i := range schedule.Overrides {
+		o := &schedule.Overrides[i]
+		if t.Before(o.StartsAt) || !t.Before(o.EndsAt) {
+			continue
+		}
+		if override == nil || o.CreatedAt.After(override.CreatedAt) || (o.CreatedAt.Equal(override.CreatedAt) && o.ID > override.ID) {
+			override = o
+		}
+	}
+	if override != nil {
+		return &Shift{UserID: override.UserID, Start: override.StartsAt, End: override.EndsAt, Override: override}, nil
+	}
+
+	loc, err := location(schedule)
+	if err != nil {
+		return nil, err
+	}
+	hour, minute, err := parseHandoffTime(schedule.HandoffTime)
+	if err != nil {
+		return nil, err
+	}
+
+	start := lastHandoff(t.In(loc), schedule.HandoffDay, hour, minute)
+	end := handoffOn(start.AddDate(0, 0, 7), hour, minute)
+
+	// Count whole weeks between the first hand-off and this one on the calendar,
+	// so daylight saving changes do not shift the rotation
+	first := firstHandoff(schedule.RotationStart.In(loc), schedule.HandoffDay, hour, minute)
+	weeks := floorDiv(civilDay(start)-civilDay(first), 7)
+	n := len(schedule.UserIDs)
+	index := ((weeks % n) + n) % n
+
+	return &Shift{UserID: schedule.UserIDs[index], Start: start, End: end}, nil
+}
+
+// lastHandoff returns the most recent hand-off at or before t
+func lastHandoff(t time.Time, day time.Weekday, hour, minute int) time.Time {
+	back := (int(t.Weekday()) - int(day) + 7) % 7
+	h := handoffOn(t.AddDate(0, 0, -back), hour, minute)
+	if h.After(t) {
+		h = handoffOn(h.AddDate(0, 0, -7), hour, minute)
+	}
+	return h
+}
+
+// firstHandoff returns the first hand-off at or after t
+func firstHandoff(t time.Time, day time.Weekday, hour, minute int) time.Time {
+	h := lastHandoff(t, day, hour, minute)
+	if h.Before(t) {
+		h = handoffOn(h.AddDate(0, 0, 7), hour, minute)
+	}
+	return h
+}
+
+// handoffOn returns the hand-off time on the calendar day of t
+func handoffOn(t time.Time, hour, minute int) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), hour, minute, 0, 0, t.Location())
+}
+
+// civilDay returns the number of calendar days since the Unix epoch
+func civilDay(t time.Time) int {
+	return int(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+// parseHandoffTime parses an "HH:MM" hand-off time
+func parseHandoffTime(s string) (int, int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, 0, fmt.Errorf("handoff_time must be HH:MM")
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+// location returns the time zone of a schedule
+func location(schedule *models.OnCallSchedule) (*time.Location, error) {
+	if schedule.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(schedule.Timezone)
+}
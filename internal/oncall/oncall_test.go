@@ -0,0 +1,132 @@
+package oncall
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harungecit/vigilon/internal/models"
+)
+
+func TestAt(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := func(s string) time.Time {
+		t, err := time.ParseInLocation(time.DateTime, s, berlin)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+
+	// Weekly from Monday 2 March 09:00; clocks move forward on 29 March
+	rotation := &models.OnCallSchedule{
+		Name:          "ops",
+		Timezone:      "Europe/Berlin",
+		HandoffDay:    time.Monday,
+		HandoffTime:   "09:00",
+		RotationStart: local("2026-03-02 00:00:00"),
+		UserIDs:       []int{1, 2, 3},
+	}
+	created := local("2026-03-01 12:00:00")
+	withOverrides := *rotation
+	withOverrides.Overrides = []models.OnCallOverride{
+		// From Sunday evening over Monday's hand-off
+		{ID: 1, UserID: 9, StartsAt: local("2026-03-08 20:00:00"), EndsAt: local("2026-03-10 08:00:00"), CreatedAt: created},
+		// Created later, so it wins where they overlap
+		{ID: 2, UserID: 8, StartsAt: local("2026-03-09 08:00:00"), EndsAt: local("2026-03-09 12:00:00"), CreatedAt: created.Add(time.Hour)},
+		// Same creation time: the later ID wins
+		{ID: 4, UserID: 7, StartsAt: local("2026-03-20 00:00:00"), EndsAt: local("2026-03-21 00:00:00"), CreatedAt: created},
+		{ID: 3, UserID: 6, StartsAt: local("2026-03-20 00:00:00"), EndsAt: local("2026-03-21 00:00:00"), CreatedAt: created},
+	}
+
+	tests := []struct {
+		name         string
+		schedule     *models.OnCallSchedule
+		at           time.Time
+		wantUser     int
+		wantStart    string
+		wantEnd      string
+		wantOverride int // ID of the override in effect
+	}{
+		{"before the rotation starts", rotation, local("2026-03-02 08:59:59"), 3, "2026-02-23 09:00:00", "2026-03-02 09:00:00", 0},
+		{"first hand-off", rotation, local("2026-03-02 09:00:00"), 1, "2026-03-02 09:00:00", "2026-03-09 09:00:00", 0},
+		{"end of the first shift", rotation, local("2026-03-09 08:59:59"), 1, "2026-03-02 09:00:00", "2026-03-09 09:00:00", 0},
+		{"second hand-off", rotation, local("2026-03-09 09:00:00"), 2, "2026-03-09 09:00:00", "2026-03-16 09:00:00", 0},
+		{"third shift", rotation, local("2026-03-20 12:00:00"), 3, "2026-03-16 09:00:00", "2026-03-23 09:00:00", 0},
+		{"rotation wraps", rotation, local("2026-03-23 09:00:00"), 1, "2026-03-23 09:00:00", "2026-03-30 09:00:00", 0},
+		{"shift over the clock change", rotation, local("2026-03-29 12:00:00"), 1, "2026-03-23 09:00:00", "2026-03-30 09:00:00", 0},
+		// 09:00 in Berlin is 07:00 UTC after the clock change
+		{"hand-off after the clock change", rotation, time.Date(2026, 3, 30, 7, 0, 0, 0, time.UTC), 2, "2026-03-30 09:00:00", "2026-04-06 09:00:00", 0},
+		{"just before it in UTC", rotation, time.Date(2026, 3, 30, 6, 59, 59, 0, time.UTC), 1, "2026-03-23 09:00:00", "2026-03-30 09:00:00", 0},
+		{"months later", rotation, local("2026-10-26 09:00:00"), 2, "2026-10-26 09:00:00", "2026-11-02 09:00:00", 0},
+
+		{"before an override", &withOverrides, local("2026-03-08 19:59:59"), 1, "2026-03-02 09:00:00", "2026-03-09 09:00:00", 0},
+		{"override starts", &withOverrides, local("2026-03-08 20:00:00"), 9, "2026-03-08 20:00:00", "2026-03-10 08:00:00", 1},
+		{"newer override wins", &withOverrides, local("2026-03-09 09:00:00"), 8, "2026-03-09 08:00:00", "2026-03-09 12:00:00", 2},
+		{"override over the hand-off", &withOverrides, local("2026-03-09 12:00:00"), 9, "2026-03-08 20:00:00", "2026-03-10 08:00:00", 1},
+		{"rotation after the override", &withOverrides, local("2026-03-10 08:00:00"), 2, "2026-03-09 09:00:00", "2026-03-16 09:00:00", 0},
+		{"later ID wins a tie", &withOverrides, local("2026-03-20 12:00:00"), 7, "2026-03-20 00:00:00", "2026-03-21 00:00:00", 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shift, err := At(tt.schedule, tt.at)
+			if err != nil {
+				t.Fatalf("At: %v", err)
+			}
+			if shift.UserID != tt.wantUser {
+				t.Errorf("user = %d, want %d", shift.UserID, tt.wantUser)
+			}
+			if !shift.Start.Equal(local(tt.wantStart)) || !shift.End.Equal(local(tt.wantEnd)) {
+				t.Errorf("shift = %s - %s, want %s - %s", shift.Start.In(berlin).Format(time.DateTime),
+					shift.End.In(berlin).Format(time.DateTime), tt.wantStart, tt.wantEnd)
+			}
+			override := 0
+			if shift.Override != nil {
+				override = shift.Override.ID
+			}
+			if override != tt.wantOverride {
+				t.Errorf("override = %d, want %d", override, tt.wantOverride)
+			}
+		})
+	}
+
+	if _, err := At(&models.OnCallSchedule{Name: "empty"}, time.Now()); err == nil || !strings.Contains(err.Error(), "has no users") {
+		t.Errorf("schedule without users: error = %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := models.OnCallSchedule{Name: "ops", UserIDs: []int{1}, HandoffDay: time.Monday, HandoffTime: "09:00", Timezone: "Europe/Istanbul"}
+	with := func(change func(*models.OnCallSchedule)) models.OnCallSchedule {
+		s := valid
+		change(&s)
+		return s
+	}
+
+	tests := []struct {
+		name     string
+		schedule models.OnCallSchedule
+		wantErr  string
+	}{
+		{"valid", valid, ""},
+		{"no name", with(func(s *models.OnCallSchedule) { s.Name = "" }), "name is required"},
+		{"no users", with(func(s *models.OnCallSchedule) { s.UserIDs = nil }), "at least one user is required"},
+		{"invalid day", with(func(s *models.OnCallSchedule) { s.HandoffDay = 7 }), "handoff_day must be between"},
+		{"invalid time", with(func(s *models.OnCallSchedule) { s.HandoffTime = "9am" }), "handoff_time must be HH:MM"},
+		{"unknown time zone", with(func(s *models.OnCallSchedule) { s.Timezone = "Mars/Olympus" }), "invalid timezone"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(&tt.schedule)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
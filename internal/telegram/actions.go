@@ -0,0 +1,200 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/harungecit/vigilon/internal/control"
+	"github.com/harungecit/vigilon/internal/models"
+	tele "gopkg.in/telebot.v3"
+)
+
+// confirmTimeout is how long a confirmation button stays valid
+const confirmTimeout = 2 * time.Minute
+
+// actionTimeout bounds a remote check or restart, including the agent round trip
+const actionTimeout = 90 * time.Second
+
+// maxActionRows limits the service rows per /status page; Telegram allows 100 buttons
+const maxActionRows = 40
+
+// Callback endpoints of the remote action buttons
+var (
+	btnCheck   = &tele.Btn{Unique: "check"}
+	btnRestart = &tele.Btn{Unique: "restart"}
+	btnConfirm = &tele.Btn{Unique: "confirm"}
+	btnCancel  = &tele.Btn{Unique: "cancel"}
+)
+
+// actionPermissions maps each action to the permission it requires
+var actionPermissions = map[models.ServiceAction]string{
+	models.ActionCheck:   "services.view",
+	models.ActionRestart: "services.control",
+}
+
+// SetController enables the re-check and restart buttons. It must be called
+// before Start.
+func (n *Notifier) SetController(controller *control.Controller) {
+	n.controller = controller
+}
+
+// serviceActionRow returns the re-check and restart buttons of a service
+func serviceActionRow(markup *tele.ReplyMarkup, server *models.Server, service *models.Service, status models.ServiceStatus) tele.Row {
+	id := strconv.Itoa(service.ID)
+	row := tele.Row{
+		markup.Data(fmt.Sprintf("🔄 %s %s/%s", getStatusIcon(status), server.Name, service.DisplayName), btnCheck.Unique, id),
+	}
+	// Endpoints have nothing to restart
+	if !service.IsEndpoint() {
+		row = append(row, markup.Data("♻️ Restart", btnRestart.Unique, id))
+	}
+	return row
+}
+
+// handleActionButton asks the user to confirm a re-check or restart
+func (n *Notifier) handleActionButton(action models.ServiceAction) tele.HandlerFunc {
+	return func(c tele.Context) error {
+		if n.controller == nil {
+			return c.Respond(&tele.CallbackResponse{Text: "❌ Remote actions are not available", ShowAlert: true})
+		}
+
+		serviceID, err := strconv.Atoi(c.Data())
+		if err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "❌ Invalid service", ShowAlert: true})
+		}
+		server, service, err := n.lookupService(serviceID)
+		if err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "❌ " + err.Error(), ShowAlert: true})
+		}
+
+		markup := &tele.ReplyMarkup{}
+		markup.Inline(tele.Row{
+			markup.Data("✅ Confirm", btnConfirm.Unique, string(action), strconv.Itoa(service.ID),
+				strconv.FormatInt(c.Sender().ID, 10), strconv.FormatInt(time.Now().Unix(), 10)),
+			markup.Data("✖ Cancel", btnCancel.Unique, strconv.FormatInt(c.Sender().ID, 10)),
+		})
+
+		if err := c.Respond(); err != nil {
+			return err
+		}
+		return c.Send(fmt.Sprintf("%s %s on %s?\nOnly %s can confirm, within %d minutes.",
+			actionVerb(action), service.Name, server.Name, actor(c), int(confirmTimeout.Minutes())), markup)
+	}
+}
+
+// handleConfirmButton runs a confirmed action. Only the user who asked for it
+// can confirm, and only for a short while.
+func (n *Notifier) handleConfirmButton(c tele.Context) error {
+	args := c.Args()
+	if len(args) != 4 || n.controller == nil {
+		return c.Respond(&tele.CallbackResponse{Text: "❌ Invalid request", ShowAlert: true})
+	}
+
+	action := models.ServiceAction(args[0])
+	permission, ok := actionPermissions[action]
+	serviceID, err := strconv.Atoi(args[1])
+	if !ok || err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "❌ Invalid request", ShowAlert: true})
+	}
+	if args[2] != strconv.FormatInt(c.Sender().ID, 10) {
+		return c.Respond(&tele.CallbackResponse{Text: "⛔ Only the user who asked can confirm", ShowAlert: true})
+	}
+	requestedAt, _ := strconv.ParseInt(args[3], 10, 64)
+	if time.Since(time.Unix(requestedAt, 0)) > confirmTimeout {
+		c.Respond(&tele.CallbackResponse{Text: "⌛ This request has expired", ShowAlert: true})
+		return c.Edit("⌛ Request expired")
+	}
+
+	// Permissions may have changed since the first tap
+	user, err := n.authorize(c, permission)
+	if err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "⛔ " + err.Error(), ShowAlert: true})
+	}
+	server, service, err := n.lookupService(serviceID)
+	if err != nil {
+		// The service was deleted since the first tap: Run rejects and audits it
+		if _, runErr := n.controller.Run(context.Background(), action, serviceID, user.Username, "telegram"); runErr != nil {
+			err = runErr
+		}
+		return c.Respond(&tele.CallbackResponse{Text: "❌ " + err.Error(), ShowAlert: true})
+	}
+
+	if err := c.Respond(&tele.CallbackResponse{Text: "Working on it…"}); err != nil {
+		return err
+	}
+	if err := c.Edit(fmt.Sprintf("⏳ %s %s on %s…", actionProgress(action), service.Name, server.Name)); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), actionTimeout)
+	defer cancel()
+	result, err := n.controller.Run(ctx, action, service.ID, user.Username, "telegram")
+
+	return c.Edit(actionResultMessage(action, server, service, user.Username, result, err))
+}
+
+// handleCancelButton drops a pending confirmation
+func (n *Notifier) handleCancelButton(c tele.Context) error {
+	if c.Data() != strconv.FormatInt(c.Sender().ID, 10) {
+		return c.Respond(&tele.CallbackResponse{Text: "⛔ Only the user who asked can cancel", ShowAlert: true})
+	}
+	if err := c.Respond(); err != nil {
+		return err
+	}
+	return c.Edit("✖ Cancelled")
+}
+
+// lookupService loads a service and its server
+func (n *Notifier) lookupService(serviceID int) (*models.Server, *models.Service, error) {
+	service, err := n.db.GetService(serviceID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("service not found")
+	}
+	server, err := n.db.GetServer(service.ServerID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("server not found")
+	}
+	return server, service, nil
+}
+
+// actionResultMessage describes the outcome of an action
+func actionResultMessage(action models.ServiceAction, server *models.Server, service *models.Service, by string, result *control.Result, err error) string {
+	if err != nil {
+		return fmt.Sprintf("❌ %s of %s on %s failed: %v\nRequested by %s", actionNoun(action), service.Name, server.Name, err, by)
+	}
+
+	message := fmt.Sprintf("✅ %s of %s on %s done\n", actionNoun(action), service.Name, server.Name)
+	if result.Check != nil {
+		message += fmt.Sprintf("Status: %s %s\n", getStatusIcon(result.Check.Status), result.Check.Status)
+		if result.Check.ErrorMessage != "" {
+			message += "Error: " + result.Check.ErrorMessage + "\n"
+		}
+	}
+	return message + fmt.Sprintf("Requested by %s at %s", by, time.Now().Format("2006-01-02 15:04"))
+}
+
+// actionVerb returns the imperative form of an action, e.g. "Restart"
+func actionVerb(action models.ServiceAction) string {
+	if action == models.ActionRestart {
+		return "♻️ Restart"
+	}
+	return "🔄 Re-check"
+}
+
+// actionProgress returns the progressive form of an action, e.g. "Restarting"
+func actionProgress(action models.ServiceAction) string {
+	if action == models.ActionRestart {
+		return "Restarting"
+	}
+	return "Checking"
+}
+
+// actionNoun returns the noun form of an action, e.g. "Restart"
+func actionNoun(action models.ServiceAction) string {
+	if action == models.ActionRestart {
+		return "Restart"
+	}
+	return "Re-check"
+}
@@ -0,0 +1,177 @@
+package telegram
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/harungecit/vigilon/internal/maintenance"
+	"github.com/harungecit/vigilon/internal/models"
+	tele "gopkg.in/telebot.v3"
+)
+
+// silenceDuration is how long the Silence button mutes a service
+const silenceDuration = time.Hour
+
+// Callback endpoints of the buttons attached to alert messages
+var (
+	btnAck     = &tele.Btn{Unique: "ack"}
+	btnSilence = &tele.Btn{Unique: "silence"}
+)
+
+// alertMarkup returns the inline keyboard attached to alert messages
+func (n *Notifier) alertMarkup(alert *models.Alert) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+	id := strconv.Itoa(alert.ID)
+
+	row := tele.Row{markup.Data("🔕 Silence 1h", btnSilence.Unique, id)}
+	if !alert.Acknowledged {
+		row = append(tele.Row{markup.Data("✅ Acknowledge", btnAck.Unique, id)}, row...)
+	}
+	if n.publicURL != "" && alert.ServerID > 0 {
+		row = append(row, markup.URL("🔗 Open in UI", fmt.Sprintf("%s/server/%d", n.publicURL, alert.ServerID)))
+	}
+
+	markup.Inline(row)
+	return markup
+}
+
+// handleAckButton acknowledges the alert of the message and notes who did it
+func (n *Notifier) handleAckButton(c tele.Context) error {
+	alert, err := n.callbackAlert(c)
+	if err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: err.Error(), ShowAlert: true})
+	}
+
+	if !alert.Acknowledged {
+		if err := n.db.AcknowledgeAlert(alert.ID, actor(c)); err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "❌ Failed to acknowledge alert", ShowAlert: true})
+		}
+		if alert, err = n.db.GetAlert(alert.ID); err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "❌ Alert not found", ShowAlert: true})
+		}
+	}
+
+	// The alert may already have been acknowledged elsewhere, e.g. in the web UI
+	acknowledgedAt := time.Now()
+	if alert.AcknowledgedAt != nil {
+		acknowledgedAt = *alert.AcknowledgedAt
+	}
+	by := alert.AcknowledgedBy
+	if by == "" {
+		by = "unknown"
+	}
+
+	if err := c.Respond(&tele.CallbackResponse{Text: "Alert acknowledged"}); err != nil {
+		return err
+	}
+	return appendStatus(c, btnAck.Unique, fmt.Sprintf("✅ Acknowledged by %s at %s",
+		by, acknowledgedAt.Format("2006-01-02 15:04")))
+}
+
+// handleSilenceButton silences the service of the alert for silenceDuration
+func (n *Notifier) handleSilenceButton(c tele.Context) error {
+	alert, err := n.callbackAlert(c)
+	if err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: err.Error(), ShowAlert: true})
+	}
+
+	server, err := n.db.GetServer(alert.ServerID)
+	if err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "❌ Server not found", ShowAlert: true})
+	}
+	service, err := n.db.GetService(alert.ServiceID)
+	if err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "❌ Service not found", ShowAlert: true})
+	}
+
+	by := actor(c)
+	start := time.Now()
+	end := start.Add(silenceDuration)
+	window := &models.MaintenanceWindow{
+		Name:      "Silence " + server.Name + "/" + service.Name,
+		Reason:    fmt.Sprintf("Silenced from alert #%d", alert.ID),
+		Scope:     models.ScopeService,
+		ServerID:  server.ID,
+		ServiceID: service.ID,
+		StartsAt:  &start,
+		EndsAt:    &end,
+		CreatedBy: by,
+	}
+	if err := maintenance.Validate(window); err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "❌ " + err.Error(), ShowAlert: true})
+	}
+	if err := n.db.CreateMaintenanceWindow(window); err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "❌ Failed to create silence", ShowAlert: true})
+	}
+
+	if err := c.Respond(&tele.CallbackResponse{Text: "Silenced for 1h"}); err != nil {
+		return err
+	}
+	return appendStatus(c, btnSilence.Unique, fmt.Sprintf("🔕 Silenced by %s until %s (/unmute %d)",
+		by, end.Format("2006-01-02 15:04"), window.ID))
+}
+
+// callbackAlert loads the alert referenced by a button callback
+func (n *Notifier) callbackAlert(c tele.Context) (*models.Alert, error) {
+	id, err := strconv.Atoi(c.Data())
+	if err != nil {
+		return nil, fmt.Errorf("❌ Invalid alert id")
+	}
+	alert, err := n.db.GetAlert(id)
+	if err != nil {
+		return nil, fmt.Errorf("❌ Alert not found")
+	}
+	return alert, nil
+}
+
+// appendStatus edits the callback's message to add a status line and removes
+// the button that was used. The original formatting is kept by re-sending the
+// message entities, which stay valid because text is only appended.
+func appendStatus(c tele.Context, unique, status string) error {
+	msg := c.Message()
+	if msg == nil {
+		return nil
+	}
+	return c.Edit(msg.Text+"\n\n"+status, &tele.SendOptions{
+		Entities:    msg.Entities,
+		ReplyMarkup: withoutButton(msg.ReplyMarkup, unique),
+	})
+}
+
+// withoutButton returns a copy of an inline keyboard without the buttons of a
+// callback endpoint
+func withoutButton(markup *tele.ReplyMarkup, unique string) *tele.ReplyMarkup {
+	result := &tele.ReplyMarkup{}
+	if markup == nil {
+		return result
+	}
+
+	prefix := "\f" + unique + "|"
+	for _, row := range markup.InlineKeyboard {
+		var kept []tele.InlineButton
+		for _, btn := range row {
+			if !strings.HasPrefix(btn.Data, prefix) {
+				kept = append(kept, btn)
+			}
+		}
+		if len(kept) > 0 {
+			result.InlineKeyboard = append(result.InlineKeyboard, kept)
+		}
+	}
+	return result
+}
+
+// senderName identifies a Telegram user in audit fields, e.g. "telegram:@alice"
+func senderName(sender *tele.User) string {
+	switch {
+	case sender == nil:
+		return "telegram"
+	case sender.Username != "":
+		return "telegram:@" + sender.Username
+	case sender.FirstName != "":
+		return "telegram:" + strings.TrimSpace(sender.FirstName+" "+sender.LastName)
+	}
+	return "telegram:" + strconv.FormatInt(sender.ID, 10)
+}
@@ -0,0 +1,118 @@
+package telegram
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+
+	"github.com/harungecit/vigilon/internal/models"
+	"github.com/harungecit/vigilon/internal/notify"
+	tele "gopkg.in/telebot.v3"
+)
+
+// userKey is the context key of the Vigilon user running a command
+const userKey = "user"
+
+// require returns middleware that only runs a handler for linked Vigilon
+// users holding permission, in chats that are allowed to use the bot
+func (n *Notifier) require(permission string) tele.MiddlewareFunc {
+	return func(next tele.HandlerFunc) tele.HandlerFunc {
+		return func(c tele.Context) error {
+			user, err := n.authorize(c, permission)
+			if err != nil {
+				log.Printf("Telegram: denied %q in chat %d: %v", c.Text(), chatID(c), err)
+				if c.Callback() != nil {
+					return c.Respond(&tele.CallbackResponse{Text: "⛔ " + err.Error(), ShowAlert: true})
+				}
+				return c.Send("⛔ " + err.Error())
+			}
+			c.Set(userKey, user)
+			return next(c)
+		}
+	}
+}
+
+// authorize returns the Vigilon user linked to the sender if the chat is
+// allowed and the user's role grants permission. Private chats only need a
+// linked user; groups must also be on the allow-list.
+func (n *Notifier) authorize(c tele.Context, permission string) (*models.User, error) {
+	chat, sender := c.Chat(), c.Sender()
+	if chat == nil || sender == nil {
+		return nil, fmt.Errorf("not authorized")
+	}
+	if chat.Type != tele.ChatPrivate && !n.chatAllowed(strconv.FormatInt(chat.ID, 10)) {
+		return nil, fmt.Errorf("this chat is not allowed to use the bot (chat ID %d)", chat.ID)
+	}
+
+	user, err := n.db.GetUserByContactMethod("telegram", strconv.FormatInt(sender.ID, 10))
+	if err != nil || !user.Enabled {
+		return nil, fmt.Errorf("your Telegram account (ID %d) is not linked to a Vigilon user", sender.ID)
+	}
+
+	if user.Role != nil && user.Role.IsSuperAdmin {
+		return user, nil
+	}
+	ok, err := n.db.UserHasPermission(user.ID, permission)
+	if err != nil || !ok {
+		return nil, fmt.Errorf("you need the %s permission", permission)
+	}
+	return user, nil
+}
+
+// chatAllowed reports whether a group chat may use the bot: it is one of the
+// configured chats or an enabled chat on the allow-list
+func (n *Notifier) chatAllowed(chatID string) bool {
+	if chat, err := n.db.GetTelegramChatByChatID(chatID); err == nil {
+		return chat.Enabled
+	}
+	return slices.Contains(n.config.ChatIDs, chatID)
+}
+
+// recipients returns the chats that should receive an event: the configured
+// chats plus every enabled chat subscribed to the event's server and
+// severity. A chat on the allow-list follows its subscription even when it
+// is also configured.
+func (n *Notifier) recipients(event *notify.Event) []string {
+	chats, err := n.db.GetTelegramChats()
+	if err != nil {
+		log.Printf("Failed to get Telegram chats: %v", err)
+		return n.config.ChatIDs
+	}
+
+	var severity models.Severity
+	if event.Alert != nil {
+		severity = event.Alert.Severity()
+	}
+
+	listed := make(map[string]bool)
+	var chatIDs []string
+	for _, chat := range chats {
+		listed[chat.ChatID] = true
+		if chat.Enabled && chat.Subscribes(event.Server, severity) {
+			chatIDs = append(chatIDs, chat.ChatID)
+		}
+	}
+	for _, chatID := range n.config.ChatIDs {
+		if !listed[chatID] && !slices.Contains(chatIDs, chatID) {
+			chatIDs = append(chatIDs, chatID)
+		}
+	}
+	return chatIDs
+}
+
+// actor returns the username of the Vigilon user running a command
+func actor(c tele.Context) string {
+	if user, ok := c.Get(userKey).(*models.User); ok {
+		return user.Username
+	}
+	return senderName(c.Sender())
+}
+
+// chatID returns the ID of the chat of c, or 0 if there is none
+func chatID(c tele.Context) int64 {
+	if chat := c.Chat(); chat != nil {
+		return chat.ID
+	}
+	return 0
+}
@@ -0,0 +1,126 @@
+package telegram
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf16"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// maxPageLength is the size budget of one message page in UTF-16 code units.
+// Telegram allows 4096 after entity parsing; the escaped text is always
+// longer than that, so staying below the budget keeps a margin for the footer.
+const maxPageLength = 3800
+
+// markdownReplacer escapes every character MarkdownV2 treats as markup
+var markdownReplacer = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+	"~", `\~`, "`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`,
+	"|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+// escapeMarkdown escapes s for use as plain text in a MarkdownV2 message
+func escapeMarkdown(s string) string {
+	return markdownReplacer.Replace(s)
+}
+
+// bold returns s escaped and in bold
+func bold(s string) string {
+	return "*" + escapeMarkdown(s) + "*"
+}
+
+// italic returns s escaped and in italics
+func italic(s string) string {
+	return "_" + escapeMarkdown(s) + "_"
+}
+
+// mdf formats a MarkdownV2 line, escaping the arguments but not the format.
+// The format itself must already be valid MarkdownV2.
+func mdf(format string, args ...interface{}) string {
+	escaped := make([]interface{}, len(args))
+	for i, arg := range args {
+		escaped[i] = escapeMarkdown(fmt.Sprint(arg))
+	}
+	return fmt.Sprintf(format, escaped...)
+}
+
+// block is one item of a paginated message, in MarkdownV2, with the buttons
+// that belong to it. Consecutive blocks with the same heading are shown under
+// it, and the heading is repeated when a page starts in the middle of them.
+type block struct {
+	heading string
+	text    string
+	rows    []tele.Row
+}
+
+// page is one message of a paginated output
+type page struct {
+	text string
+	rows []tele.Row
+}
+
+// paginate lays blocks out under a title, starting a new page whenever the
+// text would pass maxPageLength or the buttons maxActionRows. A block never
+// spans two pages, so blocks must be shorter than a page on their own.
+func paginate(title string, blocks []block) []page {
+	var pages []page
+	var current []block
+	rows := 0
+
+	for _, b := range blocks {
+		if len(current) > 0 {
+			next := append(current[:len(current):len(current)], b)
+			if textLength(renderBlocks(title, next)) > maxPageLength || rows+len(b.rows) > maxActionRows {
+				pages = append(pages, newPage(title, current))
+				current, rows = nil, 0
+			}
+		}
+		current = append(current, b)
+		rows += len(b.rows)
+	}
+	return append(pages, newPage(title, current))
+}
+
+// newPage renders the blocks of one page
+func newPage(title string, blocks []block) page {
+	var rows []tele.Row
+	for _, b := range blocks {
+		rows = append(rows, b.rows...)
+	}
+	return page{text: renderBlocks(title, blocks), rows: rows}
+}
+
+// renderBlocks joins a title and blocks into the text of a page
+func renderBlocks(title string, blocks []block) string {
+	var sb strings.Builder
+	sb.WriteString(title + "\n")
+
+	heading := ""
+	for _, b := range blocks {
+		switch {
+		case b.heading == "":
+			sb.WriteString("\n")
+		case b.heading != heading:
+			sb.WriteString("\n" + b.heading + "\n")
+		}
+		heading = b.heading
+		sb.WriteString(b.text + "\n")
+	}
+	return sb.String()
+}
+
+// textLength returns the length of s as Telegram counts it
+func textLength(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
+// truncate shortens s to at most max characters. Use it on plain text before
+// escaping so a cut never splits an escape sequence.
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-1]) + "…"
+}
@@ -2,26 +2,36 @@ package telegram
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/harungecit/vigilon/internal/control"
 	"github.com/harungecit/vigilon/internal/database"
+	"github.com/harungecit/vigilon/internal/maintenance"
 	"github.com/harungecit/vigilon/internal/models"
+	"github.com/harungecit/vigilon/internal/notify"
 	tele "gopkg.in/telebot.v3"
 )
 
 // Notifier handles Telegram notifications
 type Notifier struct {
-	bot    *tele.Bot
-	config *models.TelegramConfig
-	db     *database.DB
+	bot        *tele.Bot
+	config     *models.TelegramConfig
+	db         *database.DB
+	publicURL  string
+	controller *control.Controller
 }
 
-// New creates a new Telegram notifier
-func New(config *models.TelegramConfig, db *database.DB) (*Notifier, error) {
+// New creates a new Telegram notifier. publicURL is the base URL of the web
+// UI used for the "Open in UI" button; leave it empty to omit the button.
+func New(config *models.TelegramConfig, db *database.DB, publicURL string) (*Notifier, error) {
+	publicURL = strings.TrimRight(publicURL, "/")
 	if !config.Enabled || config.BotToken == "" {
-		return &Notifier{config: config, db: db}, nil
+		return &Notifier{config: config, db: db, publicURL: publicURL}, nil
 	}
 
 	pref := tele.Settings{
@@ -35,9 +45,10 @@ func New(config *models.TelegramConfig, db *database.DB) (*Notifier, error) {
 	}
 
 	notifier := &Notifier{
-		bot:    bot,
-		config: config,
-		db:     db,
+		bot:       bot,
+		config:    config,
+		db:        db,
+		publicURL: publicURL,
 	}
 
 	// Set up command handlers
@@ -61,42 +72,70 @@ func (n *Notifier) Start(ctx context.Context) {
 	n.bot.Stop()
 }
 
-// SendAlert sends an alert message to all configured chat IDs
-func (n *Notifier) SendAlert(alert *models.Alert) error {
+// Name implements notify.Notifier
+func (n *Notifier) Name() string {
+	return "telegram"
+}
+
+// Enabled implements notify.Notifier and honours the server's Telegram setting
+func (n *Notifier) Enabled(server *models.Server) bool {
 	if n.bot == nil || !n.config.Enabled {
-		return nil
+		return false
 	}
+	return server == nil || server.NotifyTelegram
+}
 
-	for _, chatID := range n.config.ChatIDs {
-		recipient := &tele.Chat{ID: parseInt64(chatID)}
-		_, err := n.bot.Send(recipient, alert.Message, &tele.SendOptions{
-			ParseMode: "Markdown",
-		})
-		if err != nil {
-			log.Printf("Failed to send alert to chat %s: %v", chatID, err)
-			continue
-		}
+// Send implements notify.Notifier. Events addressed to specific chats (e.g.
+// escalations) go to those chats, everything else to the configured chats and
+// the chats subscribed to the event. A retry only goes to the chats that
+// failed before.
+func (n *Notifier) Send(ctx context.Context, event *notify.Event) error {
+	chatIDs := notify.RetryRecipients(ctx)
+	if len(chatIDs) == 0 {
+		chatIDs = event.AddressesFor(n.Name())
+	}
+	if len(chatIDs) == 0 {
+		chatIDs = n.recipients(event)
 	}
 
-	return nil
+	if event.Type == notify.EventAlert && event.Alert != nil {
+		return n.send(chatIDs, escapeMarkdown(event.Alert.Message), &tele.SendOptions{
+			ParseMode:   tele.ModeMarkdownV2,
+			ReplyMarkup: n.alertMarkup(event.Alert),
+		})
+	}
+	if event.Type != notify.EventRecovery && event.Alert != nil && event.Alert.ID > 0 {
+		// Reminders and escalations can be acted on like the original alert
+		return n.send(chatIDs, event.Message(), &tele.SendOptions{ReplyMarkup: n.alertMarkup(event.Alert)})
+	}
+	return n.send(chatIDs, event.Message())
 }
 
-// SendMessage sends a custom message to all configured chat IDs
-func (n *Notifier) SendMessage(message string) error {
+// send delivers a message to the given chats, joining per-chat failures. If
+// only some chats failed, the error is a notify.PartialError listing them.
+func (n *Notifier) send(chatIDs []string, message string, opts ...interface{}) error {
 	if n.bot == nil || !n.config.Enabled {
 		return nil
 	}
 
-	for _, chatID := range n.config.ChatIDs {
+	var errs []error
+	var failed []string
+	for _, chatID := range chatIDs {
 		recipient := &tele.Chat{ID: parseInt64(chatID)}
-		_, err := n.bot.Send(recipient, message)
+		_, err := n.bot.Send(recipient, message, opts...)
 		if err != nil {
 			log.Printf("Failed to send message to chat %s: %v", chatID, err)
+			errs = append(errs, fmt.Errorf("chat %s: %w", chatID, err))
+			failed = append(failed, chatID)
 			continue
 		}
 	}
 
-	return nil
+	err := errors.Join(errs...)
+	if len(failed) > 0 && len(failed) < len(chatIDs) {
+		return &notify.PartialError{Failed: failed, Err: err}
+	}
+	return err
 }
 
 // setupHandlers sets up bot command handlers
@@ -104,135 +143,254 @@ func (n *Notifier) setupHandlers() {
 	// /start command
 	n.bot.Handle("/start", func(c tele.Context) error {
 		return c.Send("👋 Welcome to Vigilon Bot!\n\nAvailable commands:\n" +
-			"/status - Get current status of all services\n" +
+			"/status [server] - Get current status of all services or one server\n" +
 			"/servers - List all servers\n" +
 			"/alerts - View recent alerts\n" +
-			"/help - Show help message")
+			"/mute - Silence notifications during maintenance\n" +
+			"/whoami - Show your Telegram and chat IDs\n" +
+			"/help - Show help message\n\n" +
+			"Commands require a Telegram account linked to a Vigilon user.")
 	})
 
 	// /help command
 	n.bot.Handle("/help", func(c tele.Context) error {
 		return c.Send("📚 Vigilon Bot Commands:\n\n" +
-			"/status - Get current status of all services\n" +
+			"/status [server] - Get current status of all services or one server\n" +
 			"/servers - List all monitored servers\n" +
 			"/alerts - View recent alerts (unacknowledged)\n" +
 			"/ack <id> - Acknowledge an alert\n" +
+			"/mute <server>[/<service>] <duration> [reason] - Silence notifications\n" +
+			"/mute tag:<tag> <duration> [reason] - Silence a group of servers\n" +
+			"/unmute <id> - End a silence early\n" +
+			"/whoami - Show your Telegram and chat IDs\n" +
 			"/help - Show this help message")
 	})
 
-	// /status command
+	// /whoami command, open to everyone so accounts and chats can be linked
+	n.bot.Handle("/whoami", n.handleWhoami)
+
+	// /status [server] command
 	n.bot.Handle("/status", func(c tele.Context) error {
-		servers, err := n.db.GetAllServers()
+		if len(c.Args()) == 0 {
+			return n.sendView(c, "status", 0)
+		}
+		server, _, err := n.findTarget(strings.Join(c.Args(), " "), "")
 		if err != nil {
-			return c.Send("❌ Failed to get servers")
+			return c.Send("❌ " + err.Error())
 		}
+		return n.sendView(c, "status", server.ID)
+	}, n.require("servers.view"))
 
-		if len(servers) == 0 {
-			return c.Send("ℹ️ No servers configured")
-		}
+	// /servers command
+	n.bot.Handle("/servers", func(c tele.Context) error {
+		return n.sendView(c, "servers", 0)
+	}, n.require("servers.view"))
 
-		message := "📊 *Service Status Overview*\n\n"
-		for _, server := range servers {
-			if !server.Enabled {
-				continue
-			}
-
-			message += fmt.Sprintf("🖥 *%s* (%s)\n", server.Name, server.IPAddress)
-
-			services, err := n.db.GetServicesByServer(server.ID)
-			if err != nil {
-				message += "  ❌ Failed to get services\n"
-				continue
-			}
-
-			if len(services) == 0 {
-				message += "  ℹ️ No services configured\n\n"
-				continue
-			}
-
-			for _, service := range services {
-				if !service.Enabled {
-					continue
-				}
-
-				check, err := n.db.GetLatestServiceCheck(service.ID)
-				if err != nil {
-					message += fmt.Sprintf("  ❓ %s: Unknown\n", service.DisplayName)
-					continue
-				}
-
-				statusIcon := getStatusIcon(check.Status)
-				message += fmt.Sprintf("  %s %s: %s\n", statusIcon, service.DisplayName, check.Status)
-			}
-			message += "\n"
-		}
+	// /alerts command
+	n.bot.Handle("/alerts", func(c tele.Context) error {
+		return n.sendView(c, "alerts", 0)
+	}, n.require("alerts.view"))
 
-		return c.Send(message, &tele.SendOptions{ParseMode: "Markdown"})
-	})
+	// /ack command
+	n.bot.Handle("/ack", func(c tele.Context) error {
+		if len(c.Args()) != 1 {
+			return c.Send("Usage: /ack <id>")
+		}
 
-	// /servers command
-	n.bot.Handle("/servers", func(c tele.Context) error {
-		servers, err := n.db.GetAllServers()
+		id, err := strconv.Atoi(c.Args()[0])
 		if err != nil {
-			return c.Send("❌ Failed to get servers")
+			return c.Send("❌ Invalid alert id")
 		}
-
-		if len(servers) == 0 {
-			return c.Send("ℹ️ No servers configured")
+		alert, err := n.db.GetAlert(id)
+		if err != nil {
+			return c.Send("❌ Alert not found")
 		}
-
-		message := "🖥 *Monitored Servers*\n\n"
-		for _, server := range servers {
-			status := "✅ Enabled"
-			if !server.Enabled {
-				status = "⏸ Disabled"
-			}
-
-			lastSeen := "Never"
-			if server.LastSeen != nil {
-				lastSeen = server.LastSeen.Format("2006-01-02 15:04:05")
-			}
-
-			message += fmt.Sprintf("*%s*\n", server.Name)
-			message += fmt.Sprintf("  IP: %s\n", server.IPAddress)
-			message += fmt.Sprintf("  OS: %s\n", server.OS)
-			message += fmt.Sprintf("  Mode: %s\n", server.MonitoringMode)
-			message += fmt.Sprintf("  Status: %s\n", status)
-			message += fmt.Sprintf("  Last Seen: %s\n\n", lastSeen)
+		if alert.Acknowledged {
+			return c.Send(fmt.Sprintf("ℹ️ Alert #%d was already acknowledged", id))
+		}
+		if err := n.db.AcknowledgeAlert(id, actor(c)); err != nil {
+			return c.Send("❌ Failed to acknowledge alert")
 		}
 
-		return c.Send(message, &tele.SendOptions{ParseMode: "Markdown"})
-	})
+		return c.Send(fmt.Sprintf("✅ Alert #%d acknowledged, escalation stopped", id))
+	}, n.require("alerts.acknowledge"))
 
-	// /alerts command
-	n.bot.Handle("/alerts", func(c tele.Context) error {
-		alerts, err := n.db.GetRecentAlerts(10)
+	// /mute and /unmute commands
+	n.bot.Handle("/mute", n.handleMute, n.require("servers.edit"))
+	n.bot.Handle("/unmute", n.handleUnmute, n.require("servers.edit"))
+
+	// Alert message buttons
+	n.bot.Handle(btnAck, n.handleAckButton, n.require("alerts.acknowledge"))
+	n.bot.Handle(btnSilence, n.handleSilenceButton, n.require("servers.edit"))
+
+	// Remote action buttons; the confirmation re-checks the permission itself
+	n.bot.Handle(btnCheck, n.handleActionButton(models.ActionCheck), n.require(actionPermissions[models.ActionCheck]))
+	n.bot.Handle(btnRestart, n.handleActionButton(models.ActionRestart), n.require(actionPermissions[models.ActionRestart]))
+	n.bot.Handle(btnConfirm, n.handleConfirmButton)
+	n.bot.Handle(btnCancel, n.handleCancelButton)
+
+	// Page navigation; the permission depends on the view being paged
+	n.bot.Handle(btnPage, n.handlePageButton)
+}
+
+// handleWhoami shows the IDs needed to link a Telegram account or allow a chat
+func (n *Notifier) handleWhoami(c tele.Context) error {
+	message := fmt.Sprintf("Chat ID: %d\n", chatID(c))
+	sender := c.Sender()
+	if sender == nil {
+		return c.Send(message)
+	}
+	message += fmt.Sprintf("Telegram user ID: %d\n", sender.ID)
+
+	user, err := n.db.GetUserByContactMethod("telegram", strconv.FormatInt(sender.ID, 10))
+	if err != nil {
+		message += "\nNot linked to a Vigilon user. Ask an administrator to add your Telegram user ID as a telegram contact method."
+	} else {
+		message += "Vigilon user: " + user.Username
+	}
+	return c.Send(message)
+}
+
+// handleMute silences notifications for a server, service or tag
+func (n *Notifier) handleMute(c tele.Context) error {
+	args := c.Args()
+	if len(args) < 2 {
+		return n.listSilences(c)
+	}
+
+	duration, err := time.ParseDuration(args[1])
+	if err != nil || duration <= 0 {
+		return c.Send("❌ Invalid duration, use e.g. 30m or 2h")
+	}
+
+	start := time.Now()
+	end := start.Add(duration)
+	window := &models.MaintenanceWindow{
+		Reason:    strings.Join(args[2:], " "),
+		StartsAt:  &start,
+		EndsAt:    &end,
+		CreatedBy: actor(c),
+	}
+
+	target := args[0]
+	if tag, ok := strings.CutPrefix(target, "tag:"); ok {
+		window.Scope = models.ScopeTag
+		window.Tag = tag
+	} else {
+		serverName, serviceName, _ := strings.Cut(target, "/")
+		server, service, err := n.findTarget(serverName, serviceName)
 		if err != nil {
-			return c.Send("❌ Failed to get alerts")
+			return c.Send("❌ " + err.Error())
 		}
-
-		// Filter unacknowledged alerts
-		unacked := make([]*models.Alert, 0)
-		for _, alert := range alerts {
-			if !alert.Acknowledged {
-				unacked = append(unacked, alert)
-			}
+		window.Scope = models.ScopeServer
+		window.ServerID = server.ID
+		if service != nil {
+			window.Scope = models.ScopeService
+			window.ServiceID = service.ID
 		}
+	}
+	window.Name = "Silence " + target
 
-		if len(unacked) == 0 {
-			return c.Send("✅ No pending alerts")
+	if err := maintenance.Validate(window); err != nil {
+		return c.Send("❌ " + err.Error())
+	}
+	if err := n.db.CreateMaintenanceWindow(window); err != nil {
+		return c.Send("❌ Failed to create silence")
+	}
+
+	return c.Send(fmt.Sprintf("🔕 Silenced %s until %s (id %d)\nUse /unmute %d to end it early.",
+		target, end.Format("2006-01-02 15:04"), window.ID, window.ID))
+}
+
+// handleUnmute ends a silence or maintenance window early
+func (n *Notifier) handleUnmute(c tele.Context) error {
+	if len(c.Args()) != 1 {
+		return c.Send("Usage: /unmute <id>")
+	}
+
+	id, err := strconv.Atoi(c.Args()[0])
+	if err != nil {
+		return c.Send("❌ Invalid id")
+	}
+
+	window, err := n.db.GetMaintenanceWindow(id)
+	if err != nil {
+		return c.Send("❌ Silence not found")
+	}
+
+	if window.Recurring() {
+		// Deleting a recurring window would drop future occurrences too
+		return c.Send("❌ Recurring maintenance windows can only be changed from the web UI")
+	}
+
+	if err := n.db.DeleteMaintenanceWindow(id); err != nil {
+		return c.Send("❌ Failed to remove silence")
+	}
+	return c.Send(fmt.Sprintf("🔔 Silence %d removed", id))
+}
+
+// listSilences shows usage and the maintenance windows that are active now
+func (n *Notifier) listSilences(c tele.Context) error {
+	message := "Usage: /mute <server>[/<service>] <duration> [reason]\n" +
+		"       /mute tag:<tag> <duration> [reason]\n\n"
+
+	windows, err := n.db.GetMaintenanceWindows()
+	if err != nil {
+		return c.Send(message + "❌ Failed to get maintenance windows")
+	}
+
+	now := time.Now()
+	active := 0
+	for _, window := range windows {
+		until, ok := maintenance.Until(window, now)
+		if !ok {
+			continue
 		}
+		if active == 0 {
+			message += "🔕 Active silences and maintenance:\n"
+		}
+		active++
+		message += fmt.Sprintf("  #%d %s (until %s)\n", window.ID, window.Name, until.Format("2006-01-02 15:04"))
+	}
+	if active == 0 {
+		message += "No active silences"
+	}
+
+	return c.Send(message)
+}
 
-		message := "🚨 *Recent Alerts*\n\n"
-		for _, alert := range unacked {
-			message += fmt.Sprintf("*Alert #%d*\n", alert.ID)
-			message += fmt.Sprintf("%s\n", alert.Message)
-			message += fmt.Sprintf("Time: %s\n", alert.CreatedAt.Format("2006-01-02 15:04:05"))
-			message += fmt.Sprintf("Use /ack %d to acknowledge\n\n", alert.ID)
+// findTarget looks up a server by name and optionally one of its services by
+// unit or display name
+func (n *Notifier) findTarget(serverName, serviceName string) (*models.Server, *models.Service, error) {
+	servers, err := n.db.GetAllServers()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get servers")
+	}
+
+	var server *models.Server
+	for _, s := range servers {
+		if strings.EqualFold(s.Name, serverName) {
+			server = s
+			break
 		}
+	}
+	if server == nil {
+		return nil, nil, fmt.Errorf("server %q not found", serverName)
+	}
+	if serviceName == "" {
+		return server, nil, nil
+	}
 
-		return c.Send(message, &tele.SendOptions{ParseMode: "Markdown"})
-	})
+	services, err := n.db.GetServicesByServer(server.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get services")
+	}
+	for _, service := range services {
+		if strings.EqualFold(service.Name, serviceName) || strings.EqualFold(service.DisplayName, serviceName) {
+			return server, service, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("service %q not found on %s", serviceName, server.Name)
 }
 
 // getStatusIcon returns an emoji icon for a service status
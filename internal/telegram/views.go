@@ -0,0 +1,228 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/harungecit/vigilon/internal/models"
+	tele "gopkg.in/telebot.v3"
+)
+
+// btnPage switches a paginated message to another page. Its data is
+// "view|arg|page", so the page is rendered again from current data.
+var btnPage = &tele.Btn{Unique: "page"}
+
+// maxAlertLength caps alert messages in /alerts so one alert fits a page
+const maxAlertLength = 1000
+
+// view renders the output of a paginated command. arg narrows the output
+// down, e.g. to one server; zero means everything. Errors are sent to the
+// chat as they are, so they also carry the "nothing to show" messages.
+type view struct {
+	permission string
+	render     func(n *Notifier, arg int) (title string, blocks []block, err error)
+}
+
+var views = map[string]view{
+	"status":  {permission: "servers.view", render: (*Notifier).statusView},
+	"servers": {permission: "servers.view", render: (*Notifier).serversView},
+	"alerts":  {permission: "alerts.view", render: (*Notifier).alertsView},
+}
+
+// sendView sends the first page of a view
+func (n *Notifier) sendView(c tele.Context, name string, arg int) error {
+	text, opts, err := n.renderPage(name, arg, 0)
+	if err != nil {
+		return c.Send(err.Error())
+	}
+	return c.Send(text, opts)
+}
+
+// handlePageButton shows another page of a view, checking the view's
+// permission again since anyone in the chat can press the button
+func (n *Notifier) handlePageButton(c tele.Context) error {
+	args := c.Args()
+	if len(args) != 3 {
+		return c.Respond()
+	}
+	v, ok := views[args[0]]
+	if !ok {
+		return c.Respond()
+	}
+	arg, _ := strconv.Atoi(args[1])
+	number, _ := strconv.Atoi(args[2])
+
+	return n.require(v.permission)(func(c tele.Context) error {
+		if err := c.Respond(); err != nil {
+			return err
+		}
+		text, opts, err := n.renderPage(args[0], arg, number)
+		if err != nil {
+			return c.Edit(err.Error())
+		}
+		err = c.Edit(text, opts)
+		if errors.Is(err, tele.ErrSameMessageContent) || errors.Is(err, tele.ErrMessageNotModified) {
+			return nil
+		}
+		return err
+	})(c)
+}
+
+// renderPage renders one page of a view with its buttons and the page
+// navigation. Out of range page numbers show the closest page, as the
+// output may have shrunk since the buttons were sent.
+func (n *Notifier) renderPage(name string, arg, number int) (string, *tele.SendOptions, error) {
+	title, blocks, err := views[name].render(n, arg)
+	if err != nil {
+		return "", nil, err
+	}
+
+	pages := paginate(title, blocks)
+	number = max(0, min(number, len(pages)-1))
+	p := pages[number]
+
+	markup := &tele.ReplyMarkup{}
+	rows := p.rows
+	text := p.text
+	if len(pages) > 1 {
+		text += "\n" + italic(fmt.Sprintf("Page %d/%d", number+1, len(pages)))
+
+		var nav tele.Row
+		if number > 0 {
+			nav = append(nav, markup.Data("◀ Prev", btnPage.Unique, name, strconv.Itoa(arg), strconv.Itoa(number-1)))
+		}
+		if number < len(pages)-1 {
+			nav = append(nav, markup.Data("Next ▶", btnPage.Unique, name, strconv.Itoa(arg), strconv.Itoa(number+1)))
+		}
+		rows = append(rows[:len(rows):len(rows)], nav)
+	}
+	markup.Inline(rows...)
+
+	return text, &tele.SendOptions{ParseMode: tele.ModeMarkdownV2, ReplyMarkup: markup}, nil
+}
+
+// statusView lists the status of every enabled service, or of the services
+// of one server, with re-check and restart buttons when actions are enabled
+func (n *Notifier) statusView(serverID int) (string, []block, error) {
+	var servers []*models.Server
+	if serverID > 0 {
+		server, err := n.db.GetServer(serverID)
+		if err != nil {
+			return "", nil, errors.New("❌ Server not found")
+		}
+		servers = []*models.Server{server}
+	} else {
+		all, err := n.db.GetAllServers()
+		if err != nil {
+			return "", nil, errors.New("❌ Failed to get servers")
+		}
+		for _, server := range all {
+			if server.Enabled {
+				servers = append(servers, server)
+			}
+		}
+	}
+	if len(servers) == 0 {
+		return "", nil, errors.New("ℹ️ No servers configured")
+	}
+
+	// Re-check and restart buttons, one row per service
+	markup := &tele.ReplyMarkup{}
+
+	var blocks []block
+	for _, server := range servers {
+		heading := mdf("🖥 *%s* \\(%s\\)", server.Name, server.IPAddress)
+
+		services, err := n.db.GetServicesByServer(server.ID)
+		if err != nil {
+			blocks = append(blocks, block{heading: heading, text: escapeMarkdown("  ❌ Failed to get services")})
+			continue
+		}
+
+		var enabled []*models.Service
+		for _, service := range services {
+			if service.Enabled {
+				enabled = append(enabled, service)
+			}
+		}
+		if len(enabled) == 0 {
+			blocks = append(blocks, block{heading: heading, text: escapeMarkdown("  ℹ️ No services configured")})
+			continue
+		}
+
+		for _, service := range enabled {
+			b := block{heading: heading}
+			status := models.StatusUnknown
+			if check, err := n.db.GetLatestServiceCheck(service.ID); err != nil {
+				b.text = mdf("  ❓ %s: Unknown", service.DisplayName)
+			} else {
+				status = check.Status
+				b.text = mdf("  %s %s: %s", getStatusIcon(check.Status), service.DisplayName, check.Status)
+			}
+			if n.controller != nil {
+				b.rows = []tele.Row{serviceActionRow(markup, server, service, status)}
+			}
+			blocks = append(blocks, b)
+		}
+	}
+
+	return "📊 *Service Status Overview*", blocks, nil
+}
+
+// serversView lists every server with its connection details
+func (n *Notifier) serversView(int) (string, []block, error) {
+	servers, err := n.db.GetAllServers()
+	if err != nil {
+		return "", nil, errors.New("❌ Failed to get servers")
+	}
+	if len(servers) == 0 {
+		return "", nil, errors.New("ℹ️ No servers configured")
+	}
+
+	blocks := make([]block, 0, len(servers))
+	for _, server := range servers {
+		status := "✅ Enabled"
+		if !server.Enabled {
+			status = "⏸ Disabled"
+		}
+
+		lastSeen := "Never"
+		if server.LastSeen != nil {
+			lastSeen = server.LastSeen.Format("2006-01-02 15:04:05")
+		}
+
+		blocks = append(blocks, block{text: bold(server.Name) + "\n" +
+			mdf("  IP: %s\n", server.IPAddress) +
+			mdf("  OS: %s\n", server.OS) +
+			mdf("  Mode: %s\n", server.MonitoringMode) +
+			mdf("  Status: %s\n", status) +
+			mdf("  Last Seen: %s", lastSeen)})
+	}
+
+	return "🖥 *Monitored Servers*", blocks, nil
+}
+
+// alertsView lists the unacknowledged alerts among the recent ones
+func (n *Notifier) alertsView(int) (string, []block, error) {
+	alerts, err := n.db.GetRecentAlerts(50)
+	if err != nil {
+		return "", nil, errors.New("❌ Failed to get alerts")
+	}
+
+	var blocks []block
+	for _, alert := range alerts {
+		if alert.Acknowledged {
+			continue
+		}
+		blocks = append(blocks, block{text: bold(fmt.Sprintf("Alert #%d", alert.ID)) + "\n" +
+			escapeMarkdown(truncate(alert.Message, maxAlertLength)) + "\n" +
+			mdf("Time: %s\n", alert.CreatedAt.Format("2006-01-02 15:04:05")) +
+			mdf("Use /ack %d to acknowledge", alert.ID)})
+	}
+	if len(blocks) == 0 {
+		return "", nil, errors.New("✅ No pending alerts")
+	}
+
+	return "🚨 *Recent Alerts*", blocks, nil
+}
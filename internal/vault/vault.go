@@ -0,0 +1,268 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/harungecit/vigilon/internal/database"
+	"github.com/harungecit/vigilon/internal/models"
+	"golang.org/x/crypto/ssh"
+)
+
+// EnvMasterKey is the environment variable holding the master key. It takes
+// precedence over the key file in the config.
+const EnvMasterKey = "VIGILON_MASTER_KEY"
+
+// KeySize is the size of a master key: AES-256
+const KeySize = 32
+
+// ErrNotConfigured is returned for secrets when no master key is configured
+var ErrNotConfigured = errors.New("the secret vault is not configured, set " + EnvMasterKey + " or vault.master_key_file")
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+// Vault stores secrets in the database, encrypted with AES-256-GCM under the
+// master key. The secret name is authenticated with the value, so a value
+// cannot be moved to another secret.
+type Vault struct {
+	db       *database.DB
+	current  *masterKey
+	previous []*masterKey // Older keys, only used to re-encrypt
+}
+
+type masterKey struct {
+	id   string // Identifies the key without revealing it
+	aead cipher.AEAD
+}
+
+// New creates a vault with the current master key and the keys it replaced
+func New(db *database.DB, key []byte, previous ...[]byte) (*Vault, error) {
+	current, err := newMasterKey(key)
+	if err != nil {
+		return nil, err
+	}
+	v := &Vault{db: db, current: current}
+	for _, key := range previous {
+		old, err := newMasterKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("previous master key: %w", err)
+		}
+		v.previous = append(v.previous, old)
+	}
+	return v, nil
+}
+
+func newMasterKey(key []byte) (*masterKey, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("master key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(key)
+	return &masterKey{id: hex.EncodeToString(sum[:4]), aead: aead}, nil
+}
+
+// LoadMasterKey returns the master key from the environment or, if it is not
+// set there, from a key file. It returns nil if neither is configured.
+func LoadMasterKey(path string) ([]byte, error) {
+	if value := os.Getenv(EnvMasterKey); value != "" {
+		key, err := DecodeKey(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", EnvMasterKey, err)
+		}
+		return key, nil
+	}
+	if path == "" {
+		return nil, nil
+	}
+	return ReadKeyFile(path)
+}
+
+// ReadKeyFile reads a base64 encoded master key from a file
+func ReadKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read master key: %w", err)
+	}
+	key, err := DecodeKey(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// DecodeKey decodes a base64 encoded master key
+func DecodeKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, errors.New("master key is not valid base64")
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("master key must be %d bytes, got %d", KeySize, len(key))
+	}
+	return key, nil
+}
+
+// GenerateKey returns a new random master key, base64 encoded
+func GenerateKey() (string, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// Validate checks the name and type of a secret and, for SSH keys, that the
+// value is a private key, which may be passphrase protected
+func Validate(secret *models.Secret, value []byte) error {
+	if !validName.MatchString(secret.Name) {
+		return errors.New("name must be 1-64 letters, digits, '.', '_' or '-'")
+	}
+	switch secret.Type {
+	case models.SecretSSHKey:
+		// Encrypted keys are decrypted with a passphrase secret when used
+		var missing *ssh.PassphraseMissingError
+		if _, err := ssh.ParseRawPrivateKey(value); err != nil && !errors.As(err, &missing) {
+			return fmt.Errorf("value is not a private key: %w", err)
+		}
+	case models.SecretPassword, models.SecretToken:
+	default:
+		return errors.New("type must be one of ssh_key, password or token")
+	}
+	if len(value) == 0 {
+		return errors.New("value is required")
+	}
+	return nil
+}
+
+// Create encrypts a value and stores it as a new secret
+func (v *Vault) Create(secret *models.Secret, value []byte) error {
+	if err := Validate(secret, value); err != nil {
+		return err
+	}
+	if err := v.seal(secret, value); err != nil {
+		return err
+	}
+	return v.db.CreateSecret(secret)
+}
+
+// Update stores a new value and description of a secret
+func (v *Vault) Update(secret *models.Secret, value []byte) error {
+	if err := Validate(secret, value); err != nil {
+		return err
+	}
+	if err := v.seal(secret, value); err != nil {
+		return err
+	}
+	return v.db.UpdateSecret(secret)
+}
+
+// Secret returns the decrypted value of a secret by name
+func (v *Vault) Secret(name string) ([]byte, error) {
+	if v == nil {
+		return nil, ErrNotConfigured
+	}
+	secret, err := v.db.GetSecretByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("secret %q not found", name)
+	}
+	return v.open(secret)
+}
+
+// Rekey re-encrypts every secret that is not encrypted with the current
+// master key, which completes a key rotation. It returns how many secrets
+// were re-encrypted.
+func (v *Vault) Rekey() (int, error) {
+	secrets, err := v.db.GetSecrets()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	var errs []error
+	for _, secret := range secrets {
+		if secret.KeyID == v.current.id {
+			continue
+		}
+		value, err := v.open(secret)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		oldKeyID := secret.KeyID
+		if err := v.seal(secret, value); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := v.db.RekeySecret(secret.ID, oldKeyID, secret.Ciphertext, secret.KeyID); err != nil {
+			errs = append(errs, fmt.Errorf("secret %q: %w", secret.Name, err))
+			continue
+		}
+		log.Printf("Re-encrypted secret %s with master key %s", secret.Name, v.current.id)
+		count++
+	}
+	return count, errors.Join(errs...)
+}
+
+// KeyID returns the ID of the current master key
+func (v *Vault) KeyID() string {
+	return v.current.id
+}
+
+// seal encrypts a value with the current master key into the secret. The
+// ciphertext is the nonce followed by the sealed value.
+func (v *Vault) seal(secret *models.Secret, value []byte) error {
+	nonce := make([]byte, v.current.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	secret.Ciphertext = v.current.aead.Seal(nonce, nonce, value, []byte(secret.Name))
+	secret.KeyID = v.current.id
+	return nil
+}
+
+// open decrypts a secret with the master key it was encrypted with
+func (v *Vault) open(secret *models.Secret) ([]byte, error) {
+	key := v.key(secret.KeyID)
+	if key == nil {
+		return nil, fmt.Errorf("secret %q is encrypted with master key %s, which is not configured", secret.Name, secret.KeyID)
+	}
+	size := key.aead.NonceSize()
+	if len(secret.Ciphertext) < size {
+		return nil, fmt.Errorf("secret %q is corrupt", secret.Name)
+	}
+	value, err := key.aead.Open(nil, secret.Ciphertext[:size], secret.Ciphertext[size:], []byte(secret.Name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secret %q: %w", secret.Name, err)
+	}
+	return value, nil
+}
+
+// key returns the master key with an ID, or nil
+func (v *Vault) key(id string) *masterKey {
+	if v.current.id == id {
+		return v.current
+	}
+	for _, key := range v.previous {
+		if key.id == id {
+			return key
+		}
+	}
+	return nil
+}
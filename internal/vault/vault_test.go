@@ -0,0 +1,255 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/harungecit/vigilon/internal/database"
+	"github.com/harungecit/vigilon/internal/models"
+	"golang.org/x/crypto/ssh"
+)
+
+// testKey returns a master key of a single repeated byte
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, KeySize)
+}
+
+func newTestVault(t *testing.T, key []byte, previous ...[]byte) (*Vault, *database.DB) {
+	t.Helper()
+	db, err := database.New(filepath.Join(t.TempDir(), "vigilon.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	v, err := New(db, key, previous...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v, db
+}
+
+func TestSecretRoundTrip(t *testing.T) {
+	v, db := newTestVault(t, testKey(1))
+
+	secret := &models.Secret{Name: "db-password", Type: models.SecretPassword}
+	if err := v.Create(secret, []byte("s3cret")); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if secret.KeyID != v.KeyID() {
+		t.Errorf("key ID = %s, want %s", secret.KeyID, v.KeyID())
+	}
+	stored, err := db.GetSecretByName("db-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(stored.Ciphertext, []byte("s3cret")) {
+		t.Errorf("value stored in plain text")
+	}
+
+	value, err := v.Secret("db-password")
+	if err != nil {
+		t.Fatalf("Secret: %v", err)
+	}
+	if string(value) != "s3cret" {
+		t.Errorf("value = %q, want s3cret", value)
+	}
+
+	secret.Description = "rotated"
+	if err := v.Update(secret, []byte("n3w")); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if value, _ := v.Secret("db-password"); string(value) != "n3w" {
+		t.Errorf("updated value = %q, want n3w", value)
+	}
+
+	_, err = v.Secret("missing")
+	checkError(t, err, `secret "missing" not found`)
+	_, err = (*Vault)(nil).Secret("db-password")
+	checkError(t, err, "the secret vault is not configured")
+}
+
+func TestSecretBoundToName(t *testing.T) {
+	v, db := newTestVault(t, testKey(1))
+
+	for _, name := range []string{"prod-password", "test-password"} {
+		if err := v.Create(&models.Secret{Name: name, Type: models.SecretPassword}, []byte(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Someone with access to the database copies the value of one secret
+	// to another
+	prod, err := db.GetSecretByName("prod-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	test, err := db.GetSecretByName("test-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RekeySecret(test.ID, test.KeyID, prod.Ciphertext, prod.KeyID); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = v.Secret("test-password")
+	checkError(t, err, `failed to decrypt secret "test-password"`)
+	if value, err := v.Secret("prod-password"); err != nil || string(value) != "prod-password" {
+		t.Errorf("prod-password = %q, %v", value, err)
+	}
+}
+
+func TestRekey(t *testing.T) {
+	oldKey, currentKey := testKey(1), testKey(2)
+	old, db := newTestVault(t, oldKey)
+	for _, name := range []string{"a", "b"} {
+		if err := old.Create(&models.Secret{Name: name, Type: models.SecretToken}, []byte("value "+name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	v, err := New(db, currentKey, oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.KeyID() == old.KeyID() {
+		t.Fatalf("key IDs of different keys are both %s", v.KeyID())
+	}
+	// Already on the current key, so left alone
+	if err := v.Create(&models.Secret{Name: "c", Type: models.SecretToken}, []byte("value c")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Secrets on the previous key can be read before the rotation is done
+	if value, err := v.Secret("a"); err != nil || string(value) != "value a" {
+		t.Errorf("a = %q, %v before rekeying", value, err)
+	}
+
+	count, err := v.Rekey()
+	if err != nil {
+		t.Fatalf("Rekey: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("re-encrypted %d secrets, want 2", count)
+	}
+	secrets, err := db.GetSecrets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range secrets {
+		if secret.KeyID != v.KeyID() {
+			t.Errorf("secret %s is on key %s, want %s", secret.Name, secret.KeyID, v.KeyID())
+		}
+	}
+
+	// The previous key is no longer needed
+	current, err := New(db, currentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if value, err := current.Secret(name); err != nil || string(value) != "value "+name {
+			t.Errorf("%s = %q, %v after rekeying", name, value, err)
+		}
+	}
+	if count, err := current.Rekey(); err != nil || count != 0 {
+		t.Errorf("second Rekey = %d, %v; want nothing to do", count, err)
+	}
+}
+
+func TestUnknownKey(t *testing.T) {
+	old, db := newTestVault(t, testKey(1))
+	if err := old.Create(&models.Secret{Name: "orphan", Type: models.SecretPassword}, []byte("s3cret")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The key was rotated without listing the old one as previous
+	v, err := New(db, testKey(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = v.Secret("orphan")
+	checkError(t, err, `secret "orphan" is encrypted with master key `+old.KeyID()+", which is not configured")
+
+	count, err := v.Rekey()
+	if count != 0 {
+		t.Errorf("re-encrypted %d secrets, want 0", count)
+	}
+	checkError(t, err, "which is not configured")
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(nil, make([]byte, 16))
+	checkError(t, err, "master key must be 32 bytes, got 16")
+	_, err = New(nil, testKey(1), []byte("short"))
+	checkError(t, err, "previous master key: master key must be 32 bytes, got 5")
+}
+
+func TestValidate(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(private, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plainKey := pem.EncodeToMemory(block)
+	block, err = ssh.MarshalPrivateKeyWithPassphrase(private, "", []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	protectedKey := pem.EncodeToMemory(block)
+
+	tests := []struct {
+		name    string
+		secret  models.Secret
+		value   []byte
+		wantErr string
+	}{
+		{"password", models.Secret{Name: "db.password_1", Type: models.SecretPassword}, []byte("s3cret"), ""},
+		{"ssh key", models.Secret{Name: "deploy-key", Type: models.SecretSSHKey}, plainKey, ""},
+		{"passphrase protected ssh key", models.Secret{Name: "deploy-key", Type: models.SecretSSHKey}, protectedKey, ""},
+		{"not a key", models.Secret{Name: "deploy-key", Type: models.SecretSSHKey}, []byte("ssh-ed25519 AAAA"), "value is not a private key"},
+		{"empty value", models.Secret{Name: "token", Type: models.SecretToken}, nil, "value is required"},
+		{"unknown type", models.Secret{Name: "token", Type: "certificate"}, []byte("x"), "type must be one of"},
+		{"invalid name", models.Secret{Name: "-token", Type: models.SecretToken}, []byte("x"), "name must be"},
+		{"long name", models.Secret{Name: strings.Repeat("a", 65), Type: models.SecretToken}, []byte("x"), "name must be"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkError(t, Validate(&tt.secret, tt.value), tt.wantErr)
+		})
+	}
+}
+
+func TestDecodeKey(t *testing.T) {
+	encoded, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := DecodeKey(encoded + "\n")
+	if err != nil || len(key) != KeySize {
+		t.Errorf("DecodeKey = %d bytes, %v; want %d", len(key), err, KeySize)
+	}
+	_, err = DecodeKey("not base64!")
+	checkError(t, err, "master key is not valid base64")
+	_, err = DecodeKey("c2hvcnQ=")
+	checkError(t, err, "master key must be 32 bytes, got 5")
+}
+
+func checkError(t *testing.T, err error, want string) {
+	t.Helper()
+	switch {
+	case want == "" && err != nil:
+		t.Errorf("unexpected error: %v", err)
+	case want != "" && err == nil:
+		t.Errorf("error = nil, want %q", want)
+	case want != "" && !strings.Contains(err.Error(), want):
+		t.Errorf("error = %q, want %q", err, want)
+	}
+}
@@ -0,0 +1,412 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/harungecit/vigilon/internal/database"
+	"github.com/harungecit/vigilon/internal/models"
+	"github.com/harungecit/vigilon/internal/notify"
+)
+
+// ChannelPrefix prefixes the channel name of every webhook
+const ChannelPrefix = "webhook:"
+
+// EventTest is the event type of test deliveries
+const EventTest = "test"
+
+// DefaultBodyTemplate renders the whole payload as JSON
+const DefaultBodyTemplate = `{{json .}}`
+
+// maxResponseBody bounds the response body kept in the delivery log
+const maxResponseBody = 4096
+
+// Payload is the data available to body templates
+type Payload struct {
+	Event     string               `json:"event"`
+	Message   string               `json:"message"`
+	Timestamp time.Time            `json:"timestamp"`
+	Alert     *AlertInfo           `json:"alert,omitempty"`
+	Server    *ServerInfo          `json:"server,omitempty"`
+	Service   *ServiceInfo         `json:"service,omitempty"`
+	Check     *models.ServiceCheck `json:"check,omitempty"`
+}
+
+// AlertInfo is the alert part of a payload
+type AlertInfo struct {
+	ID              int                  `json:"id"`
+	Status          models.ServiceStatus `json:"status"`
+	State           models.AlertState    `json:"state"`
+	Message         string               `json:"message"`
+	Acknowledged    bool                 `json:"acknowledged"`
+	CreatedAt       time.Time            `json:"created_at"`
+	ResolvedAt      *time.Time           `json:"resolved_at,omitempty"`
+	DowntimeSeconds int64                `json:"downtime_seconds,omitempty"`
+}
+
+// ServerInfo is the server part of a payload. Credentials are left out on purpose.
+type ServerInfo struct {
+	ID        int      `json:"id"`
+	Name      string   `json:"name"`
+	Hostname  string   `json:"hostname"`
+	IPAddress string   `json:"ip_address"`
+	Tags      []string `json:"tags"`
+}
+
+// ServiceInfo is the service part of a payload
+type ServiceInfo struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+}
+
+var funcs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// ApplyDefaults fills unset webhook settings with their defaults
+func ApplyDefaults(hook *models.Webhook) {
+	if hook.Method == "" {
+		hook.Method = http.MethodPost
+	}
+	hook.Method = strings.ToUpper(hook.Method)
+	if hook.ContentType == "" {
+		hook.ContentType = "application/json"
+	}
+	if hook.SignatureHeader == "" {
+		hook.SignatureHeader = "X-Vigilon-Signature"
+	}
+	if hook.Timeout <= 0 {
+		hook.Timeout = 10
+	}
+	if hook.MaxAttempts <= 0 {
+		hook.MaxAttempts = 3
+	}
+	if hook.RetryBackoff <= 0 {
+		hook.RetryBackoff = 10
+	}
+}
+
+// Validate checks that a webhook is complete and its template parses
+func Validate(hook *models.Webhook) error {
+	if hook.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	u, err := url.Parse(hook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http or https URL")
+	}
+
+	switch hook.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return fmt.Errorf("method must be POST, PUT or PATCH")
+	}
+
+	for _, event := range hook.Events {
+		switch notify.EventType(event) {
+		case notify.EventAlert, notify.EventRecovery, notify.EventReminder, notify.EventEscalation:
+		default:
+			return fmt.Errorf("unknown event %q", event)
+		}
+	}
+
+	if hook.Timeout > 300 {
+		return fmt.Errorf("timeout must not exceed 300 seconds")
+	}
+	if hook.MaxAttempts > 10 {
+		return fmt.Errorf("max_attempts must not exceed 10")
+	}
+
+	if _, err := parseTemplate(hook); err != nil {
+		return fmt.Errorf("invalid body_template: %w", err)
+	}
+	return nil
+}
+
+// SecretStore returns the values of vault secrets
+type SecretStore interface {
+	Secret(name string) ([]byte, error)
+}
+
+// Notifier delivers events to a single webhook
+type Notifier struct {
+	db      *database.DB
+	secrets SecretStore
+	hook    *models.Webhook
+	tmpl    *template.Template
+	client  *http.Client
+}
+
+// New creates a notifier for a webhook that loads its signing key from
+// secrets when the key is kept in the vault
+func New(db *database.DB, secrets SecretStore, hook *models.Webhook) (*Notifier, error) {
+	ApplyDefaults(hook)
+	tmpl, err := parseTemplate(hook)
+	if err != nil {
+		return nil, fmt.Errorf("invalid body template for webhook %s: %w", hook.Name, err)
+	}
+
+	return &Notifier{
+		db:      db,
+		secrets: secrets,
+		hook:    hook,
+		tmpl:    tmpl,
+		client:  &http.Client{},
+	}, nil
+}
+
+// Name returns the channel name of the webhook
+func (n *Notifier) Name() string {
+	return ChannelPrefix + n.hook.Name
+}
+
+// Enabled reports whether the webhook is active
+func (n *Notifier) Enabled(server *models.Server) bool {
+	return n.hook.Enabled
+}
+
+// Accepts reports whether the webhook subscribed to the event type
+func (n *Notifier) Accepts(event *notify.Event) bool {
+	if len(n.hook.Events) == 0 {
+		return true
+	}
+	for _, e := range n.hook.Events {
+		if notify.EventType(e) == event.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// Timeout returns the timeout of a single delivery attempt
+func (n *Notifier) Timeout() time.Duration {
+	return time.Duration(n.hook.Timeout) * time.Second
+}
+
+// RetryPolicy returns the retry policy of the webhook
+func (n *Notifier) RetryPolicy() notify.RetryPolicy {
+	return notify.RetryPolicy{
+		MaxAttempts: n.hook.MaxAttempts,
+		Backoff:     time.Duration(n.hook.RetryBackoff) * time.Second,
+	}
+}
+
+// Send renders the event and posts it to the webhook
+func (n *Notifier) Send(ctx context.Context, event *notify.Event) error {
+	delivery := n.deliver(ctx, newPayload(string(event.Type), event), notify.Attempt(ctx))
+	if !delivery.Success {
+		return errors.New(delivery.Error)
+	}
+	return nil
+}
+
+// Test sends a sample event to the webhook once and returns the recorded attempt
+func (n *Notifier) Test(ctx context.Context) *models.WebhookDelivery {
+	now := time.Now()
+	event := &notify.Event{
+		Server:  &models.Server{Name: "example-server", Hostname: "example.local", IPAddress: "192.0.2.1", Tags: []string{"test"}},
+		Service: &models.Service{Name: "example.service", DisplayName: "Example Service"},
+		Alert: &models.Alert{
+			Status:    models.StatusStopped,
+			State:     models.AlertOpen,
+			Message:   "This is a test event from Vigilon",
+			CreatedAt: now,
+		},
+		CreatedAt: now,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, n.Timeout())
+	defer cancel()
+	return n.deliver(ctx, newPayload(EventTest, event), 1)
+}
+
+// deliver performs one HTTP request and records it in the delivery log
+func (n *Notifier) deliver(ctx context.Context, payload *Payload, attempt int) *models.WebhookDelivery {
+	delivery := &models.WebhookDelivery{
+		WebhookID: n.hook.ID,
+		Event:     payload.Event,
+		Attempt:   attempt,
+	}
+	if payload.Alert != nil {
+		delivery.AlertID = payload.Alert.ID
+	}
+
+	start := time.Now()
+	err := n.post(ctx, payload, delivery)
+	delivery.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		delivery.Error = err.Error()
+	} else {
+		delivery.Success = true
+	}
+
+	if n.db != nil && n.hook.ID > 0 {
+		if err := n.db.CreateWebhookDelivery(delivery); err != nil {
+			log.Printf("Failed to record delivery for webhook %s: %v", n.hook.Name, err)
+		}
+	}
+	return delivery
+}
+
+// post renders the body, signs it and sends the request
+func (n *Notifier) post(ctx context.Context, payload *Payload, delivery *models.WebhookDelivery) error {
+	var body bytes.Buffer
+	if err := n.tmpl.Execute(&body, payload); err != nil {
+		return fmt.Errorf("failed to render body: %w", err)
+	}
+	delivery.RequestBody = body.String()
+
+	req, err := http.NewRequestWithContext(ctx, n.hook.Method, n.hook.URL, bytes.NewReader(body.Bytes()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", n.hook.ContentType)
+	req.Header.Set("User-Agent", "Vigilon-Webhook")
+	req.Header.Set("X-Vigilon-Event", payload.Event)
+	for key, value := range n.hook.Headers {
+		req.Header.Set(key, value)
+	}
+	key, err := n.signingKey()
+	if err != nil {
+		return err
+	}
+	if key != "" {
+		req.Header.Set(n.hook.SignatureHeader, Sign(key, body.Bytes()))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	delivery.StatusCode = resp.StatusCode
+	delivery.ResponseBody = string(respBody)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// signingKey returns the HMAC key of the webhook, read from the vault on
+// every delivery so a rotated secret is used right away
+func (n *Notifier) signingKey() (string, error) {
+	if n.hook.SigningSecret == "" {
+		return n.hook.Secret, nil
+	}
+	if n.secrets == nil {
+		return "", fmt.Errorf("failed to load signing key: secret vault unavailable")
+	}
+	key, err := n.secrets.Secret(n.hook.SigningSecret)
+	if err != nil {
+		return "", fmt.Errorf("failed to load signing key: %w", err)
+	}
+	return string(key), nil
+}
+
+// Sign returns the signature header value of a body: "sha256=" followed by
+// the hex encoded HMAC-SHA256 of the body keyed with the secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sync registers every enabled webhook with the dispatcher and removes
+// webhooks that were deleted or disabled
+func Sync(db *database.DB, secrets SecretStore, dispatcher *notify.Dispatcher) error {
+	hooks, err := db.GetWebhooks()
+	if err != nil {
+		return err
+	}
+
+	active := make(map[string]bool)
+	var errs []string
+	for _, hook := range hooks {
+		if !hook.Enabled {
+			continue
+		}
+		n, err := New(db, secrets, hook)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		dispatcher.Register(n)
+		active[n.Name()] = true
+	}
+
+	for _, name := range dispatcher.Channels() {
+		if strings.HasPrefix(name, ChannelPrefix) && !active[name] {
+			dispatcher.Unregister(name)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// parseTemplate parses the body template of a webhook
+func parseTemplate(hook *models.Webhook) (*template.Template, error) {
+	body := hook.BodyTemplate
+	if body == "" {
+		body = DefaultBodyTemplate
+	}
+	return template.New(hook.Name).Funcs(funcs).Parse(body)
+}
+
+// newPayload builds the template data of an event
+func newPayload(eventType string, event *notify.Event) *Payload {
+	payload := &Payload{
+		Event:     eventType,
+		Message:   event.Message(),
+		Timestamp: event.CreatedAt,
+		Check:     event.Check,
+	}
+	if payload.Timestamp.IsZero() {
+		payload.Timestamp = time.Now()
+	}
+
+	if a := event.Alert; a != nil {
+		payload.Alert = &AlertInfo{
+			ID:              a.ID,
+			Status:          a.Status,
+			State:           a.State,
+			Message:         a.Message,
+			Acknowledged:    a.Acknowledged,
+			CreatedAt:       a.CreatedAt,
+			ResolvedAt:      a.ResolvedAt,
+			DowntimeSeconds: a.DowntimeSecs,
+		}
+	}
+	if s := event.Server; s != nil {
+		payload.Server = &ServerInfo{ID: s.ID, Name: s.Name, Hostname: s.Hostname, IPAddress: s.IPAddress, Tags: s.Tags}
+	}
+	if s := event.Service; s != nil {
+		payload.Service = &ServiceInfo{ID: s.ID, Name: s.Name, DisplayName: s.DisplayName}
+	}
+	return payload
+}
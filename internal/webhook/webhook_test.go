@@ -0,0 +1,306 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harungecit/vigilon/internal/database"
+	"github.com/harungecit/vigilon/internal/models"
+	"github.com/harungecit/vigilon/internal/notify"
+)
+
+// request is what the test server received
+type request struct {
+	method string
+	header http.Header
+	body   string
+}
+
+// serveHooks starts a server that records every request and answers with
+// status and reply
+func serveHooks(t *testing.T, status int, reply string) (string, <-chan request) {
+	t.Helper()
+	requests := make(chan request, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{method: r.Method, header: r.Header.Clone(), body: string(body)}
+		w.WriteHeader(status)
+		io.WriteString(w, reply)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, requests
+}
+
+// alertEvent returns an alert event of nginx going down on web-1
+func alertEvent() *notify.Event {
+	created := time.Date(2026, 3, 14, 9, 26, 53, 0, time.UTC)
+	return &notify.Event{
+		Type: notify.EventAlert,
+		Alert: &models.Alert{
+			ID:        42,
+			Status:    models.StatusStopped,
+			State:     models.AlertOpen,
+			Message:   "Service nginx on web-1 is stopped",
+			CreatedAt: created,
+		},
+		Server: &models.Server{
+			ID:                7,
+			Name:              "web-1",
+			Hostname:          "web-1.internal",
+			IPAddress:         "10.0.0.7",
+			Tags:              []string{"prod"},
+			SSHPasswordSecret: "do-not-leak",
+		},
+		Service:   &models.Service{ID: 3, Name: "nginx.service", DisplayName: "Nginx"},
+		CreatedAt: created,
+	}
+}
+
+func TestSign(t *testing.T) {
+	// Test case 2 of RFC 4231
+	got := Sign("Jefe", []byte("what do ya want for nothing?"))
+	want := "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"
+	if got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+}
+
+func TestSendSignedTemplate(t *testing.T) {
+	url, requests := serveHooks(t, http.StatusNoContent, "")
+	n, err := New(nil, nil, &models.Webhook{
+		Name:         "pager",
+		URL:          url,
+		Method:       "put",
+		Headers:      map[string]string{"Authorization": "Bearer abc"},
+		Secret:       "s3cret",
+		BodyTemplate: `{"summary": "{{upper .Event}} {{.Server.Name}}/{{.Service.Name}}", "text": {{json .Message}}}`,
+		Enabled:      true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := n.Send(context.Background(), alertEvent()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	req := <-requests
+
+	wantBody := `{"summary": "ALERT web-1/nginx.service", "text": "Service nginx on web-1 is stopped"}`
+	if req.body != wantBody {
+		t.Errorf("body = %s, want %s", req.body, wantBody)
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(wantBody))
+	if got, want := req.header.Get("X-Vigilon-Signature"), "sha256="+hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Errorf("signature = %q, want %q", got, want)
+	}
+	if req.method != http.MethodPut {
+		t.Errorf("method = %s, want PUT", req.method)
+	}
+	for header, want := range map[string]string{
+		"Content-Type":    "application/json",
+		"Authorization":   "Bearer abc",
+		"X-Vigilon-Event": "alert",
+	} {
+		if got := req.header.Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+}
+
+// secretMap is a SecretStore backed by a map
+type secretMap map[string]string
+
+func (m secretMap) Secret(name string) ([]byte, error) {
+	value, ok := m[name]
+	if !ok {
+		return nil, fmt.Errorf("secret %q not found", name)
+	}
+	return []byte(value), nil
+}
+
+func TestSendVaultSigningKey(t *testing.T) {
+	url, requests := serveHooks(t, http.StatusOK, "")
+	secrets := secretMap{"hook-key": "Jefe"}
+	n, err := New(nil, secrets, &models.Webhook{Name: "vault", URL: url, SigningSecret: "hook-key", Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := n.Send(context.Background(), alertEvent()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	req := <-requests
+	if got, want := req.header.Get("X-Vigilon-Signature"), Sign("Jefe", []byte(req.body)); got != want {
+		t.Errorf("signature = %q, want %q", got, want)
+	}
+
+	delete(secrets, "hook-key")
+	err = n.Send(context.Background(), alertEvent())
+	checkError(t, err, `failed to load signing key: secret "hook-key" not found`)
+	if len(requests) > 0 {
+		t.Error("sent an unsigned request without its signing key")
+	}
+}
+
+func TestSendDefaultBody(t *testing.T) {
+	url, requests := serveHooks(t, http.StatusOK, "")
+	n, err := New(nil, nil, &models.Webhook{Name: "default", URL: url, SignatureHeader: "X-Hub-Signature-256", Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := n.Send(context.Background(), alertEvent()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	req := <-requests
+
+	if req.header.Get("X-Hub-Signature-256") != "" {
+		t.Errorf("request signed without a secret")
+	}
+	if strings.Contains(req.body, "do-not-leak") {
+		t.Errorf("body contains server credentials: %s", req.body)
+	}
+
+	var payload Payload
+	if err := json.Unmarshal([]byte(req.body), &payload); err != nil {
+		t.Fatalf("body is not JSON: %v\n%s", err, req.body)
+	}
+	if payload.Event != "alert" || payload.Message != "Service nginx on web-1 is stopped" {
+		t.Errorf("event, message = %q, %q", payload.Event, payload.Message)
+	}
+	if payload.Alert == nil || payload.Alert.ID != 42 || payload.Alert.Status != models.StatusStopped {
+		t.Errorf("alert = %+v, want alert 42 stopped", payload.Alert)
+	}
+	if payload.Alert != nil && !payload.Alert.CreatedAt.Equal(time.Date(2026, 3, 14, 9, 26, 53, 0, time.UTC)) {
+		t.Errorf("alert created at = %v, want 2026-03-14 09:26:53", payload.Alert.CreatedAt)
+	}
+	if payload.Server == nil || payload.Server.Name != "web-1" || payload.Server.IPAddress != "10.0.0.7" {
+		t.Errorf("server = %+v, want web-1", payload.Server)
+	}
+	if payload.Service == nil || payload.Service.DisplayName != "Nginx" {
+		t.Errorf("service = %+v, want Nginx", payload.Service)
+	}
+}
+
+func TestSendCreatedAlert(t *testing.T) {
+	db, err := database.New(filepath.Join(t.TempDir(), "vigilon.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	server := &models.Server{Name: "web-1", Hostname: "web-1.internal", OS: "linux", MonitoringMode: models.ModePull, Enabled: true}
+	if err := db.CreateServer(server); err != nil {
+		t.Fatal(err)
+	}
+	nginx := &models.Service{ServerID: server.ID, Name: "nginx.service", DisplayName: "Nginx", Enabled: true}
+	if err := db.CreateService(nginx); err != nil {
+		t.Fatal(err)
+	}
+	alert := &models.Alert{ServiceID: nginx.ID, ServerID: server.ID, Status: models.StatusStopped, Message: "nginx stopped"}
+	if err := db.CreateAlert(alert); err != nil {
+		t.Fatal(err)
+	}
+
+	url, requests := serveHooks(t, http.StatusOK, "")
+	n, err := New(db, nil, &models.Webhook{Name: "created", URL: url, Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := &notify.Event{Type: notify.EventAlert, Alert: alert, Server: server, Service: nginx, CreatedAt: time.Now()}
+	if err := n.Send(context.Background(), event); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	var payload Payload
+	if err := json.Unmarshal([]byte((<-requests).body), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Alert == nil || payload.Alert.CreatedAt.IsZero() {
+		t.Fatalf("alert = %+v, want its creation time", payload.Alert)
+	}
+	if !payload.Alert.CreatedAt.Equal(alert.CreatedAt) || time.Since(payload.Alert.CreatedAt) > time.Minute {
+		t.Errorf("alert created at = %v, want %v", payload.Alert.CreatedAt, alert.CreatedAt)
+	}
+}
+
+func TestSendFailure(t *testing.T) {
+	url, _ := serveHooks(t, http.StatusServiceUnavailable, "try again later")
+	n, err := New(nil, nil, &models.Webhook{Name: "down", URL: url, Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = n.Send(context.Background(), alertEvent())
+	checkError(t, err, "unexpected status 503")
+
+	delivery := n.Test(context.Background())
+	if delivery.Success || delivery.StatusCode != http.StatusServiceUnavailable || delivery.ResponseBody != "try again later" {
+		t.Errorf("delivery = %+v, want a failed 503 with the response body", delivery)
+	}
+	if delivery.Event != EventTest || !strings.Contains(delivery.RequestBody, "This is a test event") {
+		t.Errorf("delivery = %+v, want the test event", delivery)
+	}
+}
+
+func TestAccepts(t *testing.T) {
+	n, err := New(nil, nil, &models.Webhook{Name: "recoveries", URL: "https://example.com", Events: []string{"recovery"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Accepts(&notify.Event{Type: notify.EventAlert}) {
+		t.Error("accepts alert events, want recoveries only")
+	}
+	if !n.Accepts(&notify.Event{Type: notify.EventRecovery}) {
+		t.Error("rejects recovery events")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		hook    models.Webhook
+		wantErr string
+	}{
+		{"valid", models.Webhook{Name: "ok", URL: "https://example.com/hook", Events: []string{"alert", "escalation"}}, ""},
+		{"no name", models.Webhook{URL: "https://example.com/hook"}, "name is required"},
+		{"relative url", models.Webhook{Name: "x", URL: "/hook"}, "absolute http or https URL"},
+		{"bad scheme", models.Webhook{Name: "x", URL: "ftp://example.com"}, "absolute http or https URL"},
+		{"bad method", models.Webhook{Name: "x", URL: "https://example.com", Method: "GET"}, "method must be POST, PUT or PATCH"},
+		{"unknown event", models.Webhook{Name: "x", URL: "https://example.com", Events: []string{"deploy"}}, `unknown event "deploy"`},
+		{"bad template", models.Webhook{Name: "x", URL: "https://example.com", BodyTemplate: "{{.Alert"}, "invalid body_template"},
+		{"long timeout", models.Webhook{Name: "x", URL: "https://example.com", Timeout: 301}, "timeout must not exceed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ApplyDefaults(&tt.hook)
+			checkError(t, Validate(&tt.hook), tt.wantErr)
+		})
+	}
+}
+
+// checkError fails the test unless err contains want, or is nil when want
+// is empty
+func checkError(t *testing.T, err error, want string) {
+	t.Helper()
+	switch {
+	case want == "" && err != nil:
+		t.Errorf("unexpected error: %v", err)
+	case want != "" && err == nil:
+		t.Errorf("error = nil, want %q", want)
+	case want != "" && !strings.Contains(err.Error(), want):
+		t.Errorf("error = %v, want %q", err, want)
+	}
+}
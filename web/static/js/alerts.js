@@ -140,6 +140,9 @@ function createAlertCard(alert) {
         <div class="alert-header">
             <span class="alert-id">#${alert.id}</span>
             <span class="alert-status status-${statusClass}">${alert.status}</span>
+            ${alert.type === 'host_key' ? '<span class="host-key-badge">Host key</span>' : ''}
+            ${alert.type === 'host_down' ? '<span class="host-down-badge">Host down</span>' : ''}
+            ${alert.state === 'resolved' ? `<span class="resolved-badge">Resolved after ${formatDuration(alert.downtime_seconds || 0)}</span>` : ''}
             <span class="alert-time">${formattedDate}</span>
         </div>
         <div class="alert-body">
@@ -148,7 +151,7 @@ function createAlertCard(alert) {
         <div class="alert-footer">
             <span class="alert-via">Sent via: ${alert.sent_via}</span>
             ${alert.acknowledged
-                ? `<span class="acknowledged-badge">Acknowledged ${new Date(alert.acknowledged_at).toLocaleString()}</span>`
+                ? `<span class="acknowledged-badge">Acknowledged${alert.acknowledged_by ? ' by ' + escapeHtml(alert.acknowledged_by) : ''} ${new Date(alert.acknowledged_at).toLocaleString()}</span>`
                 : `<button class="btn btn-sm" onclick="acknowledgeAlert(${alert.id})">Acknowledge</button>`
             }
         </div>
@@ -168,6 +171,15 @@ function getStatusClass(status) {
     return statusMap[status] || 'unknown';
 }
 
+function formatDuration(seconds) {
+    const h = Math.floor(seconds / 3600);
+    const m = Math.floor((seconds % 3600) / 60);
+    const s = seconds % 60;
+    if (h > 0) return `${h}h${m}m${s}s`;
+    if (m > 0) return `${m}m${s}s`;
+    return `${s}s`;
+}
+
 function escapeHtml(text) {
     const div = document.createElement('div');
     div.textContent = text;
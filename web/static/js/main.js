@@ -75,6 +75,11 @@ async function apiFetch(url, options = {}) {
     }
 }
 
+// Split a comma separated tag list
+function parseTags(value) {
+    return (value || '').split(',').map(t => t.trim()).filter(t => t !== '');
+}
+
 function closeModal() {
     const modals = document.querySelectorAll('.modal');
     modals.forEach(modal => {
@@ -2,6 +2,99 @@ function showAddServiceModal() {
     document.getElementById('addServiceModal').style.display = 'block';
 }
 
+function updateServiceTypeFields() {
+    const type = document.getElementById('serviceType').value;
+    document.getElementById('httpFields').classList.toggle('hidden', type !== 'http');
+    document.getElementById('httpURL').required = type === 'http';
+    document.getElementById('tlsFields').classList.toggle('hidden', type !== 'tls');
+    document.getElementById('tlsHost').required = type === 'tls';
+    document.getElementById('tcpFields').classList.toggle('hidden', type !== 'tcp');
+    document.getElementById('tcpHost').required = type === 'tcp';
+    document.getElementById('tcpPort').required = type === 'tcp';
+    document.getElementById('pingFields').classList.toggle('hidden', type !== 'ping');
+    document.getElementById('pingHost').required = type === 'ping';
+    document.getElementById('reachabilityField').classList.toggle('hidden', type !== 'tcp' && type !== 'ping');
+
+    // Only push servers have an agent to run endpoint checks
+    const checkFrom = document.getElementById('checkFromField');
+    if (checkFrom) {
+        checkFrom.classList.toggle('hidden', type === '');
+    }
+}
+
+// httpCheckFromForm collects the settings of an http check
+function httpCheckFromForm(formData) {
+    const headers = {};
+    (formData.get('http_headers') || '').split('\n').forEach(line => {
+        const colon = line.indexOf(':');
+        if (colon > 0) {
+            headers[line.slice(0, colon).trim()] = line.slice(colon + 1).trim();
+        }
+    });
+
+    const expectedStatus = (formData.get('http_expected_status') || '')
+        .split(',')
+        .map(code => parseInt(code.trim()))
+        .filter(code => !isNaN(code));
+
+    return {
+        url: formData.get('http_url'),
+        method: formData.get('http_method'),
+        headers: headers,
+        body: formData.get('http_body') || '',
+        expected_status: expectedStatus,
+        body_contains: formData.get('http_body_contains') || '',
+        body_regex: formData.get('http_body_regex') || '',
+        json_path: formData.get('http_json_path') || '',
+        json_value: formData.get('http_json_value') || '',
+        redirects: formData.get('http_redirects'),
+        timeout: parseInt(formData.get('http_timeout')) || 0,
+        degraded_after_ms: parseInt(formData.get('http_degraded_after_ms')) || 0,
+        insecure: formData.get('http_insecure') === 'on'
+    };
+}
+
+// tcpCheckFromForm collects the settings of a tcp check
+function tcpCheckFromForm(formData) {
+    // Line breaks cannot be typed in the field, so \r and \n stand for them
+    const unescape = value => (value || '').replace(/\\r/g, '\r').replace(/\\n/g, '\n');
+    return {
+        host: formData.get('tcp_host'),
+        port: parseInt(formData.get('tcp_port')) || 0,
+        send: unescape(formData.get('tcp_send')),
+        expect: unescape(formData.get('tcp_expect')),
+        degraded_after_ms: parseInt(formData.get('tcp_degraded_after_ms')) || 0
+    };
+}
+
+// pingCheckFromForm collects the settings of a ping check
+function pingCheckFromForm(formData) {
+    return {
+        host: formData.get('ping_host'),
+        count: parseInt(formData.get('ping_count')) || 0,
+        max_loss: parseInt(formData.get('ping_max_loss')) || 0,
+        degraded_after_ms: parseInt(formData.get('ping_degraded_after_ms')) || 0
+    };
+}
+
+// tlsCheckFromForm collects the settings of a tls check
+function tlsCheckFromForm(formData) {
+    const thresholds = [
+        { days: parseInt(formData.get('tls_info_days')), severity: 'info' },
+        { days: parseInt(formData.get('tls_warning_days')), severity: 'warning' },
+        { days: parseInt(formData.get('tls_critical_days')), severity: 'critical' }
+    ].filter(threshold => !isNaN(threshold.days));
+
+    return {
+        host: formData.get('tls_host'),
+        port: parseInt(formData.get('tls_port')) || 0,
+        server_name: formData.get('tls_server_name') || '',
+        starttls: formData.get('tls_starttls') || '',
+        ca_file: formData.get('tls_ca_file') || '',
+        expiry_thresholds: thresholds
+    };
+}
+
 function toggleEdit() {
     const infoTable = document.getElementById('serverInfo');
     const editForm = document.getElementById('editServerForm');
@@ -33,8 +126,10 @@ function toggleAgentScript() {
 
 async function loadAgentScript() {
     try {
-        // Use the new one-line installer
-        const installCommand = `curl -fsSL ${window.location.origin}/install.sh?token=${serverData.token} | sudo bash`;
+        // Use the new one-line installer. The token is only known right after
+        // the server was added, as the panel stores it hashed.
+        const token = sessionStorage.getItem('agentToken_' + serverData.id) || 'YOUR_TOKEN';
+        const installCommand = `curl -fsSL ${window.location.origin}/install.sh?token=${token} | sudo bash`;
         document.getElementById('agentInstallScript').textContent = installCommand;
     } catch (error) {
         document.getElementById('agentInstallScript').textContent = 'Error: ' + error.message;
@@ -204,6 +299,7 @@ document.getElementById('editServerForm').addEventListener('submit', async (e) =
         server.port = parseInt(formData.get('port'));
         server.check_interval = parseInt(formData.get('check_interval'));
         server.notify_telegram = formData.get('notify_telegram') === 'on';
+        server.tags = parseTags(formData.get('tags'));
 
         // Update server
         const response = await fetch(`/api/servers/${serverData.id}`, {
@@ -235,8 +331,28 @@ document.getElementById('addServiceForm').addEventListener('submit', async (e) =
         name: formData.get('name'),
         display_name: formData.get('display_name'),
         description: formData.get('description') || '',
-        enabled: formData.get('enabled') === 'on'
+        enabled: formData.get('enabled') === 'on',
+        failure_threshold: parseInt(formData.get('failure_threshold')) || 1,
+        recovery_threshold: parseInt(formData.get('recovery_threshold')) || 1,
+        flap_threshold: parseInt(formData.get('flap_threshold')) || 0,
+        flap_window: parseInt(formData.get('flap_window')) || 600,
+        type: formData.get('type') || ''
     };
+    if (data.type === 'http') {
+        data.http = httpCheckFromForm(formData);
+    } else if (data.type === 'tls') {
+        data.tls = tlsCheckFromForm(formData);
+    } else if (data.type === 'tcp') {
+        data.tcp = tcpCheckFromForm(formData);
+    } else if (data.type === 'ping') {
+        data.ping = pingCheckFromForm(formData);
+    }
+    if (data.type === 'tcp' || data.type === 'ping') {
+        data.reachability = formData.get('reachability') === 'on';
+    }
+    if (data.type !== '') {
+        data.check_from = formData.get('check_from') || '';
+    }
 
     try {
         const response = await fetch('/api/services', {
@@ -286,6 +402,117 @@ async function deleteService(serviceId) {
     }
 }
 
+async function loadHostKeys() {
+    const container = document.getElementById('hostKeys');
+    if (!container) return;
+
+    try {
+        const response = await fetch(`/api/servers/${serverData.id}/host-keys`);
+        if (!response.ok) {
+            container.innerHTML = '<p class="error">Failed to load host keys</p>';
+            return;
+        }
+        renderHostKeys(await response.json());
+    } catch (error) {
+        container.innerHTML = '<p class="error">Failed to load host keys: ' + escapeHtml(error.message) + '</p>';
+    }
+}
+
+function renderHostKeys(hops) {
+    let html = '<table class="table"><thead><tr><th>Host</th><th>Address</th><th>Trusted Key</th><th>Actions</th></tr></thead><tbody>';
+
+    hops.forEach(hop => {
+        const key = hop.key;
+        let trusted = '<span class="badge badge-secondary">Not connected yet</span>';
+        let actions = '';
+        if (key) {
+            const trustedAt = new Date(key.trusted_at).toLocaleString();
+            trusted = `<code>${escapeHtml(key.key_type)} ${escapeHtml(key.fingerprint)}</code>
+                <br><small>Trusted by ${escapeHtml(key.trusted_by)} on ${trustedAt}</small>`;
+            if (key.pending_fingerprint) {
+                const seenAt = new Date(key.pending_seen_at).toLocaleString();
+                trusted += `<br><span class="badge badge-danger">Key changed</span>
+                    <code>${escapeHtml(key.pending_key_type)} ${escapeHtml(key.pending_fingerprint)}</code>
+                    <br><small>Presented on ${seenAt}</small>`;
+                actions += `<button class="btn btn-sm btn-danger" onclick="trustHostKey(${key.id}, '${escapeHtml(key.address)}', '${escapeHtml(key.pending_fingerprint)}')">Trust New Key</button> `;
+            }
+            actions += `<button class="btn btn-sm" onclick="forgetHostKey(${key.id}, '${escapeHtml(key.address)}')">Forget</button>`;
+        }
+        html += `<tr>
+            <td>${escapeHtml(hop.role)}</td>
+            <td><code>${escapeHtml(hop.address)}</code></td>
+            <td>${trusted}</td>
+            <td>${actions}</td>
+        </tr>`;
+    });
+
+    html += '</tbody></table>';
+    document.getElementById('hostKeys').innerHTML = html;
+}
+
+async function trustHostKey(id, address, fingerprint) {
+    const confirmed = await Confirm.show({
+        title: 'Trust New Host Key',
+        message: `${address} presented a different host key (${fingerprint}). Only trust it if you know why the key changed, e.g. the host was reinstalled.`,
+        confirmText: 'Trust Key',
+        type: 'danger'
+    });
+
+    if (!confirmed) return;
+
+    try {
+        const response = await fetch(`/api/host-keys/${id}/trust`, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ fingerprint: fingerprint })
+        });
+
+        if (response.ok) {
+            Toast.success('Host key trusted');
+            loadHostKeys();
+        } else {
+            const error = await response.json();
+            Toast.error(error.error || 'Unknown error', 'Failed to trust host key');
+            loadHostKeys();
+        }
+    } catch (error) {
+        Toast.error(error.message, 'Failed to trust host key');
+    }
+}
+
+async function forgetHostKey(id, address) {
+    const confirmed = await Confirm.show({
+        title: 'Forget Host Key',
+        message: `Forget the host key of ${address}? The next connection will trust whatever key the host presents.`,
+        confirmText: 'Forget',
+        type: 'warning'
+    });
+
+    if (!confirmed) return;
+
+    try {
+        const response = await fetch(`/api/host-keys/${id}`, {
+            method: 'DELETE'
+        });
+
+        if (response.ok) {
+            Toast.success('Host key removed');
+            loadHostKeys();
+        } else {
+            const error = await response.json();
+            Toast.error(error.error || 'Unknown error', 'Failed to forget host key');
+        }
+    } catch (error) {
+        Toast.error(error.message, 'Failed to forget host key');
+    }
+}
+
+function escapeHtml(text) {
+    const div = document.createElement('div');
+    div.textContent = text;
+    return div.innerHTML;
+}
+
 async function logout() {
     try {
         const response = await fetch('/api/auth/logout', {
@@ -351,15 +578,20 @@ function renderServiceHistory(checks) {
         return;
     }
     
-    let html = '<table class="table"><thead><tr><th>Date & Time</th><th>Status</th><th>PID</th><th>CPU</th><th>Memory</th><th>Error</th></tr></thead><tbody>';
+    let html = '<table class="table"><thead><tr><th>Date & Time</th><th>Status</th><th>Response</th><th>PID</th><th>CPU</th><th>Memory</th><th>Error</th></tr></thead><tbody>';
     
     checks.forEach(check => {
         const date = new Date(check.checked_at).toLocaleString();
         const statusClass = check.status === 'running' ? 'badge-success' : 
                           check.status === 'stopped' ? 'badge-danger' : 'badge-warning';
+        const expiry = check.days_to_expiry !== undefined ? `<br><small>Certificate: ${check.days_to_expiry} days left</small>` : '';
+        const loss = check.packet_loss_percent !== undefined ? `<br><small>${check.packet_loss_percent.toFixed(0)}% loss</small>` : '';
+        const response = check.latency_ms !== undefined ? check.latency_ms.toFixed(1) + ' ms' :
+                         check.response_time_ms ? check.response_time_ms + ' ms' : '-';
         html += `<tr>
             <td>${date}</td>
-            <td><span class="badge ${statusClass}">${check.status}</span></td>
+            <td><span class="badge ${statusClass}">${check.status}</span>${expiry}</td>
+            <td>${response}${loss}</td>
             <td>${check.pid || '-'}</td>
             <td>${check.cpu ? check.cpu.toFixed(1) + '%' : '-'}</td>
             <td>${check.memory ? check.memory.toFixed(1) + ' MB' : '-'}</td>
@@ -396,4 +628,6 @@ document.addEventListener('DOMContentLoaded', function() {
             loadAgentScript();
         }
     }
+
+    loadHostKeys();
 });
@@ -32,17 +32,150 @@ function updateFormFields() {
     }
 }
 
+function updateTransportFields() {
+    const winrm = document.getElementById('transport').value === 'winrm';
+    document.getElementById('sshFields').style.display = winrm ? 'none' : 'block';
+    document.getElementById('winrmFields').style.display = winrm ? 'block' : 'none';
+
+    // Move the SSH default port to the WinRM one and back
+    const form = document.getElementById('addServerForm');
+    const port = form.elements['port'];
+    const winrmPort = form.elements['winrm_https'].checked ? '5986' : '5985';
+    if (winrm && ['22', '5985', '5986'].includes(port.value)) {
+        port.value = winrmPort;
+    } else if (!winrm && ['5985', '5986'].includes(port.value)) {
+        port.value = '22';
+    }
+}
+
+// Returns the WinRM settings of the form, or null for SSH
+function getWinRMConfig(formData) {
+    if (formData.get('transport') !== 'winrm') {
+        return null;
+    }
+    return {
+        user: (formData.get('winrm_user') || '').trim(),
+        password_secret: (formData.get('winrm_password_secret') || '').trim(),
+        auth: formData.get('winrm_auth'),
+        https: formData.get('winrm_https') === 'on',
+        insecure: formData.get('winrm_insecure') === 'on'
+    };
+}
+
 function toggleJumpHost() {
     const useJumpHost = document.getElementById('useJumpHost').checked;
     const jumpHostFields = document.getElementById('jumpHostFields');
 
     if (useJumpHost) {
         jumpHostFields.classList.remove('hidden');
+        if (!document.querySelector('#jumpHops .jump-hop')) {
+            addJumpHop();
+        }
     } else {
         jumpHostFields.classList.add('hidden');
     }
 }
 
+function addJumpHop() {
+    const hops = document.getElementById('jumpHops');
+    const hop = document.createElement('div');
+    hop.className = 'jump-hop';
+    hop.style.cssText = 'border-bottom: 1px solid #e0e0e0; margin-bottom: 0.75rem;';
+    hop.innerHTML = `
+        <h5 style="margin-bottom: 0.5rem;">Hop <span class="jump-hop-number"></span>
+            <button type="button" class="btn btn-sm btn-danger" onclick="removeJumpHop(this)" style="float: right;">Remove</button>
+        </h5>
+        <div class="form-group">
+            <label>Jump Host:</label>
+            <input type="text" class="jump-host" placeholder="jump.example.com or 192.168.1.1">
+        </div>
+        <div class="form-group">
+            <label>Port:</label>
+            <input type="number" class="jump-port" placeholder="22" min="1" max="65535">
+        </div>
+        <div class="form-group">
+            <label>User:</label>
+            <input type="text" class="jump-user" placeholder="admin">
+        </div>
+        <div class="form-group">
+            <label>Authentication:</label>
+            <select class="ssh-auth-method" onchange="updateAuthFields(this)">
+                <option value="">Key file or stored key (default)</option>
+                <option value="key_file">Key file</option>
+                <option value="stored_key">Stored key</option>
+                <option value="password">Password</option>
+                <option value="agent">SSH agent</option>
+            </select>
+        </div>
+        <div class="form-group" data-auth="key_file">
+            <label>SSH Key:</label>
+            <input type="text" class="jump-key-path" placeholder="/root/.ssh/jump_key">
+            <small style="color: #7f8c8d;">Path to private key for this hop</small>
+        </div>
+        <div class="form-group" data-auth="stored_key">
+            <label>SSH Key Secret:</label>
+            <input type="text" class="jump-key-secret" placeholder="bastion-key">
+            <small style="color: #7f8c8d;">Private key stored in the secret vault, instead of a key path</small>
+        </div>
+        <div class="form-group" data-auth="key_file stored_key">
+            <label>Key Passphrase Secret:</label>
+            <input type="text" class="jump-passphrase-secret" placeholder="bastion-passphrase">
+        </div>
+        <div class="form-group hidden" data-auth="password">
+            <label>Password Secret:</label>
+            <input type="text" class="jump-password-secret" placeholder="bastion-password">
+        </div>
+        <div class="form-group hidden" data-auth="agent">
+            <label>Agent Socket:</label>
+            <input type="text" class="jump-agent-socket" placeholder="$SSH_AUTH_SOCK">
+        </div>`;
+    hops.appendChild(hop);
+    numberJumpHops();
+}
+
+function removeJumpHop(button) {
+    button.closest('.jump-hop').remove();
+    numberJumpHops();
+}
+
+function numberJumpHops() {
+    document.querySelectorAll('#jumpHops .jump-hop-number').forEach((el, i) => {
+        el.textContent = i + 1;
+    });
+}
+
+// Shows the credential fields of the selected SSH auth method. The default
+// method uses a stored key if one is set and the key file otherwise.
+function updateAuthFields(select) {
+    const container = select.closest('.jump-hop') || select.closest('.ssh-auth');
+    const method = select.value;
+    container.querySelectorAll('[data-auth]').forEach(field => {
+        const methods = field.dataset.auth.split(' ');
+        const visible = method ? methods.includes(method) : methods.some(m => m === 'key_file' || m === 'stored_key');
+        field.classList.toggle('hidden', !visible);
+    });
+}
+
+// Collects the jump chain in connection order, skipping hops without a host
+function getJumpHops() {
+    if (!document.getElementById('useJumpHost').checked) {
+        return [];
+    }
+    return Array.from(document.querySelectorAll('#jumpHops .jump-hop'))
+        .map(hop => ({
+            host: hop.querySelector('.jump-host').value.trim(),
+            port: parseInt(hop.querySelector('.jump-port').value) || 0,
+            user: hop.querySelector('.jump-user').value.trim(),
+            key_path: hop.querySelector('.jump-key-path').value.trim(),
+            key_secret: hop.querySelector('.jump-key-secret').value.trim(),
+            auth_method: hop.querySelector('.ssh-auth-method').value,
+            password_secret: hop.querySelector('.jump-password-secret').value.trim(),
+            passphrase_secret: hop.querySelector('.jump-passphrase-secret').value.trim(),
+            agent_socket: hop.querySelector('.jump-agent-socket').value.trim()
+        }))
+        .filter(hop => hop.host);
+}
+
 function generateToken() {
     // Generate a random token
     const token = Array.from(crypto.getRandomValues(new Uint8Array(32)))
@@ -75,13 +208,19 @@ document.getElementById('addServerForm').addEventListener('submit', async (e) =>
         monitoring_mode: mode,
         ssh_user: formData.get('ssh_user') || '',
         ssh_key_path: formData.get('ssh_key_path') || '',
-        ssh_jump_host: formData.get('ssh_jump_host') || '',
-        ssh_jump_user: formData.get('ssh_jump_user') || '',
-        ssh_jump_key_path: formData.get('ssh_jump_key_path') || '',
+        ssh_key_secret: formData.get('ssh_key_secret') || '',
+        ssh_auth_method: formData.get('ssh_auth_method') || '',
+        ssh_password_secret: formData.get('ssh_password_secret') || '',
+        ssh_passphrase_secret: formData.get('ssh_passphrase_secret') || '',
+        ssh_agent_socket: formData.get('ssh_agent_socket') || '',
+        ssh_jump_hosts: getJumpHops(),
+        transport: formData.get('transport') || '',
+        winrm: getWinRMConfig(formData),
         agent_token: token || '',
         check_interval: parseInt(formData.get('check_interval')) || 0,
         enabled: formData.get('enabled') === 'on',
-        notify_telegram: formData.get('notify_telegram') === 'on'
+        notify_telegram: formData.get('notify_telegram') === 'on',
+        tags: parseTags(formData.get('tags'))
     };
 
     try {
@@ -99,6 +238,8 @@ document.getElementById('addServerForm').addEventListener('submit', async (e) =>
         closeModal();
 
         if (mode === 'push') {
+            // The token is stored hashed; keep it for the install command
+            sessionStorage.setItem('agentToken_' + server.id, token);
             showToast('Server added successfully! Redirecting...', 'success');
             setTimeout(() => window.location.href = `/server/${server.id}`, 1000);
         } else {